@@ -0,0 +1,139 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync/atomic"
+
+	"golang.org/x/net/html"
+)
+
+// anchorLink is a link to a #fragment, checked in broken-link mode when
+// Config.CheckFragments is set
+type anchorLink struct {
+	page     string // linked page, without the fragment
+	fragment string
+	external bool
+	missing  bool // the page has no element with this id
+	status   int  // the linked page's status
+
+	referrerSet
+}
+
+// pageAnchors is the ids and <a name>s on a page
+type pageAnchors struct {
+	status int
+	names  map[string]bool // nil if the page couldn't be read as HTML
+}
+
+// anchorName returns the element id a fragment points to, or "" for one
+// that needs no element: "#" and "#top" go to the top of the page, "#!" and
+// "#/" are routes in single-page apps, and text fragments ("#:~:text=")
+// highlight text rather than an element.
+func anchorName(fragment string) string {
+	fragment, _, _ = strings.Cut(fragment, ":~:")
+	if fragment == "" || strings.EqualFold(fragment, "top") || strings.HasPrefix(fragment, "!") || strings.HasPrefix(fragment, "/") {
+		return ""
+	}
+	return fragment
+}
+
+// anchorKey is the key a page's anchors are kept under
+func (c *Crawler) anchorKey(link string) string {
+	if u, err := url.Parse(link); err == nil {
+		u.Fragment, u.RawFragment = "", ""
+		link = u.String()
+	}
+	return c.getVisitedKey(link)
+}
+
+// collectAnchors adds an element's id, or an <a>'s name, to names
+func collectAnchors(n *html.Node, names map[string]bool) {
+	if id := attrValue(n, "id"); id != "" {
+		names[id] = true
+	}
+	if n.Data == "a" {
+		if name := attrValue(n, "name"); name != "" {
+			names[name] = true
+		}
+	}
+}
+
+// noteAnchorLink records a link from pageURL to the fragment on page
+func (c *Crawler) noteAnchorLink(page, fragment, pageURL string, external bool) {
+	name := anchorName(fragment)
+	if name == "" {
+		return
+	}
+	value, _ := c.anchorLinks.LoadOrStore(page+"#"+name, &anchorLink{page: page, fragment: name, external: external})
+	value.(*anchorLink).addReferrer(pageURL)
+}
+
+// checkAnchors looks up every #fragment link in the anchors of the page it
+// points to. Pages the crawl didn't fetch (outside its scope or limits, or
+// on other sites) are fetched once here; links to pages already reported
+// broken, or that aren't HTML, aren't checked.
+func (c *Crawler) checkAnchors(ctx context.Context) {
+	byPage := make(map[string][]*anchorLink)
+	c.anchorLinks.Range(func(_, value any) bool {
+		link := value.(*anchorLink)
+		if result, ok := c.linkResults.Load(link.page); ok && result.(*linkResult).broken() {
+			return true
+		}
+		byPage[link.page] = append(byPage[link.page], link)
+		return true
+	})
+	pages := make([]string, 0, len(byPage))
+	for page := range byPage {
+		pages = append(pages, page)
+	}
+
+	c.forEachLinked(ctx, pages, func(page string) {
+		anchors := c.anchorsOf(ctx, page)
+		if anchors.names == nil {
+			return
+		}
+		for _, link := range byPage[page] {
+			if anchors.names[link.fragment] {
+				continue
+			}
+			link.missing, link.status = true, anchors.status
+			atomic.AddInt64(&c.stats.BrokenAnchors, 1)
+			target := page + "#" + link.fragment
+			c.log.Info(fmt.Sprintf("⚓ BROKEN ANCHOR: %s", target), "url", target)
+			c.noteBreakage("broken_anchor", target, fmt.Sprintf("no element with id %q, linked from %s", link.fragment, strings.Join(link.referrers(), ", ")))
+		}
+	})
+}
+
+// anchorsOf returns a page's anchors, as recorded when the crawl checked its
+// links or, for a page it didn't, by fetching it
+func (c *Crawler) anchorsOf(ctx context.Context, page string) *pageAnchors {
+	if value, ok := c.pageAnchors.Load(c.anchorKey(page)); ok {
+		return value.(*pageAnchors)
+	}
+	status, _, body := c.fetchLinked(ctx, page, "text/html")
+	if body == nil {
+		return &pageAnchors{}
+	}
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return &pageAnchors{}
+	}
+
+	anchors := &pageAnchors{status: status, names: make(map[string]bool)}
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			collectAnchors(n, anchors.names)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(doc)
+	return anchors
+}
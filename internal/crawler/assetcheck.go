@@ -0,0 +1,113 @@
+package crawler
+
+import (
+	"context"
+	"net/url"
+	"path"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// What a URL checked in broken-link mode was linked as, for its Type column
+const (
+	resourcePage       = "page"
+	resourceAnchor     = "anchor" // a #fragment on a page (Config.CheckFragments)
+	resourceScript     = "script"
+	resourceStylesheet = "stylesheet"
+	resourceFont       = "font"
+	resourceMedia      = "media" // video, audio and captions
+	resourceImage      = "image" // video posters and icons
+	resourceLink       = "link"  // other <link href>s: manifests, alternates, canonicals
+)
+
+// resourceRef is a URL a page loads or links to, with what it was linked as
+type resourceRef struct {
+	href string
+	kind string
+}
+
+// skippedLinkRels are the <link rel>s whose href isn't a file: connection
+// hints name an origin
+var skippedLinkRels = []string{"preconnect", "dns-prefetch"}
+
+// assetRefs returns the scripts, stylesheets, fonts and media an element
+// loads. <a> links are checked separately; <img> and srcsets are left to the
+// oversized-images mode.
+func assetRefs(n *html.Node) []resourceRef {
+	var refs []resourceRef
+	add := func(attr, kind string) {
+		if v := strings.TrimSpace(attrValue(n, attr)); v != "" {
+			refs = append(refs, resourceRef{v, kind})
+		}
+	}
+	switch n.Data {
+	case "script":
+		add("src", resourceScript)
+	case "link":
+		if kind := linkResourceKind(n); kind != "" {
+			add("href", kind)
+		}
+	case "video":
+		add("src", resourceMedia)
+		add("poster", resourceImage)
+	case "audio", "track":
+		add("src", resourceMedia)
+	case "source":
+		// <source> in a <picture> is an image with a srcset
+		if n.Parent != nil && (n.Parent.Data == "video" || n.Parent.Data == "audio") {
+			add("src", resourceMedia)
+		}
+	}
+	return refs
+}
+
+// linkResourceKind returns what a <link> loads, or "" if its href isn't
+// worth checking
+func linkResourceKind(n *html.Node) string {
+	rel := strings.ToLower(attrValue(n, "rel"))
+	for _, skip := range skippedLinkRels {
+		if containsField(rel, skip) {
+			return ""
+		}
+	}
+	as := strings.ToLower(strings.TrimSpace(attrValue(n, "as")))
+	switch {
+	case containsField(rel, "stylesheet"), containsField(rel, "preload") && as == "style":
+		return resourceStylesheet
+	case containsField(rel, "modulepreload"), containsField(rel, "preload") && as == "script":
+		return resourceScript
+	case containsField(rel, "preload") && as == "font":
+		return resourceFont
+	case containsField(rel, "icon"), containsField(rel, "apple-touch-icon"):
+		return resourceImage
+	}
+	return resourceLink
+}
+
+// fontExtensions are the file types fonts are served as
+var fontExtensions = map[string]bool{".woff": true, ".woff2": true, ".ttf": true, ".otf": true, ".eot": true}
+
+// isFontURL reports whether a url() in CSS is a font file
+func isFontURL(ref string) bool {
+	u, err := url.Parse(ref)
+	return err == nil && fontExtensions[strings.ToLower(path.Ext(u.Path))]
+}
+
+// checkCSSFonts checks the fonts a stylesheet or <style> block loads, found
+// on pageURL (the stylesheet itself, for an external one)
+func (c *Crawler) checkCSSFonts(ctx context.Context, css, pageURL string) {
+	for _, ref := range cssRefs(css, "") {
+		if isFontURL(ref) {
+			c.checkLink(ctx, ref, pageURL, resourceFont)
+		}
+	}
+}
+
+// checkStylesheetFonts downloads a stylesheet and checks the fonts its
+// @font-face rules load
+func (c *Crawler) checkStylesheetFonts(ctx context.Context, cssURL string) {
+	if _, _, body := c.fetchLinked(ctx, cssURL, "text/css"); body != nil {
+		c.checkCSSFonts(ctx, string(body), cssURL)
+	}
+}
@@ -0,0 +1,232 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// AuthMode selects how the crawler logs in to the site
+type AuthMode int
+
+const (
+	// AuthNone crawls as an anonymous visitor
+	AuthNone AuthMode = iota
+	// AuthBasic sends HTTP Basic credentials with every in-scope request
+	AuthBasic
+	// AuthForm submits a login form once before crawling; the session
+	// cookies it sets are kept in the crawler's cookie jar
+	AuthForm
+)
+
+func (m AuthMode) String() string {
+	switch m {
+	case AuthNone:
+		return "None"
+	case AuthBasic:
+		return "HTTP Basic"
+	case AuthForm:
+		return "Login form"
+	default:
+		return "Unknown"
+	}
+}
+
+type AuthOptions struct {
+	Mode          AuthMode
+	Username      string
+	Password      string
+	LoginURL      string            // Form login: page with the login form, or the URL it posts to
+	UsernameField string            // Form field for the username (default: detected from the form)
+	PasswordField string            // Form field for the password (default: the form's password input)
+	ExtraFields   map[string]string // Additional form fields to submit (e.g. "remember": "1")
+}
+
+// loginForm is a login form found on the login page
+type loginForm struct {
+	action        *url.URL
+	fields        url.Values
+	usernameField string
+	passwordField string
+}
+
+// login performs the configured login step. It returns false if the crawl
+// should not go ahead because the site couldn't be logged in to.
+func (c *Crawler) login(ctx context.Context) bool {
+	auth := c.config.Auth
+	if auth.Mode != AuthForm {
+		return true
+	}
+
+	loginURL, err := url.Parse(auth.LoginURL)
+	if err != nil || loginURL.Host == "" {
+		c.log.Error(fmt.Sprintf("❌ Invalid login URL: %s", auth.LoginURL), "url", auth.LoginURL)
+		return false
+	}
+
+	c.log.Info(fmt.Sprintf("🔐 Logging in at %s...", loginURL), "url", loginURL.String())
+
+	// Load the login page first: it sets pre-login cookies and carries the
+	// form's action URL and hidden fields such as CSRF tokens
+	form := &loginForm{action: loginURL, fields: url.Values{}}
+	if body, err := c.fetchLoginPage(ctx, loginURL.String()); err == nil {
+		if found := findLoginForm(body, loginURL); found != nil {
+			form = found
+		}
+	}
+
+	usernameField := firstNonEmpty(auth.UsernameField, form.usernameField, "username")
+	passwordField := firstNonEmpty(auth.PasswordField, form.passwordField, "password")
+	form.fields.Set(usernameField, auth.Username)
+	form.fields.Set(passwordField, auth.Password)
+	for name, value := range auth.ExtraFields {
+		form.fields.Set(name, value)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", form.action.String(), strings.NewReader(form.fields.Encode()))
+	if err != nil {
+		c.log.Error(fmt.Sprintf("❌ Login failed: %v", err), "error", err)
+		return false
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", c.fingerprint.userAgent)
+	req.Header.Set("Referer", loginURL.String())
+	req.Header.Set("Origin", loginURL.Scheme+"://"+loginURL.Host)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		c.log.Error(fmt.Sprintf("❌ Login failed: %v", err), "error", err)
+		return false
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		c.log.Error(fmt.Sprintf("❌ Login failed: status %d", resp.StatusCode), "url", form.action.String(), "status", resp.StatusCode)
+		return false
+	}
+
+	// Sites usually redisplay the form on a bad password
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 2<<20))
+	if findLoginForm(body, resp.Request.URL) != nil {
+		c.log.Error("❌ Login failed: the site showed the login form again - check the username and password", "url", resp.Request.URL.String())
+		return false
+	}
+
+	cookies := len(c.httpClient.Jar.Cookies(resp.Request.URL))
+	c.log.Info(fmt.Sprintf("   ✅ Logged in as %s (%d session cookies)", auth.Username, cookies), "user", auth.Username, "cookies", cookies)
+	return true
+}
+
+func (c *Crawler) fetchLoginPage(ctx context.Context, loginURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", loginURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", c.fingerprint.userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 2<<20))
+}
+
+// findLoginForm returns the first form on the page that has a password
+// input, with its hidden and prefilled fields, or nil if there isn't one
+func findLoginForm(body []byte, pageURL *url.URL) *loginForm {
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil
+	}
+
+	var found *loginForm
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if found != nil {
+			return
+		}
+		if n.Type == html.ElementNode && n.Data == "form" {
+			if form := parseLoginForm(n, pageURL); form.passwordField != "" {
+				found = form
+				return
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(doc)
+	return found
+}
+
+func parseLoginForm(formNode *html.Node, pageURL *url.URL) *loginForm {
+	form := &loginForm{action: pageURL, fields: url.Values{}}
+	if action := strings.TrimSpace(attrValue(formNode, "action")); action != "" {
+		if u, err := url.Parse(action); err == nil {
+			form.action = pageURL.ResolveReference(u)
+		}
+	}
+
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "input" {
+			name := attrValue(n, "name")
+			inputType := strings.ToLower(attrValue(n, "type"))
+			switch {
+			case name == "":
+			case inputType == "password":
+				if form.passwordField == "" {
+					form.passwordField = name
+				}
+			case inputType == "" || inputType == "text" || inputType == "email":
+				// The username is the visible text field before the password
+				if form.usernameField == "" && form.passwordField == "" {
+					form.usernameField = name
+				} else {
+					form.fields.Set(name, attrValue(n, "value"))
+				}
+			case inputType == "checkbox" || inputType == "radio":
+				if _, checked := attrLookup(n, "checked"); checked {
+					form.fields.Set(name, firstNonEmpty(attrValue(n, "value"), "on"))
+				}
+			case inputType == "submit" || inputType == "button" || inputType == "image" || inputType == "reset":
+			default:
+				form.fields.Set(name, attrValue(n, "value"))
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(formNode)
+	return form
+}
+
+func attrLookup(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
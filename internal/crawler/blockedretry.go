@@ -0,0 +1,108 @@
+package crawler
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// blockedRetryDelay is about how long a blocked page waits before its first
+// retry. Each retry after that waits about twice as long, up to maxBackoff.
+const blockedRetryDelay = 5 * time.Second
+
+// blockedRetrier puts blocked pages back in the frontier while the crawl is
+// still running, each once its own backoff has passed, so a page that gets
+// through is fetched with the cookies the rest of the crawl has built up and
+// its links are crawled along with everything else. A page is retried up to
+// Config.BlockedRetryPasses times. A nil retrier doesn't retry.
+type blockedRetrier struct {
+	c       *Crawler
+	mu      sync.Mutex
+	waiting map[string]*blockedWait // URL -> page waiting for its retry
+}
+
+// blockedWait is a blocked page and when it's next retried
+type blockedWait struct {
+	page *BlockedPage
+	due  time.Time
+}
+
+func newBlockedRetrier(c *Crawler) *blockedRetrier {
+	return &blockedRetrier{c: c, waiting: make(map[string]*blockedWait)}
+}
+
+// queueBlocked records a page the site blocked, to be reported as still
+// blocked unless a retry gets through
+func (c *Crawler) queueBlocked(page *BlockedPage) {
+	c.blockedQueue.Store(page.URL, page)
+	c.retrier.schedule(page)
+}
+
+// schedule sets a page to be retried after its backoff, if it has retries
+// left. The frontier counts it as in progress until then, so the workers
+// wait for it instead of finishing the crawl.
+func (r *blockedRetrier) schedule(page *BlockedPage) {
+	if r == nil || page.Attempts >= r.c.config.BlockedRetryPasses {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.waiting[page.URL]; ok {
+		return
+	}
+	r.waiting[page.URL] = &blockedWait{page: page, due: time.Now().Add(backoffDelay(blockedRetryDelay, page.Attempts+1))}
+	r.c.frontier.hold()
+}
+
+// run retries pages as they come due until ctx is cancelled or stop is
+// closed
+func (r *blockedRetrier) run(ctx context.Context, stop <-chan struct{}) {
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-stop:
+			return
+		case <-ticker.C:
+			r.retryDue(ctx)
+		}
+	}
+}
+
+// retryDue puts the pages whose backoff has passed back in the frontier.
+// Once the crawl is stopping, the pages still waiting are let go and stay
+// in the blocked queue.
+func (r *blockedRetrier) retryDue(ctx context.Context) {
+	c := r.c
+	giveUp := c.interrupted(ctx) || c.outOfTime() || c.outOfBudget()
+	now := time.Now()
+
+	r.mu.Lock()
+	var due []*BlockedPage
+	for link, w := range r.waiting {
+		if giveUp || !now.Before(w.due) {
+			due = append(due, w.page)
+			delete(r.waiting, link)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, page := range due {
+		if !giveUp {
+			page.Attempts++
+			if atomic.AddInt64(&c.stats.BlockedRetried, 1) == 1 {
+				c.log.Info("🔄 Retrying blocked pages with the session's cookies...")
+			}
+			c.blockedQueue.Delete(page.URL)
+			c.visited.remove(c.getVisitedKey(page.URL))
+
+			it := c.frontier.item(page.URL, page.FoundOn, page.Depth)
+			it.blocked = page
+			c.enqueue(it)
+		}
+		c.frontier.done()
+	}
+}
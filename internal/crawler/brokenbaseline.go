@@ -0,0 +1,132 @@
+package crawler
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Values of the Change column broken-link mode adds when it's compared
+// against Config.BrokenLinksBaseline
+const (
+	changeNew       = "new"        // broken now, not in the baseline
+	changeFixed     = "fixed"      // in the baseline, and works now
+	changeNotLinked = "not linked" // in the baseline, and no crawled page links to it any more
+)
+
+// brokenBaseline is the broken links of an earlier check, by URL, each with
+// its row keyed by column name
+type brokenBaseline map[string]map[string]string
+
+// loadBrokenBaseline reads a broken-links results CSV. Rows an earlier
+// comparison marked fixed or no longer linked aren't breakages, so they're
+// left out.
+func loadBrokenBaseline(path string) (brokenBaseline, error) {
+	header, rows := readResultsCSV(path)
+	if header == nil {
+		return nil, fmt.Errorf("can't read %s", path)
+	}
+	column := make(map[string]int, len(header))
+	for i, name := range header {
+		column[name] = i
+	}
+	if _, ok := column["BrokenURL"]; !ok {
+		return nil, fmt.Errorf("%s isn't a broken-links results file (it has no BrokenURL column)", path)
+	}
+
+	baseline := make(brokenBaseline, len(rows))
+	for _, row := range rows {
+		fields := make(map[string]string, len(header))
+		for name, i := range column {
+			if i < len(row) {
+				fields[name] = row[i]
+			}
+		}
+		if change := fields["Change"]; change == changeFixed || change == changeNotLinked {
+			continue
+		}
+		baseline[fields["BrokenURL"]] = fields
+	}
+	return baseline, nil
+}
+
+// knownBreakage reports whether brokenURL was already broken in the
+// baseline, in which case it isn't reported as a finding again
+func (c *Crawler) knownBreakage(brokenURL string) bool {
+	if c.brokenBaseline == nil {
+		return false
+	}
+	_, ok := c.brokenBaseline[brokenURL]
+	if ok {
+		c.log.Debug(fmt.Sprintf("   📌 Still broken, as in the baseline: %s", brokenURL), "url", brokenURL)
+	}
+	return ok
+}
+
+// noteBreakage counts a broken link as a finding and sends a notification
+// for it, unless it's on the ignore list or the baseline already had it
+func (c *Crawler) noteBreakage(kind, brokenURL, detail string) {
+	if c.ignores(ModeBrokenLinks, brokenURL) || c.knownBreakage(brokenURL) {
+		return
+	}
+	atomic.AddInt64(&c.stats.MatchesFound, 1)
+	c.notifyFinding(kind, brokenURL, detail)
+}
+
+// brokenLinkRow adds the Change column to a broken-links row when there's a
+// baseline to compare with
+func (c *Crawler) brokenLinkRow(row []string, change string) []string {
+	if c.brokenBaseline == nil {
+		return row
+	}
+	return append([]string{row[0], change}, row[1:]...)
+}
+
+// writeFixedLinks adds a row for each of the baseline's broken links that
+// works now, or that no page links to any more. A link the crawl didn't come
+// across is only reported as no longer linked when the crawl was complete,
+// since a partial one may just not have reached the pages linking to it.
+func (c *Crawler) writeFixedLinks(complete bool) {
+	urls := make([]string, 0, len(c.brokenBaseline))
+	for u := range c.brokenBaseline {
+		urls = append(urls, u)
+	}
+	sort.Strings(urls)
+
+	now := time.Now().Format(time.RFC3339)
+	for _, u := range urls {
+		if c.ignores(ModeBrokenLinks, u) {
+			continue
+		}
+		old := c.brokenBaseline[u]
+		change, status, method := changeNotLinked, "", ""
+		var pages []string
+		if value, ok := c.anchorLinks.Load(u); ok {
+			link := value.(*anchorLink)
+			if link.missing {
+				continue
+			}
+			change, status, method, pages = changeFixed, strconv.Itoa(link.status), "GET", link.referrers()
+		} else if value, ok := c.linkResults.Load(u); ok && value.(*linkResult).checked {
+			result := value.(*linkResult)
+			if result.broken() {
+				continue
+			}
+			change, status, method, pages = changeFixed, strconv.Itoa(result.statusCode), result.method, result.referrers()
+		} else if !complete {
+			continue
+		}
+
+		if change == changeFixed {
+			atomic.AddInt64(&c.stats.BrokenFixed, 1)
+			c.log.Info(fmt.Sprintf("🩹 FIXED: %s", u), "url", u, "status", status)
+		} else {
+			atomic.AddInt64(&c.stats.BrokenUnlinked, 1)
+			c.log.Info(fmt.Sprintf("👻 NO LONGER LINKED: %s", u), "url", u)
+		}
+		c.writeResult(ModeBrokenLinks, []string{u, change, old["Type"], old["Scope"], status, "", method, strconv.Itoa(len(pages)), strings.Join(pages, " | "), now})
+	}
+}
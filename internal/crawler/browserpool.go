@@ -0,0 +1,155 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chromedp/cdproto/browser"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/target"
+	"github.com/chromedp/chromedp"
+)
+
+const defaultBrowserInstances = 2
+
+// browserPool shares a few long-lived headless Chrome processes between
+// captures. Starting Chrome for every page took seconds and hundreds of MB
+// each, so a capture now opens a tab in a pooled browser instead. Each tab
+// gets its own browser context, so pages still don't share cookies, cache or
+// storage, and its own pick from the proxy rotation.
+type browserPool struct {
+	c        *Crawler
+	flags    []chromedp.ExecAllocatorOption
+	browsers []*pooledBrowser
+	next     uint32
+}
+
+// pooledBrowser is one Chrome process in the pool. It's started on first use
+// and restarted when it crashes or stops responding.
+type pooledBrowser struct {
+	mu          sync.Mutex
+	ctx         context.Context // the browser's first tab; cancelled if Chrome exits
+	cancel      context.CancelFunc
+	allocCancel context.CancelFunc
+	started     bool
+}
+
+func newBrowserPool(c *Crawler, flags ...chromedp.ExecAllocatorOption) *browserPool {
+	size := c.config.BrowserInstances
+	if size <= 0 {
+		size = defaultBrowserInstances
+	}
+	if c.config.MaxConcurrency > 0 && size > c.config.MaxConcurrency {
+		size = c.config.MaxConcurrency
+	}
+
+	opts := append(chromedp.DefaultExecAllocatorOptions[:],
+		chromedp.Flag("headless", true),
+		chromedp.Flag("disable-gpu", true),
+		chromedp.Flag("no-sandbox", true),
+		chromedp.Flag("disable-setuid-sandbox", true),
+		chromedp.Flag("disable-dev-shm-usage", true),
+		chromedp.Flag("ignore-certificate-errors", c.config.SkipTLSVerify),
+		chromedp.WindowSize(1920, 1080),
+	)
+	if rules := c.resolver.chromeRules(context.Background(), c.browserHosts()); rules != "" {
+		opts = append(opts, chromedp.Flag("host-resolver-rules", rules))
+	}
+
+	bp := &browserPool{
+		c:        c,
+		flags:    append(opts, flags...),
+		browsers: make([]*pooledBrowser, size),
+	}
+	for i := range bp.browsers {
+		bp.browsers[i] = &pooledBrowser{}
+	}
+	return bp
+}
+
+// tab opens a new tab in one of the pool's browsers, starting or restarting
+// the browser first if needed. The tab is closed when ctx is done or the
+// returned cancel func is called.
+func (bp *browserPool) tab(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	i := atomic.AddUint32(&bp.next, 1) % uint32(len(bp.browsers))
+	browserCtx, err := bp.browsers[i].healthy(bp)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	proxy := bp.c.proxies.chromeProxyServer()
+	tabCtx, cancel := chromedp.NewContext(browserCtx, chromedp.WithNewBrowserContext(
+		func(p *target.CreateBrowserContextParams) *target.CreateBrowserContextParams {
+			if proxy == "" {
+				return p
+			}
+			return p.WithProxyServer(proxy)
+		},
+	))
+
+	stop := context.AfterFunc(ctx, cancel)
+	return tabCtx, func() {
+		stop()
+		cancel()
+	}, nil
+}
+
+// healthy returns the browser's context once Chrome is running and answering
+// the DevTools protocol
+func (b *pooledBrowser) healthy(bp *browserPool) (context.Context, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.started && b.ctx.Err() == nil {
+		pingCtx, cancel := context.WithTimeout(b.ctx, 10*time.Second)
+		err := chromedp.Run(pingCtx, chromedp.ActionFunc(func(ctx context.Context) error {
+			_, _, _, _, _, err := browser.GetVersion().Do(cdp.WithExecutor(ctx, chromedp.FromContext(ctx).Browser))
+			return err
+		}))
+		cancel()
+		if err == nil {
+			return b.ctx, nil
+		}
+	}
+
+	if b.started {
+		bp.c.log.Warn("♻️  Chrome stopped responding - restarting it")
+		b.stop()
+	}
+
+	allocCtx, allocCancel := chromedp.NewExecAllocator(context.Background(), bp.flags...)
+	ctx, cancel := chromedp.NewContext(allocCtx)
+
+	// The first Run launches Chrome. It must use the browser's own context,
+	// since Chrome is shut down when the context that launched it is done.
+	if err := chromedp.Run(ctx); err != nil {
+		cancel()
+		allocCancel()
+		return nil, fmt.Errorf("could not start Chrome: %v", err)
+	}
+	b.ctx, b.cancel, b.allocCancel = ctx, cancel, allocCancel
+	b.started = true
+	return b.ctx, nil
+}
+
+// stop shuts down the browser process. The caller holds b.mu.
+func (b *pooledBrowser) stop() {
+	if !b.started {
+		return
+	}
+	b.cancel()
+	b.allocCancel()
+	b.started = false
+}
+
+// close shuts down every browser in the pool
+func (bp *browserPool) close() {
+	for _, b := range bp.browsers {
+		b.mu.Lock()
+		b.stop()
+		b.mu.Unlock()
+	}
+}
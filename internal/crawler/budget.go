@@ -0,0 +1,129 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// bandwidthBurst is how much of Config.MaxBandwidth a quiet spell saves up:
+// after a pause between pages, downloads may briefly go faster by at most a
+// second's worth
+const bandwidthBurst = time.Second
+
+// bandwidthLimiter paces downloads across every request so that together
+// they stay under a number of bytes per second
+type bandwidthLimiter struct {
+	rate float64 // bytes per second
+	mu   sync.Mutex
+	next time.Time // when the bytes read so far have been paid for
+}
+
+// chunk returns the most bytes one read may take, so that a single read of
+// a large buffer doesn't hold a download up for seconds at a time
+func (l *bandwidthLimiter) chunk() int {
+	return min(max(int(l.rate/20), 512), 32<<10)
+}
+
+// wait blocks until n more bytes are allowed. It returns false if ctx was
+// cancelled first.
+func (l *bandwidthLimiter) wait(ctx context.Context, n int) bool {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now.Add(-bandwidthBurst)) {
+		l.next = now.Add(-bandwidthBurst)
+	}
+	l.next = l.next.Add(time.Duration(float64(n) / l.rate * float64(time.Second)))
+	delay := l.next.Sub(now)
+	l.mu.Unlock()
+
+	return sleepCtx(ctx, delay)
+}
+
+// budgetTransport counts the response bodies downloaded through it against
+// Config.MaxTotalBytes, and slows them to Config.MaxBandwidth
+type budgetTransport struct {
+	base http.RoundTripper
+	c    *Crawler
+}
+
+func (t *budgetTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &budgetBody{ReadCloser: resp.Body, ctx: req.Context(), c: t.c}
+	return resp, nil
+}
+
+// budgetBody is a response body read through the crawler's download budget
+type budgetBody struct {
+	io.ReadCloser
+	ctx context.Context
+	c   *Crawler
+}
+
+func (b *budgetBody) Read(p []byte) (int, error) {
+	limiter := b.c.bandwidth
+	if limiter != nil && len(p) > limiter.chunk() {
+		p = p[:limiter.chunk()]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.c.spend(int64(n))
+		if limiter != nil && !limiter.wait(b.ctx, n) {
+			return n, b.ctx.Err()
+		}
+	}
+	return n, err
+}
+
+// withDownloadBudget wraps the crawler's transports in a budgetTransport when
+// Config.MaxBandwidth or Config.MaxTotalBytes is set
+func (c *Crawler) withDownloadBudget() {
+	if c.config.MaxBandwidth <= 0 && c.config.MaxTotalBytes <= 0 {
+		return
+	}
+	if c.config.MaxBandwidth > 0 {
+		c.bandwidth = &bandwidthLimiter{rate: float64(c.config.MaxBandwidth)}
+	}
+
+	wrap := func(base http.RoundTripper) http.RoundTripper {
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		return &budgetTransport{base: base, c: c}
+	}
+	c.httpClient.Transport = wrap(c.httpClient.Transport)
+	c.checkTransport = wrap(c.checkTransport)
+}
+
+// spend counts n downloaded bytes against Config.MaxTotalBytes
+func (c *Crawler) spend(n int64) {
+	total := atomic.AddInt64(&c.bytesSpent, n)
+	if c.config.MaxTotalBytes > 0 && total >= c.config.MaxTotalBytes && atomic.CompareAndSwapInt32(&c.overBudget, 0, 1) {
+		c.log.Warn(fmt.Sprintf("💸 Download budget of %s used up - finishing in-progress pages...", formatBytes(c.config.MaxTotalBytes)), "limit_bytes", c.config.MaxTotalBytes)
+	}
+}
+
+// outOfBudget reports whether Config.MaxTotalBytes has been downloaded. As
+// with the time limit, no new pages are started once it has, but pages
+// already being fetched, and their link and image checks, finish.
+func (c *Crawler) outOfBudget() bool {
+	return atomic.LoadInt32(&c.overBudget) == 1
+}
+
+// ParseByteSize parses a size given on the command line, such as "500KB",
+// "50MB" or "2GB"; a number without a unit is in bytes
+func ParseByteSize(s string) (int64, error) {
+	if n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64); err == nil && n >= 0 {
+		return n, nil
+	}
+	return parseByteSize(s)
+}
@@ -0,0 +1,102 @@
+package crawler
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// pageIndexing holds the indexing hints a page gives search engines
+type pageIndexing struct {
+	Canonical string // absolute canonical URL, or "" if none
+	NoIndex   bool
+}
+
+// parsePageIndexing reads <link rel="canonical"> and robots meta tags from a
+// page. xRobotsTag is the X-Robots-Tag response header, which can also carry
+// noindex.
+func parsePageIndexing(doc *html.Node, pageURL, xRobotsTag string) pageIndexing {
+	var info pageIndexing
+	if robotsDirectivesNoIndex(xRobotsTag) {
+		info.NoIndex = true
+	}
+
+	if doc == nil {
+		return info
+	}
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return info
+	}
+
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "link":
+				var rel, href string
+				for _, a := range n.Attr {
+					switch a.Key {
+					case "rel":
+						rel = strings.ToLower(a.Val)
+					case "href":
+						href = strings.TrimSpace(a.Val)
+					}
+				}
+				if info.Canonical == "" && href != "" && containsField(rel, "canonical") {
+					if u, err := url.Parse(href); err == nil {
+						resolved := base.ResolveReference(u)
+						resolved.Fragment = ""
+						info.Canonical = resolved.String()
+					}
+				}
+			case "meta":
+				var name, content string
+				for _, a := range n.Attr {
+					switch a.Key {
+					case "name":
+						name = strings.ToLower(strings.TrimSpace(a.Val))
+					case "content":
+						content = a.Val
+					}
+				}
+				if (name == "robots" || name == robotsAgent) && robotsDirectivesNoIndex(content) {
+					info.NoIndex = true
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(doc)
+
+	return info
+}
+
+// robotsDirectivesNoIndex reports whether a robots meta/X-Robots-Tag value
+// contains noindex or none
+func robotsDirectivesNoIndex(directives string) bool {
+	for _, d := range strings.Split(strings.ToLower(directives), ",") {
+		d = strings.TrimSpace(d)
+		// X-Robots-Tag may be prefixed with a user agent ("googlebot: noindex")
+		if i := strings.LastIndex(d, ":"); i >= 0 {
+			d = strings.TrimSpace(d[i+1:])
+		}
+		if d == "noindex" || d == "none" {
+			return true
+		}
+	}
+	return false
+}
+
+// containsField reports whether a space-separated attribute value contains word
+func containsField(value, word string) bool {
+	for _, f := range strings.Fields(value) {
+		if f == word {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,219 @@
+package crawler
+
+import (
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultCertExpiryDays is how soon a certificate has to expire to be
+// flagged when Config.CertExpiryDays isn't set
+const defaultCertExpiryDays = 30
+
+var certAuditHeader = []string{"Host", "Subject", "Issuer", "NotBefore", "NotAfter", "DaysLeft", "DNSNames", "TLSVersion", "Issues", "Timestamp"}
+
+// clientTLSConfig returns the TLS settings for the crawl's requests.
+// Certificates are checked unless Config.SkipTLSVerify is set. The
+// certificate audit also accepts TLS 1.0 and 1.1, so that hosts still using
+// them can be reported instead of failing to connect.
+func clientTLSConfig(cfg Config) *tls.Config {
+	conf := &tls.Config{InsecureSkipVerify: cfg.SkipTLSVerify}
+	if cfg.CertAudit {
+		conf.MinVersion = tls.VersionTLS10
+	}
+	return conf
+}
+
+// certAudit records the certificate of each HTTPS host the crawl fetches
+// pages from, once per host, in a CSV next to the results file
+type certAudit struct {
+	mu         sync.Mutex
+	seen       map[string]bool
+	file       *os.File
+	w          *csv.Writer
+	expiryDays int
+	verified   bool // the transport checks certificates, so a response means the chain and name are fine
+}
+
+func openCertAudit(path string, cfg Config) (*certAudit, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("creating the certificate audit: %v", err)
+	}
+	days := cfg.CertExpiryDays
+	if days <= 0 {
+		days = defaultCertExpiryDays
+	}
+	a := &certAudit{
+		seen:       make(map[string]bool),
+		file:       file,
+		w:          csv.NewWriter(file),
+		expiryDays: days,
+		verified:   !cfg.SkipTLSVerify,
+	}
+	a.w.Write(certAuditHeader)
+	a.w.Flush()
+	return a, nil
+}
+
+// first reports whether host hasn't been audited yet, and marks it
+func (a *certAudit) first(host string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.seen[host] {
+		return false
+	}
+	a.seen[host] = true
+	return true
+}
+
+// issues lists what's wrong with a host's certificate chain. verifyErr is
+// the transport's verification error, if the handshake failed on it.
+func (a *certAudit) issues(host string, certs []*x509.Certificate, version uint16, verifyErr error) []string {
+	var issues []string
+	leaf := certs[0]
+
+	now := time.Now()
+	switch days := int(leaf.NotAfter.Sub(now).Hours() / 24); {
+	case now.After(leaf.NotAfter):
+		issues = append(issues, fmt.Sprintf("expired %s", leaf.NotAfter.Format("2006-01-02")))
+	case now.Before(leaf.NotBefore):
+		issues = append(issues, fmt.Sprintf("not valid until %s", leaf.NotBefore.Format("2006-01-02")))
+	case days < a.expiryDays:
+		issues = append(issues, fmt.Sprintf("expires in %d days", days))
+	}
+
+	if leaf.VerifyHostname(host) != nil {
+		issues = append(issues, fmt.Sprintf("name mismatch (valid for %s)", strings.Join(certNames(leaf), ", ")))
+	}
+
+	// The chain only needs checking here when the transport didn't
+	if verifyErr == nil && !a.verified {
+		opts := x509.VerifyOptions{Intermediates: x509.NewCertPool()}
+		for _, cert := range certs[1:] {
+			opts.Intermediates.AddCert(cert)
+		}
+		_, verifyErr = leaf.Verify(opts)
+	}
+	var unknown x509.UnknownAuthorityError
+	if errors.As(verifyErr, &unknown) {
+		if len(certs) == 1 && leaf.CheckSignatureFrom(leaf) == nil {
+			issues = append(issues, "self-signed")
+		} else {
+			issues = append(issues, "untrusted issuer")
+		}
+	}
+
+	if version != 0 && version < tls.VersionTLS12 {
+		issues = append(issues, "weak protocol "+tls.VersionName(version))
+	}
+	switch leaf.SignatureAlgorithm {
+	case x509.SHA1WithRSA, x509.ECDSAWithSHA1, x509.DSAWithSHA1, x509.MD5WithRSA:
+		issues = append(issues, "weak signature "+leaf.SignatureAlgorithm.String())
+	}
+	if key, ok := leaf.PublicKey.(*rsa.PublicKey); ok && key.N.BitLen() < 2048 {
+		issues = append(issues, fmt.Sprintf("weak key (%d-bit RSA)", key.N.BitLen()))
+	}
+	return issues
+}
+
+// add writes a host's row
+func (a *certAudit) add(host string, leaf *x509.Certificate, version uint16, issues []string) {
+	tlsVersion := ""
+	if version != 0 {
+		tlsVersion = tls.VersionName(version)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.w.Write([]string{
+		host, leaf.Subject.CommonName, leaf.Issuer.String(),
+		leaf.NotBefore.Format("2006-01-02"), leaf.NotAfter.Format("2006-01-02"),
+		strconv.Itoa(int(time.Until(leaf.NotAfter).Hours() / 24)),
+		strings.Join(certNames(leaf), " "), tlsVersion, strings.Join(issues, "; "),
+		time.Now().Format(time.RFC3339),
+	})
+	a.w.Flush()
+}
+
+func (a *certAudit) close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.w.Flush()
+	if err := a.w.Error(); err != nil {
+		a.file.Close()
+		return err
+	}
+	return a.file.Close()
+}
+
+// certNames returns the names a certificate is valid for
+func certNames(cert *x509.Certificate) []string {
+	names := append([]string{}, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	if len(names) == 0 && cert.Subject.CommonName != "" {
+		names = append(names, cert.Subject.CommonName)
+	}
+	return names
+}
+
+// auditCert records the certificate of the host a page came from, the first
+// time the crawl fetches a page there. A request that failed because the
+// transport rejected the certificate still has it audited, from err.
+func (c *Crawler) auditCert(resp *http.Response, err error) {
+	if c.certs == nil {
+		return
+	}
+	var host string
+	var certs []*x509.Certificate
+	var version uint16
+	var verifyErr *tls.CertificateVerificationError
+	var urlErr *url.Error
+	switch {
+	case err != nil && errors.As(err, &verifyErr) && errors.As(err, &urlErr):
+		if u, parseErr := url.Parse(urlErr.URL); parseErr == nil {
+			host = u.Hostname()
+		}
+		certs = verifyErr.UnverifiedCertificates
+	case err == nil && resp != nil && resp.TLS != nil:
+		host = resp.Request.URL.Hostname()
+		certs, version = resp.TLS.PeerCertificates, resp.TLS.Version
+	}
+	if host == "" || len(certs) == 0 || !c.certs.first(host) {
+		return
+	}
+
+	var cause error
+	if verifyErr != nil {
+		cause = verifyErr.Err
+	}
+	issues := c.certs.issues(host, certs, version, cause)
+	c.certs.add(host, certs[0], version, issues)
+	if len(issues) > 0 {
+		atomic.AddInt64(&c.stats.CertIssues, 1)
+		c.log.Warn(fmt.Sprintf("🔒 Certificate issue on %s: %s", host, strings.Join(issues, "; ")), "host", host, "issues", strings.Join(issues, "; "))
+		c.notifyFinding("certificate_issue", host, strings.Join(issues, "; "))
+	}
+}
+
+// closeCertAudit finishes the certificate audit once the crawl is over
+func (c *Crawler) closeCertAudit() {
+	if c.certs == nil {
+		return
+	}
+	if err := c.certs.close(); err != nil {
+		c.log.Error(fmt.Sprintf("❌ Certificate audit: %v", err), "path", c.certAuditFile, "error", err)
+	}
+}
@@ -0,0 +1,194 @@
+package crawler
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"os/exec"
+)
+
+// checkCMYKTools reports up front, before any page is captured, what a CMYK
+// format needs that's missing: Ghostscript for CMYK PDFs, and a readable
+// ICC profile when CaptureOptions.CMYKProfile is set. CMYK TIFFs are
+// written in-process and need nothing else, but can't use a profile.
+func checkCMYKTools(format CaptureFormat, opts CaptureOptions) error {
+	if format != CaptureCMYKPDF && format != CaptureCMYKTIFF {
+		return nil
+	}
+	if format == CaptureCMYKTIFF && opts.CMYKProfile != "" {
+		return fmt.Errorf("CMYK TIFFs are separated with a generic conversion, not through an ICC profile - leave the profile out, or choose CMYK PDF to convert through %s", opts.CMYKProfile)
+	}
+	if format == CaptureCMYKPDF {
+		if _, err := exec.LookPath("gs"); err != nil {
+			return fmt.Errorf("ghostscript (gs) not found in PATH - CMYK PDFs need it; install with: sudo apt install ghostscript, or choose CMYK TIFF")
+		}
+	}
+	if opts.CMYKProfile != "" {
+		if _, err := readICCProfile(opts.CMYKProfile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readICCProfile reads an ICC profile file and checks it's for CMYK output
+func readICCProfile(path string) ([]byte, error) {
+	profile, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't read ICC profile: %w", err)
+	}
+	// The header holds the profile's colour space at bytes 16-19 and the
+	// "acsp" signature at 36-39
+	if len(profile) < 128 || string(profile[36:40]) != "acsp" {
+		return nil, fmt.Errorf("%s is not an ICC profile", path)
+	}
+	if string(profile[16:20]) != "CMYK" {
+		return nil, fmt.Errorf("%s is a %s profile, not a CMYK one", path, bytes.TrimSpace(profile[16:20]))
+	}
+	return profile, nil
+}
+
+// writeCMYKTIFF converts a PNG screenshot to a CMYK TIFF at outputPath. The
+// colours are separated with Go's device CMYK conversion, so the TIFF
+// carries no ICC profile: tagging it with a press profile such as FOGRA39
+// would tell InDesign and Photoshop the colours were separated for that
+// press when they weren't.
+func writeCMYKTIFF(pngBuf []byte, outputPath string) error {
+	img, err := png.Decode(bytes.NewReader(pngBuf))
+	if err != nil {
+		return fmt.Errorf("can't decode screenshot: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := encodeCMYKTIFF(&buf, img); err != nil {
+		return err
+	}
+	return os.WriteFile(outputPath, buf.Bytes(), 0644)
+}
+
+// cmykTIFFRowsPerStrip keeps each compressed strip to about 256KB of pixels
+// for a 1920px wide image
+const cmykTIFFRowsPerStrip = 32
+
+// encodeCMYKTIFF writes img as a baseline TIFF with 8-bit CMYK samples,
+// Deflate compressed. Transparent pixels are flattened onto white.
+func encodeCMYKTIFF(w *bytes.Buffer, img image.Image) error {
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	if width == 0 || height == 0 {
+		return fmt.Errorf("screenshot is empty")
+	}
+
+	// Compress the pixels strip by strip
+	var strips [][]byte
+	row := make([]byte, width*4)
+	for y0 := 0; y0 < height; y0 += cmykTIFFRowsPerStrip {
+		var strip bytes.Buffer
+		zw := zlib.NewWriter(&strip)
+		for y := y0; y < min(y0+cmykTIFFRowsPerStrip, height); y++ {
+			for x := 0; x < width; x++ {
+				r, g, bl, a := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+				// RGBA is premultiplied, so adding the missing alpha
+				// composites onto white
+				r, g, bl = r+0xffff-a, g+0xffff-a, bl+0xffff-a
+				c, m, ye, k := color.RGBToCMYK(uint8(r>>8), uint8(g>>8), uint8(bl>>8))
+				row[x*4], row[x*4+1], row[x*4+2], row[x*4+3] = c, m, ye, k
+			}
+			if _, err := zw.Write(row); err != nil {
+				return err
+			}
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		strips = append(strips, strip.Bytes())
+	}
+
+	// Layout: header, strips, then the IFD and the tag values too big to
+	// fit in it
+	le := binary.LittleEndian
+	offset := uint32(8)
+	stripOffsets := make([]uint32, len(strips))
+	stripCounts := make([]uint32, len(strips))
+	for i, s := range strips {
+		stripOffsets[i], stripCounts[i] = offset, uint32(len(s))
+		offset += uint32(len(s))
+	}
+	offset += offset & 1 // The IFD starts on a word boundary
+
+	type tag struct {
+		id    uint16
+		typ   uint16 // 1 BYTE, 3 SHORT, 4 LONG, 5 RATIONAL, 7 UNDEFINED
+		count uint32
+		data  []byte
+	}
+	shorts := func(v ...uint16) []byte {
+		out := make([]byte, 2*len(v))
+		for i, n := range v {
+			le.PutUint16(out[2*i:], n)
+		}
+		return out
+	}
+	longs := func(v ...uint32) []byte {
+		out := make([]byte, 4*len(v))
+		for i, n := range v {
+			le.PutUint32(out[4*i:], n)
+		}
+		return out
+	}
+	tags := []tag{
+		{256, 4, 1, longs(uint32(width))},
+		{257, 4, 1, longs(uint32(height))},
+		{258, 3, 4, shorts(8, 8, 8, 8)},                       // BitsPerSample
+		{259, 3, 1, shorts(8)},                                // Compression: Deflate
+		{262, 3, 1, shorts(5)},                                // PhotometricInterpretation: separated
+		{273, 4, uint32(len(strips)), longs(stripOffsets...)}, // StripOffsets
+		{277, 3, 1, shorts(4)},                                // SamplesPerPixel
+		{278, 4, 1, longs(cmykTIFFRowsPerStrip)},              // RowsPerStrip
+		{279, 4, uint32(len(strips)), longs(stripCounts...)},  // StripByteCounts
+		{282, 5, 1, longs(72, 1)},                             // XResolution
+		{283, 5, 1, longs(72, 1)},                             // YResolution
+		{284, 3, 1, shorts(1)},                                // PlanarConfiguration: chunky
+		{296, 3, 1, shorts(2)},                                // ResolutionUnit: inch
+		{332, 3, 1, shorts(1)},                                // InkSet: CMYK
+	}
+
+	ifdSize := uint32(2 + 12*len(tags) + 4)
+	extra := offset + ifdSize
+	var ifd, values bytes.Buffer
+	binary.Write(&ifd, le, uint16(len(tags)))
+	for _, t := range tags {
+		binary.Write(&ifd, le, t.id)
+		binary.Write(&ifd, le, t.typ)
+		binary.Write(&ifd, le, t.count)
+		if len(t.data) <= 4 {
+			ifd.Write(t.data)
+			ifd.Write(make([]byte, 4-len(t.data)))
+			continue
+		}
+		binary.Write(&ifd, le, extra+uint32(values.Len()))
+		values.Write(t.data)
+		if values.Len()&1 == 1 {
+			values.WriteByte(0)
+		}
+	}
+	binary.Write(&ifd, le, uint32(0)) // No more IFDs
+
+	w.WriteString("II")
+	binary.Write(w, le, uint16(42))
+	binary.Write(w, le, offset)
+	for _, s := range strips {
+		w.Write(s)
+	}
+	if w.Len()&1 == 1 {
+		w.WriteByte(0)
+	}
+	w.Write(ifd.Bytes())
+	w.Write(values.Bytes())
+	return nil
+}
@@ -0,0 +1,179 @@
+package crawler
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// combinableModes are the modes that only read the pages a crawl fetches, so
+// several of them can share one crawl through Config.AlsoModes. Redirect and
+// mirror modes change how links are followed and saved, and the capture,
+// sitemap, feed, visual-diff and listing modes don't crawl pages this way.
+var combinableModes = map[SearchMode]bool{
+	ModeSearchLink:      true,
+	ModeSearchWord:      true,
+	ModeBrokenLinks:     true,
+	ModeOversizedImages: true,
+	ModePageWeight:      true,
+	ModeStructuredData:  true,
+	ModeExtract:         true,
+	ModeContentDiff:     true,
+}
+
+// CanCombine reports whether mode can run alongside others in one crawl,
+// either as Config.Mode or in Config.AlsoModes
+func CanCombine(mode SearchMode) bool {
+	return combinableModes[mode]
+}
+
+// ValidateModes checks Config.AlsoModes can run alongside Config.Mode
+func ValidateModes(cfg Config) error {
+	if len(cfg.AlsoModes) == 0 {
+		return nil
+	}
+	if !CanCombine(cfg.Mode) {
+		return fmt.Errorf("%s can't be combined with other checks", cfg.Mode)
+	}
+	seen := map[SearchMode]bool{cfg.Mode: true}
+	searches := 0
+	if cfg.Mode == ModeSearchLink || cfg.Mode == ModeSearchWord {
+		searches++
+	}
+	for _, mode := range cfg.AlsoModes {
+		if !CanCombine(mode) {
+			return fmt.Errorf("%s can't be combined with other checks", mode)
+		}
+		if seen[mode] {
+			return fmt.Errorf("%s is listed more than once", mode)
+		}
+		seen[mode] = true
+		if mode == ModeSearchLink || mode == ModeSearchWord {
+			searches++
+		}
+	}
+	if searches > 1 {
+		return fmt.Errorf("only one link or word search can run in a crawl, since there's one search target")
+	}
+	return nil
+}
+
+// crawlModes returns Config.Mode followed by Config.AlsoModes
+func (c *Crawler) crawlModes() []SearchMode {
+	return append([]SearchMode{c.config.Mode}, c.config.AlsoModes...)
+}
+
+// runs reports whether mode's checks run on the crawled pages, as the main
+// mode or one of Config.AlsoModes
+func (c *Crawler) runs(mode SearchMode) bool {
+	if c.config.Mode == mode {
+		return true
+	}
+	for _, also := range c.config.AlsoModes {
+		if also == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// searchMode returns the link or word search the crawl runs, or 0 if there
+// isn't one
+func searchMode(cfg Config) SearchMode {
+	for _, mode := range append([]SearchMode{cfg.Mode}, cfg.AlsoModes...) {
+		if mode == ModeSearchLink || mode == ModeSearchWord {
+			return mode
+		}
+	}
+	return 0
+}
+
+// resultFileName names a mode's results file
+func resultFileName(mode SearchMode, timestamp string) string {
+	switch mode {
+	case ModeSearchLink, ModeSearchWord:
+		return fmt.Sprintf("results-search-%s.csv", timestamp)
+	case ModeBrokenLinks:
+		return fmt.Sprintf("results-broken-links-%s.csv", timestamp)
+	case ModeOversizedImages:
+		return fmt.Sprintf("results-oversized-images-%s.csv", timestamp)
+	case ModeRedirectChains:
+		return fmt.Sprintf("results-redirects-%s.csv", timestamp)
+	case ModePageWeight:
+		return fmt.Sprintf("results-page-weight-%s.csv", timestamp)
+	case ModeStructuredData:
+		return fmt.Sprintf("results-structured-data-%s.jsonl", timestamp)
+	case ModeExtract:
+		return fmt.Sprintf("results-extract-%s.csv", timestamp)
+	case ModeContentDiff:
+		return fmt.Sprintf("results-content-diff-%s.csv", timestamp)
+	case ModeMirror:
+		return fmt.Sprintf("results-mirror-%s.csv", timestamp)
+	case ModeDiscover:
+		return fmt.Sprintf("results-discover-%s.csv", timestamp)
+	case ModeOrphans:
+		return fmt.Sprintf("results-orphans-%s.csv", timestamp)
+	}
+	return ""
+}
+
+// resultHeader returns the columns of a mode's results CSV. Structured data
+// is written as JSON lines, so it has none.
+func (c *Crawler) resultHeader(mode SearchMode) []string {
+	switch mode {
+	case ModeSearchLink:
+		return []string{"URL", "FoundOn", "ContentType", "FoundIn", "Target", "Occurrences", "AnchorText", "Timestamp"}
+	case ModeSearchWord:
+		return []string{"URL", "FoundOn", "ContentType", "FoundIn", "Target", "Occurrences", "Snippets", "Timestamp"}
+	case ModeBrokenLinks:
+		if c.config.BrokenLinksBaseline != "" {
+			return []string{"BrokenURL", "Change", "Type", "Scope", "StatusCode", "Error", "Method", "ReferringPages", "FoundOnPages", "Timestamp"}
+		}
+		return []string{"BrokenURL", "Type", "Scope", "StatusCode", "Error", "Method", "ReferringPages", "FoundOnPages", "Timestamp"}
+	case ModeOversizedImages:
+		return []string{"ImageURL", "FoundOnPage", "SizeKB", "ContentType", "Width", "Height", "DisplayWidth", "DisplayHeight", "Issues", "SuggestedFormat", "EstSavingsKB", "Timestamp"}
+	case ModeRedirectChains:
+		return []string{"StartURL", "FoundOn", "FinalURL", "FinalStatus", "Redirects", "Issues", "Chain", "Timestamp"}
+	case ModePageWeight:
+		return []string{"URL", "FoundOn", "TotalKB", "HTMLKB", "ImagesKB", "ScriptsKB", "CSSKB", "Requests", "TTFBms", "Issues", "Timestamp"}
+	case ModeExtract:
+		return extractFieldsHeader(c.config.ExtractFields)
+	case ModeContentDiff:
+		return []string{"URL", "FoundOn", "Change", "Title", "PreviousTitle", "WordsAdded", "WordsRemoved", "Timestamp"}
+	case ModeMirror:
+		return []string{"URL", "LocalPath", "Kind", "ContentType", "SizeKB", "Error", "Timestamp"}
+	case ModeDiscover:
+		return []string{"URL", "Depth", "FoundOn", "InScope", "Crawled", "SkipReason", "Status", "ContentType", "RedirectedTo", "Error"}
+	case ModeOrphans:
+		return []string{"URL", "Issue", "FoundOn", "Depth", "Status", "RedirectedTo"}
+	}
+	return nil
+}
+
+// resultFileFor returns the results file of one of the crawl's modes
+func (c *Crawler) resultFileFor(mode SearchMode) string {
+	return c.resultFiles[mode]
+}
+
+// outputPath places a file or folder the run writes in Config.OutputDir.
+// Absolute paths are left where they point.
+func (c *Crawler) outputPath(name string) string {
+	if c.config.OutputDir == "" || filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(c.config.OutputDir, name)
+}
+
+// resultsTable names a mode's table in the results database. The main mode
+// keeps "results"; the others get e.g. "results_broken_links".
+func (c *Crawler) resultsTable(mode SearchMode) string {
+	if mode == c.config.Mode {
+		return "results"
+	}
+	for name, m := range modeNames {
+		if m == mode {
+			return "results_" + strings.ReplaceAll(name, "-", "_")
+		}
+	}
+	return fmt.Sprintf("results_%d", mode)
+}
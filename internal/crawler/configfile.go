@@ -0,0 +1,510 @@
+package crawler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// modeNames are the short names a config file or schedule file can give for
+// the modes, as well as the names the wizard shows
+var modeNames = map[string]SearchMode{
+	"link-search":      ModeSearchLink,
+	"word-search":      ModeSearchWord,
+	"broken-links":     ModeBrokenLinks,
+	"oversized-images": ModeOversizedImages,
+	"page-capture":     ModePDFCapture,
+	"sitemap":          ModeSitemap,
+	"json-feed":        ModeJSONFeed,
+	"redirects":        ModeRedirectChains,
+	"page-weight":      ModePageWeight,
+	"structured-data":  ModeStructuredData,
+	"extract":          ModeExtract,
+	"content-diff":     ModeContentDiff,
+	"visual-diff":      ModeVisualDiff,
+	"mirror":           ModeMirror,
+	"listing":          ModeListing,
+	"discover":         ModeDiscover,
+	"orphans":          ModeOrphans,
+}
+
+// DefaultConfig is the starting point for a config file: the settings the
+// wizard uses when its questions are left at their defaults. The file only
+// needs what differs.
+func DefaultConfig() Config {
+	return Config{
+		MaxConcurrency:       5,
+		MaxRetries:           3,
+		RetryDelay:           2 * time.Second,
+		RetryBlockedPages:    true,
+		BlockedRetryPasses:   3,
+		AdaptiveThrottle:     true,
+		MaxBodySize:          50 * 1024 * 1024,
+		RequestTimeout:       30 * time.Second,
+		SearchVisibleText:    true,
+		ImageSizeThreshold:   500 * 1024,
+		CheckExternalLinks:   true,
+		DiffStoreText:        true,
+		MirrorExternalAssets: true,
+		SitemapOpts:          SitemapOptions{Filename: "sitemap.xml", IncludeLastMod: true},
+	}
+}
+
+// LoadConfigFile reads a crawl's settings from a YAML or JSON file on top of
+// DefaultConfig. Keys are Config's field names in any case, with or without
+// underscores or dashes ("max_pages", "maxPages", "MaxPages"), and nest for
+// the option structs:
+//
+//	mode: page-capture
+//	start_url: https://example.com/news/
+//	max_pages: 200
+//	capture_format: pdf-only
+//	capture_opts:
+//	  paper_size: a4
+//	  margins: [0.5]
+//
+// Modes and other choices take the names the wizard shows (or the short
+// mode names such as "broken-links"), durations take "30s" or "2h", and byte
+// sizes take plain numbers or "500KB", "50MB".
+func LoadConfigFile(path string) (Config, error) {
+	cfg := DefaultConfig()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+
+	var doc any
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &doc)
+	} else {
+		doc, err = parseYAML(data)
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := decodeConfigValue(reflect.ValueOf(&cfg).Elem(), doc, ""); err != nil {
+		return cfg, fmt.Errorf("%s: %v", path, err)
+	}
+
+	if cfg.Mode == 0 {
+		return cfg, fmt.Errorf("%s: mode is required, e.g. mode: broken-links", path)
+	}
+	if cfg.StartURL == "" && cfg.Mode != ModeVisualDiff {
+		return cfg, fmt.Errorf("%s: start_url is required", path)
+	}
+	if err := ValidateModes(cfg); err != nil {
+		return cfg, fmt.Errorf("%s: also_modes: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// SaveConfigFile writes cfg as a config file that LoadConfigFile reads back,
+// so a run set up in the wizard can be repeated: JSON when path ends in
+// .json, YAML otherwise. Settings left at their DefaultConfig value are
+// omitted, as are the secrets: the login password and the webhook URL.
+func SaveConfigFile(path string, cfg Config) error {
+	cfg.Logger = nil
+	cfg.Quiet = false
+	cfg.Auth.Password = ""
+	cfg.Notify.WebhookURL = ""
+	doc := encodeConfigValue(reflect.ValueOf(cfg), reflect.ValueOf(DefaultConfig()))
+
+	var data []byte
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		var err error
+		if data, err = json.MarshalIndent(doc, "", "  "); err != nil {
+			return err
+		}
+		data = append(data, '\n')
+	} else {
+		data = marshalYAML(doc)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+	cookiesType  = reflect.TypeOf([]*http.Cookie(nil))
+	stringerType = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+)
+
+// configKey normalizes a field name or file key for matching:
+// "max_pages", "maxPages" and "MaxPages" are all "maxpages"
+func configKey(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
+
+// decodeConfigValue sets v from a decoded YAML or JSON value. path names
+// the setting in errors.
+func decodeConfigValue(v reflect.Value, src any, path string) error {
+	if src == nil {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	fail := func(format string, args ...any) error {
+		if path == "" {
+			return fmt.Errorf(format, args...)
+		}
+		return fmt.Errorf("%s: %s", path, fmt.Sprintf(format, args...))
+	}
+
+	switch v.Type() {
+	case durationType:
+		s := scalarString(src)
+		if n, err := strconv.ParseFloat(s, 64); err == nil {
+			// A bare number is seconds
+			v.SetInt(int64(n * float64(time.Second)))
+			return nil
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fail("%q isn't a duration like 30s, 90m or 2h", s)
+		}
+		v.SetInt(int64(d))
+		return nil
+	case timeType:
+		s := scalarString(src)
+		for _, layout := range []string{"2006-01-02", time.RFC3339} {
+			if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+				v.Set(reflect.ValueOf(t))
+				return nil
+			}
+		}
+		return fail("%q isn't a date like 2025-01-31", s)
+	case cookiesType:
+		var header string
+		switch src := src.(type) {
+		case map[string]any:
+			var pairs []string
+			for name, value := range src {
+				pairs = append(pairs, name+"="+scalarString(value))
+			}
+			sort.Strings(pairs)
+			header = strings.Join(pairs, "; ")
+		default:
+			header = scalarString(src)
+		}
+		cookies, err := ParseCookies(header)
+		if err != nil {
+			return fail("%v", err)
+		}
+		v.Set(reflect.ValueOf(cookies))
+		return nil
+	}
+
+	if v.Kind() == reflect.Int && v.Type().Implements(stringerType) {
+		return decodeConfigEnum(v, src, fail)
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		m, ok := src.(map[string]any)
+		if !ok {
+			return fail("expected a group of settings")
+		}
+		fields := make(map[string]int)
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				fields[configKey(v.Type().Field(i).Name)] = i
+			}
+		}
+		// Sorted, so the first bad key is always the one reported
+		keys := make([]string, 0, len(m))
+		for key := range m {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			name := key
+			if path != "" {
+				name = path + "." + key
+			}
+			i, ok := fields[configKey(key)]
+			if !ok {
+				return fmt.Errorf("unknown setting %q", name)
+			}
+			field := v.Field(i)
+			if field.Kind() == reflect.Pointer || field.Kind() == reflect.Func || field.Kind() == reflect.Interface {
+				return fmt.Errorf("%s can't be set in a config file", name)
+			}
+			if err := decodeConfigValue(field, m[key], name); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.Slice:
+		list, ok := src.([]any)
+		if !ok {
+			// A single value stands for a list of one
+			list = []any{src}
+		}
+		out := reflect.MakeSlice(v.Type(), len(list), len(list))
+		for i, item := range list {
+			if err := decodeConfigValue(out.Index(i), item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		v.Set(out)
+		return nil
+
+	case reflect.Map:
+		m, ok := src.(map[string]any)
+		if !ok || v.Type().Key().Kind() != reflect.String {
+			return fail("expected name: value pairs")
+		}
+		out := reflect.MakeMapWithSize(v.Type(), len(m))
+		for key, item := range m {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := decodeConfigValue(elem, item, path+"."+key); err != nil {
+				return err
+			}
+			out.SetMapIndex(reflect.ValueOf(key), elem)
+		}
+		v.Set(out)
+		return nil
+
+	case reflect.String:
+		if _, ok := src.(map[string]any); ok {
+			return fail("expected a single value")
+		}
+		if str, ok := src.(string); ok {
+			// Kept as written: templates and scripts may need their spacing
+			v.SetString(str)
+		} else {
+			v.SetString(scalarString(src))
+		}
+		return nil
+
+	case reflect.Bool:
+		switch strings.ToLower(scalarString(src)) {
+		case "true", "yes", "on":
+			v.SetBool(true)
+		case "false", "no", "off":
+			v.SetBool(false)
+		default:
+			return fail("expected true or false")
+		}
+		return nil
+
+	case reflect.Int, reflect.Int64:
+		s := scalarString(src)
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil && v.Kind() == reflect.Int64 {
+			n, err = parseByteSize(s)
+		}
+		if err != nil {
+			return fail("%q isn't a whole number", s)
+		}
+		v.SetInt(n)
+		return nil
+
+	case reflect.Float64:
+		s := scalarString(src)
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fail("%q isn't a number", s)
+		}
+		v.SetFloat(f)
+		return nil
+	}
+	return fail("can't be set in a config file")
+}
+
+// decodeConfigEnum sets one of the typed int choices (SearchMode,
+// CaptureFormat, PaperSize, ...) from its number or its name as String
+// returns it, ignoring case, spaces, punctuation and any "(...)". A single
+// word of the name will do when no other choice has it ("basic" for
+// "HTTP Basic").
+func decodeConfigEnum(v reflect.Value, src any, fail func(string, ...any) error) error {
+	s := scalarString(src)
+	if n, err := strconv.Atoi(s); err == nil {
+		v.SetInt(int64(n))
+		return nil
+	}
+	if v.Type() == reflect.TypeOf(ModeSearchLink) {
+		if mode, ok := modeNames[strings.ToLower(s)]; ok {
+			v.SetInt(int64(mode))
+			return nil
+		}
+	}
+
+	want := configKey(s)
+	var names []string
+	seen := make(map[string]bool)
+	wordMatch := -1
+	for i := 0; i <= 32; i++ {
+		value := reflect.New(v.Type()).Elem()
+		value.SetInt(int64(i))
+		name := value.Interface().(fmt.Stringer).String()
+		if before, _, ok := strings.Cut(name, " ("); ok {
+			name = before
+		}
+		if seen[name] || name == "Unknown" {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+
+		if configKey(name) == want {
+			v.SetInt(int64(i))
+			return nil
+		}
+		for _, word := range strings.Fields(name) {
+			if configKey(word) == want {
+				if wordMatch == -1 {
+					wordMatch = i
+				} else {
+					wordMatch = -2
+				}
+				break
+			}
+		}
+	}
+	if wordMatch >= 0 {
+		v.SetInt(int64(wordMatch))
+		return nil
+	}
+	if v.Type() == reflect.TypeOf(ModeSearchLink) {
+		names = names[:0]
+		for name := range modeNames {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	return fail("%q isn't one of %s", s, strings.Join(names, ", "))
+}
+
+// encodeConfigValue converts a setting to the form decodeConfigValue reads,
+// or nil when it's the same as def, the value loading starts from. A false,
+// 0 or "" that differs from def is kept, or it would read back as def.
+func encodeConfigValue(v, def reflect.Value) any {
+	if reflect.DeepEqual(v.Interface(), def.Interface()) {
+		return nil
+	}
+	switch v.Type() {
+	case durationType:
+		return time.Duration(v.Int()).String()
+	case timeType:
+		return v.Interface().(time.Time).Format("2006-01-02")
+	case cookiesType:
+		var pairs []string
+		for _, cookie := range v.Interface().([]*http.Cookie) {
+			pairs = append(pairs, cookie.Name+"="+cookie.Value)
+		}
+		return strings.Join(pairs, "; ")
+	}
+	if v.Kind() == reflect.Int && v.Type().Implements(stringerType) {
+		name := v.Interface().(fmt.Stringer).String()
+		if before, _, ok := strings.Cut(name, " ("); ok {
+			name = before
+		}
+		if name == "Unknown" {
+			return v.Int()
+		}
+		return name
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		m := make(map[string]any)
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() || field.Type.Kind() == reflect.Pointer || field.Type.Kind() == reflect.Func {
+				continue
+			}
+			if value := encodeConfigValue(v.Field(i), def.Field(i)); value != nil {
+				m[snakeCase(field.Name)] = value
+			}
+		}
+		if len(m) == 0 {
+			return nil
+		}
+		return m
+	case reflect.Slice:
+		// Items and map values are decoded from zero, not from def
+		zero := reflect.Zero(v.Type().Elem())
+		list := make([]any, v.Len())
+		for i := range list {
+			list[i] = encodeConfigValue(v.Index(i), zero)
+		}
+		return list
+	case reflect.Map:
+		zero := reflect.Zero(v.Type().Elem())
+		m := make(map[string]any, v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			m[fmt.Sprint(iter.Key().Interface())] = encodeConfigValue(iter.Value(), zero)
+		}
+		return m
+	}
+	return v.Interface()
+}
+
+// snakeCase turns a field name into a file key: "StartURL" is "start_url",
+// "StartURLs" "start_urls" and "TTFBBudget" "ttfb_budget"
+func snakeCase(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		// A capital starts a word after a lower-case letter, or when it
+		// ends an acronym and begins a word ("TTFB|Budget", not "URL|s")
+		startsWord := i > 0 && unicode.IsLower(runes[i-1])
+		if i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1]) {
+			plural := runes[i+1] == 's' && (i+2 == len(runes) || unicode.IsUpper(runes[i+2]))
+			startsWord = startsWord || !plural
+		}
+		if unicode.IsUpper(r) && startsWord {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// scalarString returns a decoded scalar as text; JSON numbers and booleans
+// arrive typed
+func scalarString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// parseByteSize parses a size such as "500KB", "50MB" or "2 GB"
+func parseByteSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	for _, unit := range []struct {
+		suffix string
+		scale  int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if num, ok := strings.CutSuffix(s, unit.suffix); ok {
+			f, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+			if err != nil || f < 0 {
+				return 0, fmt.Errorf("invalid size %q", s)
+			}
+			return int64(f * float64(unit.scale)), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid size %q", s)
+}
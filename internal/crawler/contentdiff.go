@@ -0,0 +1,199 @@
+package crawler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"webcrawler/internal/parser"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// contentSnapshot is what content-diff mode saves of a crawl, so the next
+// crawl can be compared with it
+type contentSnapshot struct {
+	StartURL string                  `json:"start_url"`
+	Created  string                  `json:"created"`
+	Pages    map[string]snapshotPage `json:"pages"`
+}
+
+type snapshotPage struct {
+	Hash  string `json:"hash"` // SHA-256 of the page's text, or of the body when it has none
+	Title string `json:"title,omitempty"`
+	Text  string `json:"text,omitempty"` // only kept with Config.DiffStoreText
+
+	FoundOn string `json:"-"` // for this crawl's results; not saved
+}
+
+// loadSnapshot reads a snapshot saved by an earlier content-diff crawl
+func loadSnapshot(path string) (*contentSnapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var snap contentSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("%s is not a content snapshot: %v", path, err)
+	}
+	if snap.Pages == nil {
+		return nil, fmt.Errorf("%s is not a content snapshot: no pages", path)
+	}
+	return &snap, nil
+}
+
+// recordSnapshotPage hashes a fetched page's text for the snapshot. Pages are
+// compared by their readable text rather than their markup, so rotating
+// nonces, ad slots and cache-busting query strings don't show up as changes.
+func (c *Crawler) recordSnapshotPage(pageURL, foundOn, contentType string, page *htmlPage) {
+	body := page.body
+	var text, title string
+	switch {
+	case strings.Contains(contentType, "text/html"):
+		text = page.text()
+		title = page.title()
+	case strings.Contains(contentType, "application/pdf"):
+		text = parser.ExtractTextFromPDF(bytes.NewReader(body))
+	case strings.Contains(contentType, "application/vnd.openxmlformats-officedocument.wordprocessingml.document"):
+		text = parser.ExtractTextFromDocx(bytes.NewReader(body))
+	}
+	text = strings.Join(strings.Fields(text), " ")
+
+	sum := sha256.Sum256(body)
+	if text != "" {
+		sum = sha256.Sum256([]byte(text))
+	}
+	snapshot := snapshotPage{Hash: hex.EncodeToString(sum[:]), Title: title, FoundOn: foundOn}
+	if c.config.DiffStoreText {
+		snapshot.Text = text
+	}
+	c.snapshotPages.Store(pageURL, snapshot)
+}
+
+// title returns the text of the page's <title>
+func (p *htmlPage) title() string {
+	var find func(n *html.Node) *html.Node
+	find = func(n *html.Node) *html.Node {
+		if n.Type == html.ElementNode && n.DataAtom == atom.Title {
+			return n
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if found := find(child); found != nil {
+				return found
+			}
+		}
+		return nil
+	}
+	root := p.root()
+	if root == nil {
+		return ""
+	}
+	if title := find(root); title != nil && title.FirstChild != nil && title.FirstChild.Type == html.TextNode {
+		return strings.Join(strings.Fields(title.FirstChild.Data), " ")
+	}
+	return ""
+}
+
+// wordChanges counts the words added to and removed from a page's text,
+// ignoring where on the page they moved
+func wordChanges(oldText, newText string) (added, removed int) {
+	counts := make(map[string]int)
+	for _, w := range strings.Fields(strings.ToLower(oldText)) {
+		counts[w]--
+	}
+	for _, w := range strings.Fields(strings.ToLower(newText)) {
+		counts[w]++
+	}
+	for _, n := range counts {
+		if n > 0 {
+			added += n
+		} else {
+			removed -= n
+		}
+	}
+	return added, removed
+}
+
+// writeContentDiff saves this crawl's snapshot and, when there's a baseline,
+// writes one CSV row per added, removed or changed page. It runs once the
+// crawl has finished, since a page can only be known to be gone at the end.
+func (c *Crawler) writeContentDiff(complete bool) {
+	snap := contentSnapshot{
+		StartURL: c.config.StartURL,
+		Created:  time.Now().Format(time.RFC3339),
+		Pages:    make(map[string]snapshotPage),
+	}
+	c.snapshotPages.Range(func(key, value interface{}) bool {
+		snap.Pages[key.(string)] = value.(snapshotPage)
+		return true
+	})
+
+	if data, err := json.MarshalIndent(snap, "", "  "); err != nil {
+		c.log.Error(fmt.Sprintf("❌ Could not encode snapshot: %v", err), "error", err)
+	} else if err := os.WriteFile(c.snapshotFile, data, 0644); err != nil {
+		c.log.Error(fmt.Sprintf("❌ Could not save snapshot: %v", err), "path", c.snapshotFile, "error", err)
+	} else {
+		c.log.Info(fmt.Sprintf("📸 Snapshot of %d pages saved to %s", len(snap.Pages), c.snapshotFile), "path", c.snapshotFile, "pages", len(snap.Pages))
+	}
+
+	if c.baseline == nil {
+		return
+	}
+	if !complete {
+		c.log.Warn("⚠️  The crawl didn't finish - pages it never reached are reported as removed")
+	}
+
+	urls := make([]string, 0, len(snap.Pages)+len(c.baseline.Pages))
+	for u := range snap.Pages {
+		urls = append(urls, u)
+	}
+	for u := range c.baseline.Pages {
+		if _, ok := snap.Pages[u]; !ok {
+			urls = append(urls, u)
+		}
+	}
+	sort.Strings(urls)
+
+	now := time.Now().Format(time.RFC3339)
+	for _, u := range urls {
+		page, inNew := snap.Pages[u]
+		old, inOld := c.baseline.Pages[u]
+		if (!inOld || !inNew || page.Hash != old.Hash) && c.skipIgnored(ModeContentDiff, u) {
+			continue
+		}
+
+		var change, added, removed string
+		switch {
+		case !inOld:
+			change = "added"
+			atomic.AddInt64(&c.stats.PagesAdded, 1)
+		case !inNew:
+			change = "removed"
+			atomic.AddInt64(&c.stats.PagesRemoved, 1)
+		case page.Hash != old.Hash:
+			change = "changed"
+			atomic.AddInt64(&c.stats.PagesChanged, 1)
+			if c.config.DiffStoreText && old.Text != "" {
+				a, r := wordChanges(old.Text, page.Text)
+				added, removed = strconv.Itoa(a), strconv.Itoa(r)
+			}
+		default:
+			atomic.AddInt64(&c.stats.PagesUnchanged, 1)
+			continue
+		}
+
+		atomic.AddInt64(&c.stats.MatchesFound, 1)
+		c.log.Info(fmt.Sprintf("🆚 %s: %s", strings.ToUpper(change), u), "url", u, "change", change)
+		c.notifyFinding("page_"+change, u, "")
+		c.writeResult(ModeContentDiff, []string{u, page.FoundOn, change, page.Title, old.Title, added, removed, now})
+	}
+}
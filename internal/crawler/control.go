@@ -0,0 +1,125 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// runControl lets a running crawl be paused, resumed and stopped, by the
+// CLI's keyboard commands or a library caller. Pausing holds back new pages;
+// pages already being fetched finish. Stopping is like cancelling Run's
+// context, except that in-progress pages finish before the partial results
+// are written.
+type runControl struct {
+	mu          sync.Mutex
+	paused      bool
+	resumed     chan struct{} // closed when the crawl is resumed
+	pausedAt    time.Time
+	pausedFor   time.Duration // total time spent paused, which the time limit doesn't count
+	stopped     int32
+	stopWaiting chan struct{} // closed on stop, releasing paused pages
+}
+
+// Pause holds back new pages of the running crawl, in any mode, until
+// Resume. Pages already being fetched or captured finish, and time spent
+// paused doesn't count towards Config.MaxDuration.
+func (c *Crawler) Pause() {
+	if c.holdPages() {
+		c.log.Warn("⏸️  PAUSED - in-progress pages will finish")
+	}
+}
+
+// Resume lets new pages start again after Pause
+func (c *Crawler) Resume() {
+	if paused, ok := c.releasePages(); ok {
+		c.log.Warn(fmt.Sprintf("▶️  RESUMED after %s", formatDuration(paused)), "paused", paused.String())
+	}
+}
+
+// holdPages pauses the run, reporting false if it was already paused or is
+// stopping
+func (c *Crawler) holdPages() bool {
+	ctl := &c.control
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+	if ctl.paused || c.stopRequested() {
+		return false
+	}
+	ctl.paused = true
+	ctl.pausedAt = time.Now()
+	ctl.resumed = make(chan struct{})
+	return true
+}
+
+// releasePages ends a pause, returning how long it lasted, or false if the
+// run wasn't paused
+func (c *Crawler) releasePages() (time.Duration, bool) {
+	ctl := &c.control
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+	if !ctl.paused {
+		return 0, false
+	}
+	ctl.paused = false
+	paused := time.Since(ctl.pausedAt)
+	ctl.pausedFor += paused
+	close(ctl.resumed)
+	return paused, true
+}
+
+// Stop stops new pages from starting; Run then finishes the pages in
+// progress, writes the partial results and returns. It's safe to call from
+// any goroutine, and more than once.
+func (c *Crawler) Stop() {
+	ctl := &c.control
+	if !atomic.CompareAndSwapInt32(&ctl.stopped, 0, 1) {
+		return
+	}
+	close(ctl.stopWaiting)
+	c.log.Warn("⏹️  STOP REQUESTED - finishing in-progress pages, then saving results...")
+}
+
+// stopRequested reports whether Stop was called
+func (c *Crawler) stopRequested() bool {
+	return atomic.LoadInt32(&c.control.stopped) == 1
+}
+
+// proceed is called before each page is started. It waits while the run is
+// paused, and returns false if the page shouldn't start because the run was
+// stopped or ctx cancelled.
+func (c *Crawler) proceed(ctx context.Context) bool {
+	ctl := &c.control
+	ctl.mu.Lock()
+	paused, resumed := ctl.paused, ctl.resumed
+	ctl.mu.Unlock()
+
+	if paused {
+		select {
+		case <-resumed:
+		case <-ctl.stopWaiting:
+		case <-ctx.Done():
+		}
+	}
+	return ctx.Err() == nil && !c.stopRequested()
+}
+
+// pausedTime returns how long the run has spent paused so far
+func (c *Crawler) pausedTime() time.Duration {
+	ctl := &c.control
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+	total := ctl.pausedFor
+	if ctl.paused {
+		total += time.Since(ctl.pausedAt)
+	}
+	return total
+}
+
+// interrupted reports whether the run was cut short by cancelling its
+// context or by Stop
+func (c *Crawler) interrupted(ctx context.Context) bool {
+	return ctx.Err() != nil || c.stopRequested()
+}
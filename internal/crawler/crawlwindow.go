@@ -0,0 +1,106 @@
+package crawler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// crawlWindow is the time of day a crawl may start pages in, for
+// Config.CrawlWindow. The end can be earlier than the start for a window
+// that runs past midnight.
+type crawlWindow struct {
+	start, end int // minutes after local midnight
+}
+
+// parseCrawlWindow parses a window like "18:00-08:00"
+func parseCrawlWindow(s string) (crawlWindow, error) {
+	from, to, ok := strings.Cut(strings.TrimSpace(s), "-")
+	if !ok {
+		return crawlWindow{}, fmt.Errorf("invalid crawl window %q (expected HH:MM-HH:MM)", s)
+	}
+	var w crawlWindow
+	for _, part := range []struct {
+		text    string
+		minutes *int
+	}{{from, &w.start}, {to, &w.end}} {
+		t, err := time.Parse("15:04", strings.TrimSpace(part.text))
+		if err != nil {
+			return crawlWindow{}, fmt.Errorf("invalid crawl window %q (expected HH:MM-HH:MM)", s)
+		}
+		*part.minutes = t.Hour()*60 + t.Minute()
+	}
+	if w.start == w.end {
+		return crawlWindow{}, fmt.Errorf("crawl window %q is empty", s)
+	}
+	return w, nil
+}
+
+// ValidateCrawlWindow reports whether s is a window Config.CrawlWindow can
+// be set to
+func ValidateCrawlWindow(s string) error {
+	_, err := parseCrawlWindow(s)
+	return err
+}
+
+// contains reports whether t falls inside the window
+func (w crawlWindow) contains(t time.Time) bool {
+	m := t.Hour()*60 + t.Minute()
+	if w.start < w.end {
+		return m >= w.start && m < w.end
+	}
+	return m >= w.start || m < w.end
+}
+
+func (w crawlWindow) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", w.start/60, w.start%60, w.end/60, w.end%60)
+}
+
+// opens returns when the window next opens after t
+func (w crawlWindow) opens(t time.Time) time.Time {
+	open := time.Date(t.Year(), t.Month(), t.Day(), w.start/60, w.start%60, 0, 0, t.Location())
+	if !open.After(t) {
+		open = open.AddDate(0, 0, 1)
+	}
+	return open
+}
+
+// watchCrawlWindow pauses the run while it's outside Config.CrawlWindow and
+// resumes it when the window opens, until stop is closed. The first check
+// is made before it returns, so a run started outside the window doesn't
+// fetch anything. Time spent waiting doesn't count towards the time limit,
+// and typing r resumes the run early.
+func (c *Crawler) watchCrawlWindow(w crawlWindow, stop <-chan struct{}) {
+	waiting := false
+	check := func() {
+		now := time.Now()
+		switch inside := w.contains(now); {
+		case !inside && !waiting:
+			if c.holdPages() {
+				waiting = true
+				opens := w.opens(now)
+				c.log.Warn(fmt.Sprintf("🌙 Outside the crawl window (%s) - waiting until %s; in-progress pages will finish", w, opens.Format("Mon 15:04")),
+					"window", w.String(), "opens", opens)
+			}
+		case inside && waiting:
+			waiting = false
+			if waited, ok := c.releasePages(); ok {
+				c.log.Warn(fmt.Sprintf("☀️  Crawl window (%s) open - resuming after %s", w, formatDuration(waited)), "window", w.String(), "waited", waited.String())
+			}
+		}
+	}
+
+	check()
+	go func() {
+		ticker := time.NewTicker(15 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				check()
+			}
+		}
+	}()
+}
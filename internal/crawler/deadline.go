@@ -0,0 +1,41 @@
+package crawler
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// defaultRequestTimeout limits page fetches when Config.RequestTimeout is unset
+const defaultRequestTimeout = 30 * time.Second
+
+// requestTimeout returns the timeout for a single page or image request
+func (c *Crawler) requestTimeout() time.Duration {
+	if c.config.RequestTimeout > 0 {
+		return c.config.RequestTimeout
+	}
+	return defaultRequestTimeout
+}
+
+// outOfTime reports whether Config.MaxDuration has run out. Once it has, no
+// new pages are started, but pages already being fetched are allowed to
+// finish so their results make it into the output. Time spent paused
+// doesn't count.
+func (c *Crawler) outOfTime() bool {
+	if c.config.MaxDuration <= 0 || time.Since(c.startTime)-c.pausedTime() < c.config.MaxDuration {
+		return false
+	}
+	if atomic.CompareAndSwapInt32(&c.timedOut, 0, 1) {
+		c.log.Warn(fmt.Sprintf("⌛ Time limit of %s reached - finishing in-progress pages...", formatDuration(c.config.MaxDuration)), "limit", c.config.MaxDuration.String())
+	}
+	return true
+}
+
+// coverage returns the percentage of discovered pages that were crawled
+// before the time limit stopped the crawl
+func coverage(crawled, skipped int64) float64 {
+	if crawled+skipped == 0 {
+		return 100
+	}
+	return float64(crawled) / float64(crawled+skipped) * 100
+}
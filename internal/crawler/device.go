@@ -0,0 +1,208 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+// DevicePreset is the screen pages are rendered on for captures
+type DevicePreset int
+
+const (
+	DeviceDesktop DevicePreset = iota + 1
+	DeviceLaptop
+	DeviceIPad
+	DeviceIPhone
+	DeviceAndroid
+)
+
+func (d DevicePreset) String() string {
+	switch d {
+	case DeviceDesktop:
+		return "Desktop"
+	case DeviceLaptop:
+		return "Laptop"
+	case DeviceIPad:
+		return "iPad"
+	case DeviceIPhone:
+		return "iPhone"
+	case DeviceAndroid:
+		return "Android phone"
+	default:
+		return "Desktop"
+	}
+}
+
+// deviceSpec is the viewport in CSS pixels, pixel ratio and browser a device
+// preset emulates
+type deviceSpec struct {
+	width     int64
+	height    int64
+	scale     float64
+	mobile    bool
+	userAgent string // "" keeps Chrome's own
+}
+
+var deviceSpecs = map[DevicePreset]deviceSpec{
+	DeviceDesktop: {width: 1920, height: 1080, scale: 1},
+	DeviceLaptop:  {width: 1366, height: 768, scale: 1},
+	DeviceIPad: {width: 820, height: 1180, scale: 2, mobile: true,
+		userAgent: "Mozilla/5.0 (iPad; CPU OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Mobile/15E148 Safari/604.1"},
+	DeviceIPhone: {width: 390, height: 844, scale: 3, mobile: true,
+		userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Mobile/15E148 Safari/604.1"},
+	DeviceAndroid: {width: 412, height: 915, scale: 2.625, mobile: true,
+		userAgent: "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Mobile Safari/537.36"},
+}
+
+// device returns the preset with any viewport or user agent overrides
+func (o CaptureOptions) device() deviceSpec {
+	spec, ok := deviceSpecs[o.Device]
+	if !ok {
+		spec = deviceSpecs[DeviceDesktop]
+	}
+	if o.ViewportWidth > 0 {
+		spec.width = o.ViewportWidth
+	}
+	if o.ViewportHeight > 0 {
+		spec.height = o.ViewportHeight
+	}
+	if o.UserAgent != "" {
+		spec.userAgent = o.UserAgent
+	}
+	return spec
+}
+
+// emulate switches a tab to the capture device. It runs before navigating,
+// so the site sees the device's user agent and the page's first layout is
+// at the device's width.
+func (o CaptureOptions) emulate() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		spec := o.device()
+		err := emulation.SetDeviceMetricsOverride(spec.width, spec.height, spec.scale, spec.mobile).Do(ctx)
+		if err != nil {
+			return err
+		}
+		if spec.mobile {
+			if err := emulation.SetTouchEmulationEnabled(true).WithMaxTouchPoints(5).Do(ctx); err != nil {
+				return err
+			}
+		}
+		if spec.userAgent != "" {
+			return emulation.SetUserAgentOverride(spec.userAgent).Do(ctx)
+		}
+		return nil
+	})
+}
+
+// screenshot captures the whole page into buf, at the device's width and
+// pixel ratio, and sets format to the format it was saved in
+func (o CaptureOptions) screenshot(pageURL string, buf *[]byte, format *ImageFormat, log *slog.Logger) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		_, _, contentSize, _, _, _, err := page.GetLayoutMetrics().Do(ctx)
+		if err != nil {
+			return err
+		}
+
+		// A mobile page that overflows sideways is still shot at the device
+		// width, as widening the viewport would switch it to another layout
+		spec := o.device()
+		width, height := int64(contentSize.Width), int64(contentSize.Height)
+		if spec.mobile {
+			width = spec.width
+		}
+
+		out := o.imageOutput(float64(width) * spec.scale)
+		var captured int64
+		*buf, *format, captured, err = captureFullPage(ctx, width, height, spec.scale, spec.mobile, out)
+		if err != nil {
+			return err
+		}
+		if captured < height {
+			log.Warn(fmt.Sprintf("✂️  Screenshot cut off at %d of %d px: %s", captured, height, pageURL), "url", pageURL, "height", height)
+		}
+		if *format != out.format {
+			log.Warn(fmt.Sprintf("⚠️  Page too tall for %s, saved as %s: %s", out.format, *format, pageURL), "url", pageURL, "height", height)
+		}
+		return nil
+	})
+}
+
+// snapshotMHTML saves the rendered page into buf as one MHTML file, with the
+// style sheets, images and fonts it uses embedded, so it opens offline in
+// Chrome or Edge as it looked when captured
+func snapshotMHTML(buf *string) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+		*buf, err = page.CaptureSnapshot().WithFormat(page.CaptureSnapshotFormatMhtml).Do(ctx)
+		return err
+	})
+}
+
+// imageOutput is the screenshot encoding the options ask for. width is the
+// page's width in device pixels, for ImageMaxWidth.
+func (o CaptureOptions) imageOutput(width float64) imageOutput {
+	out := imageOutput{format: o.ImageFormat, quality: o.ImageQuality, scale: o.ImageScale}
+	if out.format == 0 {
+		out.format = ImagePNG
+	}
+	if out.quality <= 0 || out.quality > 100 {
+		out.quality = defaultImageQuality
+	}
+	if out.scale <= 0 {
+		out.scale = 1
+	}
+	if o.ImageMaxWidth > 0 && width*out.scale > float64(o.ImageMaxWidth) {
+		out.scale = float64(o.ImageMaxWidth) / width
+	}
+	return out
+}
+
+// imageLabel describes how screenshots are saved, e.g. "JPEG 85%, max 1200px wide"
+func (o CaptureOptions) imageLabel() string {
+	out := o.imageOutput(0)
+	label := out.format.String()
+	if out.format != ImagePNG {
+		label += fmt.Sprintf(" %d%%", out.quality)
+	}
+	if out.scale != 1 {
+		label += fmt.Sprintf(", scaled %g%%", out.scale*100)
+	}
+	if o.ImageMaxWidth > 0 {
+		label += fmt.Sprintf(", max %dpx wide", o.ImageMaxWidth)
+	}
+	return label
+}
+
+// deviceLabel describes the capture device, e.g. "iPhone (390x844)"
+func (o CaptureOptions) deviceLabel() string {
+	spec := o.device()
+	label := fmt.Sprintf("%s (%dx%d)", o.Device, spec.width, spec.height)
+	if o.UserAgent != "" {
+		label += ", custom user agent"
+	}
+	return label
+}
+
+// ParseViewport parses a viewport size in CSS pixels such as "1440x900"
+func ParseViewport(s string) (width, height int64, err error) {
+	w, h, ok := strings.Cut(strings.ToLower(strings.ReplaceAll(s, "×", "x")), "x")
+	if !ok {
+		return 0, 0, fmt.Errorf("viewport must look like 1440x900")
+	}
+	width, err1 := strconv.ParseInt(strings.TrimSpace(w), 10, 64)
+	height, err2 := strconv.ParseInt(strings.TrimSpace(h), 10, 64)
+	if err1 != nil || err2 != nil {
+		return 0, 0, fmt.Errorf("viewport must look like 1440x900")
+	}
+	if width < 200 || height < 200 || width > 7680 || height > 7680 {
+		return 0, 0, fmt.Errorf("viewport must be between 200 and 7680 pixels each way")
+	}
+	return width, height, nil
+}
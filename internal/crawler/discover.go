@@ -0,0 +1,160 @@
+package crawler
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// Reasons discover mode gives for a URL that wasn't crawled
+const (
+	skipExternal  = "external host"
+	skipPattern   = "include/exclude pattern"
+	skipPath      = "outside path filter"
+	skipRobots    = "robots.txt"
+	skipDepth     = "max depth"
+	skipPageLimit = "page limit"
+	skipTimeLimit = "time limit"
+	skipBudget    = "download budget"
+	skipQueueFull = "queue full"
+	skipDuplicate = "same page as a crawled URL"
+)
+
+// discoveredURL is a URL discover mode found, with where it was first found
+// and what became of it
+type discoveredURL struct {
+	mu           sync.Mutex
+	depth        int
+	foundOn      string
+	inScope      bool
+	crawled      bool
+	reason       string // why it wasn't crawled
+	status       int
+	contentType  string
+	redirectedTo string
+	errMsg       string
+}
+
+// tracksURLs reports whether the crawl keeps a record of every URL it finds:
+// discover mode lists them, and orphan mode compares them with the sitemap
+func (c *Crawler) tracksURLs() bool {
+	return c.config.Mode == ModeDiscover || c.config.Mode == ModeOrphans
+}
+
+// noteLink records a link found on foundOn ("" for a start URL). A URL found
+// from several pages keeps the shallowest, then alphabetically first, so the
+// listing doesn't depend on the order pages happened to be fetched in.
+func (c *Crawler) noteLink(link, foundOn string, depth int) {
+	if !c.tracksURLs() {
+		return
+	}
+	value, loaded := c.discovered.LoadOrStore(link, &discoveredURL{depth: depth, foundOn: foundOn, inScope: true})
+	if !loaded {
+		atomic.AddInt64(&c.stats.URLsDiscovered, 1)
+		return
+	}
+	d := value.(*discoveredURL)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch {
+	case depth < d.depth:
+		d.depth, d.foundOn = depth, foundOn
+	case depth == d.depth && foundOn != "" && (d.foundOn == "" || foundOn < d.foundOn):
+		d.foundOn = foundOn
+	}
+}
+
+// noteSkipped records why a discovered link isn't being crawled. A link that
+// is crawled after all, e.g. found again nearer the start, loses its reason.
+func (c *Crawler) noteSkipped(link, reason string) {
+	if !c.tracksURLs() {
+		return
+	}
+	value, ok := c.discovered.Load(link)
+	if !ok {
+		return
+	}
+	d := value.(*discoveredURL)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if reason == skipExternal {
+		d.inScope = false
+	}
+	if !d.crawled && d.reason == "" {
+		d.reason = reason
+	}
+}
+
+// noteCrawled records that a discovered link's page is being fetched
+func (c *Crawler) noteCrawled(link string) {
+	if !c.tracksURLs() {
+		return
+	}
+	if value, ok := c.discovered.Load(link); ok {
+		d := value.(*discoveredURL)
+		d.mu.Lock()
+		d.crawled, d.reason = true, ""
+		d.mu.Unlock()
+	}
+}
+
+// noteFetch records the response to a discovered page's last fetch attempt
+func (c *Crawler) noteFetch(f fetchRecord) {
+	if !c.tracksURLs() {
+		return
+	}
+	value, ok := c.discovered.Load(f.URL)
+	if !ok {
+		return
+	}
+	d := value.(*discoveredURL)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.status, d.contentType, d.redirectedTo, d.errMsg = f.Status, f.ContentType, f.RedirectedTo, ""
+	if f.Err != nil {
+		d.errMsg = f.Err.Error()
+	}
+}
+
+// writeDiscovered writes every URL discover mode found, by depth and then
+// URL. There's no timestamp column, so two runs over an unchanged site give
+// the same file.
+func (c *Crawler) writeDiscovered() {
+	type row struct {
+		link string
+		d    *discoveredURL
+	}
+	var rows []row
+	c.discovered.Range(func(key, value any) bool {
+		rows = append(rows, row{key.(string), value.(*discoveredURL)})
+		return true
+	})
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].d.depth != rows[j].d.depth {
+			return rows[i].d.depth < rows[j].d.depth
+		}
+		return rows[i].link < rows[j].link
+	})
+
+	yesNo := map[bool]string{true: "yes", false: "no"}
+	for _, r := range rows {
+		if c.skipIgnored(ModeDiscover, r.link) {
+			continue
+		}
+		d := r.d
+		d.mu.Lock()
+		status := ""
+		if d.status != 0 {
+			status = strconv.Itoa(d.status)
+		}
+		if d.inScope {
+			atomic.AddInt64(&c.stats.URLsInScope, 1)
+		}
+		c.writeResult(ModeDiscover, []string{
+			r.link, strconv.Itoa(d.depth), d.foundOn, yesNo[d.inScope], yesNo[d.crawled], d.reason,
+			status, d.contentType, d.redirectedTo, d.errMsg,
+		})
+		d.mu.Unlock()
+	}
+}
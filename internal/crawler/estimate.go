@@ -0,0 +1,140 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// browserPageTime is the least a page loaded in Chrome takes, for estimates
+// of page capture and JavaScript-rendered crawls
+const browserPageTime = 3 * time.Second
+
+// SiteEstimate is a rough size and length of a crawl, from a look at the
+// site's sitemap and start page before it starts. Pages reached only through
+// deeper links, and the time spent checking images and external links, aren't
+// counted, so a large site can take longer.
+type SiteEstimate struct {
+	SitemapPages int           // pages the sitemap lists within the crawl's scope and filters (0 = no sitemap)
+	StartLinks   int           // distinct on-site links on the start page
+	Pages        int           // pages the crawl is expected to fetch, at most Config.MaxPages
+	AtLeast      bool          // Pages is only a lower bound, as there was no sitemap to count
+	PageTime     time.Duration // how long a page takes, from the start page
+	Rate         float64       // pages per second the concurrency, rate limit and delays allow
+	Duration     time.Duration // how long Pages take at Rate
+	TimeLimited  bool          // Config.MaxDuration runs out first
+}
+
+// Estimate probes the site's sitemap and start page to estimate how many
+// pages the crawl will fetch and how long it will take with its settings. It
+// doesn't log in, so a site behind a login may look smaller than it is.
+func (c *Crawler) Estimate(ctx context.Context) (SiteEstimate, error) {
+	var e SiteEstimate
+	cfg := c.config
+
+	// The sitemap, counted as the crawl would follow it
+	if urls, err := c.loadSitemapSeeds(ctx, cfg.StartURL, cfg.SitemapSeedURL); err == nil {
+		seen := make(map[string]bool)
+		for _, link := range urls {
+			u, err := url.Parse(link)
+			if err != nil || !c.scope.contains(u) || !underPath(u, cfg.PathFilter) || !c.filter.allowed(u) {
+				continue
+			}
+			seen[c.getVisitedKey(link)] = true
+		}
+		e.SitemapPages = len(seen)
+	}
+
+	start := time.Now()
+	status, final, body := c.fetchLinked(ctx, cfg.StartURL, "text/html")
+	if status == 0 {
+		return e, fmt.Errorf("could not reach %s", cfg.StartURL)
+	}
+	if status >= 400 {
+		return e, fmt.Errorf("%s answered %d", cfg.StartURL, status)
+	}
+	e.PageTime = time.Since(start)
+	e.StartLinks = c.countStartLinks(body, final)
+
+	e.Pages = e.StartLinks + 1
+	if e.SitemapPages > e.Pages {
+		e.Pages = e.SitemapPages
+	}
+	e.AtLeast = e.SitemapPages == 0
+	if cfg.MaxPages > 0 && e.Pages > cfg.MaxPages {
+		e.Pages, e.AtLeast = cfg.MaxPages, false
+	}
+
+	pageTime := e.PageTime + cfg.RequestJitter/2
+	if (cfg.RenderJS || cfg.Mode == ModePDFCapture) && pageTime < browserPageTime {
+		pageTime = browserPageTime
+	}
+	e.Rate = float64(cfg.MaxConcurrency) / pageTime.Seconds()
+	if cfg.RequestsPerSecond > 0 && cfg.RequestsPerSecond < e.Rate {
+		e.Rate = cfg.RequestsPerSecond
+	}
+	delay := cfg.HostDelay
+	if u, err := url.Parse(cfg.StartURL); err == nil && !cfg.IgnoreRobots {
+		if rules := c.robots.rules(ctx, u); rules != nil && rules.crawlDelay > delay {
+			delay = rules.crawlDelay
+		}
+	}
+	if delay > 0 && 1/delay.Seconds() < e.Rate {
+		e.Rate = 1 / delay.Seconds()
+	}
+
+	e.Duration = time.Duration(float64(e.Pages) / e.Rate * float64(time.Second))
+	e.TimeLimited = cfg.MaxDuration > 0 && e.Duration > cfg.MaxDuration
+	return e, nil
+}
+
+// countStartLinks counts the distinct links on the start page the crawl would
+// follow
+func (c *Crawler) countStartLinks(body []byte, base *url.URL) int {
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil || base == nil {
+		return 0
+	}
+	seen := make(map[string]bool)
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if href := linkTarget(n); href != "" {
+				if u, err := base.Parse(href); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+					u.Fragment, u.RawFragment = "", ""
+					if c.scope.contains(u) && underPath(u, c.config.PathFilter) && c.filter.allowed(u) {
+						seen[c.getVisitedKey(u.String())] = true
+					}
+				}
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			f(child)
+		}
+	}
+	f(doc)
+	delete(seen, c.getVisitedKey(base.String()))
+	return len(seen)
+}
+
+// Describe returns the estimate's page count and duration as the wizard's
+// launch summary shows them
+func (e SiteEstimate) Describe() (pages, duration string) {
+	switch {
+	case e.AtLeast:
+		pages = fmt.Sprintf("at least %d (no sitemap)", e.Pages)
+	case e.SitemapPages > 0:
+		pages = fmt.Sprintf("~%d (sitemap lists %d)", e.Pages, e.SitemapPages)
+	default:
+		pages = fmt.Sprintf("~%d", e.Pages)
+	}
+	duration = fmt.Sprintf("~%s at %.1f pages/s", formatDuration(e.Duration), e.Rate)
+	if e.TimeLimited {
+		duration = fmt.Sprintf("~%s - over the time limit", formatDuration(e.Duration))
+	}
+	return pages, duration
+}
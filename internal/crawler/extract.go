@@ -0,0 +1,190 @@
+package crawler
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// ExtractField maps a CSS selector or XPath expression to a column of the
+// extraction results
+type ExtractField struct {
+	Name     string
+	Selector string // CSS selector, optionally ending in @attr; XPath when it starts with /
+}
+
+// fieldExtractor is a compiled ExtractField
+type fieldExtractor struct {
+	name  string
+	css   cssSelector
+	attr  string // read this attribute of each CSS match instead of its text
+	xpath xpathExpr
+}
+
+// ParseExtractFields parses one "selector → field" mapping per line, such as
+//
+//	h1 → title
+//	.price -> price
+//	meta[name=description]@content -> description
+//	//a[@rel='author']/text() -> author
+//
+// "->" may be used in place of "→". A CSS selector ending in @attr extracts
+// that attribute from the matched elements instead of their text.
+func ParseExtractFields(s string) ([]ExtractField, error) {
+	var fields []ExtractField
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		i := strings.LastIndex(line, "→")
+		sep := len("→")
+		if j := strings.LastIndex(line, "->"); j > i {
+			i, sep = j, len("->")
+		}
+		if i < 0 {
+			return nil, fmt.Errorf("mapping %q must look like selector → field", line)
+		}
+		field := ExtractField{
+			Name:     strings.TrimSpace(line[i+sep:]),
+			Selector: strings.TrimSpace(line[:i]),
+		}
+		if field.Name == "" || field.Selector == "" {
+			return nil, fmt.Errorf("mapping %q must look like selector → field", line)
+		}
+		if seen[strings.ToLower(field.Name)] {
+			return nil, fmt.Errorf("field %q is mapped twice", field.Name)
+		}
+		seen[strings.ToLower(field.Name)] = true
+
+		if _, err := compileExtractField(field); err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+func compileExtractField(field ExtractField) (fieldExtractor, error) {
+	e := fieldExtractor{name: field.Name}
+	sel := strings.TrimSpace(field.Selector)
+
+	var err error
+	if strings.HasPrefix(sel, "/") {
+		e.xpath, err = compileXPath(sel)
+		return e, err
+	}
+
+	// A trailing @attr picks an attribute; an @ inside [...] is part of
+	// an attribute selector's value
+	if i := strings.LastIndex(sel, "@"); i > 0 && !strings.ContainsAny(sel[i:], "]\"'") {
+		e.attr = strings.ToLower(strings.TrimSpace(sel[i+1:]))
+		sel = sel[:i]
+		if e.attr == "" {
+			return e, fmt.Errorf("missing attribute name after @ in %q", field.Selector)
+		}
+	}
+	e.css, err = compileCSS(sel)
+	return e, err
+}
+
+// compileExtractFields compiles Config.ExtractFields for the crawl
+func compileExtractFields(fields []ExtractField) ([]fieldExtractor, error) {
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no fields to extract")
+	}
+	extractors := make([]fieldExtractor, 0, len(fields))
+	for _, field := range fields {
+		e, err := compileExtractField(field)
+		if err != nil {
+			return nil, err
+		}
+		extractors = append(extractors, e)
+	}
+	return extractors, nil
+}
+
+// values returns the whitespace-collapsed text or attribute value of each
+// match. href and src values are resolved against the page URL.
+func (e fieldExtractor) values(doc *html.Node, base *url.URL) []string {
+	var raw []string
+	attr := e.attr
+	if e.xpath != nil {
+		raw = e.xpath.values(doc)
+		if last := e.xpath[len(e.xpath)-1]; last[len(last)-1].kind == xpathAttr {
+			attr = last[len(last)-1].name
+		}
+	} else {
+		for _, n := range e.css.matchAll(doc) {
+			if e.attr == "" {
+				raw = append(raw, nodeText(n))
+			} else if v, ok := attrLookup(n, e.attr); ok {
+				raw = append(raw, v)
+			}
+		}
+	}
+
+	values := make([]string, 0, len(raw))
+	for _, v := range raw {
+		v = strings.Join(strings.Fields(v), " ")
+		if v == "" {
+			continue
+		}
+		if attr == "href" || attr == "src" {
+			if u, err := url.Parse(v); err == nil {
+				v = base.ResolveReference(u).String()
+			}
+		}
+		values = append(values, v)
+	}
+	return values
+}
+
+// extractFieldsHeader is the results CSV header: the page URL and the page it
+// was found on, one column per field, and the time it was scraped
+func extractFieldsHeader(fields []ExtractField) []string {
+	header := []string{"URL", "FoundOn"}
+	for _, field := range fields {
+		header = append(header, field.Name)
+	}
+	return append(header, "Timestamp")
+}
+
+// processExtraction writes one CSV row for the page with the values of each
+// field. Several matches for a field are joined with " | ".
+func (c *Crawler) processExtraction(pageURL, foundOn string, doc *html.Node) {
+	if c.skipIgnored(ModeExtract, pageURL) || doc == nil {
+		return
+	}
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return
+	}
+
+	row := []string{pageURL, foundOn}
+	found := 0
+	for _, e := range c.extractors {
+		values := e.values(doc, base)
+		if len(values) > 0 {
+			found++
+		}
+		row = append(row, strings.Join(values, " | "))
+	}
+	row = append(row, time.Now().Format(time.RFC3339))
+
+	if found == 0 {
+		atomic.AddInt64(&c.stats.ExtractEmptyPages, 1)
+		c.log.Debug(fmt.Sprintf("   🧲 No fields matched: %s", pageURL), "url", pageURL)
+	} else {
+		atomic.AddInt64(&c.stats.MatchesFound, 1)
+		c.log.Info(fmt.Sprintf("🧲 EXTRACTED %d/%d fields: %s", found, len(c.extractors), pageURL), "url", pageURL, "fields", found)
+	}
+
+	c.writeResult(ModeExtract, row)
+}
@@ -0,0 +1,96 @@
+package crawler
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// feedDateLayouts are the date formats feeds commonly use, tried in order
+var feedDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02",
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"Mon, 2 Jan 2006 15:04 -0700",
+	"Mon, 2 Jan 2006",
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006",
+	"2 January 2006",
+	"January 2, 2006 3:04 PM",
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"Jan. 2, 2006",
+	"Monday, January 2, 2006",
+	"01/02/2006",
+	"1/2/2006",
+}
+
+// parseFeedDate parses a feed item's date in any of feedDateLayouts, or as
+// a Unix timestamp in seconds or milliseconds
+func parseFeedDate(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		switch len(value) {
+		case 10:
+			return time.Unix(n, 0).UTC(), true
+		case 13:
+			return time.UnixMilli(n).UTC(), true
+		}
+		return time.Time{}, false
+	}
+	for _, layout := range feedDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// calendarDay is t's date, in t's own time zone, at midnight UTC
+func calendarDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// hasDateRange reports whether DateFrom or DateTo is set
+func (o JSONFeedOptions) hasDateRange() bool {
+	return !o.DateFrom.IsZero() || !o.DateTo.IsZero()
+}
+
+// inDateRange reports whether an item dated t falls within DateFrom and
+// DateTo. Only the days are compared, each in its own time zone, so an
+// article is kept when the date it shows is in the range.
+func (o JSONFeedOptions) inDateRange(t time.Time) bool {
+	day := calendarDay(t)
+	if !o.DateFrom.IsZero() && day.Before(calendarDay(o.DateFrom)) {
+		return false
+	}
+	if !o.DateTo.IsZero() && day.After(calendarDay(o.DateTo)) {
+		return false
+	}
+	return true
+}
+
+// dateRangeLabel describes DateFrom and DateTo for the console
+func (o JSONFeedOptions) dateRangeLabel() string {
+	switch {
+	case o.DateFrom.IsZero():
+		return "up to " + o.DateTo.Format("2006-01-02")
+	case o.DateTo.IsZero():
+		return "from " + o.DateFrom.Format("2006-01-02")
+	default:
+		return o.DateFrom.Format("2006-01-02") + " to " + o.DateTo.Format("2006-01-02")
+	}
+}
@@ -0,0 +1,265 @@
+package crawler
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// defaultMaxFeedPages caps how many pages of a paginated feed are fetched
+const defaultMaxFeedPages = 100
+
+// FeedPaging is how further pages of a JSON feed are requested
+type FeedPaging int
+
+const (
+	FeedPagingPage     FeedPaging = iota + 1 // ?page=1, ?page=2, ...
+	FeedPagingOffset                         // ?offset=0, then advanced by the number of items received
+	FeedPagingNextLink                       // Each response links to the next page
+	FeedPagingCursor                         // Each response has a token that's sent back for the next page
+)
+
+func (p FeedPaging) String() string {
+	switch p {
+	case FeedPagingPage:
+		return "Page number"
+	case FeedPagingOffset:
+		return "Offset"
+	case FeedPagingNextLink:
+		return "Next-page link"
+	case FeedPagingCursor:
+		return "Cursor"
+	default:
+		return "None"
+	}
+}
+
+// param is the query parameter the paging scheme uses by default
+func (p FeedPaging) param() string {
+	switch p {
+	case FeedPagingOffset:
+		return "offset"
+	case FeedPagingCursor:
+		return "cursor"
+	default:
+		return "page"
+	}
+}
+
+// feedItemKeys are where wrapped feeds commonly keep their item array,
+// checked in order when JSONFeedOptions.ItemsPath isn't set
+var feedItemKeys = []string{"items", "data", "results", "entries", "posts", "articles", "news", "records", "hits", "value", "docs"}
+
+// feedNextKeys are where feeds commonly put the next page's URL
+var feedNextKeys = []string{"next", "links.next", "_links.next.href", "next_page_url", "nextPage", "paging.next", "pagination.next", "meta.next", "@odata.nextLink"}
+
+// feedCursorKeys are where feeds commonly put the next page's cursor
+var feedCursorKeys = []string{"next_cursor", "nextCursor", "cursor", "meta.next_cursor", "meta.nextCursor", "pagination.next_cursor", "response_metadata.next_cursor", "paging.cursors.after"}
+
+// feedItems finds the item array in a decoded feed page: the page itself
+// when it's an array, the array at path when that's set, or else the first
+// array of objects under one of feedItemKeys, up to two levels down (so
+// {"data": {"results": [...]}} works too)
+func feedItems(doc any, path string) ([]map[string]any, error) {
+	var list []any
+	if path != "" {
+		v, ok := jsonPath(doc, path)
+		if !ok {
+			return nil, fmt.Errorf("feed has no %q", path)
+		}
+		if list, ok = v.([]any); !ok {
+			return nil, fmt.Errorf("%q in the feed isn't a list", path)
+		}
+	} else if l, ok := doc.([]any); ok {
+		list = l
+	} else if l := findFeedList(doc, 2); l != nil {
+		list = l
+	} else {
+		return nil, fmt.Errorf("feed isn't a list and has no list of items under %s - set the items path", strings.Join(feedItemKeys[:4], ", "))
+	}
+
+	items := make([]map[string]any, 0, len(list))
+	for _, v := range list {
+		if m, ok := v.(map[string]any); ok {
+			items = append(items, m)
+		}
+	}
+	return items, nil
+}
+
+// findFeedList looks for an array of objects under feedItemKeys, going
+// down at most depth objects
+func findFeedList(v any, depth int) []any {
+	obj, ok := v.(map[string]any)
+	if !ok || depth == 0 {
+		return nil
+	}
+	for _, key := range feedItemKeys {
+		switch child := obj[key].(type) {
+		case []any:
+			if len(child) == 0 {
+				return child
+			}
+			if _, ok := child[0].(map[string]any); ok {
+				return child
+			}
+		case map[string]any:
+			if l := findFeedList(child, depth-1); l != nil {
+				return l
+			}
+		}
+	}
+	return nil
+}
+
+// jsonPath looks up a dotted path such as "meta.next_cursor" in decoded JSON.
+// Numeric parts index into arrays.
+func jsonPath(v any, path string) (any, bool) {
+	for _, key := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]any:
+			child, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+			v = child
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			v = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// feedString returns the first non-empty string or number found at one of
+// paths, or at field if that's set
+func feedString(doc any, field string, paths []string) string {
+	if field != "" {
+		paths = []string{field}
+	}
+	for _, path := range paths {
+		v, ok := jsonPath(doc, path)
+		if !ok || v == nil {
+			continue
+		}
+		switch v.(type) {
+		case string, float64:
+			if s := toString(v); s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// nextFeedPage returns the URL of the page after pageURL, or "" when there
+// isn't one. doc is the decoded page, header its response headers and
+// count the number of items it held.
+func nextFeedPage(opts JSONFeedOptions, pageURL string, doc any, header http.Header, count int) string {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return ""
+	}
+	param := opts.PageParam
+	if param == "" {
+		param = opts.Paging.param()
+	}
+
+	switch opts.Paging {
+	case FeedPagingPage, FeedPagingOffset:
+		if count == 0 {
+			return ""
+		}
+		q := u.Query()
+		n, _ := strconv.Atoi(q.Get(param))
+		if opts.Paging == FeedPagingPage {
+			n++
+		} else {
+			n += count
+		}
+		q.Set(param, strconv.Itoa(n))
+		u.RawQuery = q.Encode()
+		return u.String()
+
+	case FeedPagingNextLink:
+		next := feedString(doc, opts.NextField, feedNextKeys)
+		if next == "" {
+			next = linkHeaderNext(header)
+		}
+		if next == "" {
+			return ""
+		}
+		ref, err := u.Parse(next)
+		if err != nil {
+			return ""
+		}
+		return ref.String()
+
+	case FeedPagingCursor:
+		cursor := feedString(doc, opts.NextField, feedCursorKeys)
+		if cursor == "" || count == 0 {
+			return ""
+		}
+		q := u.Query()
+		q.Set(param, cursor)
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+	return ""
+}
+
+// firstFeedPage returns the URL of the feed's first page: feedURL, with the
+// page number or offset parameter added if it isn't already there
+func firstFeedPage(feedURL string, opts JSONFeedOptions) string {
+	if opts.Paging != FeedPagingPage && opts.Paging != FeedPagingOffset {
+		return feedURL
+	}
+	u, err := url.Parse(feedURL)
+	if err != nil {
+		return feedURL
+	}
+	param := opts.PageParam
+	if param == "" {
+		param = opts.Paging.param()
+	}
+	q := u.Query()
+	if q.Get(param) != "" {
+		return feedURL
+	}
+	first := 0
+	if opts.Paging == FeedPagingPage {
+		first = opts.FirstPage
+		if first == 0 {
+			first = 1
+		}
+	}
+	q.Set(param, strconv.Itoa(first))
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// linkHeaderNext returns the rel="next" URL of an RFC 8288 Link header
+func linkHeaderNext(header http.Header) string {
+	for _, value := range header.Values("Link") {
+		for _, link := range strings.Split(value, ",") {
+			target, params, ok := strings.Cut(link, ";")
+			if !ok {
+				continue
+			}
+			for _, param := range strings.Split(params, ";") {
+				name, val, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if strings.EqualFold(name, "rel") && strings.Contains(" "+strings.Trim(val, `"`)+" ", " next ") {
+					return strings.Trim(strings.TrimSpace(target), "<>")
+				}
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,112 @@
+package crawler
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// feedState is the record incremental feed capture keeps of the items it
+// has captured, so the next run only captures new ones
+type feedState struct {
+	Feed    string          `json:"feed"`
+	Updated string          `json:"updated"`
+	Items   []feedStateItem `json:"items"`
+
+	path  string
+	mu    sync.Mutex
+	ids   map[string]bool
+	links map[string]bool
+}
+
+type feedStateItem struct {
+	ID       string `json:"id,omitempty"` // the item's GUID or id in the feed, if it has one
+	URL      string `json:"url"`
+	Headline string `json:"headline,omitempty"`
+	Captured string `json:"captured"`
+	Output   string `json:"output"` // directory the capture was saved in
+}
+
+// loadFeedState reads the state file at path, or starts an empty one if it
+// doesn't exist yet
+func loadFeedState(path, feedURL string) (*feedState, error) {
+	state := &feedState{Feed: feedURL, path: path}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("can't read feed state: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, state); err != nil {
+			return nil, fmt.Errorf("%s is not a feed state file: %v", path, err)
+		}
+	}
+
+	state.ids = make(map[string]bool)
+	state.links = make(map[string]bool)
+	for _, item := range state.Items {
+		if item.ID != "" {
+			state.ids[item.ID] = true
+		}
+		state.links[item.URL] = true
+	}
+	return state, nil
+}
+
+// captured reports whether an earlier run captured the item, matching its
+// GUID when it has one and its URL either way
+func (s *feedState) captured(item FeedItem, itemURL string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return (item.ID != "" && s.ids[item.ID]) || s.links[itemURL]
+}
+
+// record adds a captured item to the state
+func (s *feedState) record(item FeedItem, itemURL, outputDir string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if item.ID != "" {
+		s.ids[item.ID] = true
+	}
+	s.links[itemURL] = true
+	s.Items = append(s.Items, feedStateItem{
+		ID:       item.ID,
+		URL:      itemURL,
+		Headline: item.Headline,
+		Captured: time.Now().Format(time.RFC3339),
+		Output:   outputDir,
+	})
+}
+
+// save writes the state back to its file, without risking the record of
+// earlier runs if the write is interrupted
+func (s *feedState) save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Updated = time.Now().Format(time.RFC3339)
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data)
+}
+
+// writeFileAtomic replaces the file at path with data, through a temporary
+// file so an interrupted write can't leave it half written
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
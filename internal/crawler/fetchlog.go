@@ -0,0 +1,102 @@
+package crawler
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// fetchLogHeader is the fetch log's columns. The phases add up to roughly
+// Totalms: DNS, connect and TLS are 0 when a kept-alive connection was
+// reused, TTFB counts from sending the request (after any redirects) to the
+// first byte of the response, and Download is the time spent reading the body.
+var fetchLogHeader = []string{
+	"URL", "FoundOn", "Attempt", "Depth", "Status", "RedirectedTo", "ContentType", "Bytes",
+	"DNSms", "Connectms", "TLSms", "TTFBms", "Downloadms", "Totalms", "Error", "FetchedAt",
+}
+
+// fetchLog writes every page request of a crawl to a CSV file, whether it
+// succeeded or not, so slow or flaky URLs can be looked into afterwards
+type fetchLog struct {
+	mu   sync.Mutex
+	file *os.File
+	w    *csv.Writer
+}
+
+// openFetchLog creates the fetch log at path and writes its header
+func openFetchLog(path string) (*fetchLog, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("creating the fetch log: %v", err)
+	}
+	l := &fetchLog{file: file, w: csv.NewWriter(file)}
+	l.w.Write(fetchLogHeader)
+	l.w.Flush()
+	return l, nil
+}
+
+// add writes a page request. Rows are flushed as they're written, so the
+// log is complete up to the last request even if the crawl is killed.
+func (l *fetchLog) add(f fetchRecord) {
+	var status, errMsg string
+	if f.Status != 0 {
+		status = strconv.Itoa(f.Status)
+	}
+	if f.Err != nil {
+		errMsg = f.Err.Error()
+	}
+	ms := func(d time.Duration) string {
+		return strconv.FormatInt(d.Milliseconds(), 10)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.w.Write([]string{
+		f.URL, f.FoundOn, strconv.Itoa(f.Attempt), strconv.Itoa(f.Depth), status, f.RedirectedTo, f.ContentType, strconv.Itoa(f.Bytes),
+		ms(f.DNS), ms(f.Connect), ms(f.TLS), ms(f.TTFB), ms(f.Download), ms(f.Duration), errMsg, f.Fetched.Format(time.RFC3339),
+	})
+	l.w.Flush()
+}
+
+func (l *fetchLog) close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.w.Flush()
+	if err := l.w.Error(); err != nil {
+		l.file.Close()
+		return err
+	}
+	return l.file.Close()
+}
+
+// setTiming copies the phases a trace measured into f, once f.Duration is set
+func (f *fetchRecord) setTiming(t *pageTiming) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	f.DNS, f.Connect, f.TLS, f.TTFB = t.dns, t.connect, t.tls, t.ttfb
+	if t.ttfb > 0 && f.Duration > t.ttfb {
+		f.Download = f.Duration - t.ttfb
+	}
+}
+
+// redirectTarget returns where a page request's redirects ended, or "" if
+// the page wasn't redirected
+func redirectTarget(link string, resp *http.Response) string {
+	if final := resp.Request.URL.String(); final != link {
+		return final
+	}
+	return ""
+}
+
+// closeFetchLog finishes the fetch log once the crawl is over
+func (c *Crawler) closeFetchLog() {
+	if c.fetchLog == nil {
+		return
+	}
+	if err := c.fetchLog.close(); err != nil {
+		c.log.Error(fmt.Sprintf("❌ Fetch log: %v", err), "path", c.fetchLogFile, "error", err)
+	}
+}
@@ -0,0 +1,146 @@
+package crawler
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// streamedModes write their results rows as pages finish, in whatever order
+// the workers get to them. The other modes write their rows, already sorted,
+// once the crawl is over.
+var streamedModes = map[SearchMode]bool{
+	ModeSearchLink:      true,
+	ModeSearchWord:      true,
+	ModeOversizedImages: true,
+	ModeRedirectChains:  true,
+	ModePageWeight:      true,
+	ModeExtract:         true,
+}
+
+// statusColumns are the results columns holding a page's HTTP status
+var statusColumns = map[string]bool{"StatusCode": true, "FinalStatus": true, "Status": true}
+
+// finalizeResults rewrites the results CSV of each mode that writes rows as
+// pages finish, without duplicate rows and sorted by URL and status, so two
+// runs can be compared line by line. With Config.KeepRawResults the file as
+// it was written during the crawl is kept next to it, with -raw added to its
+// name.
+func (c *Crawler) finalizeResults() {
+	for _, mode := range c.crawlModes() {
+		if !streamedModes[mode] {
+			continue
+		}
+		path := c.resultFileFor(mode)
+		header, rows := readResultsCSV(path)
+		if header == nil {
+			continue
+		}
+		rows, dropped := dedupeResults(header, rows)
+		sortResults(header, rows)
+
+		if c.config.KeepRawResults {
+			raw := strings.TrimSuffix(path, ".csv") + "-raw.csv"
+			if err := os.Rename(path, raw); err != nil {
+				c.log.Error("❌ Could not keep the raw results: "+err.Error(), "file", path, "error", err)
+				continue
+			}
+			c.extraOutputs = append(c.extraOutputs, raw)
+		}
+		if err := writeResultsCSV(path, header, rows); err != nil {
+			c.log.Error("❌ Could not sort the results: "+err.Error(), "file", path, "error", err)
+			continue
+		}
+		if dropped > 0 {
+			c.log.Info(fmt.Sprintf("🧹 Removed %d duplicate rows from %s", dropped, path), "file", path, "duplicates", dropped)
+		}
+	}
+}
+
+// dedupeResults drops rows that repeat an earlier one, comparing every
+// column but the time the row was written. It returns the rows left and how
+// many were dropped.
+func dedupeResults(header []string, rows [][]string) ([][]string, int) {
+	timestamp := -1
+	for i, name := range header {
+		if name == "Timestamp" {
+			timestamp = i
+		}
+	}
+	seen := make(map[string]bool, len(rows))
+	kept := rows[:0]
+	for _, row := range rows {
+		key := make([]string, 0, len(row))
+		for i, value := range row {
+			if i != timestamp {
+				key = append(key, value)
+			}
+		}
+		k := strings.Join(key, "\x00")
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		kept = append(kept, row)
+	}
+	return kept, len(rows) - len(kept)
+}
+
+// sortResults sorts rows by their first column, the URL, then by status
+// code, then by the rest of the row so the order is the same every run
+func sortResults(header []string, rows [][]string) {
+	status := -1
+	for i, name := range header {
+		if statusColumns[name] {
+			status = i
+			break
+		}
+	}
+	column := func(row []string, i int) string {
+		if i < 0 || i >= len(row) {
+			return ""
+		}
+		return row[i]
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if x, y := column(a, 0), column(b, 0); x != y {
+			return x < y
+		}
+		if x, y := column(a, status), column(b, status); x != y {
+			xn, xerr := strconv.Atoi(x)
+			yn, yerr := strconv.Atoi(y)
+			if xerr == nil && yerr == nil {
+				return xn < yn
+			}
+			return x < y
+		}
+		return strings.Join(a, "\x00") < strings.Join(b, "\x00")
+	})
+}
+
+// writeResultsCSV replaces the results CSV at path, writing to a temporary
+// file first so a failed write leaves the old one in place
+func writeResultsCSV(path string, header []string, rows [][]string) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	w.Write(header)
+	w.WriteAll(rows)
+	if err := w.Error(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,326 @@
+package crawler
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	utls "github.com/refraction-networking/utls"
+	"golang.org/x/net/http2"
+)
+
+// browserFingerprint is the set of headers one real browser sends with a
+// page request. Its User-Agent, Accept, Accept-Language and, for Chromium
+// browsers, sec-ch-ua client hints all describe the same browser: a Chrome
+// User-Agent without Chrome's client hints, or with Firefox's Accept header,
+// is an easy giveaway to bot detection. A crawl keeps one fingerprint for
+// all its requests, as a browser keeps its identity for its session cookies.
+type browserFingerprint struct {
+	browser        string // chrome, edge, firefox or safari; "" for a User-Agent that's none of them
+	userAgent      string
+	accept         string
+	acceptLanguage string
+	clientHints    [][2]string // sec-ch-ua headers; none for Firefox and Safari
+}
+
+// browserProfile is a browser and platform fingerprints are made for
+type browserProfile struct {
+	browser  string // chrome, edge, firefox or safari
+	platform string // Windows, macOS or Linux, as sec-ch-ua-platform names them
+}
+
+// fingerprintProfiles are the browsers a crawl can pass itself off as,
+// roughly in proportion to how common they are on the desktop
+var fingerprintProfiles = []browserProfile{
+	{"chrome", "Windows"}, {"chrome", "Windows"}, {"chrome", "Windows"},
+	{"chrome", "macOS"}, {"chrome", "macOS"}, {"chrome", "Linux"},
+	{"edge", "Windows"}, {"firefox", "Windows"}, {"firefox", "macOS"},
+	{"firefox", "Linux"}, {"safari", "macOS"},
+}
+
+// fingerprintVersions are the recent major versions of each browser
+var fingerprintVersions = map[string][]int{
+	"chrome":  {128, 129, 130, 131},
+	"edge":    {128, 129, 130, 131},
+	"firefox": {128, 130, 131, 132},
+	"safari":  {17, 18},
+}
+
+// Accept headers browsers send when navigating to a page
+const (
+	chromiumAccept = "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7"
+	firefoxAccept  = "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"
+)
+
+// newBrowserFingerprint picks a browser, version and platform at random
+func newBrowserFingerprint() *browserFingerprint {
+	p := fingerprintProfiles[rand.Intn(len(fingerprintProfiles))]
+	versions := fingerprintVersions[p.browser]
+	return browserFingerprintFor(p, versions[rand.Intn(len(versions))])
+}
+
+// browserFingerprintFor builds the headers version of a browser sends on a
+// platform
+func browserFingerprintFor(p browserProfile, version int) *browserFingerprint {
+	chromeOS := map[string]string{
+		"Windows": "Windows NT 10.0; Win64; x64",
+		"macOS":   "Macintosh; Intel Mac OS X 10_15_7",
+		"Linux":   "X11; Linux x86_64",
+	}[p.platform]
+	f := &browserFingerprint{browser: p.browser, accept: chromiumAccept, acceptLanguage: "en-US,en;q=0.9"}
+
+	switch p.browser {
+	case "chrome", "edge":
+		f.userAgent = fmt.Sprintf("Mozilla/5.0 (%s) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/%d.0.0.0 Safari/537.36", chromeOS, version)
+		brand := "Google Chrome"
+		if p.browser == "edge" {
+			f.userAgent += fmt.Sprintf(" Edg/%d.0.0.0", version)
+			brand = "Microsoft Edge"
+		}
+		f.clientHints = [][2]string{
+			{"Sec-Ch-Ua", fmt.Sprintf(`"%s";v="%d", "Chromium";v="%d", "Not_A Brand";v="24"`, brand, version, version)},
+			{"Sec-Ch-Ua-Mobile", "?0"},
+			{"Sec-Ch-Ua-Platform", strconv.Quote(p.platform)},
+		}
+	case "firefox":
+		firefoxOS := map[string]string{
+			"Windows": "Windows NT 10.0; Win64; x64",
+			"macOS":   "Macintosh; Intel Mac OS X 10.15",
+			"Linux":   "X11; Linux x86_64",
+		}[p.platform]
+		f.userAgent = fmt.Sprintf("Mozilla/5.0 (%s; rv:%d.0) Gecko/20100101 Firefox/%d.0", firefoxOS, version, version)
+		f.accept, f.acceptLanguage = firefoxAccept, "en-US,en;q=0.5"
+	case "safari":
+		f.userAgent = fmt.Sprintf("Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/%d.0 Safari/605.1.15", version)
+		f.accept = firefoxAccept
+	}
+	return f
+}
+
+var (
+	chromeVersionRe  = regexp.MustCompile(`Chrome/(\d+)`)
+	firefoxVersionRe = regexp.MustCompile(`Firefox/(\d+)`)
+	safariVersionRe  = regexp.MustCompile(`Version/(\d+)`)
+)
+
+// fingerprintForUserAgent returns the fingerprint that goes with a
+// User-Agent set in Config.Headers or imported from a browser session, so the
+// other headers agree with it. A User-Agent that isn't a desktop Chrome, Edge,
+// Firefox or Safari gets no client hints.
+func fingerprintForUserAgent(ua string) *browserFingerprint {
+	p := browserProfile{platform: "Windows"}
+	switch {
+	case strings.Contains(ua, "Macintosh"):
+		p.platform = "macOS"
+	case strings.Contains(ua, "Linux") && !strings.Contains(ua, "Android"):
+		p.platform = "Linux"
+	}
+
+	var version int
+	switch {
+	case strings.Contains(ua, "Mobile"):
+	case strings.Contains(ua, "Firefox/"):
+		p.browser = "firefox"
+		version = atoiMatch(firefoxVersionRe, ua)
+	case strings.Contains(ua, "Chrome/"):
+		p.browser = "chrome"
+		if strings.Contains(ua, "Edg/") {
+			p.browser = "edge"
+		}
+		version = atoiMatch(chromeVersionRe, ua)
+	case strings.Contains(ua, "Safari/") && strings.Contains(ua, "Version/"):
+		p.browser = "safari"
+		version = atoiMatch(safariVersionRe, ua)
+	}
+
+	f := &browserFingerprint{accept: firefoxAccept, acceptLanguage: "en-US,en;q=0.5"}
+	if p.browser != "" && version > 0 {
+		f = browserFingerprintFor(p, version)
+	}
+	f.userAgent = ua
+	return f
+}
+
+// atoiMatch returns the number re's first group matches in s, or 0
+func atoiMatch(re *regexp.Regexp, s string) int {
+	if m := re.FindStringSubmatch(s); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		return n
+	}
+	return 0
+}
+
+// apply sets the headers of a page navigation. fetchSite is the
+// Sec-Fetch-Site value: "none" for a page opened directly, "same-origin" for
+// one followed from the site.
+func (f *browserFingerprint) apply(req *http.Request, fetchSite string) {
+	req.Header.Set("User-Agent", f.userAgent)
+	req.Header.Set("Accept", f.accept)
+	req.Header.Set("Accept-Language", f.acceptLanguage)
+	req.Header.Set("Accept-Encoding", "gzip, deflate, br")
+	for _, h := range f.clientHints {
+		req.Header.Set(h[0], h[1])
+	}
+	req.Header.Set("Upgrade-Insecure-Requests", "1")
+	req.Header.Set("Sec-Fetch-Dest", "document")
+	req.Header.Set("Sec-Fetch-Mode", "navigate")
+	req.Header.Set("Sec-Fetch-Site", fetchSite)
+	if fetchSite == "none" {
+		req.Header.Set("Sec-Fetch-User", "?1")
+	}
+}
+
+// helloID returns the uTLS ClientHello of the fingerprint's browser. Edge
+// shakes hands as the Chrome it's built on. A User-Agent that isn't one of
+// the known browsers has none, and keeps Go's own handshake.
+func (f *browserFingerprint) helloID() (utls.ClientHelloID, bool) {
+	switch f.browser {
+	case "chrome", "edge":
+		return utls.HelloChrome_Auto, true
+	case "firefox":
+		return utls.HelloFirefox_Auto, true
+	case "safari":
+		return utls.HelloSafari_Auto, true
+	}
+	return utls.ClientHelloID{}, false
+}
+
+// helloTransport makes HTTPS requests with a browser's TLS ClientHello, so
+// services that fingerprint the handshake (JA3/JA4) see the browser the
+// headers describe rather than Go. The hello offers HTTP/2 as the browser's
+// does, so hosts that choose it are sent requests over HTTP/2.
+type helloTransport struct {
+	h1     *http.Transport  // plain HTTP, and HTTPS hosts that chose HTTP/1.1
+	h2     *http2.Transport // HTTPS hosts that chose HTTP/2
+	hello  utls.ClientHelloID
+	verify bool
+	dial   func(ctx context.Context, network, addr string) (net.Conn, error)
+	protos sync.Map // host:port → the protocol it chose, once known
+}
+
+func newHelloTransport(base *http.Transport, hello utls.ClientHelloID, skipVerify bool) *helloTransport {
+	t := &helloTransport{h1: base.Clone(), hello: hello, verify: !skipVerify, dial: base.DialContext}
+	if t.dial == nil {
+		t.dial = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	}
+	t.h1.DialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := t.dialTLS(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		if conn.ConnectionState().NegotiatedProtocol == http2.NextProtoTLS {
+			// The host wants HTTP/2: this connection can't be used for
+			// HTTP/1.1, so the request is sent again over h2
+			conn.Close()
+			return nil, errHelloUseHTTP2
+		}
+		return conn, nil
+	}
+	t.h2 = &http2.Transport{
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return t.dialTLS(ctx, network, addr)
+		},
+		ReadIdleTimeout: 30 * time.Second,
+	}
+	return t
+}
+
+// errHelloUseHTTP2 is returned by helloTransport's HTTP/1.1 dial for a host
+// that chose HTTP/2
+var errHelloUseHTTP2 = errors.New("host chose HTTP/2")
+
+// dialTLS connects to addr and shakes hands with the browser's ClientHello,
+// noting which protocol the host chose. The handshake is reported to the
+// request's trace as net/http's own would be, for the fetch log's timings.
+func (t *helloTransport) dialTLS(ctx context.Context, network, addr string) (*utls.UConn, error) {
+	raw, err := t.dial(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	host, _, _ := net.SplitHostPort(addr)
+	trace := httptrace.ContextClientTrace(ctx)
+	if trace != nil && trace.TLSHandshakeStart != nil {
+		trace.TLSHandshakeStart()
+	}
+	conn := utls.UClient(raw, &utls.Config{ServerName: host, InsecureSkipVerify: !t.verify}, t.hello)
+	err = conn.HandshakeContext(ctx)
+	proto := conn.ConnectionState().NegotiatedProtocol
+	if trace != nil && trace.TLSHandshakeDone != nil {
+		trace.TLSHandshakeDone(tls.ConnectionState{HandshakeComplete: err == nil, ServerName: host, NegotiatedProtocol: proto}, err)
+	}
+	if err != nil {
+		raw.Close()
+		return nil, err
+	}
+	if proto == "" {
+		proto = "http/1.1"
+	}
+	t.protos.Store(addr, proto)
+	return conn, nil
+}
+
+func (t *helloTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "https" {
+		return t.h1.RoundTrip(req)
+	}
+	addr := req.URL.Host
+	if req.URL.Port() == "" {
+		addr = net.JoinHostPort(req.URL.Hostname(), "443")
+	}
+	if proto, ok := t.protos.Load(addr); ok && proto == http2.NextProtoTLS {
+		return t.h2.RoundTrip(req)
+	}
+
+	resp, err := t.h1.RoundTrip(req)
+	if err != nil && errors.Is(err, errHelloUseHTTP2) {
+		if req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody == nil {
+				return nil, err
+			}
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+		return t.h2.RoundTrip(req)
+	}
+	return resp, err
+}
+
+// CloseIdleConnections closes both transports' idle connections
+func (t *helloTransport) CloseIdleConnections() {
+	t.h1.CloseIdleConnections()
+	t.h2.CloseIdleConnections()
+}
+
+// withTLSFingerprint sends the crawl's page requests with the ClientHello of
+// the browser its headers describe, when Config.TLSFingerprint is set. Link
+// and image checks keep Go's handshake. It's left off through proxies, whose
+// tunnels net/http sets up itself, and for the certificate audit, which
+// reads the certificates from Go's handshake.
+func (c *Crawler) withTLSFingerprint() {
+	if !c.config.TLSFingerprint {
+		return
+	}
+	hello, ok := c.fingerprint.helloID()
+	base, isTransport := c.httpClient.Transport.(*http.Transport)
+	switch {
+	case !ok:
+		c.log.Warn("⚠️  The User-Agent isn't a desktop Chrome, Edge, Firefox or Safari - keeping Go's TLS handshake")
+	case c.proxies != nil:
+		c.log.Warn("⚠️  TLS fingerprints aren't sent through proxies - keeping Go's TLS handshake")
+	case c.config.CertAudit:
+		c.log.Warn("⚠️  The certificate audit needs Go's TLS handshake - not sending a browser TLS fingerprint")
+	case isTransport:
+		c.httpClient.Transport = newHelloTransport(base, hello, c.config.SkipTLSVerify)
+	}
+}
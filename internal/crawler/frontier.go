@@ -0,0 +1,271 @@
+package crawler
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// defaultMaxQueuedPages is how many pages the frontier holds when
+// Config.MaxQueuedPages isn't set
+const defaultMaxQueuedPages = 100000
+
+// frontierItem is a page waiting to be fetched
+type frontierItem struct {
+	link     string
+	foundOn  string // page the link was found on, "" for a start URL
+	depth    int
+	rank     int          // index of the Config.PriorityPaths prefix the URL is under, or len(PriorityPaths)
+	segments int          // segments in the URL's path
+	seq      int64        // order of discovery, for ties
+	blocked  *BlockedPage // set for a blocked page being retried
+}
+
+// before reports whether a should be fetched before b: pages under the
+// earliest priority path first, then the fewest links from the start, then
+// the shortest path, then the first discovered
+func (a *frontierItem) before(b *frontierItem) bool {
+	switch {
+	case a.rank != b.rank:
+		return a.rank < b.rank
+	case a.depth != b.depth:
+		return a.depth < b.depth
+	case a.segments != b.segments:
+		return a.segments < b.segments
+	}
+	return a.seq < b.seq
+}
+
+type frontierHeap []*frontierItem
+
+func (h frontierHeap) Len() int           { return len(h) }
+func (h frontierHeap) Less(i, j int) bool { return h[i].before(h[j]) }
+func (h frontierHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *frontierHeap) Push(x any)        { *h = append(*h, x.(*frontierItem)) }
+func (h *frontierHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return item
+}
+
+// frontier holds the pages discovered but not yet fetched, most important
+// first, for a fixed pool of workers to take from. It holds at most
+// Config.MaxQueuedPages; past that the least important pages are dropped.
+type frontier struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	items    frontierHeap
+	max      int
+	seq      int64
+	active   int // pages taken and not yet done, which may still add more
+	closed   bool
+	priority []string
+}
+
+func newFrontier(cfg Config) *frontier {
+	f := &frontier{max: cfg.MaxQueuedPages}
+	if f.max <= 0 {
+		f.max = defaultMaxQueuedPages
+	}
+	for _, p := range cfg.PriorityPaths {
+		if p = strings.TrimSpace(p); p != "" {
+			if !strings.HasPrefix(p, "/") {
+				p = "/" + p
+			}
+			f.priority = append(f.priority, p)
+		}
+	}
+	f.cond = sync.NewCond(&f.mu)
+	return f
+}
+
+// item returns the frontier entry for a page
+func (f *frontier) item(link, foundOn string, depth int) *frontierItem {
+	it := &frontierItem{link: link, foundOn: foundOn, depth: depth, rank: len(f.priority)}
+	if u, err := url.Parse(link); err == nil {
+		for i, prefix := range f.priority {
+			if strings.HasPrefix(u.Path, prefix) {
+				it.rank = i
+				break
+			}
+		}
+		for _, seg := range strings.Split(u.Path, "/") {
+			if seg != "" {
+				it.segments++
+			}
+		}
+	}
+	return it
+}
+
+// push adds a page, and returns the pages dropped to make room for it (which
+// may include the page itself). When the frontier overflows, it's cut back to
+// nine tenths of its size at once so the sort isn't repeated on every push.
+func (f *frontier) push(it *frontierItem) (dropped []*frontierItem) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return nil
+	}
+	f.seq++
+	it.seq = f.seq
+	heap.Push(&f.items, it)
+	if len(f.items) > f.max {
+		sort.Slice(f.items, func(i, j int) bool { return f.items[i].before(f.items[j]) })
+		keep := max(f.max*9/10, 1)
+		dropped = append(dropped, f.items[keep:]...)
+		clear(f.items[keep:])
+		// A sorted slice is already a heap
+		f.items = f.items[:keep]
+	}
+	f.cond.Signal()
+	return dropped
+}
+
+// pop waits for the next page to fetch. It returns nil once the frontier is
+// empty and no page being fetched can add more, or once it's closed.
+func (f *frontier) pop() *frontierItem {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for len(f.items) == 0 && f.active > 0 && !f.closed {
+		f.cond.Wait()
+	}
+	if f.closed || len(f.items) == 0 {
+		f.cond.Broadcast()
+		return nil
+	}
+	f.active++
+	return heap.Pop(&f.items).(*frontierItem)
+}
+
+// hold counts a page that will be pushed later as in progress, so pop waits
+// for it rather than reporting the frontier finished. done releases it.
+func (f *frontier) hold() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.active++
+}
+
+// done marks a page taken with pop, or held, as finished, with its links
+// pushed
+func (f *frontier) done() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.active--
+	if f.active == 0 && len(f.items) == 0 {
+		f.cond.Broadcast()
+	}
+}
+
+// close drops the pages left, for a cancelled crawl
+func (f *frontier) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	clear(f.items)
+	f.items = nil
+	f.cond.Broadcast()
+}
+
+// enqueue adds a page to the frontier. Blocked pages dropped from a full
+// frontier go back to the blocked queue, to be reported as still blocked.
+func (c *Crawler) enqueue(it *frontierItem) {
+	for _, d := range c.frontier.push(it) {
+		if d.blocked != nil {
+			c.blockedQueue.Store(d.link, d.blocked)
+			continue
+		}
+		c.noteSkipped(d.link, skipQueueFull)
+		if atomic.AddInt64(&c.stats.SkippedQueueFull, 1) == 1 {
+			c.log.Warn(fmt.Sprintf("⚠️  More than %d pages waiting - dropping the least important", c.frontier.max), "max_queued", c.frontier.max)
+		}
+	}
+}
+
+// work fetches the frontier's pages with Config.MaxConcurrency workers, until
+// it's empty and no page being fetched can add more
+func (c *Crawler) work(ctx context.Context) {
+	stop := context.AfterFunc(ctx, c.frontier.close)
+	defer stop()
+
+	var wg sync.WaitGroup
+	for i := 0; i < c.config.MaxConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for it := c.frontier.pop(); it != nil; it = c.frontier.pop() {
+				if it.blocked != nil {
+					c.retryQueued(ctx, it)
+				} else {
+					c.fetchQueued(ctx, it)
+				}
+				c.frontier.done()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// fetchQueued fetches a page taken from the frontier. Pages count against
+// Config.MaxPages as they start rather than as they're found, so the places
+// go to the most important pages discovered.
+func (c *Crawler) fetchQueued(ctx context.Context, it *frontierItem) {
+	if !c.throttle.acquire(ctx) {
+		return
+	}
+	defer c.throttle.release()
+
+	if !c.proceed(ctx) {
+		return
+	}
+	if c.outOfTime() {
+		atomic.AddInt64(&c.stats.SkippedTimeLimit, 1)
+		c.noteSkipped(it.link, skipTimeLimit)
+		return
+	}
+	if c.outOfBudget() {
+		atomic.AddInt64(&c.stats.SkippedBudget, 1)
+		c.noteSkipped(it.link, skipBudget)
+		return
+	}
+	if queued := atomic.AddInt64(&c.stats.PagesQueued, 1); c.config.MaxPages > 0 && queued > int64(c.config.MaxPages) {
+		atomic.AddInt64(&c.stats.PagesQueued, -1)
+		atomic.AddInt64(&c.stats.SkippedLimit, 1)
+		c.noteSkipped(it.link, skipPageLimit)
+		return
+	}
+
+	c.noteCrawled(it.link)
+	c.fetchWithRetry(ctx, it.link, it.foundOn, it.depth)
+}
+
+// retryQueued fetches a blocked page again, once blockedRetrier has put it
+// back in the frontier
+func (c *Crawler) retryQueued(ctx context.Context, it *frontierItem) {
+	if !c.throttle.acquire(ctx) {
+		return
+	}
+	defer c.throttle.release()
+
+	page := it.blocked
+	if !c.proceed(ctx) {
+		c.blockedQueue.Store(it.link, page)
+		return
+	}
+	c.log.Debug(fmt.Sprintf("   🔄 Retrying: %s", it.link), "url", it.link, "attempt", page.Attempts)
+	if !c.limiter.wait(ctx, it.link) {
+		return
+	}
+
+	if c.fetchPageForRetry(ctx, it.link, it.foundOn, page.Attempts, it.depth) {
+		atomic.AddInt64(&c.stats.BlockedRecovered, 1)
+		c.log.Info(fmt.Sprintf("   ✅ RECOVERED: %s", it.link), "url", it.link)
+	}
+}
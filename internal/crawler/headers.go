@@ -0,0 +1,172 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// headerTransport adds Config.Headers, Config.Cookies and HTTP Basic
+// credentials to requests for hosts inside the crawl scope. Requests to other
+// hosts (external link and image checks) go out untouched so tokens and
+// session cookies don't leak.
+type headerTransport struct {
+	base    http.RoundTripper
+	headers map[string]string
+	cookies []*http.Cookie
+	auth    AuthOptions
+	scope   *crawlScope
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.scope.contains(req.URL) {
+		return t.base.RoundTrip(req)
+	}
+
+	// A RoundTripper must not modify the caller's request
+	req = req.Clone(req.Context())
+	if t.auth.Mode == AuthBasic {
+		req.SetBasicAuth(t.auth.Username, t.auth.Password)
+	}
+	for name, value := range t.headers {
+		req.Header.Set(name, value)
+	}
+	for _, cookie := range t.cookies {
+		// Cookies the site has since set in the jar take precedence
+		if _, err := req.Cookie(cookie.Name); err != nil {
+			req.AddCookie(cookie)
+		}
+	}
+	return t.base.RoundTrip(req)
+}
+
+// ParseHeaders parses "Name: value" pairs, as typed on the command line or in
+// the wizard, into a Config.Headers map
+func ParseHeaders(raw []string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, h := range raw {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(h, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("invalid header %q (expected Name: value)", h)
+		}
+		headers[http.CanonicalHeaderKey(name)] = strings.TrimSpace(value)
+	}
+	return headers, nil
+}
+
+// ParseCookies parses a Cookie header style list ("session=abc; theme=dark")
+// into Config.Cookies
+func ParseCookies(raw string) ([]*http.Cookie, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	cookies, err := http.ParseCookie(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid cookies: %v", err)
+	}
+	return cookies, nil
+}
+
+// withRequestHeaders wraps the crawler's transports in a headerTransport when
+// custom headers, cookies or Basic auth are configured
+func (c *Crawler) withRequestHeaders() {
+	if len(c.config.Headers) == 0 && len(c.config.Cookies) == 0 && c.config.Auth.Mode != AuthBasic {
+		return
+	}
+
+	wrap := func(base http.RoundTripper) http.RoundTripper {
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		return &headerTransport{base: base, headers: c.config.Headers, cookies: c.config.Cookies, auth: c.config.Auth, scope: c.scope}
+	}
+	c.httpClient.Transport = wrap(c.httpClient.Transport)
+	c.checkTransport = wrap(c.checkTransport)
+}
+
+// browserRequestHeaders applies Config.Headers, Config.Cookies, Basic auth
+// and any login session cookies to a headless Chrome tab before it loads
+// pageURL. The headers are added request by request, like headerTransport
+// does: every request the tab makes is paused, and only those to in-scope
+// hosts get them, so third-party scripts, fonts, frames and redirects to
+// other hosts go out without the credentials.
+func (c *Crawler) browserRequestHeaders(pageURL string) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		u, err := url.Parse(pageURL)
+		if err != nil {
+			return nil
+		}
+
+		headers := make(map[string]string, len(c.config.Headers)+1)
+		for name, value := range c.config.Headers {
+			headers[http.CanonicalHeaderKey(name)] = value
+		}
+		if c.config.Auth.Mode == AuthBasic {
+			req := &http.Request{Header: http.Header{}}
+			req.SetBasicAuth(c.config.Auth.Username, c.config.Auth.Password)
+			headers["Authorization"] = req.Header.Get("Authorization")
+		}
+		var cookies []*http.Cookie
+		if c.scope.contains(u) {
+			cookies = append(c.httpClient.Jar.Cookies(u), c.config.Cookies...)
+		}
+		if len(headers) == 0 && len(cookies) == 0 {
+			return nil
+		}
+
+		if len(headers) > 0 {
+			chromedp.ListenTarget(ctx, func(ev any) {
+				if ev, ok := ev.(*fetch.EventRequestPaused); ok {
+					// Replying from the listener itself would block the
+					// tab's event loop
+					go c.continueBrowserRequest(ctx, ev, headers)
+				}
+			})
+			if err := fetch.Enable().Do(ctx); err != nil {
+				return err
+			}
+		}
+		if err := network.Enable().Do(ctx); err != nil {
+			return err
+		}
+		for _, cookie := range cookies {
+			if err := network.SetCookie(cookie.Name, cookie.Value).WithURL(pageURL).Do(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// continueBrowserRequest lets a request Chrome paused go on, with headers
+// added when it's for an in-scope host
+func (c *Crawler) continueBrowserRequest(ctx context.Context, ev *fetch.EventRequestPaused, headers map[string]string) {
+	ctx = cdp.WithExecutor(ctx, chromedp.FromContext(ctx).Target)
+	req := fetch.ContinueRequest(ev.RequestID)
+	if u, err := url.Parse(ev.Request.URL); err == nil && c.scope.contains(u) {
+		entries := make([]*fetch.HeaderEntry, 0, len(ev.Request.Headers)+len(headers))
+		for name, value := range ev.Request.Headers {
+			if _, replaced := headers[http.CanonicalHeaderKey(name)]; !replaced {
+				entries = append(entries, &fetch.HeaderEntry{Name: name, Value: fmt.Sprint(value)})
+			}
+		}
+		for name, value := range headers {
+			entries = append(entries, &fetch.HeaderEntry{Name: name, Value: value})
+		}
+		req = req.WithHeaders(entries)
+	}
+	// Fails only when the tab has gone, taking the request with it
+	_ = req.Do(ctx)
+}
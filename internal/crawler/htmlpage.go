@@ -0,0 +1,61 @@
+package crawler
+
+import (
+	"bytes"
+	"io"
+	"sync"
+
+	"golang.org/x/net/html"
+)
+
+// htmlPage is a fetched page's body with its HTML parsed at most once, on
+// first use, so each mode's checks and the link extraction that follows
+// them walk one shared tree instead of each parsing the body again. The
+// checks only read the tree.
+type htmlPage struct {
+	body []byte
+	once sync.Once
+	doc  *html.Node
+}
+
+func newHTMLPage(body []byte) *htmlPage {
+	return &htmlPage{body: body}
+}
+
+// root returns the parsed document, or nil if the body couldn't be parsed
+func (p *htmlPage) root() *html.Node {
+	p.once.Do(func() {
+		if doc, err := html.Parse(bytes.NewReader(p.body)); err == nil {
+			p.doc = doc
+		}
+	})
+	return p.doc
+}
+
+// maxPooledBuffer is the largest read buffer kept for reuse. A rare huge
+// page gets a buffer of its own, so the pool's memory stays bounded by
+// typical page sizes times the concurrency.
+const maxPooledBuffer = 4 << 20
+
+// bodyBuffers holds the buffers response bodies are read into. Reading into
+// a reused buffer and copying out the result makes one allocation of the
+// body's size, where io.ReadAll grows a slice many times over.
+var bodyBuffers = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// readAllPooled reads r to the end through a pooled buffer
+func readAllPooled(r io.Reader) ([]byte, error) {
+	buf := bodyBuffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer func() {
+		if buf.Cap() <= maxPooledBuffer {
+			bodyBuffers.Put(buf)
+		}
+	}()
+
+	if _, err := buf.ReadFrom(r); err != nil {
+		return nil, err
+	}
+	return bytes.Clone(buf.Bytes()), nil
+}
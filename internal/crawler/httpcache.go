@@ -0,0 +1,191 @@
+package crawler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// cachedStatus marks a response rebuilt from the cache after a 304, so it
+// isn't saved again
+const cachedStatus = "200 OK (not modified, from cache)"
+
+// DefaultCacheDir returns the folder the HTTP cache is kept in unless
+// Config.HTTPCache names another
+func DefaultCacheDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("finding the cache folder: %v", err)
+	}
+	return filepath.Join(home, ".webcrawler", "cache"), nil
+}
+
+// cacheEntry is what's kept for a page: its validators, and the body to use
+// when the server says the page hasn't changed
+type cacheEntry struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	ContentType  string `json:"content_type,omitempty"`
+	Saved        string `json:"saved"`
+}
+
+// httpCache keeps pages fetched by earlier crawls in a folder, two files per
+// URL named by its hash: the entry as JSON and the decoded body. A nil cache
+// does nothing.
+type httpCache struct {
+	dir string
+}
+
+func newHTTPCache(dir string) (*httpCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating the cache folder: %v", err)
+	}
+	return &httpCache{dir: dir}, nil
+}
+
+// paths returns the entry and body files for a URL
+func (hc *httpCache) paths(link string) (entry, body string) {
+	sum := sha256.Sum256([]byte(link))
+	name := hex.EncodeToString(sum[:])
+	base := filepath.Join(hc.dir, name[:2], name)
+	return base + ".json", base + ".body"
+}
+
+// load returns the saved entry for a URL, or nil
+func (hc *httpCache) load(link string) *cacheEntry {
+	if hc == nil {
+		return nil
+	}
+	entryPath, _ := hc.paths(link)
+	data, err := os.ReadFile(entryPath)
+	if err != nil {
+		return nil
+	}
+	var entry cacheEntry
+	if json.Unmarshal(data, &entry) != nil || entry.URL != link {
+		return nil
+	}
+	return &entry
+}
+
+// save keeps a page's validators and body. Pages without an ETag or
+// Last-Modified can't be asked for conditionally, so they aren't saved.
+func (hc *httpCache) save(link string, resp *http.Response, body []byte) {
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if etag == "" && lastModified == "" {
+		return
+	}
+	entry := cacheEntry{
+		URL:          link,
+		ETag:         etag,
+		LastModified: lastModified,
+		ContentType:  resp.Header.Get("Content-Type"),
+		Saved:        time.Now().Format(time.RFC3339),
+	}
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return
+	}
+	entryPath, bodyPath := hc.paths(link)
+	if os.MkdirAll(filepath.Dir(entryPath), 0755) != nil {
+		return
+	}
+	// The body goes first: an entry is only used once it's written
+	if os.WriteFile(bodyPath, body, 0644) != nil {
+		return
+	}
+	os.WriteFile(entryPath, data, 0644)
+}
+
+// response rebuilds a 200 response from a saved entry, for a 304
+func (hc *httpCache) response(entry *cacheEntry, notModified *http.Response) (*http.Response, error) {
+	_, bodyPath := hc.paths(entry.URL)
+	body, err := os.ReadFile(bodyPath)
+	if err != nil {
+		return nil, err
+	}
+	header := http.Header{}
+	header.Set("Content-Type", entry.ContentType)
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	for _, name := range []string{"ETag", "Last-Modified"} {
+		if v := notModified.Header.Get(name); v != "" {
+			header.Set(name, v)
+		}
+	}
+	if header.Get("ETag") == "" && entry.ETag != "" {
+		header.Set("ETag", entry.ETag)
+	}
+	if header.Get("Last-Modified") == "" && entry.LastModified != "" {
+		header.Set("Last-Modified", entry.LastModified)
+	}
+	return &http.Response{
+		Status:        cachedStatus,
+		StatusCode:    http.StatusOK,
+		Proto:         notModified.Proto,
+		ProtoMajor:    notModified.ProtoMajor,
+		ProtoMinor:    notModified.ProtoMinor,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       notModified.Request,
+	}, nil
+}
+
+// conditionalRequest sends a page request, asking only for changes when an
+// earlier crawl saved the page. A 304 comes back as the saved page with
+// status 200, so the page is checked as if it had been downloaded again.
+func (c *Crawler) conditionalRequest(req *http.Request, attempt int) (*http.Response, error) {
+	link := req.URL.String()
+	entry := c.cache.load(link)
+	if entry != nil {
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	resp, err := c.httpClient.Do(c.proxies.withAttempt(req, attempt))
+	if err != nil || resp.StatusCode != http.StatusNotModified || entry == nil || resp.Request.Response != nil {
+		return resp, err
+	}
+	resp.Body.Close()
+
+	cached, err := c.cache.response(entry, resp)
+	if err != nil {
+		// The body went missing; ask for the whole page
+		req.Header.Del("If-None-Match")
+		req.Header.Del("If-Modified-Since")
+		return c.httpClient.Do(c.proxies.withAttempt(req, attempt))
+	}
+	atomic.AddInt64(&c.stats.PagesNotModified, 1)
+	c.log.Debug(fmt.Sprintf("   ♻️  304 Not Modified: %s", link), "url", link)
+	return cached, nil
+}
+
+// fromCache reports whether a response was rebuilt from the cache after a
+// 304, rather than downloaded
+func fromCache(resp *http.Response) bool {
+	return resp.Status == cachedStatus
+}
+
+// cachePage saves a page downloaded in full for the next crawl's conditional
+// requests. Pages that redirected are left out, since the validators belong
+// to the page redirected to.
+func (c *Crawler) cachePage(resp *http.Response, body []byte) {
+	if c.cache == nil || fromCache(resp) || resp.Request.Response != nil {
+		return
+	}
+	c.cache.save(resp.Request.URL.String(), resp, body)
+}
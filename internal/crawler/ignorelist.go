@@ -0,0 +1,90 @@
+package crawler
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// ignoreList is Config.IgnoreFile: acknowledged exceptions, such as a hero
+// image that's meant to be large, that recurring audits shouldn't report.
+// Each line is a URL pattern, as for Config.ExcludePatterns, optionally
+// after a mode name and a colon to only apply in that mode:
+//
+//	# The homepage hero is meant to be big
+//	oversized-images: /img/hero-*.jpg
+//	https://partner.example.com/retired-page
+//	/archive/*
+//
+// Lines starting with # are comments.
+type ignoreList struct {
+	all    []urlPattern                // entries without a mode
+	byMode map[SearchMode][]urlPattern // entries for one mode
+}
+
+// loadIgnoreList reads an ignore file, returning it and how many entries it
+// has
+func loadIgnoreList(path string) (*ignoreList, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	list := &ignoreList{byMode: make(map[SearchMode][]urlPattern)}
+	entries := 0
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var mode SearchMode
+		if name, rest, ok := strings.Cut(line, ":"); ok {
+			if m, known := modeNames[strings.TrimSpace(name)]; known {
+				mode, line = m, strings.TrimSpace(rest)
+			}
+		}
+		pattern, err := compileURLPattern(line)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s line %d: %v", path, n, err)
+		}
+		if mode != 0 {
+			list.byMode[mode] = append(list.byMode[mode], pattern)
+		} else {
+			list.all = append(list.all, pattern)
+		}
+		entries++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, err
+	}
+	return list, entries, nil
+}
+
+// ignores reports whether a finding about link in mode is on the ignore
+// list
+func (c *Crawler) ignores(mode SearchMode, link string) bool {
+	if c.ignore == nil {
+		return false
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	return matchAnyPattern(c.ignore.all, u) || matchAnyPattern(c.ignore.byMode[mode], u)
+}
+
+// skipIgnored reports whether a result about link in mode is on the ignore
+// list, counting it when it is, so it can be left out of the results
+func (c *Crawler) skipIgnored(mode SearchMode, link string) bool {
+	if !c.ignores(mode, link) {
+		return false
+	}
+	atomic.AddInt64(&c.stats.Ignored, 1)
+	c.log.Debug(fmt.Sprintf("   🙈 Ignored: %s", link), "url", link)
+	return true
+}
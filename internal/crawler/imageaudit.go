@@ -0,0 +1,221 @@
+package crawler
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// cssBackgroundRe finds url(...) values in background and background-image declarations
+var cssBackgroundRe = regexp.MustCompile(`(?i)background(?:-image)?\s*:[^;}]*`)
+
+// cssURLRe extracts the target of a CSS url(...) reference
+var cssURLRe = regexp.MustCompile(`(?i)url\(\s*['"]?([^'")]+)['"]?\s*\)`)
+
+// retinaFactor is how much larger than its display size an image may be
+// before it's flagged, leaving room for 2x (HiDPI) screens
+const retinaFactor = 2
+
+// avifSavings is the typical size reduction from re-encoding JPEG or PNG
+// photos as AVIF at similar visual quality (WebP is roughly 25-35%)
+const avifSavings = 0.5
+
+// gifVideoSavings is the typical size reduction from converting an animated
+// GIF to an MP4 or WebM video
+const gifVideoSavings = 0.9
+
+// imageAudit describes one image found in oversized-image mode
+type imageAudit struct {
+	Format        string // jpeg, png, gif, webp, avif, svg, or "" if unknown
+	Width         int
+	Height        int
+	DisplayWidth  int // from the <img> width attribute (0 = not set)
+	DisplayHeight int // from the <img> height attribute (0 = not set)
+	Frames        int // GIFs: how many frames; more than one is an animation
+	Issues        []string
+	Suggestion    string
+	EstSavingsKB  int64
+}
+
+// auditImage decodes the image header and works out what could be improved.
+// limit gives the size an image of a format, animated or not, may be.
+// Dimension checks only run when the page gives a display size.
+func auditImage(body []byte, sizeBytes int64, limit func(format string, animated bool) int64, displayW, displayH int) imageAudit {
+	a := imageAudit{DisplayWidth: displayW, DisplayHeight: displayH}
+
+	if cfg, format, err := image.DecodeConfig(bytes.NewReader(body)); err == nil {
+		a.Format, a.Width, a.Height = format, cfg.Width, cfg.Height
+	} else {
+		a.Format = sniffImageFormat(body)
+	}
+	if a.Format == "gif" {
+		a.Frames = gifFrames(body)
+	}
+
+	if threshold := limit(a.Format, a.animated()); sizeBytes > threshold {
+		if a.animated() {
+			a.Issues = append(a.Issues, fmt.Sprintf("animated GIF over %dKB", threshold/1024))
+		} else {
+			a.Issues = append(a.Issues, fmt.Sprintf("file size over %dKB", threshold/1024))
+		}
+	}
+
+	// Estimate the optimized size: scale to the display area, then re-encode
+	optimized := float64(sizeBytes)
+	if a.overDimensioned() {
+		a.Issues = append(a.Issues, "dimensions")
+		scale := 1.0
+		if displayW > 0 {
+			scale = math.Min(scale, float64(displayW*retinaFactor)/float64(a.Width))
+		}
+		if displayH > 0 {
+			scale = math.Min(scale, float64(displayH*retinaFactor)/float64(a.Height))
+		}
+		optimized *= scale * scale
+	}
+
+	switch {
+	case a.Format == "jpeg" || a.Format == "png":
+		a.Suggestion = "AVIF or WebP"
+		optimized *= 1 - avifSavings
+	case a.animated():
+		a.Suggestion = "MP4 or WebM video"
+		optimized *= 1 - gifVideoSavings
+	}
+
+	if saved := int64(float64(sizeBytes)-optimized) / 1024; saved > 0 {
+		a.EstSavingsKB = saved
+	}
+
+	return a
+}
+
+// flagged reports whether the image should be written to the results
+func (a imageAudit) flagged() bool {
+	return len(a.Issues) > 0
+}
+
+// animated reports whether the image is a GIF with more than one frame
+func (a imageAudit) animated() bool {
+	return a.Frames > 1
+}
+
+// overDimensioned reports whether the image is more than retinaFactor times
+// larger than the size it's displayed at
+func (a imageAudit) overDimensioned() bool {
+	if a.Width == 0 || a.Height == 0 {
+		return false
+	}
+	if a.DisplayWidth > 0 && a.Width > a.DisplayWidth*retinaFactor {
+		return true
+	}
+	return a.DisplayHeight > 0 && a.Height > a.DisplayHeight*retinaFactor
+}
+
+// sniffImageFormat recognizes formats the standard library can't decode
+func sniffImageFormat(body []byte) string {
+	switch {
+	case len(body) >= 12 && string(body[0:4]) == "RIFF" && string(body[8:12]) == "WEBP":
+		return "webp"
+	case len(body) >= 12 && string(body[4:8]) == "ftyp" && (string(body[8:12]) == "avif" || string(body[8:12]) == "avis"):
+		return "avif"
+	case bytes.Contains(body[:min(len(body), 512)], []byte("<svg")):
+		return "svg"
+	}
+	return ""
+}
+
+// gifFrames counts a GIF's frames by walking its blocks, without decoding
+// them. It returns 0 for data that isn't a GIF.
+func gifFrames(body []byte) int {
+	if len(body) < 13 || (string(body[:6]) != "GIF87a" && string(body[:6]) != "GIF89a") {
+		return 0
+	}
+	i := 13
+	if flags := body[10]; flags&0x80 != 0 {
+		i += 3 << (flags&0x07 + 1) // global color table
+	}
+	// skipSubBlocks moves past a run of length-prefixed data sub-blocks
+	skipSubBlocks := func() bool {
+		for i < len(body) {
+			n := int(body[i])
+			i++
+			if n == 0 {
+				return true
+			}
+			i += n
+		}
+		return false
+	}
+
+	frames := 0
+	for i < len(body) {
+		switch body[i] {
+		case 0x21: // extension: its label, then sub-blocks
+			i += 2
+			if !skipSubBlocks() {
+				return frames
+			}
+		case 0x2C: // image descriptor
+			frames++
+			if i+10 > len(body) {
+				return frames
+			}
+			flags := body[i+9]
+			i += 10
+			if flags&0x80 != 0 {
+				i += 3 << (flags&0x07 + 1) // local color table
+			}
+			i++ // LZW minimum code size
+			if !skipSubBlocks() {
+				return frames
+			}
+		default: // the trailer, or data it can't read
+			return frames
+		}
+	}
+	return frames
+}
+
+// parseSrcset returns the image URLs in a srcset attribute, dropping the
+// width/density descriptors ("hero-800.jpg 800w, hero-1600.jpg 1600w")
+func parseSrcset(srcset string) []string {
+	var urls []string
+	for _, candidate := range strings.Split(srcset, ",") {
+		fields := strings.Fields(candidate)
+		if len(fields) > 0 {
+			urls = append(urls, fields[0])
+		}
+	}
+	return urls
+}
+
+// cssImageURLs returns the url(...) targets of background declarations in a
+// style attribute or <style> block
+func cssImageURLs(css string) []string {
+	var urls []string
+	for _, decl := range cssBackgroundRe.FindAllString(css, -1) {
+		for _, m := range cssURLRe.FindAllStringSubmatch(decl, -1) {
+			urls = append(urls, strings.TrimSpace(m[1]))
+		}
+	}
+	return urls
+}
+
+// parseDimension reads an <img> width/height attribute such as "300" or
+// "300px". Percentages and other units return 0.
+func parseDimension(v string) int {
+	v = strings.TrimSuffix(strings.TrimSpace(v), "px")
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,155 @@
+package crawler
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// ImagePathThreshold is an oversized-image size limit for the images whose
+// URL matches Pattern
+type ImagePathThreshold struct {
+	Pattern string // as for Config.ExcludePatterns, e.g. /img/hero/* or re:\.gif$
+	Limit   int64  // bytes
+}
+
+// Defaults for Config.AnimatedGIFThreshold and Config.VideoSizeThreshold
+const (
+	defaultAnimatedGIFLimit = 200 << 10
+	defaultVideoLimit       = 5 << 20
+)
+
+// imageLimits picks the size an image may be in oversized-image mode: the
+// limit of the first path rule its URL matches, or else the animated GIF
+// limit, or else the limit for its format, or else Config.ImageSizeThreshold.
+// Videos have a limit of their own.
+type imageLimits struct {
+	global   int64
+	animated int64
+	video    int64
+	byType   map[string]int64
+	paths    []imagePathLimit
+}
+
+type imagePathLimit struct {
+	pattern urlPattern
+	limit   int64
+}
+
+func newImageLimits(cfg Config) (*imageLimits, error) {
+	l := &imageLimits{
+		global:   cfg.ImageSizeThreshold,
+		animated: cfg.AnimatedGIFThreshold,
+		video:    cfg.VideoSizeThreshold,
+		byType:   make(map[string]int64, len(cfg.ImageTypeThresholds)),
+	}
+	if l.animated <= 0 {
+		l.animated = defaultAnimatedGIFLimit
+	}
+	if l.video <= 0 {
+		l.video = defaultVideoLimit
+	}
+	for format, limit := range cfg.ImageTypeThresholds {
+		l.byType[imageType(format)] = limit
+	}
+	for _, rule := range cfg.ImagePathThresholds {
+		pattern, err := compileURLPattern(strings.TrimSpace(rule.Pattern))
+		if err != nil {
+			return nil, err
+		}
+		l.paths = append(l.paths, imagePathLimit{pattern: pattern, limit: rule.Limit})
+	}
+	return l, nil
+}
+
+// imageType normalizes a format name or file extension: "JPG" and ".jpeg"
+// are both "jpeg"
+func imageType(name string) string {
+	name = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "."))
+	if name == "jpg" {
+		return "jpeg"
+	}
+	return name
+}
+
+// limit returns the size limit for the image at imageURL, whose detected
+// format is format ("" when it couldn't be told from its content, in which
+// case the URL's extension is used)
+func (l *imageLimits) limit(imageURL, format string, animated bool) int64 {
+	u, err := url.Parse(imageURL)
+	if err == nil {
+		for _, rule := range l.paths {
+			if matchAnyPattern([]urlPattern{rule.pattern}, u) {
+				return rule.limit
+			}
+		}
+		if animated {
+			return l.animated
+		}
+		if format == "" {
+			format = path.Ext(u.Path)
+		}
+	}
+	if limit, ok := l.byType[imageType(format)]; ok {
+		return limit
+	}
+	return l.global
+}
+
+// ParseImageTypeThresholds reads size limits by format as entered in the
+// wizard, e.g. "png=200, jpeg=400, gif=1MB". Sizes without a unit are in KB.
+func ParseImageTypeThresholds(entries []string) (map[string]int64, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	limits := make(map[string]int64, len(entries))
+	for _, entry := range entries {
+		format, size, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(format) == "" {
+			return nil, fmt.Errorf("%q should be a format and a size, e.g. png=200", entry)
+		}
+		limit, err := parseImageLimit(size)
+		if err != nil {
+			return nil, err
+		}
+		limits[imageType(format)] = limit
+	}
+	return limits, nil
+}
+
+// ParseImagePathThresholds reads size limits by path as entered in the
+// wizard, e.g. "/img/hero/*=1MB, /blog/*=300". Sizes without a unit are in
+// KB.
+func ParseImagePathThresholds(entries []string) ([]ImagePathThreshold, error) {
+	var rules []ImagePathThreshold
+	for _, entry := range entries {
+		i := strings.LastIndex(entry, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("%q should be a pattern and a size, e.g. /img/hero/*=1MB", entry)
+		}
+		pattern := strings.TrimSpace(entry[:i])
+		if _, err := compileURLPattern(pattern); err != nil {
+			return nil, err
+		}
+		limit, err := parseImageLimit(entry[i+1:])
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, ImagePathThreshold{Pattern: pattern, Limit: limit})
+	}
+	return rules, nil
+}
+
+// parseImageLimit reads a size in KB, or with a unit such as "1MB"
+func parseImageLimit(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if kb, err := strconv.ParseInt(s, 10, 64); err == nil && kb > 0 {
+		return kb * 1024, nil
+	}
+	if n, err := parseByteSize(s); err == nil && n > 0 {
+		return n, nil
+	}
+	return 0, fmt.Errorf("%q isn't a size like 200 (KB) or 1MB", s)
+}
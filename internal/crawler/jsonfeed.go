@@ -16,13 +16,12 @@ import (
 	"sync/atomic"
 	"time"
 
-	"github.com/chromedp/cdproto/emulation"
-	"github.com/chromedp/cdproto/page"
 	"github.com/chromedp/chromedp"
 )
 
 // FeedItem represents a single item from a JSON feed
 type FeedItem struct {
+	ID       string `json:"id"` // GUID or id, used to recognise the item in incremental runs
 	Headline string `json:"headline"`
 	Link     string `json:"link"`
 	Date     string `json:"date"`
@@ -32,63 +31,97 @@ type FeedItem struct {
 }
 
 type JSONFeedStats struct {
-	ItemsFetched   int64
-	ItemsFiltered  int64
-	PagesCapture   int64
-	PDFsGenerated  int64
-	ScreenshotsGen int64
-	Errors         int64
+	ItemsFetched     int64
+	ItemsFiltered    int64
+	AlreadyCaptured  int64 // items skipped because an earlier run captured them (JSONFeedOptions.StateFile)
+	FeedPages        int64 // pages of a paginated feed fetched
+	PagesCapture     int64
+	PDFsGenerated    int64
+	ScreenshotsGen   int64
+	ArchivesSaved    int64 // MHTML files
+	Errors           int64
+	SkippedTimeLimit int64 // items not captured because Config.MaxDuration ran out
+	SkippedBudget    int64 // items not captured because Config.MaxTotalBytes was used up
 }
 
-var (
-	jsonFeedStats      JSONFeedStats
-	jsonFeedStartTime  time.Time
-	jsonFeedOutputDir  string
-	jsonFeedFormat     CaptureFormat
-	jsonFeedBaseURL    *url.URL
-	jsonFeedWg         sync.WaitGroup
-	jsonFeedSema       chan struct{}
-	jsonFeedCSVFile    string
-	jsonFeedCSVMu      sync.Mutex
-	jsonCancelRequested int32
-)
+// jsonFeedCapture holds the state of a JSON feed capture run
+type jsonFeedCapture struct {
+	c               *Crawler
+	stats           JSONFeedStats
+	startTime       time.Time
+	outputDir       string
+	format          CaptureFormat
+	baseURL         *url.URL
+	wg              sync.WaitGroup
+	sema            chan struct{}
+	csvFile         string
+	csvMu           sync.Mutex
+	cancelRequested int32
+	browsers        *browserPool
+	feedType        string     // "JSON", "RSS 2.0", "Atom"... as detected from the feed
+	state           *feedState // items captured by earlier runs; nil unless JSONFeedOptions.StateFile is set
+}
+
+func newJSONFeedCapture(c *Crawler) *jsonFeedCapture {
+	return &jsonFeedCapture{
+		c:      c,
+		format: c.config.CaptureFormat,
+		sema:   make(chan struct{}, c.config.MaxConcurrency),
+	}
+}
 
 // StartJSONFeedCapture fetches a JSON feed and captures all article pages
 func StartJSONFeedCapture(cfg Config) {
-	jsonFeedStats = JSONFeedStats{}
-	jsonFeedStartTime = time.Now()
-	jsonFeedFormat = cfg.CaptureFormat
-	atomic.StoreInt32(&jsonCancelRequested, 0)
+	cfg.Mode = ModeJSONFeed
+	New(cfg).Run(context.Background())
+}
+
+func (j *jsonFeedCapture) run(ctx context.Context) {
+	cfg := j.c.config
+	j.startTime = time.Now()
 
-	if jsonFeedFormat == 0 {
-		jsonFeedFormat = CaptureBoth
+	if j.format == 0 {
+		j.format = CaptureBoth
 	}
 
 	var err error
-	jsonFeedBaseURL, err = url.Parse(cfg.StartURL)
+	j.baseURL, err = url.Parse(cfg.StartURL)
 	if err != nil {
-		fmt.Printf("❌ Invalid base URL: %v\n", err)
+		j.c.log.Error(fmt.Sprintf("❌ Invalid base URL: %v", err), "error", err)
 		return
 	}
 
+	// Check for the conversion tools before fetching the feed
+	if err := checkCMYKTools(j.format, cfg.CaptureOpts); err != nil {
+		j.c.log.Error(fmt.Sprintf("❌ %v", err), "error", err)
+		return
+	}
+
+	// Load the record of earlier runs for incremental capture
+	if cfg.JSONFeedOpts.StateFile != "" {
+		j.state, err = loadFeedState(cfg.JSONFeedOpts.StateFile, cfg.JSONFeedOpts.FeedURL)
+		if err != nil {
+			j.c.log.Error(fmt.Sprintf("❌ %v", err), "error", err)
+			return
+		}
+	}
+
 	// Create output directory with timestamp
 	timestamp := time.Now().Format("2006-01-02_15-04-05")
-	jsonFeedOutputDir = fmt.Sprintf("json_feed_captures_%s", timestamp)
-	os.MkdirAll(jsonFeedOutputDir, 0755)
+	j.outputDir = j.c.outputPath(fmt.Sprintf("json_feed_captures_%s", timestamp))
+	os.MkdirAll(j.outputDir, 0755)
 
 	// Create CSV file for feed data
-	jsonFeedCSVFile = filepath.Join(jsonFeedOutputDir, "feed_items.csv")
-	createJSONFeedCSV()
+	j.csvFile = filepath.Join(j.outputDir, "feed_items.csv")
+	j.createJSONFeedCSV()
 
-	jsonFeedSema = make(chan struct{}, cfg.MaxConcurrency)
+	// Articles are rendered in tabs of a few shared Chrome processes
+	j.browsers = newBrowserPool(j.c, chromedp.Flag("disable-web-security", true))
+	defer j.browsers.close()
 
 	// Start live stats
 	stopStats := make(chan bool)
-	go printJSONFeedLiveStats(stopStats)
-
-	// Start keyboard listener for cancellation
-	stopKeyListener := make(chan bool)
-	go listenForJSONFeedCancel(stopKeyListener)
+	go j.printJSONFeedLiveStats(stopStats)
 
 	fmt.Println("┌─────────────────── JSON FEED CAPTURE STARTING ──────────────────┐")
 	fmt.Printf("│  🌐 Base URL:  %-45s │\n", truncateString(cfg.StartURL, 45))
@@ -96,24 +129,38 @@ func StartJSONFeedCapture(cfg Config) {
 	if cfg.JSONFeedOpts.TagFilter != "" {
 		fmt.Printf("│  🏷️  Tag Filter: %-43s │\n", cfg.JSONFeedOpts.TagFilter)
 	}
-	fmt.Printf("│  📁 Output:    %-45s │\n", jsonFeedOutputDir)
-	fmt.Printf("│  📋 Format:    %-45s │\n", jsonFeedFormat.String())
-	fmt.Println("├──────────────────────────────────────────────────────────────────┤")
-	fmt.Println("│  💡 Press 'c' + Enter to cancel and save current progress       │")
+	if cfg.JSONFeedOpts.hasDateRange() {
+		fmt.Printf("│  📅 Dates:     %-45s │\n", cfg.JSONFeedOpts.dateRangeLabel())
+	}
+	if j.state != nil {
+		fmt.Printf("│  🔁 State:     %-45s │\n", truncateString(fmt.Sprintf("%s (%d captured before)", cfg.JSONFeedOpts.StateFile, len(j.state.Items)), 45))
+	}
+	fmt.Printf("│  📁 Output:    %-45s │\n", j.outputDir)
+	fmt.Printf("│  📋 Format:    %-45s │\n", j.format.String())
+	fmt.Printf("│  📱 Device:    %-45s │\n", truncateString(cfg.CaptureOpts.deviceLabel(), 45))
+	if j.format == CaptureImagesOnly || j.format == CaptureBoth {
+		fmt.Printf("│  🖼️  Images:    %-45s │\n", truncateString(cfg.CaptureOpts.imageLabel(), 45))
+	}
+	if j.format == CapturePDFOnly || j.format == CaptureBoth || j.format == CaptureCMYKPDF {
+		fmt.Printf("│  📐 Paper:     %-45s │\n", truncateString(cfg.CaptureOpts.String(), 45))
+	}
 	fmt.Println("└──────────────────────────────────────────────────────────────────┘")
 	fmt.Println()
 
 	// Fetch and parse the JSON feed
-	items, err := fetchJSONFeed(cfg.JSONFeedOpts.FeedURL, cfg.JSONFeedOpts)
+	items, err := j.fetchJSONFeed(ctx, cfg.JSONFeedOpts.FeedURL, cfg.JSONFeedOpts)
 	if err != nil {
-		fmt.Printf("❌ Error fetching JSON feed: %v\n", err)
+		j.c.log.Error(fmt.Sprintf("❌ Error fetching JSON feed: %v", err), "url", cfg.JSONFeedOpts.FeedURL, "error", err)
 		stopStats <- true
-		stopKeyListener <- true
 		return
 	}
 
-	atomic.StoreInt64(&jsonFeedStats.ItemsFetched, int64(len(items)))
-	fmt.Printf("📊 Fetched %d items from feed\n\n", len(items))
+	atomic.StoreInt64(&j.stats.ItemsFetched, int64(len(items)))
+	if cfg.JSONFeedOpts.Paging != 0 {
+		fmt.Printf("📊 Fetched %d items from %d pages of the %s feed\n\n", len(items), j.stats.FeedPages, j.feedType)
+	} else {
+		fmt.Printf("📊 Fetched %d items from %s feed\n\n", len(items), j.feedType)
+	}
 
 	// Filter items by tag if specified
 	if cfg.JSONFeedOpts.TagFilter != "" {
@@ -124,47 +171,125 @@ func StartJSONFeedCapture(cfg Config) {
 			}
 		}
 		items = filtered
-		atomic.StoreInt64(&jsonFeedStats.ItemsFiltered, int64(len(items)))
 		fmt.Printf("🏷️  Filtered to %d items with tag '%s'\n\n", len(items), cfg.JSONFeedOpts.TagFilter)
-	} else {
-		atomic.StoreInt64(&jsonFeedStats.ItemsFiltered, int64(len(items)))
 	}
 
+	// Filter items by date if a range is set; items without a readable
+	// date can't be placed in it, so they're left out too
+	if cfg.JSONFeedOpts.hasDateRange() {
+		filtered := make([]FeedItem, 0)
+		undated := 0
+		for _, item := range items {
+			date, ok := parseFeedDate(item.Date)
+			if !ok {
+				date, ok = parseFeedDate(item.DateCode)
+			}
+			if !ok {
+				undated++
+				continue
+			}
+			if cfg.JSONFeedOpts.inDateRange(date) {
+				filtered = append(filtered, item)
+			}
+		}
+		items = filtered
+		if undated > 0 {
+			j.c.log.Warn(fmt.Sprintf("⚠️  Skipped %d items with no date or one in an unrecognised format", undated), "count", undated)
+		}
+		fmt.Printf("📅 Filtered to %d items dated %s\n\n", len(items), cfg.JSONFeedOpts.dateRangeLabel())
+	}
+
+	// Skip items captured by earlier runs
+	if j.state != nil {
+		fresh := make([]FeedItem, 0)
+		for _, item := range items {
+			if j.state.captured(item, resolveURL(cfg.StartURL, item.Link)) {
+				atomic.AddInt64(&j.stats.AlreadyCaptured, 1)
+				continue
+			}
+			fresh = append(fresh, item)
+		}
+		items = fresh
+		fmt.Printf("🔁 %d new items, %d already captured\n\n", len(items), j.stats.AlreadyCaptured)
+	}
+	atomic.StoreInt64(&j.stats.ItemsFiltered, int64(len(items)))
+
 	// Process each item
 	for _, item := range items {
-		if atomic.LoadInt32(&jsonCancelRequested) == 1 {
+		if j.stopped(ctx) {
 			break
 		}
+		if j.c.outOfTime() {
+			atomic.AddInt64(&j.stats.SkippedTimeLimit, 1)
+			continue
+		}
+		if j.c.outOfBudget() {
+			atomic.AddInt64(&j.stats.SkippedBudget, 1)
+			continue
+		}
 
 		// Resolve relative URLs
 		itemURL := resolveURL(cfg.StartURL, item.Link)
 
 		// Write to CSV
-		writeJSONFeedCSV(item, itemURL)
+		j.writeJSONFeedCSV(item, itemURL)
 
 		// Capture the page
-		jsonFeedWg.Add(1)
+		j.wg.Add(1)
 		go func(feedItem FeedItem, pageURL string) {
-			defer jsonFeedWg.Done()
-			jsonFeedSema <- struct{}{}
-			defer func() { <-jsonFeedSema }()
+			defer j.wg.Done()
+			select {
+			case j.sema <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-j.sema }()
 
-			if atomic.LoadInt32(&jsonCancelRequested) == 1 {
+			if !j.c.proceed(ctx) {
+				return
+			}
+			if j.c.outOfTime() {
+				atomic.AddInt64(&j.stats.SkippedTimeLimit, 1)
+				return
+			}
+			if j.c.outOfBudget() {
+				atomic.AddInt64(&j.stats.SkippedBudget, 1)
+				return
+			}
+			if !j.c.limiter.wait(ctx, pageURL) {
 				return
 			}
 
-			captureJSONFeedPage(pageURL, feedItem)
+			if j.captureJSONFeedPage(ctx, pageURL, feedItem) && j.state != nil {
+				j.state.record(feedItem, pageURL, j.outputDir)
+			}
 		}(item, itemURL)
 	}
 
-	jsonFeedWg.Wait()
+	j.wg.Wait()
+
+	// Items that failed aren't recorded, so the next run tries them again
+	if j.state != nil {
+		if err := j.state.save(); err != nil {
+			j.c.log.Error(fmt.Sprintf("❌ Error saving feed state: %v", err), "path", cfg.JSONFeedOpts.StateFile, "error", err)
+		}
+	}
+
+	// Ctrl+C and Stop both count as cancelling
+	if j.stopped(ctx) {
+		atomic.StoreInt32(&j.cancelRequested, 1)
+	}
 	stopStats <- true
-	stopKeyListener <- true
-	printJSONFeedFinalStats()
+	j.printJSONFeedFinalStats()
+}
+
+// stopped reports whether Stop was called or ctx was cancelled
+func (j *jsonFeedCapture) stopped(ctx context.Context) bool {
+	return j.c.interrupted(ctx)
 }
 
-func createJSONFeedCSV() {
-	f, _ := os.Create(jsonFeedCSVFile)
+func (j *jsonFeedCapture) createJSONFeedCSV() {
+	f, _ := os.Create(j.csvFile)
 	defer f.Close()
 
 	w := csv.NewWriter(f)
@@ -172,44 +297,113 @@ func createJSONFeedCSV() {
 	w.Write([]string{"Headline", "Link", "Date", "Brief", "Tags", "CapturedFile"})
 }
 
-func writeJSONFeedCSV(item FeedItem, fullURL string) {
-	jsonFeedCSVMu.Lock()
-	defer jsonFeedCSVMu.Unlock()
+func (j *jsonFeedCapture) writeJSONFeedCSV(item FeedItem, fullURL string) {
+	j.csvMu.Lock()
+	defer j.csvMu.Unlock()
 
-	f, _ := os.OpenFile(jsonFeedCSVFile, os.O_APPEND|os.O_WRONLY, 0644)
+	f, _ := os.OpenFile(j.csvFile, os.O_APPEND|os.O_WRONLY, 0644)
 	defer f.Close()
 
-	filename := sanitizeFilename(fullURL)
+	filename := sanitizeFilename(fullURL, j.c.config.IgnoreQueryParams)
 	w := csv.NewWriter(f)
 	defer w.Flush()
 	w.Write([]string{item.Headline, fullURL, item.Date, item.Brief, item.Tags, filename})
 }
 
-func fetchJSONFeed(feedURL string, opts JSONFeedOptions) ([]FeedItem, error) {
-	req, err := http.NewRequest("GET", feedURL, nil)
+// fetchJSONFeed fetches the feed's items, following its pages when
+// opts.Paging is set. Items already seen on an earlier page are skipped.
+func (j *jsonFeedCapture) fetchJSONFeed(ctx context.Context, feedURL string, opts JSONFeedOptions) ([]FeedItem, error) {
+	maxPages := opts.MaxPages
+	if maxPages <= 0 {
+		maxPages = defaultMaxFeedPages
+	}
+
+	var items []FeedItem
+	seenLinks := make(map[string]bool)
+	seenPages := make(map[string]bool)
+	pageURL := firstFeedPage(feedURL, opts)
+	for page := 1; pageURL != "" && !seenPages[pageURL]; page++ {
+		if page > maxPages {
+			j.c.log.Warn(fmt.Sprintf("⚠️  Stopped after %d feed pages", maxPages), "url", pageURL)
+			break
+		}
+		seenPages[pageURL] = true
+
+		doc, header, err := j.fetchFeedPage(ctx, pageURL)
+		var rawItems []map[string]any
+		if err == nil {
+			rawItems, err = feedItems(doc, opts.ItemsPath)
+		}
+		if err != nil {
+			if page == 1 {
+				return nil, err
+			}
+			j.c.log.Warn(fmt.Sprintf("⚠️  Feed page %d: %v - stopping", page, err), "url", pageURL, "error", err)
+			break
+		}
+		atomic.AddInt64(&j.stats.FeedPages, 1)
+
+		// Map raw items to FeedItem using configured or default field names
+		for _, raw := range rawItems {
+			item := FeedItem{
+				ID:       getStringField(raw, "", "guid", "id"),
+				Headline: getStringField(raw, opts.HeadlineField, "headline", "title", "name"),
+				Link:     getStringField(raw, opts.LinkField, "link", "url", "href", "permalink"),
+				Date:     getStringField(raw, opts.DateField, "date", "published", "pubDate", "created"),
+				DateCode: getStringField(raw, "", "datecode", "timestamp"),
+				Brief:    getStringField(raw, opts.BriefField, "brief", "summary", "description", "excerpt"),
+				Tags:     getStringField(raw, opts.TagsField, "tags", "categories", "keywords"),
+			}
+
+			// Skip items without a link, and ones repeated across pages
+			if item.Link == "" || seenLinks[item.Link] {
+				continue
+			}
+			seenLinks[item.Link] = true
+
+			items = append(items, item)
+		}
+
+		if opts.Paging == 0 {
+			break
+		}
+		pageURL = nextFeedPage(opts, pageURL, doc, header, len(rawItems))
+	}
+
+	return items, nil
+}
+
+// fetchFeedPage fetches and decodes one page of the feed, which may be JSON,
+// RSS or Atom
+func (j *jsonFeedCapture) fetchFeedPage(ctx context.Context, pageURL string) (any, http.Header, error) {
+	if !j.c.limiter.wait(ctx, pageURL) {
+		return nil, nil, context.Canceled
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	req.Header.Set("User-Agent", userAgents[0])
-	req.Header.Set("Accept", "application/json, */*")
+	req.Header.Set("User-Agent", j.c.fingerprint.userAgent)
+	req.Header.Set("Accept", "application/json, application/feed+json, application/rss+xml, application/atom+xml, application/xml;q=0.9, */*;q=0.8")
 	req.Header.Set("Accept-Encoding", "gzip, deflate")
 
-	resp, err := httpClient.Do(req)
+	resp, err := j.c.httpClient.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+		return nil, nil, fmt.Errorf("HTTP %d", resp.StatusCode)
 	}
 
 	var reader io.Reader = resp.Body
 	if resp.Header.Get("Content-Encoding") == "gzip" {
 		gzReader, err := gzip.NewReader(resp.Body)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		defer gzReader.Close()
 		reader = gzReader
@@ -217,36 +411,25 @@ func fetchJSONFeed(feedURL string, opts JSONFeedOptions) ([]FeedItem, error) {
 
 	body, err := io.ReadAll(reader)
 	if err != nil {
-		return nil, err
-	}
-
-	// Try to parse as array of objects with flexible field names
-	var rawItems []map[string]any
-	if err := json.Unmarshal(body, &rawItems); err != nil {
-		return nil, fmt.Errorf("invalid JSON: %v", err)
+		return nil, nil, err
 	}
 
-	// Map raw items to FeedItem using configured or default field names
-	items := make([]FeedItem, 0, len(rawItems))
-	for _, raw := range rawItems {
-		item := FeedItem{
-			Headline: getStringField(raw, opts.HeadlineField, "headline", "title", "name"),
-			Link:     getStringField(raw, opts.LinkField, "link", "url", "href", "permalink"),
-			Date:     getStringField(raw, opts.DateField, "date", "published", "pubDate", "created"),
-			DateCode: getStringField(raw, "", "datecode", "timestamp"),
-			Brief:    getStringField(raw, opts.BriefField, "brief", "summary", "description", "excerpt"),
-			Tags:     getStringField(raw, opts.TagsField, "tags", "categories", "keywords"),
-		}
-
-		// Skip items without a link
-		if item.Link == "" {
-			continue
+	if isXMLFeed(body) {
+		doc, feedType, err := decodeXMLFeed(body, resp.Request.URL.String())
+		if err != nil {
+			return nil, nil, err
 		}
-
-		items = append(items, item)
+		j.feedType = feedType
+		return doc, resp.Header, nil
 	}
 
-	return items, nil
+	// JSON feeds are either a top-level array of objects or an object wrapping one
+	var doc any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, nil, fmt.Errorf("invalid JSON: %v", err)
+	}
+	j.feedType = "JSON"
+	return doc, resp.Header, nil
 }
 
 // getStringField extracts a string value from a map, trying multiple field names
@@ -342,60 +525,61 @@ func sanitizeHeadlineFilename(headline, dateCode string) string {
 	return name
 }
 
-func captureJSONFeedPage(pageURL string, item FeedItem) {
-	atomic.AddInt64(&jsonFeedStats.PagesCapture, 1)
+func (j *jsonFeedCapture) captureJSONFeedPage(parent context.Context, pageURL string, item FeedItem) bool {
+	atomic.AddInt64(&j.stats.PagesCapture, 1)
 
 	// Use headline for filename if available, otherwise use URL
 	var filename string
 	if item.Headline != "" {
 		filename = sanitizeHeadlineFilename(item.Headline, item.DateCode)
 	} else {
-		filename = sanitizeFilename(pageURL)
+		filename = sanitizeFilename(pageURL, j.c.config.IgnoreQueryParams)
 	}
-	pdfPath := filepath.Join(jsonFeedOutputDir, filename+".pdf")
-	pngPath := filepath.Join(jsonFeedOutputDir, filename+".png")
+	pdfPath := filepath.Join(j.outputDir, filename+".pdf")
+	imagePath := filepath.Join(j.outputDir, filename+"."+j.c.config.CaptureOpts.ImageFormat.Ext())
+	mhtmlPath := filepath.Join(j.outputDir, filename+".mhtml")
 
 	// Check if already captured
-	switch jsonFeedFormat {
+	switch j.format {
 	case CapturePDFOnly, CaptureCMYKPDF:
 		if _, err := os.Stat(pdfPath); err == nil {
-			return
+			return true
 		}
 	case CaptureImagesOnly, CaptureCMYKTIFF:
-		if _, err := os.Stat(pngPath); err == nil {
-			return
+		if _, err := os.Stat(imagePath); err == nil {
+			return true
 		}
 	case CaptureBoth:
 		if _, err := os.Stat(pdfPath); err == nil {
-			return
+			return true
+		}
+	case CaptureMHTML:
+		if _, err := os.Stat(mhtmlPath); err == nil {
+			return true
 		}
 	}
 
-	// Create Chrome context
-	opts := append(chromedp.DefaultExecAllocatorOptions[:],
-		chromedp.Flag("headless", true),
-		chromedp.Flag("disable-gpu", true),
-		chromedp.Flag("no-sandbox", true),
-		chromedp.Flag("disable-setuid-sandbox", true),
-		chromedp.Flag("disable-dev-shm-usage", true),
-		chromedp.Flag("disable-web-security", true),
-		chromedp.Flag("ignore-certificate-errors", true),
-		chromedp.WindowSize(1920, 1080),
-	)
-
-	allocCtx, allocCancel := chromedp.NewExecAllocator(context.Background(), opts...)
-	defer allocCancel()
-
-	ctx, cancel := chromedp.NewContext(allocCtx)
+	// Open a tab in one of the shared browsers
+	ctx, cancel, err := j.browsers.tab(parent)
+	if err != nil {
+		atomic.AddInt64(&j.stats.Errors, 1)
+		j.c.log.Error(fmt.Sprintf("❌ Error: %s - %v", truncateString(pageURL, 40), err), "url", pageURL, "error", err)
+		return false
+	}
 	defer cancel()
 
 	ctx, cancel = context.WithTimeout(ctx, 180*time.Second)
 	defer cancel()
 
 	var pdfBuf []byte
-	var pngBuf []byte
+	var pageTitle string
+	var imageBuf []byte
+	var imageFormat ImageFormat
+	var mhtml string
 
 	actions := []chromedp.Action{
+		j.c.browserRequestHeaders(pageURL),
+		j.c.config.CaptureOpts.emulate(),
 		chromedp.Navigate(pageURL),
 		chromedp.WaitReady("body", chromedp.ByQuery),
 		chromedp.Sleep(2 * time.Second),
@@ -429,136 +613,116 @@ func captureJSONFeedPage(pageURL string, item FeedItem) {
 			return nil
 		}),
 		chromedp.Sleep(1 * time.Second),
+		j.c.config.CaptureOpts.cleanPage(pageURL, j.c.log),
+	}
+
+	// Save the page as one MHTML file if needed
+	if j.format == CaptureMHTML {
+		actions = append(actions, snapshotMHTML(&mhtml))
 	}
 
 	// Add screenshot capture if needed
-	needsScreenshot := jsonFeedFormat == CaptureImagesOnly ||
-		jsonFeedFormat == CaptureBoth ||
-		jsonFeedFormat == CaptureCMYKTIFF
+	needsScreenshot := j.format == CaptureImagesOnly ||
+		j.format == CaptureBoth ||
+		j.format == CaptureCMYKTIFF
 
 	if needsScreenshot {
-		actions = append(actions, chromedp.ActionFunc(func(ctx context.Context) error {
-			_, _, contentSize, _, _, _, err := page.GetLayoutMetrics().Do(ctx)
-			if err != nil {
-				return err
-			}
-
-			width, height := int64(contentSize.Width), int64(contentSize.Height)
-			if height > 16384 {
-				height = 16384
-			}
-
-			err = emulation.SetDeviceMetricsOverride(width, height, 1, false).
-				WithScreenOrientation(&emulation.ScreenOrientation{
-					Type:  emulation.OrientationTypePortraitPrimary,
-					Angle: 0,
-				}).Do(ctx)
-			if err != nil {
-				return err
-			}
-
-			pngBuf, err = page.CaptureScreenshot().
-				WithQuality(100).
-				WithFromSurface(true).
-				Do(ctx)
-			return err
-		}))
+		// CMYK TIFFs are converted from a lossless PNG
+		opts := j.c.config.CaptureOpts
+		if j.format == CaptureCMYKTIFF {
+			opts.ImageFormat = ImagePNG
+		}
+		actions = append(actions, opts.screenshot(pageURL, &imageBuf, &imageFormat, j.c.log))
 	}
 
 	// Add PDF generation if needed
-	needsPDF := jsonFeedFormat == CapturePDFOnly ||
-		jsonFeedFormat == CaptureBoth ||
-		jsonFeedFormat == CaptureCMYKPDF
+	needsPDF := j.format == CapturePDFOnly ||
+		j.format == CaptureBoth ||
+		j.format == CaptureCMYKPDF
 
 	if needsPDF {
 		actions = append(actions, chromedp.ActionFunc(func(ctx context.Context) error {
+			// The page's title stands in for a missing headline in the
+			// PDF's metadata
+			chromedp.Title(&pageTitle).Do(ctx)
+
 			var err error
-			pdfBuf, _, err = page.PrintToPDF().
-				WithPrintBackground(true).
-				WithScale(1.0).
-				WithPaperWidth(8.5).
-				WithPaperHeight(11).
-				WithMarginTop(0.4).
-				WithMarginBottom(0.4).
-				WithMarginLeft(0.4).
-				WithMarginRight(0.4).
-				Do(ctx)
+			pdfBuf, _, err = j.c.config.CaptureOpts.printToPDF().Do(ctx)
 			return err
 		}))
 	}
 
-	err := chromedp.Run(ctx, actions...)
+	err = chromedp.Run(ctx, actions...)
 	if err != nil {
-		atomic.AddInt64(&jsonFeedStats.Errors, 1)
-		fmt.Print("\033[2K\r")
-		fmt.Printf("❌ Error: %s - %v\n\n", truncateString(pageURL, 40), err)
-		return
+		atomic.AddInt64(&j.stats.Errors, 1)
+		j.c.log.Error(fmt.Sprintf("❌ Error: %s - %v\n", truncateString(pageURL, 40), err), "url", pageURL, "error", err)
+		return false
+	}
+
+	if needsPDF {
+		title := item.Headline
+		if title == "" {
+			title = pageTitle
+		}
+		pdfBuf = j.c.stampPDF(pdfBuf, pdfMetadata{Title: title, URL: pageURL, Captured: time.Now(), Tags: item.Tags})
 	}
 
 	// Save files
-	if jsonFeedFormat == CapturePDFOnly || jsonFeedFormat == CaptureBoth {
+	if j.format == CapturePDFOnly || j.format == CaptureBoth {
 		if err := os.WriteFile(pdfPath, pdfBuf, 0644); err != nil {
-			atomic.AddInt64(&jsonFeedStats.Errors, 1)
-			return
+			atomic.AddInt64(&j.stats.Errors, 1)
+			return false
 		}
-		atomic.AddInt64(&jsonFeedStats.PDFsGenerated, 1)
+		atomic.AddInt64(&j.stats.PDFsGenerated, 1)
 	}
 
-	if jsonFeedFormat == CaptureCMYKPDF {
-		tempPdfPath := filepath.Join(jsonFeedOutputDir, filename+"_temp.pdf")
+	if j.format == CaptureCMYKPDF {
+		tempPdfPath := filepath.Join(j.outputDir, filename+"_temp.pdf")
 		if err := os.WriteFile(tempPdfPath, pdfBuf, 0644); err != nil {
-			atomic.AddInt64(&jsonFeedStats.Errors, 1)
-			return
+			atomic.AddInt64(&j.stats.Errors, 1)
+			return false
 		}
-		cmykPdfPath := filepath.Join(jsonFeedOutputDir, filename+"_cmyk.pdf")
-		if err := convertToCMYKPDF(tempPdfPath, cmykPdfPath); err != nil {
-			atomic.AddInt64(&jsonFeedStats.Errors, 1)
+		cmykPdfPath := filepath.Join(j.outputDir, filename+"_cmyk.pdf")
+		if err := convertToCMYKPDF(tempPdfPath, cmykPdfPath, j.c.config.CaptureOpts.CMYKProfile); err != nil {
+			atomic.AddInt64(&j.stats.Errors, 1)
+			j.c.log.Error(fmt.Sprintf("❌ CMYK conversion failed: %s - %v", truncateString(pageURL, 40), err), "url", pageURL, "error", err)
 			os.Remove(tempPdfPath)
-			return
+			return false
 		}
 		os.Remove(tempPdfPath)
-		atomic.AddInt64(&jsonFeedStats.PDFsGenerated, 1)
+		atomic.AddInt64(&j.stats.PDFsGenerated, 1)
 	}
 
-	if jsonFeedFormat == CaptureImagesOnly || jsonFeedFormat == CaptureBoth {
-		if err := os.WriteFile(pngPath, pngBuf, 0644); err != nil {
-			atomic.AddInt64(&jsonFeedStats.Errors, 1)
-			return
+	if j.format == CaptureImagesOnly || j.format == CaptureBoth {
+		imagePath = filepath.Join(j.outputDir, filename+"."+imageFormat.Ext())
+		if err := os.WriteFile(imagePath, imageBuf, 0644); err != nil {
+			atomic.AddInt64(&j.stats.Errors, 1)
+			return false
 		}
-		atomic.AddInt64(&jsonFeedStats.ScreenshotsGen, 1)
+		atomic.AddInt64(&j.stats.ScreenshotsGen, 1)
 	}
 
-	if jsonFeedFormat == CaptureCMYKTIFF {
-		tempPngPath := filepath.Join(jsonFeedOutputDir, filename+"_temp.png")
-		if err := os.WriteFile(tempPngPath, pngBuf, 0644); err != nil {
-			atomic.AddInt64(&jsonFeedStats.Errors, 1)
-			return
-		}
-		tiffPath := filepath.Join(jsonFeedOutputDir, filename+"_cmyk.tiff")
-		if err := convertToCMYKTIFF(tempPngPath, tiffPath); err != nil {
-			atomic.AddInt64(&jsonFeedStats.Errors, 1)
-			os.Remove(tempPngPath)
-			return
+	if j.format == CaptureCMYKTIFF {
+		tiffPath := filepath.Join(j.outputDir, filename+"_cmyk.tiff")
+		if err := writeCMYKTIFF(imageBuf, tiffPath); err != nil {
+			atomic.AddInt64(&j.stats.Errors, 1)
+			j.c.log.Error(fmt.Sprintf("❌ CMYK conversion failed: %s - %v", truncateString(pageURL, 40), err), "url", pageURL, "error", err)
+			return false
 		}
-		os.Remove(tempPngPath)
-		atomic.AddInt64(&jsonFeedStats.ScreenshotsGen, 1)
+		atomic.AddInt64(&j.stats.ScreenshotsGen, 1)
 	}
-}
 
-func listenForJSONFeedCancel(stop chan bool) {
-	// Reuse the same cancel listener pattern
-	for {
-		select {
-		case <-stop:
-			return
-		default:
-			// Non-blocking check - actual input handled by main cancel listener
-			time.Sleep(100 * time.Millisecond)
+	if j.format == CaptureMHTML {
+		if err := os.WriteFile(mhtmlPath, []byte(mhtml), 0644); err != nil {
+			atomic.AddInt64(&j.stats.Errors, 1)
+			return false
 		}
+		atomic.AddInt64(&j.stats.ArchivesSaved, 1)
 	}
+	return true
 }
 
-func printJSONFeedLiveStats(stop chan bool) {
+func (j *jsonFeedCapture) printJSONFeedLiveStats(stop chan bool) {
 	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
 
@@ -570,12 +734,16 @@ func printJSONFeedLiveStats(stop chan bool) {
 		case <-stop:
 			return
 		case <-ticker.C:
-			elapsed := time.Since(jsonFeedStartTime)
-			total := atomic.LoadInt64(&jsonFeedStats.ItemsFiltered)
-			captured := atomic.LoadInt64(&jsonFeedStats.PagesCapture)
-			pdfs := atomic.LoadInt64(&jsonFeedStats.PDFsGenerated)
-			screenshots := atomic.LoadInt64(&jsonFeedStats.ScreenshotsGen)
-			errors := atomic.LoadInt64(&jsonFeedStats.Errors)
+			if j.c.config.Quiet {
+				continue
+			}
+			elapsed := time.Since(j.startTime)
+			total := atomic.LoadInt64(&j.stats.ItemsFiltered)
+			captured := atomic.LoadInt64(&j.stats.PagesCapture)
+			pdfs := atomic.LoadInt64(&j.stats.PDFsGenerated)
+			screenshots := atomic.LoadInt64(&j.stats.ScreenshotsGen)
+			archives := atomic.LoadInt64(&j.stats.ArchivesSaved)
+			errors := atomic.LoadInt64(&j.stats.Errors)
 
 			pagesPerSec := float64(captured) / elapsed.Seconds()
 			if elapsed.Seconds() < 1 {
@@ -613,7 +781,7 @@ func printJSONFeedLiveStats(stop chan bool) {
 			}
 
 			fmt.Print("\033[2K\r")
-			switch jsonFeedFormat {
+			switch j.format {
 			case CapturePDFOnly:
 				fmt.Printf("%s \033[32m[%s]\033[0m %3d%% │ ⏱ %s │ 📑 %d captured │ ⏳ %d pending │ ❌ %d │ %.1f/s",
 					spinner, bar, pct, formatDuration(elapsed), pdfs, pending, errors, pagesPerSec)
@@ -623,6 +791,9 @@ func printJSONFeedLiveStats(stop chan bool) {
 			case CaptureBoth:
 				fmt.Printf("%s \033[32m[%s]\033[0m %3d%% │ ⏱ %s │ 📑 %d │ 🖼️ %d │ ⏳ %d pending │ ❌ %d │ %.1f/s",
 					spinner, bar, pct, formatDuration(elapsed), pdfs, screenshots, pending, errors, pagesPerSec)
+			case CaptureMHTML:
+				fmt.Printf("%s \033[32m[%s]\033[0m %3d%% │ ⏱ %s │ 📦 %d captured │ ⏳ %d pending │ ❌ %d │ %.1f/s",
+					spinner, bar, pct, formatDuration(elapsed), archives, pending, errors, pagesPerSec)
 			default:
 				fmt.Printf("%s \033[32m[%s]\033[0m %3d%% │ ⏱ %s │ 📄 %d/%d │ ❌ %d │ %.1f/s",
 					spinner, bar, pct, formatDuration(elapsed), captured, total, errors, pagesPerSec)
@@ -631,9 +802,9 @@ func printJSONFeedLiveStats(stop chan bool) {
 	}
 }
 
-func printJSONFeedFinalStats() {
-	elapsed := time.Since(jsonFeedStartTime)
-	wasCancelled := atomic.LoadInt32(&jsonCancelRequested) == 1
+func (j *jsonFeedCapture) printJSONFeedFinalStats() {
+	elapsed := time.Since(j.startTime)
+	wasCancelled := atomic.LoadInt32(&j.cancelRequested) == 1
 
 	fmt.Print("\033[2K\r")
 	fmt.Println()
@@ -647,31 +818,53 @@ func printJSONFeedFinalStats() {
 	fmt.Println("╠═══════════════════════════════════════════════════════════════════╣")
 	fmt.Println("║                                                                   ║")
 	fmt.Printf("║  ⏱️  Total Time:           %-40s ║\n", formatDuration(elapsed))
-	fmt.Printf("║  📡 Feed Items Fetched:    %-40d ║\n", jsonFeedStats.ItemsFetched)
-	fmt.Printf("║  🏷️  Items After Filter:    %-40d ║\n", jsonFeedStats.ItemsFiltered)
-	fmt.Printf("║  📄 Pages Captured:        %-40d ║\n", jsonFeedStats.PagesCapture)
+	fmt.Printf("║  📡 Feed Items Fetched:    %-40d ║\n", j.stats.ItemsFetched)
+	if j.c.config.JSONFeedOpts.Paging != 0 {
+		fmt.Printf("║  📚 Feed Pages:            %-40d ║\n", j.stats.FeedPages)
+	}
+	if j.state != nil {
+		fmt.Printf("║  🔁 Already Captured:      %-40d ║\n", j.stats.AlreadyCaptured)
+	}
+	fmt.Printf("║  🏷️  Items After Filter:    %-40d ║\n", j.stats.ItemsFiltered)
+	fmt.Printf("║  📄 Pages Captured:        %-40d ║\n", j.stats.PagesCapture)
 
-	switch jsonFeedFormat {
+	switch j.format {
 	case CapturePDFOnly:
-		fmt.Printf("║  📑 PDFs Generated:        %-40d ║\n", jsonFeedStats.PDFsGenerated)
+		fmt.Printf("║  📑 PDFs Generated:        %-40d ║\n", j.stats.PDFsGenerated)
 	case CaptureImagesOnly:
-		fmt.Printf("║  🖼️  Images Generated:      %-40d ║\n", jsonFeedStats.ScreenshotsGen)
+		fmt.Printf("║  🖼️  Images Generated:      %-40d ║\n", j.stats.ScreenshotsGen)
 	case CaptureBoth:
-		fmt.Printf("║  📑 PDFs Generated:        %-40d ║\n", jsonFeedStats.PDFsGenerated)
-		fmt.Printf("║  🖼️  Images Generated:      %-40d ║\n", jsonFeedStats.ScreenshotsGen)
+		fmt.Printf("║  📑 PDFs Generated:        %-40d ║\n", j.stats.PDFsGenerated)
+		fmt.Printf("║  🖼️  Images Generated:      %-40d ║\n", j.stats.ScreenshotsGen)
 	case CaptureCMYKPDF:
-		fmt.Printf("║  🎨 CMYK PDFs Generated:   %-40d ║\n", jsonFeedStats.PDFsGenerated)
+		fmt.Printf("║  🎨 CMYK PDFs Generated:   %-40d ║\n", j.stats.PDFsGenerated)
 	case CaptureCMYKTIFF:
-		fmt.Printf("║  🎨 CMYK TIFFs Generated:  %-40d ║\n", jsonFeedStats.ScreenshotsGen)
+		fmt.Printf("║  🎨 CMYK TIFFs Generated:  %-40d ║\n", j.stats.ScreenshotsGen)
+	case CaptureMHTML:
+		fmt.Printf("║  📦 MHTML Files Saved:     %-40d ║\n", j.stats.ArchivesSaved)
 	}
 
-	fmt.Printf("║  ❌ Errors:                %-40d ║\n", jsonFeedStats.Errors)
-	fmt.Printf("║  📁 Output Directory:      %-40s ║\n", jsonFeedOutputDir)
+	fmt.Printf("║  ❌ Errors:                %-40d ║\n", j.stats.Errors)
+	fmt.Printf("║  📁 Output Directory:      %-40s ║\n", j.outputDir)
 	fmt.Printf("║  📋 CSV Index:             %-40s ║\n", "feed_items.csv")
+	if j.c.config.MaxDuration > 0 {
+		fmt.Printf("║  ⌛ Skipped (Time Limit):  %-40d ║\n", j.stats.SkippedTimeLimit)
+	}
+	if j.c.config.MaxTotalBytes > 0 {
+		fmt.Printf("║  💸 Skipped (Budget):      %-40d ║\n", j.stats.SkippedBudget)
+	}
 	fmt.Println("║                                                                   ║")
 	if wasCancelled {
 		fmt.Println("║  ℹ️  Capture was cancelled early - partial results saved         ║")
 		fmt.Println("║                                                                   ║")
+	} else if atomic.LoadInt32(&j.c.timedOut) == 1 {
+		fmt.Printf("║  ℹ️  Time limit reached - captured %-31s ║\n",
+			fmt.Sprintf("%.1f%% of feed items", coverage(j.stats.ItemsFiltered-j.stats.SkippedTimeLimit, j.stats.SkippedTimeLimit)))
+		fmt.Println("║                                                                   ║")
+	} else if j.c.outOfBudget() {
+		fmt.Printf("║  ℹ️  Download budget used up - captured %-26s ║\n",
+			fmt.Sprintf("%.1f%% of feed items", coverage(j.stats.ItemsFiltered-j.stats.SkippedBudget, j.stats.SkippedBudget)))
+		fmt.Println("║                                                                   ║")
 	}
 	fmt.Println("╚═══════════════════════════════════════════════════════════════════╝")
 }
@@ -0,0 +1,127 @@
+package crawler
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// lastModMetaKeys are <meta> property/name/itemprop values that carry a
+// page's modification time, in order of preference
+var lastModMetaKeys = []string{
+	"article:modified_time",
+	"og:updated_time",
+	"datemodified",
+	"last-modified",
+}
+
+// lastModLayouts are the date formats CMSs commonly emit
+var lastModLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	time.RFC1123,
+	time.RFC1123Z,
+}
+
+// pageLastModified finds a modification date in the page's meta tags or
+// JSON-LD (dateModified) and returns it as YYYY-MM-DD, or "" if none is found.
+func pageLastModified(doc *html.Node) string {
+	if doc == nil {
+		return ""
+	}
+
+	meta := make(map[string]string)
+	var jsonLD []string
+
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "meta":
+				var key, content string
+				for _, a := range n.Attr {
+					switch a.Key {
+					case "property", "name", "itemprop", "http-equiv":
+						key = strings.ToLower(strings.TrimSpace(a.Val))
+					case "content":
+						content = a.Val
+					}
+				}
+				if key != "" && content != "" {
+					if _, exists := meta[key]; !exists {
+						meta[key] = content
+					}
+				}
+			case "script":
+				for _, a := range n.Attr {
+					if a.Key == "type" && strings.EqualFold(strings.TrimSpace(a.Val), "application/ld+json") && n.FirstChild != nil {
+						jsonLD = append(jsonLD, n.FirstChild.Data)
+					}
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(doc)
+
+	for _, key := range lastModMetaKeys {
+		if d := formatLastMod(meta[key]); d != "" {
+			return d
+		}
+	}
+
+	for _, script := range jsonLD {
+		var v interface{}
+		if err := json.Unmarshal([]byte(script), &v); err != nil {
+			continue
+		}
+		if d := formatLastMod(findDateModified(v)); d != "" {
+			return d
+		}
+	}
+
+	return ""
+}
+
+// findDateModified searches JSON-LD (including @graph arrays) for dateModified
+func findDateModified(v interface{}) string {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if s, ok := t["dateModified"].(string); ok && s != "" {
+			return s
+		}
+		for _, child := range t {
+			if s := findDateModified(child); s != "" {
+				return s
+			}
+		}
+	case []interface{}:
+		for _, child := range t {
+			if s := findDateModified(child); s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// formatLastMod parses a date string and formats it for <lastmod>
+func formatLastMod(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+	for _, layout := range lastModLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Format("2006-01-02")
+		}
+	}
+	return ""
+}
@@ -0,0 +1,117 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// linkResult caches the outcome of checking one link in broken-link mode,
+// along with every page that links to it
+type linkResult struct {
+	once       sync.Once
+	statusCode int
+	errMsg     string
+	method     string // HEAD, or GET when the server rejected HEAD
+	checked    bool
+	kind       string // what it was first linked as: resourcePage, resourceScript, ...
+	external   bool   // link points to a different host than the start URL
+	soft404    string // why a page that answered 2xx is a "not found" page (Config.DetectSoft404)
+
+	referrerSet
+}
+
+// referrerSet is the pages a link was found on
+type referrerSet struct {
+	mu    sync.Mutex
+	pages map[string]bool
+}
+
+func (l *referrerSet) addReferrer(pageURL string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.pages == nil {
+		l.pages = make(map[string]bool)
+	}
+	l.pages[pageURL] = true
+}
+
+// referrers returns the referring pages in sorted order
+func (l *referrerSet) referrers() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	pages := make([]string, 0, len(l.pages))
+	for p := range l.pages {
+		pages = append(pages, p)
+	}
+	sort.Strings(pages)
+	return pages
+}
+
+// broken reports whether the link failed, returned a 4xx/5xx status or
+// led to a soft 404
+func (l *linkResult) broken() bool {
+	return l.checked && (l.statusCode == 0 || l.statusCode >= 400 || l.soft404 != "")
+}
+
+// fetchLinked downloads a linked page or stylesheet, for the broken-link
+// checks that need what's in it rather than just its status. final is where
+// redirects ended up. body is nil unless the response succeeded with a
+// Content-Type containing contentType.
+func (c *Crawler) fetchLinked(ctx context.Context, link, contentType string) (status int, final *url.URL, body []byte) {
+	if !c.limiter.wait(ctx, link) {
+		return 0, nil, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", link, nil)
+	if err != nil {
+		return 0, nil, nil
+	}
+	req.Header.Set("User-Agent", c.fingerprint.userAgent)
+
+	timeout := 10 * time.Second
+	if c.config.RequestTimeout > 0 {
+		timeout = c.config.RequestTimeout
+	}
+	client := &http.Client{Timeout: timeout, Transport: c.checkTransport, Jar: c.httpClient.Jar}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 || !strings.Contains(resp.Header.Get("Content-Type"), contentType) {
+		return resp.StatusCode, resp.Request.URL, nil
+	}
+	body, err = c.readBody(resp)
+	if err != nil {
+		return resp.StatusCode, resp.Request.URL, nil
+	}
+	return resp.StatusCode, resp.Request.URL, body
+}
+
+// forEachLinked runs check on each of links with Config.MaxConcurrency
+// workers, for the broken-link checks made once the crawl is over
+func (c *Crawler) forEachLinked(ctx context.Context, links []string, check func(link string)) {
+	queue := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < max(c.config.MaxConcurrency, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for link := range queue {
+				check(link)
+			}
+		}()
+	}
+	for _, link := range links {
+		if c.interrupted(ctx) {
+			break
+		}
+		queue <- link
+	}
+	close(queue)
+	wg.Wait()
+}
@@ -0,0 +1,309 @@
+package crawler
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// linkGraphFormats are the file formats Config.LinkGraph can name
+var linkGraphFormats = []string{"dot", "gexf", "json"}
+
+// ValidateLinkGraphFormat reports whether format is one Config.LinkGraph can
+// be set to
+func ValidateLinkGraphFormat(format string) error {
+	for _, f := range linkGraphFormats {
+		if format == f {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown link graph format %q (use %s)", format, strings.Join(linkGraphFormats, ", "))
+}
+
+// linkGraph collects which pages link to which during a crawl, for
+// Config.LinkGraph. Only links within the crawl's scope are kept; each page
+// is a node, whether the crawl fetched it or not.
+type linkGraph struct {
+	mu     sync.Mutex
+	ids    map[string]int // page URL -> node
+	nodes  []*graphNode
+	edges  map[[2]int]int // source, target -> number of links
+	starts []int          // nodes the crawl started from
+}
+
+// graphNode is a page in the link graph
+type graphNode struct {
+	url     string
+	status  int // last status the page was fetched with, 0 if it wasn't
+	fetched bool
+}
+
+func newLinkGraph() *linkGraph {
+	return &linkGraph{ids: make(map[string]int), edges: make(map[[2]int]int)}
+}
+
+// node returns a page's node, adding it if it's new. The caller holds g.mu.
+func (g *linkGraph) node(link string) int {
+	id, ok := g.ids[link]
+	if !ok {
+		id = len(g.nodes)
+		g.ids[link] = id
+		g.nodes = append(g.nodes, &graphNode{url: link})
+	}
+	return id
+}
+
+// addStart records a page the crawl started from, for click depths
+func (g *linkGraph) addStart(link string) {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.starts = append(g.starts, g.node(link))
+}
+
+// addLink records a link from one page to another. Links from a page to
+// itself aren't kept.
+func (g *linkGraph) addLink(from, to string) {
+	if g == nil || from == to {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.edges[[2]int{g.node(from), g.node(to)}]++
+}
+
+// noteFetch records the status a page was fetched with
+func (g *linkGraph) noteFetch(link string, status int) {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	n := g.nodes[g.node(link)]
+	n.status, n.fetched = status, true
+}
+
+// graphPage is a node of the exported graph
+type graphPage struct {
+	ID       int    `json:"id"`
+	URL      string `json:"url"`
+	Depth    int    `json:"depth"` // clicks from a start page, -1 if no path was found
+	Status   int    `json:"status,omitempty"`
+	Crawled  bool   `json:"crawled"`
+	InLinks  int    `json:"inlinks"` // pages linking to it
+	OutLinks int    `json:"outlinks"`
+}
+
+// graphLink is an edge of the exported graph
+type graphLink struct {
+	Source int `json:"source"`
+	Target int `json:"target"`
+	Count  int `json:"count"` // links from the source page to the target
+}
+
+// export returns the graph's pages with their click depth and link counts,
+// and its links. Pages are numbered by depth, then URL, so crawls of the same
+// site give the same numbering however their pages happened to be fetched.
+func (g *linkGraph) export() ([]graphPage, []graphLink) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	pages := make([]graphPage, len(g.nodes))
+	for id, n := range g.nodes {
+		pages[id] = graphPage{ID: id, URL: n.url, Depth: -1, Status: n.status, Crawled: n.fetched}
+	}
+	out := make([][]int, len(g.nodes))
+	for e := range g.edges {
+		out[e[0]] = append(out[e[0]], e[1])
+		pages[e[0]].OutLinks++
+		pages[e[1]].InLinks++
+	}
+
+	// Click depth is the fewest links from any start page
+	var queue []int
+	for _, id := range g.starts {
+		if pages[id].Depth < 0 {
+			pages[id].Depth = 0
+			queue = append(queue, id)
+		}
+	}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, next := range out[id] {
+			if pages[next].Depth < 0 {
+				pages[next].Depth = pages[id].Depth + 1
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	// Pages no start page leads to go last
+	sort.Slice(pages, func(i, j int) bool {
+		di, dj := uint(pages[i].Depth), uint(pages[j].Depth)
+		if di != dj {
+			return di < dj
+		}
+		return pages[i].URL < pages[j].URL
+	})
+	renumber := make([]int, len(pages))
+	for i := range pages {
+		renumber[pages[i].ID] = i
+		pages[i].ID = i
+	}
+	links := make([]graphLink, 0, len(g.edges))
+	for e, count := range g.edges {
+		links = append(links, graphLink{Source: renumber[e[0]], Target: renumber[e[1]], Count: count})
+	}
+	sort.Slice(links, func(i, j int) bool {
+		if links[i].Source != links[j].Source {
+			return links[i].Source < links[j].Source
+		}
+		return links[i].Target < links[j].Target
+	})
+	return pages, links
+}
+
+// writeLinkGraph writes the crawl's link graph next to the results file in
+// the Config.LinkGraph format, and returns its path
+func (c *Crawler) writeLinkGraph() string {
+	path := strings.TrimSuffix(c.resultFile, filepath.Ext(c.resultFile)) + "-graph." + c.config.LinkGraph
+	pages, links := c.graph.export()
+
+	var data []byte
+	var err error
+	switch c.config.LinkGraph {
+	case "dot":
+		data = linkGraphDOT(c.baseURL.Hostname(), pages, links)
+	case "gexf":
+		data, err = linkGraphGEXF(pages, links)
+	case "json":
+		data, err = json.MarshalIndent(struct {
+			Pages []graphPage `json:"pages"`
+			Links []graphLink `json:"links"`
+		}{pages, links}, "", "  ")
+	}
+	if err == nil {
+		err = os.WriteFile(path, data, 0644)
+	}
+	if err != nil {
+		c.log.Error(fmt.Sprintf("❌ Could not write the link graph: %v", err), "path", path, "error", err)
+		return ""
+	}
+	c.log.Info(fmt.Sprintf("🕸️  Link graph of %d pages and %d links written to %s", len(pages), len(links), path), "path", path, "pages", len(pages), "links", len(links))
+	return path
+}
+
+// linkGraphDOT renders the graph for Graphviz. Pages are labelled with their
+// URL and carry their depth, status and link counts as attributes.
+func linkGraphDOT(name string, pages []graphPage, links []graphLink) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %s {\n", strconv.Quote(name))
+	b.WriteString("  node [shape=box];\n")
+	for _, p := range pages {
+		fmt.Fprintf(&b, "  n%d [label=%s, depth=%d, status=%d, crawled=%t, inlinks=%d, outlinks=%d];\n",
+			p.ID, strconv.Quote(p.URL), p.Depth, p.Status, p.Crawled, p.InLinks, p.OutLinks)
+	}
+	for _, l := range links {
+		fmt.Fprintf(&b, "  n%d -> n%d [weight=%d];\n", l.Source, l.Target, l.Count)
+	}
+	b.WriteString("}\n")
+	return []byte(b.String())
+}
+
+// GEXF, the format Gephi reads
+type gexfFile struct {
+	XMLName xml.Name  `xml:"gexf"`
+	XMLNS   string    `xml:"xmlns,attr"`
+	Version string    `xml:"version,attr"`
+	Meta    gexfMeta  `xml:"meta"`
+	Graph   gexfGraph `xml:"graph"`
+}
+
+type gexfMeta struct {
+	LastModified string `xml:"lastmodifieddate,attr"`
+	Creator      string `xml:"creator"`
+}
+
+type gexfGraph struct {
+	EdgeType   string         `xml:"defaultedgetype,attr"`
+	Attributes gexfAttributes `xml:"attributes"`
+	Nodes      []gexfNode     `xml:"nodes>node"`
+	Edges      []gexfEdge     `xml:"edges>edge"`
+}
+
+type gexfAttributes struct {
+	Class      string          `xml:"class,attr"`
+	Attributes []gexfAttribute `xml:"attribute"`
+}
+
+type gexfAttribute struct {
+	ID    string `xml:"id,attr"`
+	Title string `xml:"title,attr"`
+	Type  string `xml:"type,attr"`
+}
+
+type gexfNode struct {
+	ID     string          `xml:"id,attr"`
+	Label  string          `xml:"label,attr"`
+	Values []gexfAttrValue `xml:"attvalues>attvalue"`
+}
+
+type gexfAttrValue struct {
+	For   string `xml:"for,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type gexfEdge struct {
+	ID     string `xml:"id,attr"`
+	Source string `xml:"source,attr"`
+	Target string `xml:"target,attr"`
+	Weight int    `xml:"weight,attr"`
+}
+
+// linkGraphGEXF renders the graph for Gephi, with each page's depth, status
+// and link counts as node attributes and the number of links as edge weights
+func linkGraphGEXF(pages []graphPage, links []graphLink) ([]byte, error) {
+	graph := gexfGraph{
+		EdgeType: "directed",
+		Attributes: gexfAttributes{Class: "node", Attributes: []gexfAttribute{
+			{"depth", "depth", "integer"},
+			{"status", "status", "integer"},
+			{"crawled", "crawled", "boolean"},
+			{"inlinks", "inlinks", "integer"},
+			{"outlinks", "outlinks", "integer"},
+		}},
+	}
+	for _, p := range pages {
+		graph.Nodes = append(graph.Nodes, gexfNode{ID: strconv.Itoa(p.ID), Label: p.URL, Values: []gexfAttrValue{
+			{"depth", strconv.Itoa(p.Depth)},
+			{"status", strconv.Itoa(p.Status)},
+			{"crawled", strconv.FormatBool(p.Crawled)},
+			{"inlinks", strconv.Itoa(p.InLinks)},
+			{"outlinks", strconv.Itoa(p.OutLinks)},
+		}})
+	}
+	for i, l := range links {
+		graph.Edges = append(graph.Edges, gexfEdge{ID: strconv.Itoa(i), Source: strconv.Itoa(l.Source), Target: strconv.Itoa(l.Target), Weight: l.Count})
+	}
+
+	output, err := xml.MarshalIndent(gexfFile{
+		XMLNS:   "http://gexf.net/1.3",
+		Version: "1.3",
+		Meta:    gexfMeta{LastModified: time.Now().Format("2006-01-02"), Creator: "webcrawler"},
+		Graph:   graph,
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return []byte(xml.Header + string(output) + "\n"), nil
+}
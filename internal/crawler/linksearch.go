@@ -0,0 +1,158 @@
+package crawler
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+
+	"webcrawler/internal/parser"
+
+	"golang.org/x/net/html"
+)
+
+// linkSearchTarget normalizes the link being searched for. Targets without a
+// scheme are treated as https, and paths like /contact are resolved against
+// the start URL.
+func linkSearchTarget(target string, base *url.URL) string {
+	target = strings.TrimSpace(target)
+	if !strings.Contains(target, "://") && !strings.HasPrefix(target, "/") {
+		target = "https://" + target
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return target
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	return linkSearchKey(u)
+}
+
+// linkSearchKey reduces a URL to the parts that decide which page it points
+// to, so http/https, www, default ports, fragments and trailing slashes don't
+// stop two links to the same page from matching
+func linkSearchKey(u *url.URL) string {
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		host += ":" + port
+	}
+
+	key := host + strings.TrimSuffix(u.EscapedPath(), "/")
+	if u.RawQuery != "" {
+		key += "?" + u.RawQuery
+	}
+	return key
+}
+
+// searchLinks finds the <a> and <area> elements on a page whose resolved href
+// points at the link-search target and returns how many there are along with
+// their anchor text
+func (c *Crawler) searchLinks(doc *html.Node, pageURL string) (count int, anchors []string) {
+	if doc == nil {
+		return 0, nil
+	}
+
+	pageBase, err := url.Parse(pageURL)
+	if err != nil {
+		return 0, nil
+	}
+
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "base":
+				// <base href> changes how every relative link on the page resolves
+				if href := attrValue(n, "href"); href != "" {
+					if u, err := url.Parse(href); err == nil {
+						pageBase = pageBase.ResolveReference(u)
+					}
+				}
+			case "a", "area":
+				href := strings.TrimSpace(attrValue(n, "href"))
+				if href == "" || strings.HasPrefix(href, "#") {
+					break
+				}
+				u, err := url.Parse(href)
+				if err != nil || (u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https") {
+					break
+				}
+				if linkSearchKey(pageBase.ResolveReference(u)) == c.linkTarget {
+					count++
+					text := anchorText(n)
+					if text == "" {
+						text = "(no text)"
+					}
+					anchors = append(anchors, text)
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(doc)
+	return count, anchors
+}
+
+// searchPDFLinkAnnotations counts the PDF's clickable link annotations that
+// point at the link-search target. They're often attached to text like
+// "click here" without the URL appearing in the text layer.
+func (c *Crawler) searchPDFLinkAnnotations(body []byte, pdfURL string) int {
+	base, err := url.Parse(pdfURL)
+	if err != nil {
+		return 0
+	}
+
+	count := 0
+	for _, link := range parser.ExtractLinksFromPDF(bytes.NewReader(body)) {
+		u, err := url.Parse(link)
+		if err != nil || (u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+		if linkSearchKey(base.ResolveReference(u)) == c.linkTarget {
+			count++
+		}
+	}
+	return count
+}
+
+// anchorText returns the text of a link, falling back to image alt text and
+// the aria-label or title attributes for links without any
+func anchorText(n *html.Node) string {
+	var text strings.Builder
+	var alt []string
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			text.WriteString(n.Data)
+			text.WriteByte(' ')
+		case n.Type == html.ElementNode && n.Data == "img":
+			if a := attrValue(n, "alt"); a != "" {
+				alt = append(alt, a)
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(n)
+
+	candidates := []string{text.String(), strings.Join(alt, " "), attrValue(n, "aria-label"), attrValue(n, "title"), attrValue(n, "alt")}
+	for _, candidate := range candidates {
+		if s := strings.Join(strings.Fields(candidate), " "); s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+func attrValue(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
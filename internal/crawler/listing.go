@@ -0,0 +1,212 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// ListingPageToken marks where the page number goes in
+// ListingOptions.URLTemplate
+const ListingPageToken = "{page}"
+
+// maxListingPages caps how many listing pages are read when
+// ListingOptions.LastPage isn't set
+const maxListingPages = 1000
+
+// ListingOptions configures listing capture mode, which pages through a
+// newsroom, blog or press release index to collect the articles it links
+// to, then captures those like page capture mode (CaptureFormat,
+// CaptureOpts and MergePDFs apply). Only article links within the crawl's
+// domains are kept, and Config.IncludePatterns and ExcludePatterns filter
+// them too.
+type ListingOptions struct {
+	URLTemplate  string // Listing page URL with {page} for the page number, e.g. "https://example.com/news?page={page}"
+	FirstPage    int    // Number of the first listing page, often 0 or 1
+	LastPage     int    // Number of the last listing page (0 = keep going until a page has no new articles)
+	PageStep     int    // Added to the page number for each page, e.g. 10 for "?start=0", "?start=10" (default 1)
+	LinkSelector string // CSS selector for the article links or the elements holding them, e.g. "article h2 a" (default: every link)
+	LinkPattern  string // Only capture article URLs matching this regular expression, e.g. "/news/2025/"
+}
+
+// pageURL returns the URL of listing page n
+func (o ListingOptions) pageURL(n int) string {
+	return strings.ReplaceAll(o.URLTemplate, ListingPageToken, strconv.Itoa(n))
+}
+
+// pageRegexp matches the URLs of the listing pages themselves, so the
+// pagination links on each page aren't taken for articles
+func (o ListingOptions) pageRegexp() *regexp.Regexp {
+	parts := strings.Split(o.URLTemplate, ListingPageToken)
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, `\d+`) + "$")
+}
+
+// ValidateListingTemplate checks a listing URL template is an http(s) URL
+// with a {page} placeholder
+func ValidateListingTemplate(template string) error {
+	if !strings.Contains(template, ListingPageToken) {
+		return fmt.Errorf("listing URL must contain %s where the page number goes", ListingPageToken)
+	}
+	u, err := url.Parse(strings.ReplaceAll(template, ListingPageToken, "1"))
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("listing URL must be a full URL, e.g. https://example.com/news?page=%s", ListingPageToken)
+	}
+	return nil
+}
+
+// ValidateListingSelector checks a CSS selector for the article links
+func ValidateListingSelector(sel string) error {
+	_, err := compileCSS(sel)
+	return err
+}
+
+// listingArticles reads the listing pages in order and returns the article
+// URLs they link to, in the order they're listed
+func (c *Crawler) listingArticles(ctx context.Context) ([]string, error) {
+	opts := c.config.ListingOpts
+	if err := ValidateListingTemplate(opts.URLTemplate); err != nil {
+		return nil, err
+	}
+
+	var sel cssSelector
+	if opts.LinkSelector != "" {
+		var err error
+		if sel, err = compileCSS(opts.LinkSelector); err != nil {
+			return nil, fmt.Errorf("invalid link selector: %w", err)
+		}
+	}
+	var pattern *regexp.Regexp
+	if opts.LinkPattern != "" {
+		var err error
+		if pattern, err = regexp.Compile(opts.LinkPattern); err != nil {
+			return nil, fmt.Errorf("invalid link pattern: %w", err)
+		}
+	}
+	listingPage := opts.pageRegexp()
+	step := max(opts.PageStep, 1)
+
+	c.log.Info("📰 Reading listing pages...")
+	seen := make(map[string]bool)
+	var articles []string
+	for n, read := opts.FirstPage, 0; opts.LastPage <= 0 || n <= opts.LastPage; n, read = n+step, read+1 {
+		if !c.proceed(ctx) {
+			break
+		}
+		if read == maxListingPages {
+			c.log.Warn(fmt.Sprintf("   ⚠️  Stopped after %d listing pages - set a last page to read more", maxListingPages))
+			break
+		}
+
+		pageURL := opts.pageURL(n)
+		links, err := c.listingLinks(ctx, pageURL, sel)
+		if err != nil {
+			if opts.LastPage <= 0 {
+				// Past the last page, most likely
+				c.log.Info(fmt.Sprintf("   Listing page %d: %v - stopping", n, err), "url", pageURL, "error", err)
+				break
+			}
+			c.log.Warn(fmt.Sprintf("   ⚠️  Listing page %d: %v", n, err), "url", pageURL, "error", err)
+			continue
+		}
+
+		added := 0
+		for _, u := range links {
+			u.Fragment = ""
+			link := u.String()
+			if seen[link] || listingPage.MatchString(link) || !c.scope.contains(u) {
+				continue
+			}
+			if pattern != nil && !pattern.MatchString(link) {
+				continue
+			}
+			if !c.filter.allowed(u) {
+				continue
+			}
+			seen[link] = true
+			articles = append(articles, link)
+			added++
+		}
+		c.log.Info(fmt.Sprintf("   Listing page %d: %d new articles", n, added), "url", pageURL, "articles", added)
+
+		if added == 0 && opts.LastPage <= 0 {
+			break
+		}
+	}
+
+	if len(articles) == 0 {
+		return nil, fmt.Errorf("no article links found on the listing pages")
+	}
+	c.log.Info(fmt.Sprintf("   ✅ %d articles found\n", len(articles)), "count", len(articles))
+	return articles, nil
+}
+
+// listingLinks fetches a listing page and returns the links matched by sel,
+// or every link on the page if sel is nil. A matched element that isn't a
+// link stands for the links inside it.
+func (c *Crawler) listingLinks(ctx context.Context, pageURL string, sel cssSelector) ([]*url.URL, error) {
+	if !c.robots.allowed(ctx, pageURL) {
+		return nil, fmt.Errorf("disallowed by robots.txt")
+	}
+	if !c.robots.wait(ctx, pageURL) || !c.limiter.wait(ctx, pageURL) {
+		return nil, context.Canceled
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", c.fingerprint.userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,*/*;q=0.8")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	body, err := c.readBody(resp)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	roots := []*html.Node{doc}
+	if sel != nil {
+		roots = sel.matchAll(doc)
+	}
+
+	var links []*url.URL
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "a" {
+			if href := strings.TrimSpace(attrValue(n, "href")); href != "" {
+				if u, err := resp.Request.URL.Parse(href); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+					links = append(links, u)
+				}
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	for _, root := range roots {
+		walk(root)
+	}
+	return links, nil
+}
@@ -0,0 +1,86 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// consoleHandler is a slog.Handler that prints only the message, so crawl
+// events keep their familiar emoji formatting in the terminal. It clears the
+// current line first so events don't collide with the live stats display.
+type consoleHandler struct {
+	w     io.Writer
+	level slog.Leveler
+	mu    *sync.Mutex
+}
+
+func (h *consoleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *consoleHandler) Handle(_ context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := fmt.Fprintf(h.w, "\033[2K\r%s\n", r.Message)
+	return err
+}
+
+func (h *consoleHandler) WithAttrs(_ []slog.Attr) slog.Handler { return h }
+func (h *consoleHandler) WithGroup(_ string) slog.Handler      { return h }
+
+// fanoutHandler sends each record to every handler that accepts its level
+type fanoutHandler []slog.Handler
+
+func (f fanoutHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range f {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f fanoutHandler) Handle(ctx context.Context, r slog.Record) error {
+	var firstErr error
+	for _, h := range f {
+		if !h.Enabled(ctx, r.Level) {
+			continue
+		}
+		if err := h.Handle(ctx, r.Clone()); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (f fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make(fanoutHandler, len(f))
+	for i, h := range f {
+		out[i] = h.WithAttrs(attrs)
+	}
+	return out
+}
+
+func (f fanoutHandler) WithGroup(name string) slog.Handler {
+	out := make(fanoutHandler, len(f))
+	for i, h := range f {
+		out[i] = h.WithGroup(name)
+	}
+	return out
+}
+
+// NewLogger returns a logger that prints crawl events to stdout at
+// consoleLevel and, if logFile is non-nil, also writes every event at debug
+// level as JSON lines with structured fields (url, status, error, ...) for
+// auditing long crawls afterwards.
+func NewLogger(consoleLevel slog.Level, logFile io.Writer) *slog.Logger {
+	handlers := fanoutHandler{&consoleHandler{w: os.Stdout, level: consoleLevel, mu: &sync.Mutex{}}}
+	if logFile != nil {
+		handlers = append(handlers, slog.NewJSONHandler(logFile, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	}
+	return slog.New(handlers)
+}
@@ -0,0 +1,672 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// mirrorFile is one URL saved in mirror mode. Assets shared by many pages are
+// downloaded once.
+type mirrorFile struct {
+	once        sync.Once
+	url         string // where it was fetched from after redirects; its links resolve against this
+	path        string // slash-separated, relative to the mirror folder
+	contentType string
+	size        int64
+	asset       bool
+	saved       time.Time
+	err         error
+}
+
+// mirrorURLAttrs lists the attributes holding URLs, by element. Assets are
+// what a page needs to display and are downloaded with it; links are only
+// pointed at the saved copy when the crawl saved their target.
+var mirrorURLAttrs = map[string]map[string]bool{ // element -> attribute -> asset
+	"a":      {"href": false},
+	"area":   {"href": false},
+	"iframe": {"src": false},
+	"form":   {"action": false},
+	"link":   {"href": false}, // an asset for the rels in mirrorLinkRels
+	"img":    {"src": true, "srcset": true, "data-src": true, "data-srcset": true, "data-lazy-src": true, "data-original": true},
+	"source": {"src": true, "srcset": true, "data-srcset": true},
+	"script": {"src": true},
+	"video":  {"src": true, "poster": true},
+	"audio":  {"src": true},
+	"track":  {"src": true},
+	"embed":  {"src": true},
+	"object": {"data": true},
+	"input":  {"src": true},
+}
+
+var mirrorLinkRels = map[string]bool{
+	"stylesheet":       true,
+	"icon":             true,
+	"apple-touch-icon": true,
+	"preload":          true,
+	"modulepreload":    true,
+	"manifest":         true,
+}
+
+// mirrorExtensions name saved files whose URL has no extension
+var mirrorExtensions = map[string]string{
+	"text/html":                ".html",
+	"text/css":                 ".css",
+	"text/javascript":          ".js",
+	"application/javascript":   ".js",
+	"application/json":         ".json",
+	"application/xml":          ".xml",
+	"text/xml":                 ".xml",
+	"text/plain":               ".txt",
+	"application/pdf":          ".pdf",
+	"image/jpeg":               ".jpg",
+	"image/png":                ".png",
+	"image/gif":                ".gif",
+	"image/webp":               ".webp",
+	"image/avif":               ".avif",
+	"image/svg+xml":            ".svg",
+	"image/x-icon":             ".ico",
+	"image/vnd.microsoft.icon": ".ico",
+	"font/woff":                ".woff",
+	"font/woff2":               ".woff2",
+}
+
+// cssImportRe extracts the target of an @import "..." rule; @import url(...)
+// is matched by cssURLRe
+var cssImportRe = regexp.MustCompile(`(?i)@import\s+['"]([^'"]+)['"]`)
+
+// mirrorPage saves a fetched page, or a document it links to, and downloads
+// the images, scripts, stylesheets and fonts an HTML page needs
+func (c *Crawler) mirrorPage(ctx context.Context, pageURL string, final *url.URL, contentType string, body []byte) {
+	// Keyed by where redirects ended, so a page reached both directly and
+	// through a redirect is saved once
+	value, _ := c.mirrorFiles.LoadOrStore(c.mirrorKey(final.String()), &mirrorFile{})
+	file := value.(*mirrorFile)
+	saved := false
+	file.once.Do(func() {
+		saved = c.saveMirrorFile(file, final, contentType, body) == nil
+	})
+	// Links to either end of a redirect open the saved copy
+	c.mirrorFiles.LoadOrStore(c.mirrorKey(pageURL), file)
+	if !saved {
+		return
+	}
+	atomic.AddInt64(&c.stats.PagesSaved, 1)
+
+	if strings.Contains(contentType, "text/html") {
+		for _, asset := range mirrorAssetURLs(body, file.url) {
+			c.mirrorAsset(ctx, asset)
+		}
+	}
+}
+
+// mirrorAsset downloads an asset into the mirror, followed by the fonts,
+// images and other stylesheets a stylesheet uses
+func (c *Crawler) mirrorAsset(ctx context.Context, assetURL string) {
+	u, err := url.Parse(assetURL)
+	if err != nil || ctx.Err() != nil {
+		return
+	}
+	if !c.config.MirrorExternalAssets && !c.scope.contains(u) {
+		return
+	}
+
+	value, _ := c.mirrorFiles.LoadOrStore(c.mirrorKey(assetURL), &mirrorFile{asset: true})
+	file := value.(*mirrorFile)
+	var body []byte
+	fetched := false
+	file.once.Do(func() {
+		var final *url.URL
+		var contentType string
+		body, final, contentType, err = c.fetchMirrorAsset(ctx, assetURL)
+		if err == nil {
+			err = c.saveMirrorFile(file, final, contentType, body)
+		}
+		if err != nil {
+			file.url, file.err = assetURL, err
+			atomic.AddInt64(&c.stats.AssetsFailed, 1)
+			c.log.Debug(fmt.Sprintf("   ⚠️  Asset not saved: %s (%v)", assetURL, err), "url", assetURL, "error", err)
+			return
+		}
+		atomic.AddInt64(&c.stats.AssetsSaved, 1)
+		fetched = true
+	})
+
+	// Stylesheets are followed outside once.Do, so stylesheets that import
+	// each other don't wait on themselves
+	if fetched && strings.Contains(file.contentType, "text/css") {
+		for _, ref := range cssRefs(string(body), file.url) {
+			c.mirrorAsset(ctx, ref)
+		}
+	}
+}
+
+// fetchMirrorAsset downloads an asset, sending Config.Headers and cookies
+// only to in-scope hosts like the rest of the crawl
+func (c *Crawler) fetchMirrorAsset(ctx context.Context, assetURL string) ([]byte, *url.URL, string, error) {
+	if !c.limiter.wait(ctx, assetURL) {
+		return nil, nil, "", context.Canceled
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", assetURL, nil)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	req.Header.Set("User-Agent", c.fingerprint.userAgent)
+
+	client := &http.Client{Timeout: c.requestTimeout(), Transport: c.checkTransport, Jar: c.httpClient.Jar}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, "", fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	body, err := c.readBody(resp)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	atomic.AddInt64(&c.stats.BytesDownloaded, int64(len(body)))
+	return body, resp.Request.URL, resp.Header.Get("Content-Type"), nil
+}
+
+// saveMirrorFile writes body to the file's place in the mirror
+func (c *Crawler) saveMirrorFile(file *mirrorFile, u *url.URL, contentType string, body []byte) error {
+	rel := c.mirrorPath(u, contentType)
+	full := filepath.Join(c.mirrorDir, filepath.FromSlash(rel))
+	err := os.MkdirAll(filepath.Dir(full), 0755)
+	if err == nil {
+		err = os.WriteFile(full, body, 0644)
+	}
+	if err != nil {
+		c.log.Warn(fmt.Sprintf("⚠️  Could not save %s: %v", u, err), "url", u.String(), "error", err)
+		return err
+	}
+
+	file.url, file.path, file.contentType = u.String(), rel, contentType
+	file.size, file.saved = int64(len(body)), time.Now()
+	atomic.AddInt64(&c.stats.MirrorBytes, int64(len(body)))
+	return nil
+}
+
+// mirrorKey identifies the file a URL is saved to. Fragments never change
+// the file, and query strings don't when Config.IgnoreQueryParams is set.
+func (c *Crawler) mirrorKey(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+	u.Fragment = ""
+	if c.config.IgnoreQueryParams {
+		u.RawQuery = ""
+	}
+	return u.String()
+}
+
+// savedMirrorFile returns the saved file for a URL, or nil if it wasn't saved
+func (c *Crawler) savedMirrorFile(link string) *mirrorFile {
+	value, ok := c.mirrorFiles.Load(c.mirrorKey(link))
+	if !ok {
+		return nil
+	}
+	file := value.(*mirrorFile)
+	if file.path == "" {
+		return nil
+	}
+	return file
+}
+
+// mirrorPath is where a URL is saved, relative to the mirror folder, as
+// <host>/<path>. HTML pages without an .html extension are saved as
+// index.html in a folder of their own, so /about and /about/team can both be
+// saved and open in a browser from disk. A query string becomes a hash in the
+// file name.
+func (c *Crawler) mirrorPath(u *url.URL, contentType string) string {
+	segments := []string{mirrorSafeName(u.Host)}
+	for _, seg := range strings.Split(u.Path, "/") {
+		if seg != "" && seg != "." && seg != ".." {
+			segments = append(segments, mirrorSafeName(seg))
+		}
+	}
+
+	name := ""
+	if len(segments) > 1 && !strings.HasSuffix(u.Path, "/") {
+		name, segments = segments[len(segments)-1], segments[:len(segments)-1]
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	ext := strings.ToLower(path.Ext(name))
+	switch {
+	case name == "":
+		name = "index" + mirrorExtensions[mediaType]
+		if mediaType == "" {
+			name = "index.html"
+		}
+	case mediaType == "text/html" && ext != ".html" && ext != ".htm":
+		if ext == "" {
+			segments, name = append(segments, name), "index.html"
+		} else {
+			name += ".html"
+		}
+	case ext == "":
+		name += mirrorExtensions[mediaType]
+	}
+
+	if u.RawQuery != "" && !c.config.IgnoreQueryParams {
+		ext := path.Ext(name)
+		name = strings.TrimSuffix(name, ext) + "_" + hashString(u.RawQuery) + ext
+	}
+	return path.Join(append(segments, name)...)
+}
+
+// mirrorSafeName makes a URL path segment safe to use as a file name on
+// Windows, macOS and Linux, shortening very long segments
+func mirrorSafeName(seg string) string {
+	seg = strings.Map(func(r rune) rune {
+		if r < 32 || strings.ContainsRune(`<>:"\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, seg)
+	if runes := []rune(seg); len(runes) > 100 {
+		seg = string(runes[:80]) + "_" + hashString(seg)
+	}
+	return seg
+}
+
+// mirrorRelPath returns a link from the saved file from to the saved file to,
+// both relative to the mirror folder
+func mirrorRelPath(from, to string) string {
+	rel, err := filepath.Rel(filepath.Dir(filepath.FromSlash(from)), filepath.FromSlash(to))
+	if err != nil {
+		rel = to
+	}
+	return (&url.URL{Path: filepath.ToSlash(rel)}).String()
+}
+
+// mirrorAttr reports whether an attribute of a start tag holds a URL, and
+// whether that URL is an asset
+func mirrorAttr(tok html.Token, key string) (isURL, asset bool) {
+	asset, isURL = mirrorURLAttrs[tok.Data][key]
+	if isURL && tok.Data == "link" {
+		for _, a := range tok.Attr {
+			if a.Key != "rel" {
+				continue
+			}
+			for _, rel := range strings.Fields(strings.ToLower(a.Val)) {
+				if mirrorLinkRels[rel] {
+					asset = true
+				}
+			}
+		}
+	}
+	return isURL, asset
+}
+
+// mirrorAssetURLs returns the assets an HTML page needs to display, resolved
+// and de-duplicated, including url()s in style attributes and <style> blocks
+func mirrorAssetURLs(body []byte, pageURL string) []string {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil
+	}
+
+	var assets []string
+	seen := make(map[string]bool)
+	add := func(ref string) {
+		u, err := url.Parse(strings.TrimSpace(ref))
+		if err != nil {
+			return
+		}
+		resolved := base.ResolveReference(u)
+		resolved.Fragment = ""
+		if resolved.Scheme != "http" && resolved.Scheme != "https" {
+			return
+		}
+		if key := resolved.String(); !seen[key] {
+			seen[key] = true
+			assets = append(assets, key)
+		}
+	}
+
+	z := html.NewTokenizer(bytes.NewReader(body))
+	inStyle := false
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			return assets
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			inStyle = tok.Data == "style" && tt == html.StartTagToken
+			if tok.Data == "base" {
+				if u, err := url.Parse(tokenAttr(tok, "href")); err == nil && tokenAttr(tok, "href") != "" {
+					base = base.ResolveReference(u)
+				}
+				continue
+			}
+			for _, a := range tok.Attr {
+				if a.Key == "style" {
+					for _, ref := range cssRefs(a.Val, "") {
+						add(ref)
+					}
+					continue
+				}
+				isURL, asset := mirrorAttr(tok, a.Key)
+				switch {
+				case !isURL || !asset:
+				case strings.HasSuffix(a.Key, "srcset"):
+					for _, candidate := range parseSrcset(a.Val) {
+						add(candidate)
+					}
+				default:
+					add(a.Val)
+				}
+			}
+		case html.TextToken:
+			if inStyle {
+				for _, ref := range cssRefs(string(z.Text()), "") {
+					add(ref)
+				}
+			}
+		case html.EndTagToken:
+			inStyle = false
+		}
+	}
+}
+
+// cssRefs returns the url() and @import targets in a stylesheet, resolved
+// against cssURL when it's given
+func cssRefs(css, cssURL string) []string {
+	var refs []string
+	for _, m := range cssURLRe.FindAllStringSubmatch(css, -1) {
+		refs = append(refs, strings.TrimSpace(m[1]))
+	}
+	for _, m := range cssImportRe.FindAllStringSubmatch(css, -1) {
+		refs = append(refs, strings.TrimSpace(m[1]))
+	}
+	if cssURL == "" {
+		return refs
+	}
+
+	base, err := url.Parse(cssURL)
+	if err != nil {
+		return nil
+	}
+	var resolved []string
+	for _, ref := range refs {
+		if u, err := url.Parse(ref); err == nil && !strings.HasPrefix(ref, "data:") {
+			resolved = append(resolved, base.ResolveReference(u).String())
+		}
+	}
+	return resolved
+}
+
+func tokenAttr(tok html.Token, key string) string {
+	for _, a := range tok.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// writeMirror points the links in every saved page and stylesheet at the
+// saved copies, writes an index.html that opens the start page, and lists
+// every file in the results CSV. It runs once the crawl has finished, when
+// it's known which links were saved.
+func (c *Crawler) writeMirror() {
+	var files []*mirrorFile
+	seen := make(map[*mirrorFile]bool)
+	c.mirrorFiles.Range(func(_, value interface{}) bool {
+		file := value.(*mirrorFile)
+		if !seen[file] && file.url != "" {
+			seen[file] = true
+			files = append(files, file)
+		}
+		return true
+	})
+	sort.Slice(files, func(i, j int) bool { return files[i].url < files[j].url })
+
+	for _, file := range files {
+		var err error
+		switch {
+		case file.path == "":
+			continue
+		case strings.Contains(file.contentType, "text/html"):
+			err = c.rewriteMirrorHTML(file)
+		case strings.Contains(file.contentType, "text/css"):
+			err = c.rewriteMirrorStylesheet(file)
+		}
+		if err != nil {
+			c.log.Warn(fmt.Sprintf("⚠️  Could not rewrite links in %s: %v", file.path, err), "path", file.path, "error", err)
+		}
+	}
+
+	c.writeMirrorIndex()
+
+	for _, file := range files {
+		kind := "page"
+		if file.asset {
+			kind = "asset"
+		}
+		errMsg, sizeKB, timestamp := "", "", ""
+		if file.err != nil {
+			errMsg = file.err.Error()
+		} else {
+			sizeKB = strconv.FormatInt((file.size+1023)/1024, 10)
+			timestamp = file.saved.Format(time.RFC3339)
+		}
+		c.writeResult(ModeMirror, []string{file.url, file.path, kind, file.contentType, sizeKB, errMsg, timestamp})
+	}
+}
+
+// rewriteMirrorHTML points a saved page's links at the saved copies. The page
+// is rewritten tag by tag rather than re-rendered, so everything else is
+// kept byte for byte.
+func (c *Crawler) rewriteMirrorHTML(file *mirrorFile) error {
+	full := filepath.Join(c.mirrorDir, filepath.FromSlash(file.path))
+	data, err := os.ReadFile(full)
+	if err != nil {
+		return err
+	}
+	base, err := url.Parse(file.url)
+	if err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	z := html.NewTokenizer(bytes.NewReader(data))
+	inStyle := false
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if z.Err() != io.EOF {
+				return z.Err()
+			}
+			break
+		}
+		raw := append([]byte(nil), z.Raw()...)
+
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			inStyle = tok.Data == "style" && tt == html.StartTagToken
+			if tok.Data == "base" {
+				// Dropped, so relative links resolve against the saved file
+				if u, err := url.Parse(tokenAttr(tok, "href")); err == nil && tokenAttr(tok, "href") != "" {
+					base = base.ResolveReference(u)
+				}
+				continue
+			}
+			if c.rewriteMirrorTag(&tok, base, file.path) {
+				out.WriteString(tok.String())
+				continue
+			}
+		case html.TextToken:
+			if inStyle {
+				out.WriteString(c.rewriteMirrorCSS(string(raw), base, file.path))
+				continue
+			}
+		case html.EndTagToken:
+			inStyle = false
+		}
+		out.Write(raw)
+	}
+	return os.WriteFile(full, out.Bytes(), 0644)
+}
+
+// rewriteMirrorTag rewrites the URLs in a start tag's attributes, reporting
+// whether any changed
+func (c *Crawler) rewriteMirrorTag(tok *html.Token, base *url.URL, from string) bool {
+	changed, local := false, false
+	for i, a := range tok.Attr {
+		val := a.Val
+		switch isURL, _ := mirrorAttr(*tok, a.Key); {
+		case a.Key == "style":
+			val = c.rewriteMirrorCSS(a.Val, base, from)
+		case isURL && strings.HasSuffix(a.Key, "srcset"):
+			candidates := strings.Split(a.Val, ",")
+			for j, candidate := range candidates {
+				fields := strings.Fields(candidate)
+				if len(fields) > 0 {
+					fields[0], _ = c.mirrorRef(fields[0], base, from)
+					candidates[j] = strings.Join(fields, " ")
+				}
+			}
+			val = strings.Join(candidates, ", ")
+		case isURL:
+			var saved bool
+			val, saved = c.mirrorRef(a.Val, base, from)
+			local = local || saved
+		}
+		if val != a.Val {
+			tok.Attr[i].Val = val
+			changed = true
+		}
+	}
+
+	if local {
+		// Browsers refuse integrity-checked scripts and stylesheets opened
+		// from disk, since files have no origin to check them against
+		attrs := tok.Attr[:0]
+		for _, a := range tok.Attr {
+			if a.Key != "integrity" && a.Key != "crossorigin" {
+				attrs = append(attrs, a)
+			}
+		}
+		tok.Attr = attrs
+	}
+	return changed
+}
+
+// rewriteMirrorStylesheet points a saved stylesheet's url()s and @imports at
+// the saved copies
+func (c *Crawler) rewriteMirrorStylesheet(file *mirrorFile) error {
+	full := filepath.Join(c.mirrorDir, filepath.FromSlash(file.path))
+	data, err := os.ReadFile(full)
+	if err != nil {
+		return err
+	}
+	base, err := url.Parse(file.url)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(full, []byte(c.rewriteMirrorCSS(string(data), base, file.path)), 0644)
+}
+
+func (c *Crawler) rewriteMirrorCSS(css string, base *url.URL, from string) string {
+	css = cssURLRe.ReplaceAllStringFunc(css, func(m string) string {
+		ref := strings.TrimSpace(cssURLRe.FindStringSubmatch(m)[1])
+		if rewritten, _ := c.mirrorRef(ref, base, from); rewritten != ref {
+			return `url("` + rewritten + `")`
+		}
+		return m
+	})
+	return cssImportRe.ReplaceAllStringFunc(css, func(m string) string {
+		ref := strings.TrimSpace(cssImportRe.FindStringSubmatch(m)[1])
+		if rewritten, _ := c.mirrorRef(ref, base, from); rewritten != ref {
+			return `@import "` + rewritten + `"`
+		}
+		return m
+	})
+}
+
+// mirrorRef returns a link to ref's saved copy, relative to the saved file
+// from, and reports whether there is one. Links the crawl didn't save are made
+// absolute so they still work online; fragments, data: URIs and mailto: links
+// are left alone.
+func (c *Crawler) mirrorRef(ref string, base *url.URL, from string) (string, bool) {
+	trimmed := strings.TrimSpace(ref)
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+		return ref, false
+	}
+	u, err := url.Parse(trimmed)
+	if err != nil {
+		return ref, false
+	}
+	resolved := base.ResolveReference(u)
+	if resolved.Scheme != "http" && resolved.Scheme != "https" {
+		return ref, false
+	}
+
+	file := c.savedMirrorFile(resolved.String())
+	if file == nil {
+		return resolved.String(), false
+	}
+	rel := mirrorRelPath(from, file.path)
+	if resolved.Fragment != "" {
+		rel += "#" + resolved.EscapedFragment()
+	}
+	return rel, true
+}
+
+var mirrorIndexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="0; url={{.Path}}">
+<title>Offline copy of {{.URL}}</title>
+</head>
+<body>
+<p><a href="{{.Path}}">Open the offline copy of {{.URL}}</a></p>
+</body>
+</html>
+`))
+
+// writeMirrorIndex writes index.html at the top of the mirror, opening the
+// start page (or the first listed page, when crawling a URL list)
+func (c *Crawler) writeMirrorIndex() {
+	for _, start := range append([]string{c.config.StartURL}, c.urlList...) {
+		file := c.savedMirrorFile(start)
+		if file == nil || !strings.Contains(file.contentType, "text/html") {
+			continue
+		}
+
+		var buf bytes.Buffer
+		mirrorIndexTemplate.Execute(&buf, struct{ URL, Path string }{start, mirrorRelPath("index.html", file.path)})
+		if err := os.WriteFile(filepath.Join(c.mirrorDir, "index.html"), buf.Bytes(), 0644); err != nil {
+			c.log.Warn(fmt.Sprintf("⚠️  Could not write the mirror's index.html: %v", err), "error", err)
+		}
+		return
+	}
+}
@@ -0,0 +1,265 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// notifyBatchInterval is how often findings are posted, so a crawl
+	// that finds hundreds of broken links sends a message every so often
+	// rather than one per link
+	notifyBatchInterval = 30 * time.Second
+	// notifyBatchLines caps the findings listed in one Slack message
+	notifyBatchLines = 20
+)
+
+// NotifyOptions configures the messages posted while and after a run
+type NotifyOptions struct {
+	WebhookURL string // Slack incoming webhook, or any URL that takes a JSON POST
+	Findings   bool   // Also post matches, broken links and other findings as they're found, batched every 30s
+}
+
+// ValidateWebhookURL checks a notification webhook is an http(s) URL
+func ValidateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("webhook must be a full URL, e.g. https://hooks.slack.com/services/...")
+	}
+	return nil
+}
+
+// isSlackWebhook reports whether a webhook is a Slack incoming webhook,
+// which takes {"text": ...} rather than the generic payload
+func isSlackWebhook(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && u.Host == "hooks.slack.com"
+}
+
+// webhookFinding is one match, broken link or other finding
+type webhookFinding struct {
+	Kind   string `json:"kind"` // e.g. "match", "broken_link"
+	URL    string `json:"url"`
+	Detail string `json:"detail,omitempty"`
+	Time   string `json:"time"`
+}
+
+// summaryField is a line of the run summary
+type summaryField struct {
+	Name  string
+	Value any
+}
+
+// key is the field's name as a JSON key, e.g. "pages_checked"
+func (f summaryField) key() string {
+	return strings.ToLower(strings.ReplaceAll(f.Name, " ", "_"))
+}
+
+// notifier posts findings and the run summary to Config.Notify's webhook.
+// Findings are queued and sent in batches by a background goroutine.
+type notifier struct {
+	c       *Crawler
+	url     string
+	slack   bool
+	client  *http.Client
+	mu      sync.Mutex
+	pending []webhookFinding
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func newNotifier(c *Crawler) *notifier {
+	n := &notifier{
+		c:      c,
+		url:    c.config.Notify.WebhookURL,
+		slack:  isSlackWebhook(c.config.Notify.WebhookURL),
+		client: &http.Client{Timeout: 15 * time.Second},
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if c.config.Notify.Findings {
+		go n.batchFindings()
+	} else {
+		close(n.done)
+	}
+	return n
+}
+
+// batchFindings posts the queued findings every notifyBatchInterval until
+// the notifier is stopped
+func (n *notifier) batchFindings() {
+	defer close(n.done)
+	ticker := time.NewTicker(notifyBatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			n.flush(context.Background())
+		case <-n.stop:
+			return
+		}
+	}
+}
+
+// add queues a finding for the next batch
+func (n *notifier) add(kind, link, detail string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.pending = append(n.pending, webhookFinding{Kind: kind, URL: link, Detail: detail, Time: time.Now().Format(time.RFC3339)})
+}
+
+// flush posts the queued findings, if there are any
+func (n *notifier) flush(ctx context.Context) {
+	n.mu.Lock()
+	findings := n.pending
+	n.pending = nil
+	n.mu.Unlock()
+	if len(findings) == 0 {
+		return
+	}
+
+	var payload any
+	if n.slack {
+		var text strings.Builder
+		fmt.Fprintf(&text, "*%d new finding(s)* crawling %s\n", len(findings), n.c.config.StartURL)
+		for i, f := range findings {
+			if i == notifyBatchLines {
+				fmt.Fprintf(&text, "…and %d more\n", len(findings)-i)
+				break
+			}
+			fmt.Fprintf(&text, "• %s: <%s>", strings.ReplaceAll(f.Kind, "_", " "), f.URL)
+			if f.Detail != "" {
+				fmt.Fprintf(&text, " (%s)", f.Detail)
+			}
+			text.WriteString("\n")
+		}
+		payload = map[string]any{"text": text.String()}
+	} else {
+		payload = map[string]any{
+			"event":     "findings",
+			"mode":      n.c.config.Mode.String(),
+			"start_url": n.c.config.StartURL,
+			"findings":  findings,
+		}
+	}
+	n.post(ctx, payload)
+}
+
+// finish posts any findings still queued, then the run summary. ctx is the
+// run's context, which may be cancelled; the messages are sent regardless.
+func (n *notifier) finish(ctx context.Context) {
+	close(n.stop)
+	<-n.done
+	n.flush(context.WithoutCancel(ctx))
+
+	r := n.c.results
+	status := "finished"
+	if r.Cancelled {
+		status = "cancelled"
+	} else if r.TimedOut {
+		status = "stopped at the time limit"
+	} else if r.OverBudget {
+		status = "stopped at the download budget"
+	}
+	fields := runSummary(r)
+
+	var payload any
+	if n.slack {
+		var text strings.Builder
+		fmt.Fprintf(&text, "*%s: %s* in %s\n%s\n", r.Mode, status, formatDuration(r.Duration), n.c.config.StartURL)
+		for _, f := range fields {
+			fmt.Fprintf(&text, "• %s: %v\n", f.Name, f.Value)
+		}
+		payload = map[string]any{"text": text.String()}
+	} else {
+		summary := make(map[string]any, len(fields))
+		for _, f := range fields {
+			summary[f.key()] = f.Value
+		}
+		payload = map[string]any{
+			"event":            "complete",
+			"mode":             r.Mode.String(),
+			"start_url":        n.c.config.StartURL,
+			"status":           status,
+			"duration_seconds": r.Duration.Seconds(),
+			"summary":          summary,
+		}
+	}
+	n.post(context.WithoutCancel(ctx), payload)
+}
+
+// runSummary picks the numbers worth reporting for the run's mode
+func runSummary(r Results) []summaryField {
+	var fields []summaryField
+	switch r.Mode {
+	case ModePDFCapture, ModeListing:
+		s := r.PDFStats
+		fields = []summaryField{{"Pages visited", s.PagesVisited}, {"PDFs", s.PDFsGenerated}, {"Screenshots", s.ScreenshotsGen}, {"Archives", s.ArchivesSaved}, {"Errors", s.Errors}}
+	case ModeSitemap:
+		s := r.SitemapStats
+		fields = []summaryField{{"Pages found", s.PagesFound}, {"Pages checked", s.PagesChecked}, {"Errors", s.ErrorCount}, {"Blocked", s.BlockedCount}}
+	case ModeJSONFeed:
+		s := r.JSONFeedStats
+		fields = []summaryField{{"Items fetched", s.ItemsFetched}, {"Pages captured", s.PagesCapture}, {"Already captured", s.AlreadyCaptured}, {"Errors", s.Errors}}
+	case ModeVisualDiff:
+		s := r.VisualDiffStats
+		fields = []summaryField{{"Pages compared", s.PagesCompared}, {"Pages different", s.PagesDifferent}, {"Errors", s.Errors}}
+	case ModeDiscover:
+		s := r.Stats
+		fields = []summaryField{{"URLs found", s.URLsDiscovered}, {"In scope", s.URLsInScope}, {"Pages crawled", s.PagesChecked}, {"Errors", s.ErrorCount}}
+	case ModeOrphans:
+		s := r.Stats
+		fields = []summaryField{{"Sitemap URLs", s.SitemapURLs}, {"Orphan pages", s.OrphanPages}, {"Not in sitemap", s.PagesNotInSitemap}, {"Pages crawled", s.PagesChecked}}
+	default:
+		s := r.Stats
+		fields = []summaryField{{"Pages checked", s.PagesChecked}, {"Findings", s.MatchesFound}, {"Errors", s.ErrorCount}, {"Blocked", s.BlockedCount}}
+	}
+	if r.OutputPath != "" {
+		fields = append(fields, summaryField{"Output", r.OutputPath})
+	}
+	if r.UploadedFiles > 0 {
+		fields = append(fields, summaryField{"Files uploaded", r.UploadedFiles})
+	}
+	return fields
+}
+
+// post sends a JSON payload to the webhook. A failed notification is
+// logged, never treated as a crawl error.
+func (n *notifier) post(ctx context.Context, payload any) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", n.url, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", n.c.fingerprint.userAgent)
+
+	resp, err := n.client.Do(req)
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			err = fmt.Errorf("HTTP %d", resp.StatusCode)
+		}
+	}
+	if err != nil {
+		n.c.log.Warn(fmt.Sprintf("⚠️  Notification failed: %v", err), "error", err)
+	}
+}
+
+// notifyFinding queues a finding for the webhook when Config.Notify asks
+// for findings
+func (c *Crawler) notifyFinding(kind, link, detail string) {
+	if c.notify != nil && c.config.Notify.Findings {
+		c.notify.add(kind, link, detail)
+	}
+}
@@ -0,0 +1,151 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Issues orphan mode reports
+const (
+	issueOrphan       = "orphan"
+	issueNotInSitemap = "not in sitemap"
+)
+
+// orphanKey is the form sitemap URLs and crawled URLs are compared in, so
+// https://Example.com:443 and https://example.com/ count as the same page
+func (c *Crawler) orphanKey(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return c.getVisitedKey(link)
+	}
+	u.Scheme, u.Host = strings.ToLower(u.Scheme), strings.ToLower(u.Host)
+	if port := u.Port(); (u.Scheme == "https" && port == "443") || (u.Scheme == "http" && port == "80") {
+		u.Host = u.Hostname()
+	}
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	u.Fragment, u.RawFragment = "", ""
+	return c.getVisitedKey(u.String())
+}
+
+// loadOrphanSitemap reads the pages the site's sitemap lists, keeping those
+// the crawl could reach: on its hosts, under its path filter and passing its
+// include and exclude patterns
+func (c *Crawler) loadOrphanSitemap(ctx context.Context) error {
+	cfg := c.config
+	c.log.Info("🗺️  Loading URLs from sitemap...")
+
+	urls, err := c.loadSitemapSeeds(ctx, cfg.StartURL, cfg.SitemapSeedURL)
+	if err != nil {
+		return fmt.Errorf("could not read sitemap: %v", err)
+	}
+
+	c.sitemapPages = make(map[string]string)
+	for _, link := range urls {
+		u, err := url.Parse(link)
+		if err != nil || !c.scope.contains(u) || !underPath(u, cfg.PathFilter) || !c.filter.allowed(u) {
+			continue
+		}
+		if key := c.orphanKey(link); c.sitemapPages[key] == "" {
+			c.sitemapPages[key] = link
+		}
+	}
+	if len(c.sitemapPages) == 0 {
+		return fmt.Errorf("sitemap has no URLs on the crawled domains")
+	}
+
+	c.stats.SitemapURLs = int64(len(c.sitemapPages))
+	c.log.Info(fmt.Sprintf("   ✅ %d URLs loaded from sitemap\n", len(c.sitemapPages)), "count", len(c.sitemapPages))
+	return nil
+}
+
+// orphanRow is a line of orphan mode's results
+type orphanRow struct {
+	link, issue, foundOn, depth, status, redirectedTo string
+}
+
+// writeOrphans compares the sitemap with what the crawl found by following
+// links. Sitemap pages no crawled page links to are orphans, and are fetched
+// here for their status; pages the crawl reached that answered with a 2xx
+// HTML page without redirecting, but that the sitemap doesn't list, are
+// missing from it. Rows are sorted by issue and then URL, with no timestamp
+// column, so runs over an unchanged site can be diffed.
+func (c *Crawler) writeOrphans(ctx context.Context) {
+	if c.stats.SkippedDepth+c.stats.SkippedLimit+c.stats.SkippedTimeLimit+c.stats.SkippedQueueFull > 0 || c.interrupted(ctx) {
+		c.log.Warn("⚠️  The crawl stopped short of some pages, so some orphans may only be pages it didn't get to")
+	}
+
+	// What the crawl found, by comparison key. A page reached under several
+	// URLs is represented by the one that was fetched.
+	found := make(map[string]*discoveredURL)
+	links := make(map[string]string)
+	c.discovered.Range(func(key, value any) bool {
+		d := value.(*discoveredURL)
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		if !d.inScope {
+			return true
+		}
+		k := c.orphanKey(key.(string))
+		if prev, ok := found[k]; !ok || (d.crawled && !prev.crawled) {
+			found[k], links[k] = d, key.(string)
+		}
+		return true
+	})
+
+	var orphans []string
+	for key, link := range c.sitemapPages {
+		if _, ok := found[key]; !ok && !c.skipIgnored(ModeOrphans, link) {
+			orphans = append(orphans, link)
+		}
+	}
+	sort.Strings(orphans)
+
+	var rows []orphanRow
+	var mu sync.Mutex
+	c.forEachLinked(ctx, orphans, func(link string) {
+		row := orphanRow{link: link, issue: issueOrphan}
+		if status, final, _ := c.fetchLinked(ctx, link, "text/html"); status != 0 {
+			row.status = strconv.Itoa(status)
+			if final != nil && final.String() != link {
+				row.redirectedTo = final.String()
+			}
+		}
+		mu.Lock()
+		rows = append(rows, row)
+		mu.Unlock()
+		atomic.AddInt64(&c.stats.OrphanPages, 1)
+		c.log.Info(fmt.Sprintf("🏝️  ORPHAN: %s", link), "url", link, "status", row.status)
+	})
+
+	for key, d := range found {
+		d.mu.Lock()
+		missing := d.crawled && d.status >= 200 && d.status < 300 && d.redirectedTo == "" &&
+			strings.Contains(d.contentType, "text/html") && c.sitemapPages[key] == ""
+		row := orphanRow{link: links[key], issue: issueNotInSitemap, foundOn: d.foundOn, depth: strconv.Itoa(d.depth), status: strconv.Itoa(d.status)}
+		d.mu.Unlock()
+		if missing && !c.skipIgnored(ModeOrphans, row.link) {
+			rows = append(rows, row)
+			atomic.AddInt64(&c.stats.PagesNotInSitemap, 1)
+		}
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].issue != rows[j].issue {
+			return rows[i].issue == issueOrphan
+		}
+		return rows[i].link < rows[j].link
+	})
+
+	for _, r := range rows {
+		atomic.AddInt64(&c.stats.MatchesFound, 1)
+		c.writeResult(ModeOrphans, []string{r.link, r.issue, r.foundOn, r.depth, r.status, r.redirectedTo})
+	}
+}
@@ -0,0 +1,122 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+)
+
+// cookieBannerSelectors match the consent popups of the common consent
+// management platforms, generic cookie notices and chat widgets
+var cookieBannerSelectors = []string{
+	// Consent management platforms
+	"#onetrust-consent-sdk",
+	"#CybotCookiebotDialog",
+	"#CybotCookiebotDialogBodyUnderlay",
+	"#usercentrics-root",
+	"#didomi-host",
+	".qc-cmp2-container",
+	"#truste-consent-track",
+	".truste_overlay",
+	".truste_box_overlay",
+	".osano-cm-window",
+	"#hs-eu-cookie-confirmation",
+	".cky-consent-container",
+	".cky-overlay",
+	"#cmplz-cookiebanner-container",
+	"#iubenda-cs-banner",
+	"#termly-code-snippet-support",
+	"#sp_message_container",
+	"div[id^='sp_message_container_']",
+	// Generic cookie notices
+	"#cookie-notice",
+	"#cookie-law-info-bar",
+	"#cookie-banner",
+	".cookie-banner",
+	"#cookieConsent",
+	".cookie-consent",
+	".cc-window",
+	".cc-banner",
+	"[aria-label='cookieconsent']",
+	// Chat widgets
+	"#intercom-container",
+	".intercom-lightweight-app",
+	"#hubspot-messages-iframe-container",
+	"#drift-frame-controller",
+	"#drift-frame-chat",
+	"iframe#launcher",
+	"iframe[title='Button to launch messaging window']",
+	"#tidio-chat",
+	"#crisp-chatbox",
+}
+
+// cleanPage hides CaptureOptions.HideSelectors (and cookie banners when
+// HideCookieBanners is set) and runs BeforeCaptureJS, once the page has
+// rendered and before it's captured. Elements are hidden with a style sheet
+// rather than removed, so ones the page adds later are hidden too. A failing
+// script is logged rather than failing the capture.
+func (o CaptureOptions) cleanPage(pageURL string, log *slog.Logger) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		selectors := o.HideSelectors
+		if o.HideCookieBanners {
+			selectors = append(append([]string{}, cookieBannerSelectors...), selectors...)
+		}
+
+		if len(selectors) > 0 {
+			// One rule per selector, so a selector Chrome can't parse only
+			// drops its own rule
+			var css strings.Builder
+			for _, sel := range selectors {
+				fmt.Fprintf(&css, "%s { display: none !important; }\n", sel)
+			}
+			if o.HideCookieBanners {
+				// Consent popups often lock scrolling while they're open
+				css.WriteString("html, body { overflow: visible !important; }\n")
+			}
+
+			cssJSON, _ := json.Marshal(css.String())
+			script := fmt.Sprintf(`(() => {
+				const style = document.createElement('style');
+				style.textContent = %s;
+				document.head.appendChild(style);
+			})()`, cssJSON)
+			if err := chromedp.Evaluate(script, nil).Do(ctx); err != nil {
+				return err
+			}
+		}
+
+		if strings.TrimSpace(o.BeforeCaptureJS) == "" {
+			return nil
+		}
+
+		// Run the script as the body of an async function, so it can
+		// await, and report its errors back instead of throwing
+		script := fmt.Sprintf(`(async () => {
+			try {
+				await (async () => {
+%s
+				})();
+				return "";
+			} catch (e) {
+				return String(e && e.stack || e);
+			}
+		})()`, o.BeforeCaptureJS)
+
+		var scriptErr string
+		err := chromedp.Evaluate(script, &scriptErr, func(p *runtime.EvaluateParams) *runtime.EvaluateParams {
+			return p.WithAwaitPromise(true)
+		}).Do(ctx)
+		if err != nil {
+			scriptErr = err.Error()
+		}
+		if scriptErr != "" {
+			log.Warn(fmt.Sprintf("⚠️  Before-capture script failed on %s: %s", pageURL, scriptErr), "url", pageURL, "error", scriptErr)
+		}
+		return nil
+	})
+}
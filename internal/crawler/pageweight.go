@@ -0,0 +1,300 @@
+package crawler
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	defaultPageWeightBudget = 2 * 1024 * 1024 // bytes
+	defaultTTFBBudget       = 800 * time.Millisecond
+)
+
+// pageTiming measures a page request: the time to first byte and size for
+// the page-weight audit, and how long each phase took for the fetch log
+type pageTiming struct {
+	mu        sync.Mutex // the trace hooks can run on the transport's goroutines
+	start     time.Time
+	ttfb      time.Duration // until the first byte of the final response, including redirects
+	wireBytes int64         // HTML bytes as sent, before decompression
+
+	// Summed over every connection opened for the request, including for
+	// redirects; 0 when a kept-alive connection was reused
+	dns, connect, tls                time.Duration
+	dnsStart, connectStart, tlsStart time.Time
+}
+
+// trace returns req set up to record the time to first byte and the DNS,
+// connect and TLS phases
+func (t *pageTiming) trace(req *http.Request) *http.Request {
+	t.start = time.Now()
+	since := func(from time.Time) time.Duration {
+		if from.IsZero() {
+			return 0
+		}
+		return time.Since(from)
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { t.mark(&t.dnsStart) },
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.mu.Lock()
+			t.dns += since(t.dnsStart)
+			t.mu.Unlock()
+		},
+		ConnectStart: func(string, string) { t.mark(&t.connectStart) },
+		ConnectDone: func(_, _ string, err error) {
+			t.mu.Lock()
+			if err == nil {
+				t.connect += since(t.connectStart)
+			}
+			t.mu.Unlock()
+		},
+		TLSHandshakeStart: func() { t.mark(&t.tlsStart) },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.mu.Lock()
+			t.tls += since(t.tlsStart)
+			t.mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			t.ttfb = time.Since(t.start)
+			t.mu.Unlock()
+		},
+	}))
+}
+
+// mark records when a phase started
+func (t *pageTiming) mark(at *time.Time) {
+	t.mu.Lock()
+	*at = time.Now()
+	t.mu.Unlock()
+}
+
+// timeToFirstByte returns the time to first byte, once the response is in
+func (t *pageTiming) timeToFirstByte() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.ttfb
+}
+
+// countBody makes resp count the bytes read from the wire, before readBody
+// decompresses them
+func (t *pageTiming) countBody(resp *http.Response) {
+	resp.Body = &countingBody{ReadCloser: resp.Body, n: &t.wireBytes}
+}
+
+type countingBody struct {
+	io.ReadCloser
+	n *int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	*b.n += int64(n)
+	return n, err
+}
+
+// resourceSize caches the transfer size of an image, script or stylesheet,
+// which is usually shared by many pages
+type resourceSize struct {
+	once  sync.Once
+	bytes int64
+	ok    bool
+}
+
+// pageResources returns the images, scripts and stylesheets a browser
+// downloads when it loads the page, resolved and de-duplicated. Lazy-loaded
+// images and files pulled in by CSS (fonts, @import) aren't counted.
+func pageResources(doc *html.Node, pageURL string) (images, scripts, styles []string) {
+	if doc == nil {
+		return nil, nil, nil
+	}
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, nil, nil
+	}
+
+	seen := make(map[string]bool)
+	add := func(list *[]string, ref string) {
+		ref = strings.TrimSpace(ref)
+		if ref == "" || strings.HasPrefix(ref, "data:") {
+			return
+		}
+		u, err := url.Parse(ref)
+		if err != nil {
+			return
+		}
+		resolved := base.ResolveReference(u)
+		resolved.Fragment = ""
+		if resolved.Scheme != "http" && resolved.Scheme != "https" {
+			return
+		}
+		if key := resolved.String(); !seen[key] {
+			seen[key] = true
+			*list = append(*list, key)
+		}
+	}
+
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "base":
+				if href := attrValue(n, "href"); href != "" {
+					if u, err := url.Parse(href); err == nil {
+						base = base.ResolveReference(u)
+					}
+				}
+			case "img":
+				// The browser downloads one srcset candidate; src is the
+				// fallback most pages size it for
+				if src := attrValue(n, "src"); src != "" {
+					add(&images, src)
+				} else if candidates := parseSrcset(attrValue(n, "srcset")); len(candidates) > 0 {
+					add(&images, candidates[0])
+				}
+			case "script":
+				add(&scripts, attrValue(n, "src"))
+			case "link":
+				for _, rel := range strings.Fields(strings.ToLower(attrValue(n, "rel"))) {
+					if rel == "stylesheet" {
+						add(&styles, attrValue(n, "href"))
+						break
+					}
+				}
+			case "style":
+				if n.FirstChild != nil {
+					for _, bg := range cssImageURLs(n.FirstChild.Data) {
+						add(&images, bg)
+					}
+				}
+			}
+			if style := attrValue(n, "style"); style != "" {
+				for _, bg := range cssImageURLs(style) {
+					add(&images, bg)
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(doc)
+	return images, scripts, styles
+}
+
+// resourceWeight returns the number of bytes transferred for a resource, as
+// a browser asking for compressed responses would receive it
+func (c *Crawler) resourceWeight(ctx context.Context, resourceURL string) (int64, bool) {
+	value, _ := c.resourceSizes.LoadOrStore(resourceURL, &resourceSize{})
+	size := value.(*resourceSize)
+	size.once.Do(func() {
+		atomic.AddInt64(&c.stats.ResourcesChecked, 1)
+		if !c.limiter.wait(ctx, resourceURL) {
+			return
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "GET", resourceURL, nil)
+		if err != nil {
+			return
+		}
+		req.Header.Set("User-Agent", c.fingerprint.userAgent)
+		req.Header.Set("Accept-Encoding", "gzip, deflate, br")
+
+		client := &http.Client{Timeout: c.requestTimeout(), Transport: c.checkTransport, Jar: c.httpClient.Jar}
+		resp, err := client.Do(req)
+		if err != nil {
+			return
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != 200 {
+			return
+		}
+
+		n, err := io.Copy(io.Discard, resp.Body)
+		if err != nil {
+			return
+		}
+		size.bytes, size.ok = n, true
+	})
+	return size.bytes, size.ok
+}
+
+// auditPageWeight totals the page's HTML and the resources it loads, and
+// flags it when it's over the weight or time-to-first-byte budget
+func (c *Crawler) auditPageWeight(ctx context.Context, pageURL, foundOn string, doc *html.Node, timing *pageTiming) {
+	if c.skipIgnored(ModePageWeight, pageURL) {
+		return
+	}
+	images, scripts, styles := pageResources(doc, pageURL)
+
+	failed := 0
+	sum := func(urls []string) int64 {
+		var total int64
+		for _, u := range urls {
+			n, ok := c.resourceWeight(ctx, u)
+			if !ok {
+				failed++
+			}
+			total += n
+		}
+		return total
+	}
+	htmlBytes := timing.wireBytes
+	ttfb := timing.timeToFirstByte()
+	imageBytes := sum(images)
+	scriptBytes := sum(scripts)
+	styleBytes := sum(styles)
+	total := htmlBytes + imageBytes + scriptBytes + styleBytes
+	requests := 1 + len(images) + len(scripts) + len(styles)
+
+	budget := c.config.PageWeightBudget
+	if budget <= 0 {
+		budget = defaultPageWeightBudget
+	}
+	ttfbBudget := c.config.TTFBBudget
+	if ttfbBudget <= 0 {
+		ttfbBudget = defaultTTFBBudget
+	}
+
+	var issues []string
+	if total > budget {
+		atomic.AddInt64(&c.stats.PagesOverBudget, 1)
+		issues = append(issues, fmt.Sprintf("over %s budget", formatBytes(budget)))
+	}
+	if ttfb > ttfbBudget {
+		atomic.AddInt64(&c.stats.SlowTTFB, 1)
+		issues = append(issues, fmt.Sprintf("TTFB over %dms", ttfbBudget.Milliseconds()))
+	}
+	if failed > 0 {
+		issues = append(issues, fmt.Sprintf("%d resources failed to load", failed))
+	}
+
+	atomic.AddInt64(&c.stats.PageWeightTotal, total)
+	atomic.AddInt64(&c.stats.TTFBTotalMs, ttfb.Milliseconds())
+	if total > budget || ttfb > ttfbBudget {
+		atomic.AddInt64(&c.stats.MatchesFound, 1)
+		c.log.Info(fmt.Sprintf("🏋️  HEAVY PAGE (%s, TTFB %dms): %s", formatBytes(total), ttfb.Milliseconds(), pageURL),
+			"url", pageURL, "bytes", total, "ttfb_ms", ttfb.Milliseconds(), "requests", requests)
+		c.notifyFinding("heavy_page", pageURL, fmt.Sprintf("%s, TTFB %dms", formatBytes(total), ttfb.Milliseconds()))
+	}
+
+	kb := func(b int64) string { return strconv.FormatInt((b+512)/1024, 10) }
+	c.writeResult(ModePageWeight, []string{
+		pageURL, foundOn, kb(total), kb(htmlBytes), kb(imageBytes), kb(scriptBytes), kb(styleBytes),
+		strconv.Itoa(requests), strconv.FormatInt(ttfb.Milliseconds(), 10), strings.Join(issues, "; "),
+		time.Now().Format(time.RFC3339),
+	})
+}
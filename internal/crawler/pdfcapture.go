@@ -1,7 +1,6 @@
 package crawler
 
 import (
-	"bufio"
 	"context"
 	"fmt"
 	"net/url"
@@ -15,195 +14,307 @@ import (
 	"sync/atomic"
 	"time"
 
-	"github.com/chromedp/cdproto/emulation"
-	"github.com/chromedp/cdproto/page"
 	"github.com/chromedp/chromedp"
 )
 
 type PDFCaptureStats struct {
-	PagesVisited    int64
-	PagesQueued     int64
-	PDFsGenerated   int64
-	ScreenshotsGen  int64
-	Errors          int64
-	SkippedExternal int64
+	PagesVisited     int64
+	PagesQueued      int64
+	PDFsGenerated    int64
+	ScreenshotsGen   int64
+	ArchivesSaved    int64 // MHTML files
+	Errors           int64
+	SkippedExternal  int64
+	SkippedTimeLimit int64 // pages not captured because Config.MaxDuration ran out
+	SkippedBudget    int64 // pages not captured because Config.MaxTotalBytes was used up
 }
 
-var (
-	cancelRequested int32 // atomic flag for cancellation
-)
+// pdfCapture holds the state of a page capture crawl
+type pdfCapture struct {
+	c                 *Crawler
+	config            Config
+	visited           sync.Map
+	wg                sync.WaitGroup
+	sema              chan struct{}
+	stats             PDFCaptureStats
+	startTime         time.Time
+	baseURL           *url.URL
+	outputDir         string
+	concurrency       int
+	format            CaptureFormat
+	pathFilter        string // Only crawl URLs matching this path prefix
+	ignoreQueryParams bool   // Treat URLs with different query params as the same page
+	currentPage       string // Currently processing page (for status display)
+	currentMu         sync.Mutex
+	cancelRequested   int32         // atomic flag for cancellation
+	browsers          *browserPool  // Chrome processes shared by the captures
+	pdfs              []capturedPDF // PDFs to bind when Config.MergePDFs is set
+	pdfsMu            sync.Mutex
+	mergedPDF         string // Path of the combined PDF, once written
+}
 
-var (
-	pdfVisited           sync.Map
-	pdfWg                sync.WaitGroup
-	pdfSema              chan struct{}
-	pdfStats             PDFCaptureStats
-	pdfStartTime         time.Time
-	pdfBaseURL           *url.URL
-	pdfOutputDir         string
-	pdfConcurrency       int
-	pdfCaptureFormat     CaptureFormat
-	pdfPathFilter        string // Only crawl URLs matching this path prefix
-	pdfIgnoreQueryParams bool   // Treat URLs with different query params as the same page
-	pdfCurrentPage       string // Currently processing page (for status display)
-	pdfCurrentMu         sync.Mutex
-)
+func newPDFCapture(c *Crawler) *pdfCapture {
+	cfg := c.config
+	return &pdfCapture{
+		c:                 c,
+		config:            cfg,
+		concurrency:       cfg.MaxConcurrency,
+		format:            cfg.CaptureFormat,
+		pathFilter:        cfg.PathFilter,
+		ignoreQueryParams: cfg.IgnoreQueryParams,
+		sema:              make(chan struct{}, cfg.MaxConcurrency),
+	}
+}
 
 // StartPDFCapture begins crawling and capturing PDFs/screenshots
 func StartPDFCapture(cfg Config) {
-	pdfVisited = sync.Map{}
-	pdfStats = PDFCaptureStats{}
-	pdfStartTime = time.Now()
-	pdfConcurrency = cfg.MaxConcurrency
-	pdfCaptureFormat = cfg.CaptureFormat
-	pdfPathFilter = cfg.PathFilter
-	pdfIgnoreQueryParams = cfg.IgnoreQueryParams
-	atomic.StoreInt32(&cancelRequested, 0)
+	cfg.Mode = ModePDFCapture
+	New(cfg).Run(context.Background())
+}
+
+func (p *pdfCapture) run(ctx context.Context) {
+	cfg := p.config
+	p.startTime = time.Now()
 
 	// Default to both if not set
-	if pdfCaptureFormat == 0 {
-		pdfCaptureFormat = CaptureBoth
+	if p.format == 0 {
+		p.format = CaptureBoth
 	}
 
 	var err error
-	pdfBaseURL, err = url.Parse(cfg.StartURL)
+	p.baseURL, err = url.Parse(cfg.StartURL)
 	if err != nil {
-		fmt.Printf("❌ Invalid start URL: %v\n", err)
+		p.c.log.Error(fmt.Sprintf("❌ Invalid start URL: %v", err), "error", err)
+		return
+	}
+
+	// Check for the conversion tools before spending a crawl on captures
+	// that can't be saved
+	if err := checkCMYKTools(p.format, cfg.CaptureOpts); err != nil {
+		p.c.log.Error(fmt.Sprintf("❌ %v", err), "error", err)
 		return
 	}
+	if p.merging() {
+		if _, err := exec.LookPath("gs"); err != nil {
+			p.c.log.Warn("⚠️  Ghostscript (gs) not found in PATH - the PDFs won't be combined", "error", err)
+			p.config.MergePDFs = false
+		}
+	}
 
 	// Create output directory with timestamp
 	timestamp := time.Now().Format("2006-01-02_15-04-05")
-	pdfOutputDir = fmt.Sprintf("page_captures_%s", timestamp)
-	os.MkdirAll(pdfOutputDir, 0755)
+	p.outputDir = p.c.outputPath(fmt.Sprintf("page_captures_%s", timestamp))
+	os.MkdirAll(p.outputDir, 0755)
 
-	pdfSema = make(chan struct{}, cfg.MaxConcurrency)
+	// Pages are rendered in tabs of a few shared Chrome processes
+	p.browsers = newBrowserPool(p.c, chromedp.Flag("disable-web-security", true))
+	defer p.browsers.close()
 
 	// Start live stats
 	stopStats := make(chan bool)
-	go printPDFLiveStats(stopStats)
-
-	// Start keyboard listener for cancellation
-	stopKeyListener := make(chan bool)
-	go listenForCancel(stopKeyListener)
+	go p.printPDFLiveStats(stopStats)
 
 	// Determine format label
-	formatLabel := pdfCaptureFormat.String()
+	formatLabel := p.format.String()
 
 	fmt.Println("┌─────────────────── PAGE CAPTURE STARTING ──────────────────┐")
 	fmt.Printf("│  🎯 Target: %-43s │\n", truncateString(cfg.StartURL, 43))
-	if pdfPathFilter != "" {
-		fmt.Printf("│  🌲 Path:   %-43s │\n", truncateString(pdfPathFilter, 43))
+	if p.pathFilter != "" {
+		fmt.Printf("│  🌲 Path:   %-43s │\n", truncateString(p.pathFilter, 43))
 	}
-	fmt.Printf("│  📁 Output: %-43s │\n", pdfOutputDir)
+	fmt.Printf("│  📁 Output: %-43s │\n", p.outputDir)
 	fmt.Printf("│  📋 Format: %-43s │\n", formatLabel)
-	fmt.Println("├────────────────────────────────────────────────────────────┤")
-	fmt.Println("│  💡 Press 'c' + Enter to cancel and save current progress  │")
+	fmt.Printf("│  📱 Device: %-43s │\n", truncateString(cfg.CaptureOpts.deviceLabel(), 43))
+	if p.format == CaptureImagesOnly || p.format == CaptureBoth {
+		fmt.Printf("│  🖼️  Images: %-43s │\n", truncateString(cfg.CaptureOpts.imageLabel(), 43))
+	}
+	if p.format == CapturePDFOnly || p.format == CaptureBoth || p.format == CaptureCMYKPDF {
+		fmt.Printf("│  📐 Paper:  %-43s │\n", truncateString(cfg.CaptureOpts.String(), 43))
+	}
+	if p.merging() {
+		fmt.Printf("│  📚 Merge:  %-43s │\n", fmt.Sprintf("%s, %s", MergedPDFName, strings.ToLower(cfg.MergeOrder.String())))
+	}
 	fmt.Println("└────────────────────────────────────────────────────────────┘")
 	fmt.Println()
 
-	// Start crawling
-	crawlForPDF(cfg.StartURL)
-	pdfWg.Wait()
+	// Start crawling, either from the sitemap or from the start URL
+	var seeds []string
+	if len(p.c.urlList) > 0 {
+		seeds = p.c.urlList
+	} else if cfg.SeedFromSitemap {
+		seeds = p.c.sitemapSeedsForCrawl(ctx)
+		if seeds == nil {
+			p.config.SeedFromSitemap = false
+		}
+	}
+
+	if len(seeds) > 0 {
+		for _, seed := range seeds {
+			p.crawlForPDF(ctx, seed, 0)
+		}
+	} else {
+		for _, start := range p.c.startURLs() {
+			p.crawlForPDF(ctx, start, 0)
+		}
+	}
+	p.wg.Wait()
+
+	// Ctrl+C and Stop both count as cancelling
+	if p.stopped(ctx) {
+		atomic.StoreInt32(&p.cancelRequested, 1)
+	}
 
 	stopStats <- true
-	stopKeyListener <- true
-	printPDFFinalStats()
+	if p.merging() {
+		p.mergeCapturedPDFs()
+	}
+	p.printPDFFinalStats()
 }
 
-// listenForCancel listens for 'c' key press to cancel crawling
-func listenForCancel(stop chan bool) {
-	reader := bufio.NewReader(os.Stdin)
-	inputChan := make(chan string)
+// merging reports whether the captured PDFs are to be bound into one
+func (p *pdfCapture) merging() bool {
+	return p.config.MergePDFs && (p.format == CapturePDFOnly || p.format == CaptureBoth || p.format == CaptureCMYKPDF)
+}
 
-	go func() {
-		for {
-			input, err := reader.ReadString('\n')
-			if err != nil {
-				return
-			}
-			inputChan <- strings.TrimSpace(strings.ToLower(input))
-		}
-	}()
+// addMergePDF records a saved PDF for the combined PDF. buf is the PDF
+// Chrome printed, which the page count is read from.
+func (p *pdfCapture) addMergePDF(pageURL, path string, buf []byte, queued int64) {
+	if !p.merging() {
+		return
+	}
+	p.pdfsMu.Lock()
+	p.pdfs = append(p.pdfs, capturedPDF{url: pageURL, path: path, pages: pdfPageCount(buf), queue: queued})
+	p.pdfsMu.Unlock()
+}
 
-	for {
-		select {
-		case <-stop:
-			return
-		case input := <-inputChan:
-			if input == "c" {
-				atomic.StoreInt32(&cancelRequested, 1)
-				fmt.Println("\n\n⏹️  CANCEL REQUESTED - Finishing current captures...")
-				fmt.Println("   (Waiting for in-progress pages to complete)")
-				return
-			}
-		}
+// mergeCapturedPDFs binds the PDFs captured so far into MergedPDFName
+func (p *pdfCapture) mergeCapturedPDFs() {
+	if len(p.pdfs) == 0 {
+		return
+	}
+	if !p.config.Quiet {
+		fmt.Print("\033[2K\r")
+		fmt.Printf("📚 Combining %d PDFs into %s...\n", len(p.pdfs), MergedPDFName)
+	}
+	path := filepath.Join(p.outputDir, MergedPDFName)
+	if err := mergePDFs(p.pdfs, p.config.MergeOrder, path); err != nil {
+		atomic.AddInt64(&p.stats.Errors, 1)
+		p.c.log.Error(fmt.Sprintf("❌ Couldn't combine PDFs: %v", err), "error", err)
+		return
 	}
+	p.mergedPDF = path
+}
+
+// stopped reports whether Stop was called or ctx was cancelled
+func (p *pdfCapture) stopped(ctx context.Context) bool {
+	return p.c.interrupted(ctx)
 }
 
-func crawlForPDF(link string) {
+func (p *pdfCapture) crawlForPDF(ctx context.Context, link string, depth int) {
 	// Check if cancel requested
-	if atomic.LoadInt32(&cancelRequested) == 1 {
+	if p.stopped(ctx) {
+		return
+	}
+
+	// Enforce max depth
+	if p.config.MaxDepth > 0 && depth > p.config.MaxDepth {
 		return
 	}
 
 	// Normalize URL
-	link = normalizeURL(link)
+	link = p.normalizeURL(link)
 
 	// Check if already visited
-	if _, exists := pdfVisited.LoadOrStore(link, true); exists {
+	if _, exists := p.visited.LoadOrStore(link, true); exists {
 		return
 	}
 
-	atomic.AddInt64(&pdfStats.PagesQueued, 1)
+	// Respect robots.txt
+	if !p.c.robots.allowed(ctx, link) {
+		return
+	}
 
-	pdfWg.Add(1)
+	// Enforce the time limit
+	if p.c.outOfTime() {
+		atomic.AddInt64(&p.stats.SkippedTimeLimit, 1)
+		return
+	}
+	if p.c.outOfBudget() {
+		atomic.AddInt64(&p.stats.SkippedBudget, 1)
+		return
+	}
+
+	// Enforce page limit
+	queued := atomic.AddInt64(&p.stats.PagesQueued, 1)
+	if p.config.MaxPages > 0 && queued > int64(p.config.MaxPages) {
+		atomic.AddInt64(&p.stats.PagesQueued, -1)
+		return
+	}
+
+	p.wg.Add(1)
 	go func(pageURL string) {
-		defer pdfWg.Done()
-		pdfSema <- struct{}{}
-		defer func() { <-pdfSema }()
+		defer p.wg.Done()
+		select {
+		case p.sema <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		defer func() { <-p.sema }()
 
-		// Check again in case cancel happened while waiting
-		if atomic.LoadInt32(&cancelRequested) == 1 {
+		// Hold here while paused, and check again in case the capture was
+		// cancelled while waiting
+		if !p.c.proceed(ctx) {
+			return
+		}
+		if p.c.outOfTime() {
+			atomic.AddInt64(&p.stats.SkippedTimeLimit, 1)
+			return
+		}
+		if p.c.outOfBudget() {
+			atomic.AddInt64(&p.stats.SkippedBudget, 1)
 			return
 		}
 
-		atomic.AddInt64(&pdfStats.PagesVisited, 1)
+		atomic.AddInt64(&p.stats.PagesVisited, 1)
+		if !p.c.robots.wait(ctx, pageURL) || !p.c.limiter.wait(ctx, pageURL) {
+			return
+		}
 
 		// Capture PDF/screenshot and extract links from the rendered DOM
-		links := capturePage(pageURL)
+		links := p.capturePage(ctx, pageURL, queued)
 
-		// Queue discovered links for crawling (only if not cancelled)
-		if atomic.LoadInt32(&cancelRequested) == 0 {
+		// Queue discovered links for crawling (only if not cancelled or seeded from a sitemap or URL list)
+		if !p.stopped(ctx) && p.c.followLinks() {
 			for _, nextLink := range links {
-				crawlForPDF(nextLink)
+				p.crawlForPDF(ctx, nextLink, depth+1)
 			}
 		}
 	}(link)
 }
 
-func capturePage(pageURL string) []string {
+func (p *pdfCapture) capturePage(parent context.Context, pageURL string, queued int64) []string {
 	var extractedLinks []string
 	
 	// Track current page for status display
-	pdfCurrentMu.Lock()
-	pdfCurrentPage = pageURL
-	pdfCurrentMu.Unlock()
+	p.currentMu.Lock()
+	p.currentPage = pageURL
+	p.currentMu.Unlock()
 	
 	// Create a safe filename from URL
-	filename := sanitizeFilename(pageURL)
+	filename := sanitizeFilename(pageURL, p.ignoreQueryParams)
 
-	pdfPath := filepath.Join(pdfOutputDir, filename+".pdf")
-	pngPath := filepath.Join(pdfOutputDir, filename+".png")
+	pdfPath := filepath.Join(p.outputDir, filename+".pdf")
+	imagePath := filepath.Join(p.outputDir, filename+"."+p.config.CaptureOpts.ImageFormat.Ext())
+	mhtmlPath := filepath.Join(p.outputDir, filename+".mhtml")
 
 	// Check if already captured based on format
-	switch pdfCaptureFormat {
+	switch p.format {
 	case CapturePDFOnly:
 		if _, err := os.Stat(pdfPath); err == nil {
 			return nil
 		}
 	case CaptureImagesOnly:
-		if _, err := os.Stat(pngPath); err == nil {
+		if _, err := os.Stat(imagePath); err == nil {
 			return nil
 		}
 	case CaptureBoth:
@@ -211,33 +322,28 @@ func capturePage(pageURL string) []string {
 			return nil
 		}
 	case CaptureCMYKPDF:
-		cmykPdfPath := filepath.Join(pdfOutputDir, filename+"_cmyk.pdf")
+		cmykPdfPath := filepath.Join(p.outputDir, filename+"_cmyk.pdf")
 		if _, err := os.Stat(cmykPdfPath); err == nil {
 			return nil
 		}
 	case CaptureCMYKTIFF:
-		tiffPath := filepath.Join(pdfOutputDir, filename+"_cmyk.tiff")
+		tiffPath := filepath.Join(p.outputDir, filename+"_cmyk.tiff")
 		if _, err := os.Stat(tiffPath); err == nil {
 			return nil
 		}
+	case CaptureMHTML:
+		if _, err := os.Stat(mhtmlPath); err == nil {
+			return nil
+		}
 	}
 
-	// Create Chrome context with options for better rendering
-	opts := append(chromedp.DefaultExecAllocatorOptions[:],
-		chromedp.Flag("headless", true),
-		chromedp.Flag("disable-gpu", true),
-		chromedp.Flag("no-sandbox", true),
-		chromedp.Flag("disable-setuid-sandbox", true),
-		chromedp.Flag("disable-dev-shm-usage", true),
-		chromedp.Flag("disable-web-security", true),
-		chromedp.Flag("ignore-certificate-errors", true),
-		chromedp.WindowSize(1920, 1080),
-	)
-
-	allocCtx, allocCancel := chromedp.NewExecAllocator(context.Background(), opts...)
-	defer allocCancel()
-
-	ctx, cancel := chromedp.NewContext(allocCtx)
+	// Open a tab in one of the shared browsers
+	ctx, cancel, err := p.browsers.tab(parent)
+	if err != nil {
+		atomic.AddInt64(&p.stats.Errors, 1)
+		p.c.log.Error(fmt.Sprintf("❌ Error: %s - %v", truncateString(pageURL, 40), err), "url", pageURL, "error", err)
+		return nil
+	}
 	defer cancel()
 
 	// Set timeout (180s for slow/heavy pages)
@@ -245,11 +351,16 @@ func capturePage(pageURL string) []string {
 	defer cancel()
 
 	var pdfBuf []byte
-	var pngBuf []byte
+	var pageTitle string
+	var imageBuf []byte
+	var imageFormat ImageFormat
+	var mhtml string
 	var linksHTML string
 
 	// Build actions based on capture format
 	actions := []chromedp.Action{
+		p.c.browserRequestHeaders(pageURL),
+		p.config.CaptureOpts.emulate(),
 		// Navigate to page and wait for network to be mostly idle
 		chromedp.Navigate(pageURL),
 		// Wait for DOM to be ready
@@ -296,6 +407,8 @@ func capturePage(pageURL string) []string {
 		}),
 		// Extra wait for any final rendering
 		chromedp.Sleep(1 * time.Second),
+		// Hide cookie banners and other overlays, and run the user's script
+		p.config.CaptureOpts.cleanPage(pageURL, p.c.log),
 		// Extract all links from the rendered DOM
 		chromedp.Evaluate(`
 			Array.from(document.querySelectorAll('a[href]'))
@@ -305,135 +418,114 @@ func capturePage(pageURL string) []string {
 		`, &linksHTML),
 	}
 
+	// Save the page as one MHTML file if needed
+	if p.format == CaptureMHTML {
+		actions = append(actions, snapshotMHTML(&mhtml))
+	}
+
 	// Add screenshot capture if needed
-	needsScreenshot := pdfCaptureFormat == CaptureImagesOnly || 
-		pdfCaptureFormat == CaptureBoth || 
-		pdfCaptureFormat == CaptureCMYKTIFF
+	needsScreenshot := p.format == CaptureImagesOnly || 
+		p.format == CaptureBoth || 
+		p.format == CaptureCMYKTIFF
 	
 	if needsScreenshot {
-		actions = append(actions, chromedp.ActionFunc(func(ctx context.Context) error {
-			// Get page dimensions
-			_, _, contentSize, _, _, _, err := page.GetLayoutMetrics().Do(ctx)
-			if err != nil {
-				return err
-			}
-
-			width, height := int64(contentSize.Width), int64(contentSize.Height)
-
-			// Cap height to avoid memory issues
-			if height > 16384 {
-				height = 16384
-			}
-
-			// Set viewport to full page size
-			err = emulation.SetDeviceMetricsOverride(width, height, 1, false).
-				WithScreenOrientation(&emulation.ScreenOrientation{
-					Type:  emulation.OrientationTypePortraitPrimary,
-					Angle: 0,
-				}).Do(ctx)
-			if err != nil {
-				return err
-			}
-
-			// Capture screenshot
-			pngBuf, err = page.CaptureScreenshot().
-				WithQuality(100).
-				WithFromSurface(true).
-				Do(ctx)
-			return err
-		}))
+		// CMYK TIFFs are converted from a lossless PNG
+		opts := p.config.CaptureOpts
+		if p.format == CaptureCMYKTIFF {
+			opts.ImageFormat = ImagePNG
+		}
+		actions = append(actions, opts.screenshot(pageURL, &imageBuf, &imageFormat, p.c.log))
 	}
 
 	// Add PDF generation if needed
-	needsPDF := pdfCaptureFormat == CapturePDFOnly || 
-		pdfCaptureFormat == CaptureBoth || 
-		pdfCaptureFormat == CaptureCMYKPDF
+	needsPDF := p.format == CapturePDFOnly || 
+		p.format == CaptureBoth || 
+		p.format == CaptureCMYKPDF
 	
 	if needsPDF {
 		actions = append(actions, chromedp.ActionFunc(func(ctx context.Context) error {
+			// The title goes in the PDF's metadata; a page without one
+			// is still printed
+			chromedp.Title(&pageTitle).Do(ctx)
+
 			var err error
-			pdfBuf, _, err = page.PrintToPDF().
-				WithPrintBackground(true).
-				WithScale(1.0).
-				WithPaperWidth(8.5).
-				WithPaperHeight(11).
-				WithMarginTop(0.4).
-				WithMarginBottom(0.4).
-				WithMarginLeft(0.4).
-				WithMarginRight(0.4).
-				WithDisplayHeaderFooter(false).
-				WithGenerateDocumentOutline(false).
-				Do(ctx)
+			pdfBuf, _, err = p.config.CaptureOpts.printToPDF().Do(ctx)
 			return err
 		}))
 	}
 
-	err := chromedp.Run(ctx, actions...)
+	err = chromedp.Run(ctx, actions...)
 
 	if err != nil {
-		atomic.AddInt64(&pdfStats.Errors, 1)
-		// Clear progress bar line and print error
-		fmt.Print("\033[2K\r")
-		fmt.Printf("❌ Error: %s - %v\n\n", truncateString(pageURL, 40), err)
+		atomic.AddInt64(&p.stats.Errors, 1)
+		p.c.log.Error(fmt.Sprintf("❌ Error: %s - %v\n", truncateString(pageURL, 40), err), "url", pageURL, "error", err)
 		return nil
 	}
 
+	if needsPDF {
+		pdfBuf = p.c.stampPDF(pdfBuf, pdfMetadata{Title: pageTitle, URL: pageURL, Captured: time.Now()})
+	}
+
 	// Save PDF if generated
-	if pdfCaptureFormat == CapturePDFOnly || pdfCaptureFormat == CaptureBoth {
+	if p.format == CapturePDFOnly || p.format == CaptureBoth {
 		if err := os.WriteFile(pdfPath, pdfBuf, 0644); err != nil {
-			atomic.AddInt64(&pdfStats.Errors, 1)
+			atomic.AddInt64(&p.stats.Errors, 1)
 			return extractedLinks
 		}
-		atomic.AddInt64(&pdfStats.PDFsGenerated, 1)
+		atomic.AddInt64(&p.stats.PDFsGenerated, 1)
+		p.addMergePDF(pageURL, pdfPath, pdfBuf, queued)
 	}
 
 	// Save and convert to CMYK PDF if needed
-	if pdfCaptureFormat == CaptureCMYKPDF {
+	if p.format == CaptureCMYKPDF {
 		// First save the RGB PDF temporarily
-		tempPdfPath := filepath.Join(pdfOutputDir, filename+"_temp.pdf")
+		tempPdfPath := filepath.Join(p.outputDir, filename+"_temp.pdf")
 		if err := os.WriteFile(tempPdfPath, pdfBuf, 0644); err != nil {
-			atomic.AddInt64(&pdfStats.Errors, 1)
+			atomic.AddInt64(&p.stats.Errors, 1)
 			return extractedLinks
 		}
 		
 		// Convert to CMYK using Ghostscript
-		cmykPdfPath := filepath.Join(pdfOutputDir, filename+"_cmyk.pdf")
-		if err := convertToCMYKPDF(tempPdfPath, cmykPdfPath); err != nil {
-			atomic.AddInt64(&pdfStats.Errors, 1)
+		cmykPdfPath := filepath.Join(p.outputDir, filename+"_cmyk.pdf")
+		if err := convertToCMYKPDF(tempPdfPath, cmykPdfPath, p.config.CaptureOpts.CMYKProfile); err != nil {
+			atomic.AddInt64(&p.stats.Errors, 1)
+			p.c.log.Error(fmt.Sprintf("❌ CMYK conversion failed: %s - %v", truncateString(pageURL, 40), err), "url", pageURL, "error", err)
 			os.Remove(tempPdfPath)
 			return extractedLinks
 		}
 		os.Remove(tempPdfPath) // Clean up temp file
-		atomic.AddInt64(&pdfStats.PDFsGenerated, 1)
+		atomic.AddInt64(&p.stats.PDFsGenerated, 1)
+		p.addMergePDF(pageURL, cmykPdfPath, pdfBuf, queued)
 	}
 
 	// Save screenshot if generated
-	if pdfCaptureFormat == CaptureImagesOnly || pdfCaptureFormat == CaptureBoth {
-		if err := os.WriteFile(pngPath, pngBuf, 0644); err != nil {
-			atomic.AddInt64(&pdfStats.Errors, 1)
+	if p.format == CaptureImagesOnly || p.format == CaptureBoth {
+		imagePath = filepath.Join(p.outputDir, filename+"."+imageFormat.Ext())
+		if err := os.WriteFile(imagePath, imageBuf, 0644); err != nil {
+			atomic.AddInt64(&p.stats.Errors, 1)
 			return extractedLinks
 		}
-		atomic.AddInt64(&pdfStats.ScreenshotsGen, 1)
+		atomic.AddInt64(&p.stats.ScreenshotsGen, 1)
 	}
 
 	// Save and convert to CMYK TIFF if needed
-	if pdfCaptureFormat == CaptureCMYKTIFF {
-		// First save the PNG temporarily
-		tempPngPath := filepath.Join(pdfOutputDir, filename+"_temp.png")
-		if err := os.WriteFile(tempPngPath, pngBuf, 0644); err != nil {
-			atomic.AddInt64(&pdfStats.Errors, 1)
+	if p.format == CaptureCMYKTIFF {
+		tiffPath := filepath.Join(p.outputDir, filename+"_cmyk.tiff")
+		if err := writeCMYKTIFF(imageBuf, tiffPath); err != nil {
+			atomic.AddInt64(&p.stats.Errors, 1)
+			p.c.log.Error(fmt.Sprintf("❌ CMYK conversion failed: %s - %v", truncateString(pageURL, 40), err), "url", pageURL, "error", err)
 			return extractedLinks
 		}
-		
-		// Convert to CMYK TIFF using ImageMagick
-		tiffPath := filepath.Join(pdfOutputDir, filename+"_cmyk.tiff")
-		if err := convertToCMYKTIFF(tempPngPath, tiffPath); err != nil {
-			atomic.AddInt64(&pdfStats.Errors, 1)
-			os.Remove(tempPngPath)
+		atomic.AddInt64(&p.stats.ScreenshotsGen, 1)
+	}
+
+	// Save MHTML archive if captured
+	if p.format == CaptureMHTML {
+		if err := os.WriteFile(mhtmlPath, []byte(mhtml), 0644); err != nil {
+			atomic.AddInt64(&p.stats.Errors, 1)
 			return extractedLinks
 		}
-		os.Remove(tempPngPath) // Clean up temp file
-		atomic.AddInt64(&pdfStats.ScreenshotsGen, 1)
+		atomic.AddInt64(&p.stats.ArchivesSaved, 1)
 	}
 
 	// Progress bar shows current status, so no need for individual messages
@@ -452,14 +544,19 @@ func capturePage(pageURL string) []string {
 				continue
 			}
 			
-			// Only follow same-domain links
-			if u.Host != pdfBaseURL.Host {
-				atomic.AddInt64(&pdfStats.SkippedExternal, 1)
+			// Only follow links within the crawl's domains
+			if !p.c.scope.contains(u) {
+				atomic.AddInt64(&p.stats.SkippedExternal, 1)
 				continue
 			}
 			
 			// Apply path filter if set (only crawl URLs within the specified path)
-			if pdfPathFilter != "" && !strings.HasPrefix(u.Path, pdfPathFilter) {
+			if p.pathFilter != "" && !strings.HasPrefix(u.Path, p.pathFilter) {
+				continue
+			}
+
+			// Apply include/exclude patterns
+			if !p.c.filter.allowed(u) {
 				continue
 			}
 			
@@ -487,12 +584,12 @@ func capturePage(pageURL string) []string {
 	if parsedPage != nil {
 		// Normalize paths for comparison (remove trailing slash)
 		normalizedPath := strings.TrimSuffix(parsedPage.Path, "/")
-		normalizedFilter := strings.TrimSuffix(pdfPathFilter, "/")
+		normalizedFilter := strings.TrimSuffix(p.pathFilter, "/")
 		
 		// Check if this is a listing/index page (ends with / or matches filter path exactly)
 		isListingPage := strings.HasSuffix(parsedPage.Path, "/") || 
 			normalizedPath == normalizedFilter ||
-			parsedPage.Path == pdfPathFilter
+			parsedPage.Path == p.pathFilter
 		
 		if isListingPage {
 			// Try adding ?page=N if not already present
@@ -511,7 +608,7 @@ func capturePage(pageURL string) []string {
 	return extractedLinks
 }
 
-func sanitizeFilename(urlStr string) string {
+func sanitizeFilename(urlStr string, ignoreQueryParams bool) string {
 	u, err := url.Parse(urlStr)
 	if err != nil {
 		return "page"
@@ -534,7 +631,7 @@ func sanitizeFilename(urlStr string) string {
 	name = invalidChars.ReplaceAllString(name, "_")
 
 	// Add query string hash if present (unless ignoring query params)
-	if u.RawQuery != "" && !pdfIgnoreQueryParams {
+	if u.RawQuery != "" && !ignoreQueryParams {
 		name += "_q" + hashString(u.RawQuery)[:8]
 	}
 
@@ -561,7 +658,7 @@ func hashString(s string) string {
 	return fmt.Sprintf("%08x", h)
 }
 
-func normalizeURL(link string) string {
+func (p *pdfCapture) normalizeURL(link string) string {
 	u, err := url.Parse(link)
 	if err != nil {
 		return link
@@ -571,7 +668,7 @@ func normalizeURL(link string) string {
 	u.Fragment = ""
 
 	// Strip query parameters when IgnoreQueryParams is enabled
-	if pdfIgnoreQueryParams {
+	if p.ignoreQueryParams {
 		u.RawQuery = ""
 	}
 
@@ -583,7 +680,7 @@ func normalizeURL(link string) string {
 	return u.String()
 }
 
-func printPDFLiveStats(stop chan bool) {
+func (p *pdfCapture) printPDFLiveStats(stop chan bool) {
 	ticker := time.NewTicker(1 * time.Second) // Update more frequently
 	defer ticker.Stop()
 	
@@ -595,17 +692,21 @@ func printPDFLiveStats(stop chan bool) {
 		case <-stop:
 			return
 		case <-ticker.C:
-			elapsed := time.Since(pdfStartTime)
-			queued := atomic.LoadInt64(&pdfStats.PagesQueued)
-			visited := atomic.LoadInt64(&pdfStats.PagesVisited)
-			pdfs := atomic.LoadInt64(&pdfStats.PDFsGenerated)
-			screenshots := atomic.LoadInt64(&pdfStats.ScreenshotsGen)
-			errors := atomic.LoadInt64(&pdfStats.Errors)
+			if p.config.Quiet {
+				continue
+			}
+			elapsed := time.Since(p.startTime)
+			queued := atomic.LoadInt64(&p.stats.PagesQueued)
+			visited := atomic.LoadInt64(&p.stats.PagesVisited)
+			pdfs := atomic.LoadInt64(&p.stats.PDFsGenerated)
+			screenshots := atomic.LoadInt64(&p.stats.ScreenshotsGen)
+			archives := atomic.LoadInt64(&p.stats.ArchivesSaved)
+			errors := atomic.LoadInt64(&p.stats.Errors)
 			
 			// Get current page
-			pdfCurrentMu.Lock()
-			currentPage := pdfCurrentPage
-			pdfCurrentMu.Unlock()
+			p.currentMu.Lock()
+			currentPage := p.currentPage
+			p.currentMu.Unlock()
 
 			pagesPerSec := float64(visited) / elapsed.Seconds()
 			if elapsed.Seconds() < 1 {
@@ -653,7 +754,7 @@ func printPDFLiveStats(stop chan bool) {
 				pending = 0
 			}
 			
-			switch pdfCaptureFormat {
+			switch p.format {
 			case CapturePDFOnly:
 				fmt.Printf("%s \033[32m[%s]\033[0m %3d%% │ ⏱ %s │ 📑 %d captured │ ⏳ %d pending │ ❌ %d │ %.1f/s\n",
 					spinner, bar, pct, formatDuration(elapsed), pdfs, pending, errors, pagesPerSec)
@@ -669,6 +770,9 @@ func printPDFLiveStats(stop chan bool) {
 			case CaptureCMYKTIFF:
 				fmt.Printf("%s \033[32m[%s]\033[0m %3d%% │ ⏱ %s │ 🎨 %d TIFF │ ⏳ %d pending │ ❌ %d │ %.1f/s\n",
 					spinner, bar, pct, formatDuration(elapsed), screenshots, pending, errors, pagesPerSec)
+			case CaptureMHTML:
+				fmt.Printf("%s \033[32m[%s]\033[0m %3d%% │ ⏱ %s │ 📦 %d captured │ ⏳ %d pending │ ❌ %d │ %.1f/s\n",
+					spinner, bar, pct, formatDuration(elapsed), archives, pending, errors, pagesPerSec)
 			}
 			
 			// Show current page on second line
@@ -683,9 +787,9 @@ func printPDFLiveStats(stop chan bool) {
 	}
 }
 
-func printPDFFinalStats() {
-	elapsed := time.Since(pdfStartTime)
-	wasCancelled := atomic.LoadInt32(&cancelRequested) == 1
+func (p *pdfCapture) printPDFFinalStats() {
+	elapsed := time.Since(p.startTime)
+	wasCancelled := atomic.LoadInt32(&p.cancelRequested) == 1
 
 	// Clear any remaining progress bar output
 	fmt.Print("\033[2K\r") // Clear current line
@@ -701,41 +805,61 @@ func printPDFFinalStats() {
 	fmt.Println("╠═══════════════════════════════════════════════════════════════════╣")
 	fmt.Println("║                                                                   ║")
 	fmt.Printf("║  ⏱️  Total Time:           %-40s ║\n", formatDuration(elapsed))
-	fmt.Printf("║  📄 Pages Visited:         %-40d ║\n", pdfStats.PagesVisited)
+	fmt.Printf("║  📄 Pages Visited:         %-40d ║\n", p.stats.PagesVisited)
 
 	// Show stats based on capture format
-	switch pdfCaptureFormat {
+	switch p.format {
 	case CapturePDFOnly:
-		fmt.Printf("║  📑 PDFs Generated:        %-40d ║\n", pdfStats.PDFsGenerated)
+		fmt.Printf("║  📑 PDFs Generated:        %-40d ║\n", p.stats.PDFsGenerated)
 	case CaptureImagesOnly:
-		fmt.Printf("║  🖼️  Images Generated:      %-40d ║\n", pdfStats.ScreenshotsGen)
+		fmt.Printf("║  🖼️  Images Generated:      %-40d ║\n", p.stats.ScreenshotsGen)
 	case CaptureBoth:
-		fmt.Printf("║  📑 PDFs Generated:        %-40d ║\n", pdfStats.PDFsGenerated)
-		fmt.Printf("║  🖼️  Images Generated:      %-40d ║\n", pdfStats.ScreenshotsGen)
+		fmt.Printf("║  📑 PDFs Generated:        %-40d ║\n", p.stats.PDFsGenerated)
+		fmt.Printf("║  🖼️  Images Generated:      %-40d ║\n", p.stats.ScreenshotsGen)
 	case CaptureCMYKPDF:
-		fmt.Printf("║  🎨 CMYK PDFs Generated:   %-40d ║\n", pdfStats.PDFsGenerated)
+		fmt.Printf("║  🎨 CMYK PDFs Generated:   %-40d ║\n", p.stats.PDFsGenerated)
 	case CaptureCMYKTIFF:
-		fmt.Printf("║  🎨 CMYK TIFFs Generated:  %-40d ║\n", pdfStats.ScreenshotsGen)
+		fmt.Printf("║  🎨 CMYK TIFFs Generated:  %-40d ║\n", p.stats.ScreenshotsGen)
+	case CaptureMHTML:
+		fmt.Printf("║  📦 MHTML Files Saved:     %-40d ║\n", p.stats.ArchivesSaved)
 	}
 
-	fmt.Printf("║  ❌ Errors:                %-40d ║\n", pdfStats.Errors)
-	fmt.Printf("║  📁 Output Directory:      %-40s ║\n", pdfOutputDir)
+	fmt.Printf("║  ❌ Errors:                %-40d ║\n", p.stats.Errors)
+	if p.config.MaxDuration > 0 {
+		fmt.Printf("║  ⌛ Skipped (Time Limit):  %-40d ║\n", p.stats.SkippedTimeLimit)
+	}
+	if p.config.MaxTotalBytes > 0 {
+		fmt.Printf("║  💸 Skipped (Budget):      %-40d ║\n", p.stats.SkippedBudget)
+	}
+	fmt.Printf("║  📁 Output Directory:      %-40s ║\n", p.outputDir)
+	if p.mergedPDF != "" {
+		fmt.Printf("║  📚 Combined PDF:          %-40s ║\n", MergedPDFName)
+	}
 	fmt.Println("║                                                                   ║")
 	if wasCancelled {
 		fmt.Println("║  ℹ️  Crawl was cancelled early - partial results saved            ║")
 		fmt.Println("║                                                                   ║")
+	} else if atomic.LoadInt32(&p.c.timedOut) == 1 {
+		fmt.Printf("║  ℹ️  Time limit reached - captured %-31s ║\n",
+			fmt.Sprintf("%.1f%% of discovered pages", coverage(p.stats.PagesVisited, p.stats.SkippedTimeLimit)))
+		fmt.Println("║                                                                   ║")
+	} else if p.c.outOfBudget() {
+		fmt.Printf("║  ℹ️  Download budget used up - captured %-26s ║\n",
+			fmt.Sprintf("%.1f%% of discovered pages", coverage(p.stats.PagesVisited, p.stats.SkippedBudget)))
+		fmt.Println("║                                                                   ║")
 	}
 	fmt.Println("╚═══════════════════════════════════════════════════════════════════╝")
 }
 
-// convertToCMYKPDF converts an RGB PDF to CMYK using Ghostscript
-func convertToCMYKPDF(inputPath, outputPath string) error {
+// convertToCMYKPDF converts an RGB PDF to CMYK using Ghostscript, through
+// the ICC profile at profilePath if it's set
+func convertToCMYKPDF(inputPath, outputPath, profilePath string) error {
 	// Check if Ghostscript is available
 	if _, err := exec.LookPath("gs"); err != nil {
 		return fmt.Errorf("ghostscript (gs) not found in PATH - install with: sudo apt install ghostscript")
 	}
 
-	cmd := exec.Command("gs",
+	args := []string{
 		"-dSAFER",
 		"-dBATCH",
 		"-dNOPAUSE",
@@ -744,47 +868,18 @@ func convertToCMYKPDF(inputPath, outputPath string) error {
 		"-sColorConversionStrategy=CMYK",
 		"-dProcessColorModel=/DeviceCMYK",
 		"-dAutoRotatePages=/None",
-		"-sOutputFile="+outputPath,
-		inputPath,
-	)
-
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("ghostscript error: %v - %s", err, string(output))
 	}
-
-	return nil
-}
-
-// convertToCMYKTIFF converts a PNG to CMYK TIFF using ImageMagick
-func convertToCMYKTIFF(inputPath, outputPath string) error {
-	// Check if ImageMagick is available (try both 'convert' and 'magick')
-	var cmdName string
-	if _, err := exec.LookPath("magick"); err == nil {
-		cmdName = "magick"
-	} else if _, err := exec.LookPath("convert"); err == nil {
-		cmdName = "convert"
-	} else {
-		return fmt.Errorf("imagemagick not found in PATH - install with: sudo apt install imagemagick")
+	if profilePath != "" {
+		args = append(args, "--permit-file-read="+profilePath, "-sOutputICCProfile="+profilePath)
 	}
+	args = append(args, "-sOutputFile="+outputPath, inputPath)
 
-	args := []string{
-		inputPath,
-		"-colorspace", "CMYK",
-		"-compress", "LZW",
-		outputPath,
-	}
+	cmd := exec.Command("gs", args...)
 
-	// ImageMagick 7 uses 'magick convert', older versions just 'convert'
-	if cmdName == "magick" {
-		args = append([]string{"convert"}, args...)
-	}
-
-	cmd := exec.Command(cmdName, args...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		return fmt.Errorf("imagemagick error: %v - %s", err, string(output))
+		return fmt.Errorf("ghostscript error: %v - %s", err, string(output))
 	}
 
 	return nil
-}
\ No newline at end of file
+}
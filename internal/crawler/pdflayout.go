@@ -0,0 +1,250 @@
+package crawler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/chromedp/cdproto/page"
+)
+
+// PaperSize is the page size of PDF captures
+type PaperSize int
+
+const (
+	PaperLetter PaperSize = iota + 1
+	PaperA4
+	PaperLegal
+	PaperCustom // CaptureOptions.PaperWidth × PaperHeight
+	PaperDevice // The capture device's screen, so PDFs keep its layout
+)
+
+func (p PaperSize) String() string {
+	switch p {
+	case PaperLetter:
+		return "Letter"
+	case PaperA4:
+		return "A4"
+	case PaperLegal:
+		return "Legal"
+	case PaperCustom:
+		return "Custom"
+	case PaperDevice:
+		return "Device screen"
+	default:
+		return "Letter"
+	}
+}
+
+// DefaultPDFFooter is a footer template with the page URL on the left and
+// the page number on the right
+const DefaultPDFFooter = `<div style="font-size:8px;width:100%;margin:0 0.4in;display:flex;justify-content:space-between;color:#666">` +
+	`<span class="url"></span><span><span class="pageNumber"></span> / <span class="totalPages"></span></span></div>`
+
+// SourcePDFFooter is a footer template recording where and when each page was
+// captured, so a printed or archived PDF can be traced back to its source
+const SourcePDFFooter = `<div style="font-size:8px;width:100%;margin:0 0.4in;display:flex;justify-content:space-between;color:#666">` +
+	`<span>Captured <span class="date"></span> from <span class="url"></span></span><span><span class="pageNumber"></span> / <span class="totalPages"></span></span></div>`
+
+const defaultPDFMargin = 0.4 // inches
+
+// CaptureOptions sets how page capture and JSON feed modes render pages: the
+// device they're shown on, how screenshots are saved, what's cleaned off the
+// page before capture and the page layout of PDFs. The zero value renders on
+// a 1920x1080 desktop, saves full-resolution PNGs, hides nothing and prints
+// US Letter, portrait, at 100% with 0.4in margins and no header or footer.
+//
+// Header and footer templates are HTML; Chrome fills elements with the
+// classes date, title, url, pageNumber and totalPages with the page's
+// values. They're drawn inside the margins, so leave room for them.
+type CaptureOptions struct {
+	Device            DevicePreset // Screen to render on (default: 1920x1080 desktop)
+	ViewportWidth     int64        // Override the device's viewport width in CSS pixels
+	ViewportHeight    int64        // Override the device's viewport height in CSS pixels
+	UserAgent         string       // Override the device's user agent
+	ImageFormat       ImageFormat  // Screenshot format: PNG (default), JPEG or WebP
+	ImageQuality      int          // JPEG and WebP quality, 1-100 (default 85)
+	ImageScale        float64      // Resize screenshots, e.g. 0.5 for half the device resolution (default 1)
+	ImageMaxWidth     int64        // Shrink screenshots wider than this many pixels (0 = no limit)
+	HideCookieBanners bool         // Hide common cookie consent popups and chat widgets
+	HideSelectors     []string     // CSS selectors of other elements to hide, e.g. sticky headers
+	BeforeCaptureJS   string       // JavaScript run once the page has rendered, e.g. to click "Accept"; may use await
+	CMYKProfile       string       // CMYK ICC profile file, e.g. a FOGRA39 or GRACoL profile, that CMYK PDFs are converted through (CMYK TIFFs can't use one)
+	PaperSize         PaperSize    // Default Letter
+	PaperWidth        float64      // PaperCustom width in inches
+	PaperHeight       float64      // PaperCustom height in inches
+	Landscape         bool
+	Scale             float64   // Zoom the page content, 0.1 to 2 (default 1)
+	Margins           []float64 // Inches, in CSS order: one value for every side, or top, right, bottom, left (default 0.4)
+	HeaderTemplate    string    // HTML printed at the top of every page
+	FooterTemplate    string    // HTML printed at the bottom of every page, e.g. DefaultPDFFooter or SourcePDFFooter
+}
+
+// paper returns the portrait paper width and height in inches
+func (o CaptureOptions) paper() (width, height float64) {
+	switch o.PaperSize {
+	case PaperA4:
+		return 8.27, 11.69
+	case PaperLegal:
+		return 8.5, 14
+	case PaperCustom:
+		if o.PaperWidth > 0 && o.PaperHeight > 0 {
+			return o.PaperWidth, o.PaperHeight
+		}
+	case PaperDevice:
+		// Chrome prints at 96 CSS pixels per inch
+		spec := o.device()
+		return float64(spec.width) / 96, float64(spec.height) / 96
+	}
+	return 8.5, 11
+}
+
+// margins expands Margins the way CSS expands the margin shorthand
+func (o CaptureOptions) margins() (top, right, bottom, left float64) {
+	m := o.Margins
+	switch len(m) {
+	case 0:
+		return defaultPDFMargin, defaultPDFMargin, defaultPDFMargin, defaultPDFMargin
+	case 1:
+		return m[0], m[0], m[0], m[0]
+	case 2:
+		return m[0], m[1], m[0], m[1]
+	case 3:
+		return m[0], m[1], m[2], m[1]
+	default:
+		return m[0], m[1], m[2], m[3]
+	}
+}
+
+func (o CaptureOptions) scale() float64 {
+	switch {
+	case o.Scale <= 0:
+		return 1
+	case o.Scale < 0.1:
+		return 0.1
+	case o.Scale > 2:
+		return 2
+	}
+	return o.Scale
+}
+
+// printToPDF builds Chrome's print command for the layout
+func (o CaptureOptions) printToPDF() *page.PrintToPDFParams {
+	width, height := o.paper()
+	top, right, bottom, left := o.margins()
+
+	params := page.PrintToPDF().
+		WithPrintBackground(true).
+		WithScale(o.scale()).
+		WithPaperWidth(width).
+		WithPaperHeight(height).
+		WithLandscape(o.Landscape).
+		WithMarginTop(top).
+		WithMarginBottom(bottom).
+		WithMarginLeft(left).
+		WithMarginRight(right).
+		WithDisplayHeaderFooter(false).
+		WithGenerateDocumentOutline(false)
+
+	if o.HeaderTemplate != "" || o.FooterTemplate != "" {
+		// Chrome prints its own date and title, or URL and page number,
+		// in place of a missing template
+		header, footer := o.HeaderTemplate, o.FooterTemplate
+		if header == "" {
+			header = "<span></span>"
+		}
+		if footer == "" {
+			footer = "<span></span>"
+		}
+		params = params.
+			WithDisplayHeaderFooter(true).
+			WithHeaderTemplate(header).
+			WithFooterTemplate(footer)
+	}
+	return params
+}
+
+// String describes the layout, e.g. "A4 landscape, 0.4in margins, 90%"
+func (o CaptureOptions) String() string {
+	desc := o.PaperSize.String()
+	if o.PaperSize == PaperCustom || o.PaperSize == PaperDevice {
+		width, height := o.paper()
+		desc = fmt.Sprintf("%.3gx%.3gin", width, height)
+	}
+	if o.Landscape {
+		desc += " landscape"
+	}
+
+	top, right, bottom, left := o.margins()
+	if top == right && top == bottom && top == left {
+		desc += fmt.Sprintf(", %.3gin margins", top)
+	} else {
+		desc += fmt.Sprintf(", %.3g/%.3g/%.3g/%.3gin margins", top, right, bottom, left)
+	}
+	if s := o.scale(); s != 1 {
+		desc += fmt.Sprintf(", %g%%", s*100)
+	}
+	return desc
+}
+
+// ParsePaperDimensions parses a custom paper size such as "8.5x11",
+// "210×297mm" or "21 x 29.7 cm" into inches. Sizes without a unit are in
+// inches.
+func ParsePaperDimensions(s string) (width, height float64, err error) {
+	s = strings.ToLower(strings.TrimSpace(strings.ReplaceAll(s, "×", "x")))
+	unit := 1.0
+	for suffix, inches := range lengthUnits {
+		if strings.HasSuffix(s, suffix) {
+			s, unit = strings.TrimSuffix(s, suffix), inches
+			break
+		}
+	}
+
+	w, h, ok := strings.Cut(s, "x")
+	if !ok {
+		return 0, 0, fmt.Errorf("paper size must look like 8.5x11 or 210x297mm")
+	}
+	width, err1 := strconv.ParseFloat(strings.TrimSpace(w), 64)
+	height, err2 := strconv.ParseFloat(strings.TrimSpace(h), 64)
+	if err1 != nil || err2 != nil {
+		return 0, 0, fmt.Errorf("paper size must look like 8.5x11 or 210x297mm")
+	}
+	width, height = width*unit, height*unit
+	if width < 1 || height < 1 || width > 200 || height > 200 {
+		return 0, 0, fmt.Errorf("paper size must be between 1 and 200 inches each way")
+	}
+	return width, height, nil
+}
+
+// ParseMargins parses one to four margins in CSS order, separated by spaces
+// or commas, such as "0.4", "0.5 0.75" or "10mm, 15mm, 10mm, 15mm". Margins
+// without a unit are in inches.
+func ParseMargins(s string) ([]float64, error) {
+	var margins []float64
+	for _, part := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' }) {
+		part = strings.ToLower(part)
+		unit := 1.0
+		for suffix, inches := range lengthUnits {
+			if strings.HasSuffix(part, suffix) {
+				part, unit = strings.TrimSuffix(part, suffix), inches
+				break
+			}
+		}
+		m, err := strconv.ParseFloat(part, 64)
+		if err != nil || m < 0 {
+			return nil, fmt.Errorf("margin %q must be a number of inches, or end in mm or cm", part)
+		}
+		margins = append(margins, m*unit)
+	}
+	if len(margins) > 4 {
+		return nil, fmt.Errorf("give at most 4 margins: top, right, bottom, left")
+	}
+	return margins, nil
+}
+
+// lengthUnits converts the units accepted for paper sizes and margins to inches
+var lengthUnits = map[string]float64{
+	"in": 1,
+	"mm": 1 / 25.4,
+	"cm": 1 / 2.54,
+}
@@ -0,0 +1,141 @@
+package crawler
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// MergedPDFName is the file the captured PDFs are bound into, in the capture
+// output directory
+const MergedPDFName = "combined.pdf"
+
+// PDFMergeOrder is the order captured pages are bound in the combined PDF
+type PDFMergeOrder int
+
+const (
+	MergeByURL        PDFMergeOrder = iota + 1
+	MergeInCrawlOrder               // The order pages were queued: the sitemap's or URL list's order when seeded from one
+)
+
+func (o PDFMergeOrder) String() string {
+	switch o {
+	case MergeByURL:
+		return "By URL"
+	case MergeInCrawlOrder:
+		return "Crawl order"
+	default:
+		return "By URL"
+	}
+}
+
+// capturedPDF is a page's PDF waiting to be bound into the combined PDF
+type capturedPDF struct {
+	url   string
+	path  string
+	pages int   // 0 if the page count couldn't be read
+	queue int64 // position the page was queued in
+}
+
+// pdfCountRe matches the page counts of a PDF's page tree, the largest of
+// which is the root's
+var pdfCountRe = regexp.MustCompile(`/Count\s+(\d+)`)
+
+// pdfPageCount reads the number of pages in a PDF printed by Chrome, which
+// writes its page tree uncompressed
+func pdfPageCount(buf []byte) int {
+	pages := 0
+	for _, m := range pdfCountRe.FindAllSubmatch(buf, -1) {
+		if n, err := strconv.Atoi(string(m[1])); err == nil && n > pages {
+			pages = n
+		}
+	}
+	return pages
+}
+
+// mergePDFs binds pdfs into one PDF at outputPath using Ghostscript, in the
+// given order, with a bookmark for each page's URL
+func mergePDFs(pdfs []capturedPDF, order PDFMergeOrder, outputPath string) error {
+	if _, err := exec.LookPath("gs"); err != nil {
+		return fmt.Errorf("ghostscript (gs) not found in PATH - install with: sudo apt install ghostscript")
+	}
+	if len(pdfs) == 0 {
+		return fmt.Errorf("no PDFs were captured")
+	}
+
+	pdfs = append([]capturedPDF(nil), pdfs...)
+	sort.Slice(pdfs, func(i, j int) bool {
+		if order == MergeInCrawlOrder {
+			return pdfs[i].queue < pdfs[j].queue
+		}
+		return pdfs[i].url < pdfs[j].url
+	})
+
+	// Bookmarks are given as pdfmarks, read after the pages they point to
+	var marks strings.Builder
+	page := 1
+	for _, pdf := range pdfs {
+		if pdf.pages == 0 {
+			return fmt.Errorf("couldn't count the pages of %s", filepath.Base(pdf.path))
+		}
+		fmt.Fprintf(&marks, "[/Title %s /Page %d /View [/XYZ null null null] /OUT pdfmark\n", psString(pdf.url), page)
+		page += pdf.pages
+	}
+	marks.WriteString("[/PageMode /UseOutlines /DOCVIEW pdfmark\n")
+
+	marksPath := outputPath + ".pdfmarks"
+	if err := os.WriteFile(marksPath, []byte(marks.String()), 0644); err != nil {
+		return err
+	}
+	defer os.Remove(marksPath)
+
+	args := []string{
+		"-dSAFER",
+		"-dBATCH",
+		"-dNOPAUSE",
+		"-dQUIET",
+		"-sDEVICE=pdfwrite",
+		"-dAutoRotatePages=/None",
+		"-sOutputFile=" + outputPath,
+	}
+	for _, pdf := range pdfs {
+		args = append(args, pdf.path)
+	}
+	args = append(args, marksPath)
+
+	output, err := exec.Command("gs", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ghostscript error: %v - %s", err, string(output))
+	}
+	return nil
+}
+
+// psString quotes s as a PostScript string for a pdfmark, as UTF-16 when it
+// isn't plain ASCII
+func psString(s string) string {
+	ascii := true
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] > 0x7e {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		r := strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`)
+		return "(" + r.Replace(s) + ")"
+	}
+
+	var hex strings.Builder
+	hex.WriteString("<FEFF")
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&hex, "%04X", u)
+	}
+	hex.WriteString(">")
+	return hex.String()
+}
@@ -0,0 +1,109 @@
+package crawler
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// pdfMetadata is what's written into a captured PDF's document information,
+// so the file stays attributable after it's moved out of the output folder
+type pdfMetadata struct {
+	Title    string // the headline, or the page's <title>
+	URL      string
+	Captured time.Time
+	Tags     string
+}
+
+var (
+	pdfStartXrefRe = regexp.MustCompile(`startxref\s+(\d+)\s+%%EOF\s*$`)
+	pdfSizeRe      = regexp.MustCompile(`/Size\s+(\d+)`)
+	pdfRootRe      = regexp.MustCompile(`/Root\s+(\d+\s+\d+\s+R)`)
+	pdfIDRe        = regexp.MustCompile(`/ID\s*\[[^\]]*\]`)
+)
+
+// stampPDFMetadata returns the PDF with meta as its Title, Subject (the URL
+// and capture date) and Keywords (the tags). The new information dictionary
+// is appended as an incremental update, so Chrome's output is left byte for
+// byte as it was. It needs a classic cross-reference table, which is what
+// Chrome writes.
+func stampPDFMetadata(pdf []byte, meta pdfMetadata) ([]byte, error) {
+	m := pdfStartXrefRe.FindSubmatch(pdf)
+	if m == nil {
+		return nil, fmt.Errorf("PDF has no startxref")
+	}
+	prevXref := string(m[1])
+
+	at := bytes.LastIndex(pdf, []byte("trailer"))
+	if at < 0 {
+		return nil, fmt.Errorf("PDF has no trailer - cross-reference streams aren't supported")
+	}
+	trailer := pdf[at:]
+	size := pdfSizeRe.FindSubmatch(trailer)
+	root := pdfRootRe.FindSubmatch(trailer)
+	if size == nil || root == nil {
+		return nil, fmt.Errorf("PDF trailer has no /Size or /Root")
+	}
+	infoNum, err := strconv.Atoi(string(size[1]))
+	if err != nil {
+		return nil, err
+	}
+
+	subject := meta.URL
+	if !meta.Captured.IsZero() {
+		subject = fmt.Sprintf("Captured from %s on %s", meta.URL, meta.Captured.Format("2006-01-02 15:04 MST"))
+	}
+
+	var out bytes.Buffer
+	out.Write(pdf)
+	if pdf[len(pdf)-1] != '\n' {
+		out.WriteByte('\n')
+	}
+
+	infoOffset := out.Len()
+	fmt.Fprintf(&out, "%d 0 obj\n<<", infoNum)
+	if meta.Title != "" {
+		fmt.Fprintf(&out, " /Title %s", psString(meta.Title))
+	}
+	fmt.Fprintf(&out, " /Subject %s", psString(subject))
+	if meta.Tags != "" {
+		fmt.Fprintf(&out, " /Keywords %s", psString(meta.Tags))
+	}
+	if !meta.Captured.IsZero() {
+		fmt.Fprintf(&out, " /CreationDate %s", psString(pdfDate(meta.Captured)))
+	}
+	out.WriteString(" /Creator (webcrawler) >>\nendobj\n")
+
+	xrefOffset := out.Len()
+	fmt.Fprintf(&out, "xref\n0 1\n0000000000 65535 f \n%d 1\n%010d 00000 n \n", infoNum, infoOffset)
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root %s /Info %d 0 R /Prev %s", infoNum+1, root[1], infoNum, prevXref)
+	if id := pdfIDRe.Find(trailer); id != nil {
+		out.WriteString(" ")
+		out.Write(id)
+	}
+	fmt.Fprintf(&out, " >>\nstartxref\n%d\n%%%%EOF\n", xrefOffset)
+	return out.Bytes(), nil
+}
+
+// pdfDate formats t as a PDF date string, e.g. D:20250102150405+01'00'
+func pdfDate(t time.Time) string {
+	_, offset := t.Zone()
+	sign := '+'
+	if offset < 0 {
+		sign, offset = '-', -offset
+	}
+	return fmt.Sprintf("D:%s%c%02d'%02d'", t.Format("20060102150405"), sign, offset/3600, offset/60%60)
+}
+
+// stampPDF writes meta into a captured PDF, returning it unchanged, with a
+// warning, if that isn't possible
+func (c *Crawler) stampPDF(pdf []byte, meta pdfMetadata) []byte {
+	stamped, err := stampPDFMetadata(pdf, meta)
+	if err != nil {
+		c.log.Warn(fmt.Sprintf("⚠️  Couldn't add PDF metadata: %s - %v", truncateString(meta.URL, 40), err), "url", meta.URL, "error", err)
+		return pdf
+	}
+	return stamped
+}
@@ -0,0 +1,99 @@
+package crawler
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Presets are config files kept under ~/.webcrawler/presets, one per name,
+// so the settings for a recurring audit can be saved once from the wizard
+// and reused by name.
+
+// PresetDir returns the folder presets are kept in
+func PresetDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("finding the presets folder: %v", err)
+	}
+	return filepath.Join(home, ".webcrawler", "presets"), nil
+}
+
+// ValidatePresetName checks a preset name can be used as a file name: letters,
+// digits, dashes, underscores and dots, e.g. "client-x"
+func ValidatePresetName(name string) error {
+	if name == "" {
+		return fmt.Errorf("preset name cannot be empty")
+	}
+	if strings.HasPrefix(name, ".") || strings.Trim(name, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_.") != "" {
+		return fmt.Errorf("preset name %q may only use letters, digits, dashes, underscores and dots", name)
+	}
+	return nil
+}
+
+// PresetPath returns the file a preset is saved to
+func PresetPath(name string) (string, error) {
+	if err := ValidatePresetName(name); err != nil {
+		return "", err
+	}
+	dir, err := PresetDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+".yaml"), nil
+}
+
+// ListPresets returns the names of the saved presets, sorted
+func ListPresets() ([]string, error) {
+	dir, err := PresetDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if name, ok := strings.CutSuffix(entry.Name(), ".yaml"); ok && !entry.IsDir() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// SavePreset saves cfg as the named preset, replacing any preset of that
+// name, and returns the file it was written to
+func SavePreset(name string, cfg Config) (string, error) {
+	path, err := PresetPath(name)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	return path, SaveConfigFile(path, cfg)
+}
+
+// LoadPreset loads the named preset. A name that isn't saved is reported
+// with the presets that are.
+func LoadPreset(name string) (Config, error) {
+	path, err := PresetPath(name)
+	if err != nil {
+		return Config{}, err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		names, _ := ListPresets()
+		if len(names) == 0 {
+			return Config{}, fmt.Errorf("no preset named %q - presets are saved at the end of the wizard", name)
+		}
+		return Config{}, fmt.Errorf("no preset named %q (saved presets: %s)", name, strings.Join(names, ", "))
+	}
+	return LoadConfigFile(path)
+}
@@ -0,0 +1,103 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+)
+
+// proxyAttemptKey carries a request's retry attempt through its context
+type proxyAttemptKey struct{}
+
+// proxyRotator hands out proxies from Config.Proxies, either round-robin per
+// request or pinned to the retry attempt so every retry switches IPs
+type proxyRotator struct {
+	proxies       []*url.URL
+	rotateOnRetry bool
+	next          uint64
+}
+
+// parseProxies validates proxy URLs. Entries without a scheme are treated as
+// HTTP proxies; http, https, socks5 and socks5h are supported, with optional
+// user:password@ credentials.
+func parseProxies(raw []string) ([]*url.URL, error) {
+	var proxies []*url.URL
+	for _, p := range raw {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if !strings.Contains(p, "://") {
+			p = "http://" + p
+		}
+
+		u, err := url.Parse(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy %q: %v", p, err)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme %q in %s", u.Scheme, u.Redacted())
+		}
+		if u.Host == "" {
+			return nil, fmt.Errorf("proxy %s has no host", u.Redacted())
+		}
+		proxies = append(proxies, u)
+	}
+	return proxies, nil
+}
+
+// ValidateProxies reports the first invalid entry in a Config.Proxies list
+func ValidateProxies(raw []string) error {
+	_, err := parseProxies(raw)
+	return err
+}
+
+func newProxyRotator(proxies []*url.URL, rotateOnRetry bool) *proxyRotator {
+	if len(proxies) == 0 {
+		return nil
+	}
+	return &proxyRotator{proxies: proxies, rotateOnRetry: rotateOnRetry}
+}
+
+// proxy implements http.Transport.Proxy
+func (p *proxyRotator) proxy(req *http.Request) (*url.URL, error) {
+	if p.rotateOnRetry {
+		if attempt, ok := req.Context().Value(proxyAttemptKey{}).(int); ok {
+			return p.proxies[attempt%len(p.proxies)], nil
+		}
+	}
+	return p.pick(), nil
+}
+
+// pick returns the next proxy in round-robin order
+func (p *proxyRotator) pick() *url.URL {
+	if p == nil {
+		return nil
+	}
+	n := atomic.AddUint64(&p.next, 1) - 1
+	return p.proxies[n%uint64(len(p.proxies))]
+}
+
+// withAttempt tags req with its retry attempt for per-retry rotation
+func (p *proxyRotator) withAttempt(req *http.Request, attempt int) *http.Request {
+	if p == nil || !p.rotateOnRetry {
+		return req
+	}
+	return req.WithContext(context.WithValue(req.Context(), proxyAttemptKey{}, attempt))
+}
+
+// chromeProxyServer returns a --proxy-server value for headless Chrome.
+// Chrome ignores credentials in this flag, so authenticated proxies only
+// apply to plain HTTP requests.
+func (p *proxyRotator) chromeProxyServer() string {
+	u := p.pick()
+	if u == nil {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
@@ -0,0 +1,93 @@
+package crawler
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// tokenBucket paces requests to a single host
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// hostLimiter is a per-host token-bucket rate limiter with optional jitter.
+// It also holds back hosts that sent a Retry-After.
+type hostLimiter struct {
+	rate    float64 // tokens added per second (0 = unlimited)
+	burst   float64
+	jitter  time.Duration
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	held    map[string]time.Time // host -> no requests before this time
+	rng     *rand.Rand
+}
+
+func newHostLimiter(rps float64, jitter time.Duration) *hostLimiter {
+	return &hostLimiter{
+		rate:    rps,
+		burst:   math.Max(1, math.Ceil(rps)),
+		jitter:  jitter,
+		buckets: map[string]*tokenBucket{},
+		held:    map[string]time.Time{},
+		rng:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// wait blocks until a request to link's host is allowed under the configured
+// rate and any hold, plus a random jitter. It returns false if ctx was
+// cancelled first.
+func (l *hostLimiter) wait(ctx context.Context, link string) bool {
+	host := ""
+	if u, err := url.Parse(link); err == nil {
+		host = u.Host
+	}
+
+	l.mu.Lock()
+	var delay time.Duration
+	if until, ok := l.held[host]; ok {
+		if d := time.Until(until); d > 0 {
+			delay = d
+		} else {
+			delete(l.held, host)
+		}
+	}
+	if l.rate > 0 {
+		now := time.Now()
+		b, ok := l.buckets[host]
+		if !ok {
+			b = &tokenBucket{tokens: l.burst, last: now}
+			l.buckets[host] = b
+		}
+
+		// Refill, then reserve a token; a negative balance is time owed
+		b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+		b.last = now
+		b.tokens--
+		if b.tokens < 0 {
+			delay = max(delay, time.Duration(-b.tokens/l.rate*float64(time.Second)))
+		}
+	}
+	if l.jitter > 0 {
+		delay += time.Duration(l.rng.Int63n(int64(l.jitter)))
+	}
+	l.mu.Unlock()
+
+	return sleepCtx(ctx, delay)
+}
+
+// hold stops requests to host from starting before until. It returns false
+// if the host was already held at least that long.
+func (l *hostLimiter) hold(host string, until time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until.Before(l.held[host].Add(time.Second)) {
+		return false
+	}
+	l.held[host] = until
+	return true
+}
@@ -0,0 +1,195 @@
+package crawler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// maxRedirectHops is how many redirects are followed before a chain is
+// reported as endless, twice what browsers allow
+const maxRedirectHops = 20
+
+var (
+	errRedirectLoop     = errors.New("redirect loop")
+	errTooManyRedirects = errors.New("too many redirects")
+)
+
+// redirectHop is one response in a redirect chain
+type redirectHop struct {
+	url    string
+	status int
+}
+
+// doPageRequest sends a page request. In redirect mode each redirect is
+// followed by hand so every hop can be recorded; otherwise the client
+// follows them.
+func (c *Crawler) doPageRequest(req *http.Request, foundOn string, attempt int) (*http.Response, error) {
+	if c.config.Mode != ModeRedirectChains {
+		return c.conditionalRequest(req, attempt)
+	}
+
+	// A copy of the client shares its transport and cookie jar but hands
+	// back redirect responses instead of following them
+	client := *c.httpClient
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	start := req.URL.String()
+	var hops []redirectHop
+	visits := map[string]int{}
+	for {
+		// Coming back to a URL once is allowed, since sites redirect to a
+		// cookie check and back; a third visit means the chain never ends
+		current := req.URL.String()
+		if visits[current] == 2 {
+			hops = append(hops, redirectHop{url: current})
+			c.recordRedirectChain(start, foundOn, hops, errRedirectLoop)
+			return nil, errRedirectLoop
+		}
+		visits[current]++
+
+		resp, err := client.Do(c.proxies.withAttempt(req, attempt))
+		if err != nil {
+			return nil, err
+		}
+		hops = append(hops, redirectHop{url: current, status: resp.StatusCode})
+
+		location, err := resp.Location()
+		if resp.StatusCode < 300 || resp.StatusCode >= 400 || err != nil {
+			c.recordRedirectChain(start, foundOn, hops, nil)
+			return resp, nil
+		}
+		atomic.AddInt64(&c.stats.Status3xx, 1)
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+
+		if len(hops) > maxRedirectHops {
+			hops = append(hops, redirectHop{url: location.String()})
+			c.recordRedirectChain(start, foundOn, hops, errTooManyRedirects)
+			return nil, errTooManyRedirects
+		}
+
+		// Same headers as the first request, like the client's CheckRedirect
+		next := req.Clone(req.Context())
+		next.URL = location
+		next.Host = ""
+		req = next
+	}
+}
+
+// redirectAudit is what's wrong with a redirect chain
+type redirectAudit struct {
+	redirects int  // number of redirects followed
+	loop      bool // the chain revisits a URL or never ends
+	long      bool // more redirects than Config.RedirectHopLimit
+	downgrade bool // a hop goes from HTTPS back to HTTP
+	issues    []string
+}
+
+func auditRedirects(hops []redirectHop, err error, limit int) redirectAudit {
+	// When the chain was cut short the last hop is the URL that would have
+	// been requested next, which still counts as a redirect
+	a := redirectAudit{redirects: len(hops) - 1}
+	switch {
+	case errors.Is(err, errRedirectLoop):
+		a.loop = true
+		a.issues = append(a.issues, "redirect loop")
+	case errors.Is(err, errTooManyRedirects):
+		a.loop = true
+		a.issues = append(a.issues, fmt.Sprintf("over %d redirects", maxRedirectHops))
+	}
+
+	if !a.loop && a.redirects > limit {
+		a.long = true
+		a.issues = append(a.issues, fmt.Sprintf("chain of %d redirects", a.redirects))
+	}
+
+	upgraded := false
+	for i := 1; i < len(hops); i++ {
+		from, to := urlScheme(hops[i-1].url), urlScheme(hops[i].url)
+		switch {
+		case from == "http" && to == "https":
+			upgraded = true
+		case from == "https" && to == "http":
+			a.downgrade = true
+			if upgraded {
+				a.issues = append(a.issues, "http→https→http bounce")
+			} else {
+				a.issues = append(a.issues, "https→http downgrade")
+			}
+		}
+	}
+
+	if final := hops[len(hops)-1].status; final >= 400 {
+		a.issues = append(a.issues, fmt.Sprintf("ends in %d", final))
+	}
+	return a
+}
+
+func urlScheme(rawURL string) string {
+	scheme, _, _ := strings.Cut(rawURL, "://")
+	return strings.ToLower(scheme)
+}
+
+// recordRedirectChain writes one row per starting URL that redirects, with
+// the full chain. Pages are only recorded the first time they're fetched, so
+// retries don't add duplicate rows.
+func (c *Crawler) recordRedirectChain(start, foundOn string, hops []redirectHop, err error) {
+	if len(hops) < 2 {
+		return
+	}
+	if _, loaded := c.redirectsSeen.LoadOrStore(start, true); loaded {
+		return
+	}
+	if c.skipIgnored(ModeRedirectChains, start) {
+		return
+	}
+
+	limit := c.config.RedirectHopLimit
+	if limit <= 0 {
+		limit = 1
+	}
+	audit := auditRedirects(hops, err, limit)
+	issues := audit.issues
+	redirects := audit.redirects
+	final := hops[len(hops)-1]
+
+	atomic.AddInt64(&c.stats.Redirects, 1)
+	if len(issues) > 0 {
+		atomic.AddInt64(&c.stats.MatchesFound, 1)
+	}
+	if audit.loop {
+		atomic.AddInt64(&c.stats.RedirectLoops, 1)
+	}
+	if audit.long {
+		atomic.AddInt64(&c.stats.LongRedirectChains, 1)
+	}
+	if audit.downgrade {
+		atomic.AddInt64(&c.stats.SchemeDowngrades, 1)
+	}
+
+	chain := make([]string, len(hops))
+	for i, hop := range hops {
+		if hop.status == 0 {
+			chain[i] = hop.url
+		} else {
+			chain[i] = fmt.Sprintf("%s [%d]", hop.url, hop.status)
+		}
+	}
+
+	if len(issues) > 0 {
+		c.log.Info(fmt.Sprintf("↪️  REDIRECT ISSUE (%s): %s", strings.Join(issues, ", "), start), "url", start, "final_url", final.url, "redirects", redirects, "issues", strings.Join(issues, "; "))
+		c.notifyFinding("redirect_issue", start, strings.Join(issues, ", "))
+	} else {
+		c.log.Debug(fmt.Sprintf("   ↪️  %d redirect(s): %s → %s", redirects, start, final.url), "url", start, "final_url", final.url, "redirects", redirects)
+	}
+
+	c.writeResult(ModeRedirectChains, []string{start, foundOn, final.url, strconv.Itoa(final.status), strconv.Itoa(redirects), strings.Join(issues, "; "), strings.Join(chain, " → "), time.Now().Format(time.RFC3339)})
+}
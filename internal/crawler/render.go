@@ -0,0 +1,81 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// renderSettle is how long a rendered page's DOM must stop changing before
+// it's read, and renderSettleMax the longest it's waited for
+const (
+	renderSettle    = time.Second
+	renderSettleMax = 10 * time.Second
+)
+
+// rendersJS reports whether fetched HTML pages are loaded in Chrome before
+// they're checked. Mirror mode saves what the server sent and redirect mode
+// only looks at responses, so neither renders.
+func (c *Crawler) rendersJS() bool {
+	return c.config.RenderJS && c.config.Mode != ModeMirror && c.config.Mode != ModeRedirectChains
+}
+
+// renderPage loads an HTML page in one of the pooled browsers and returns
+// the DOM once its scripts have built it, so links and text that only exist
+// after a React or Vue app hydrates are seen. If Chrome can't load the page,
+// the HTML the server sent is checked instead.
+func (c *Crawler) renderPage(ctx context.Context, link string, body []byte) []byte {
+	tabCtx, cancel, err := c.browsers.tab(ctx)
+	if err == nil {
+		defer cancel()
+		tabCtx, cancel = context.WithTimeout(tabCtx, c.requestTimeout()+renderSettleMax)
+		defer cancel()
+
+		var html string
+		err = chromedp.Run(tabCtx,
+			c.browserRequestHeaders(link),
+			chromedp.Navigate(link),
+			chromedp.WaitReady("body", chromedp.ByQuery),
+			waitForStableDOM(),
+			chromedp.Evaluate(`document.documentElement.outerHTML`, &html),
+		)
+		if err == nil {
+			atomic.AddInt64(&c.stats.PagesRendered, 1)
+			return []byte(html)
+		}
+	}
+
+	if atomic.AddInt64(&c.stats.RenderFailed, 1) == 1 {
+		c.log.Warn(fmt.Sprintf("⚠️  Could not render %s in Chrome (%v) - checking the HTML the server sent", truncateString(link, 40), err), "url", link, "error", err)
+	} else {
+		c.log.Debug(fmt.Sprintf("   ⚠️  Not rendered: %s", link), "url", link, "error", err)
+	}
+	return body
+}
+
+// waitForStableDOM waits until the page's element count has stopped
+// changing for renderSettle, or renderSettleMax has passed
+func waitForStableDOM() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		deadline := time.Now().Add(renderSettleMax)
+		last, since := -1, time.Now()
+		for time.Now().Before(deadline) {
+			var count int
+			if err := chromedp.Evaluate(`document.getElementsByTagName('*').length`, &count).Do(ctx); err != nil {
+				return err
+			}
+			if count != last {
+				last, since = count, time.Now()
+			} else if time.Since(since) >= renderSettle {
+				return nil
+			}
+			if !sleepCtx(ctx, 200*time.Millisecond) {
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,348 @@
+package crawler
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// errNotArchived is the error for a request the replayed archive has no
+// response to
+var errNotArchived = errors.New("not in the replayed archive")
+
+// replayModes are the modes that can run on an archive: those that fetch
+// pages with the crawler's HTTP client. The capture, feed, listing and
+// visual diff modes load pages in Chrome.
+var replayModes = map[SearchMode]bool{
+	ModeSearchLink:      true,
+	ModeSearchWord:      true,
+	ModeBrokenLinks:     true,
+	ModeOversizedImages: true,
+	ModeSitemap:         true,
+	ModeRedirectChains:  true,
+	ModePageWeight:      true,
+	ModeStructuredData:  true,
+	ModeExtract:         true,
+	ModeContentDiff:     true,
+	ModeMirror:          true,
+	ModeDiscover:        true,
+}
+
+// CanReplay reports whether mode can run on an archive through Config.Replay
+func CanReplay(mode SearchMode) bool {
+	return replayModes[mode]
+}
+
+// archivedResponse is a response saved in an archive. Mirrored files are
+// read when they're requested rather than held in memory.
+type archivedResponse struct {
+	status int
+	header http.Header
+	body   []byte
+	file   string
+}
+
+// replayArchive answers requests from a HAR file, a WARC file or a mirror
+// folder instead of the network, as an http.RoundTripper. Requests for URLs
+// that weren't archived fail with errNotArchived.
+type replayArchive struct {
+	kind      string
+	responses map[string]*archivedResponse // by URL, without the fragment
+	mirrorDir string                       // mirror folder, for URLs missing from its results CSV
+	c         *Crawler
+}
+
+// openReplay loads the archive at path: a folder is taken to be a mirror,
+// a .har file HAR, and anything else WARC (optionally gzipped)
+func (c *Crawler) openReplay(path string) (*replayArchive, error) {
+	a := &replayArchive{responses: make(map[string]*archivedResponse), c: c}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening the archive: %v", err)
+	}
+	switch {
+	case info.IsDir():
+		a.kind = "mirror"
+		err = a.loadMirror(path)
+	case strings.EqualFold(filepath.Ext(path), ".har"):
+		a.kind = "HAR"
+		err = a.loadHAR(path)
+	default:
+		a.kind = "WARC"
+		err = a.loadWARC(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s archive %s: %v", a.kind, path, err)
+	}
+	if len(a.responses) == 0 && a.mirrorDir == "" {
+		return nil, fmt.Errorf("%s archive %s has no responses", a.kind, path)
+	}
+	return a, nil
+}
+
+// archiveKey is the URL a response is stored under
+func archiveKey(u *url.URL) string {
+	u2 := *u
+	u2.Fragment, u2.RawFragment = "", ""
+	return u2.String()
+}
+
+func (a *replayArchive) add(link string, resp *archivedResponse) {
+	u, err := url.Parse(link)
+	if err != nil || u.Host == "" {
+		return
+	}
+	a.responses[archiveKey(u)] = resp
+}
+
+// loadHAR reads the entries of a HAR file, as saved by browser developer
+// tools. Their bodies are already decoded.
+func (a *replayArchive) loadHAR(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var har struct {
+		Log struct {
+			Entries []struct {
+				Request struct {
+					Method string `json:"method"`
+					URL    string `json:"url"`
+				} `json:"request"`
+				Response struct {
+					Status  int `json:"status"`
+					Headers []struct {
+						Name  string `json:"name"`
+						Value string `json:"value"`
+					} `json:"headers"`
+					Content struct {
+						MimeType string `json:"mimeType"`
+						Text     string `json:"text"`
+						Encoding string `json:"encoding"`
+					} `json:"content"`
+				} `json:"response"`
+			} `json:"entries"`
+		} `json:"log"`
+	}
+	if err := json.Unmarshal(data, &har); err != nil {
+		return err
+	}
+
+	for _, entry := range har.Log.Entries {
+		// Requests the browser blocked or cancelled have status 0
+		if entry.Request.Method != http.MethodGet || entry.Response.Status == 0 {
+			continue
+		}
+		header := http.Header{}
+		for _, h := range entry.Response.Headers {
+			switch strings.ToLower(h.Name) {
+			case "content-encoding", "content-length", "transfer-encoding":
+			default:
+				header.Add(h.Name, h.Value)
+			}
+		}
+		content := entry.Response.Content
+		if header.Get("Content-Type") == "" && content.MimeType != "" {
+			header.Set("Content-Type", content.MimeType)
+		}
+		body := []byte(content.Text)
+		if content.Encoding == "base64" {
+			if body, err = base64.StdEncoding.DecodeString(content.Text); err != nil {
+				continue
+			}
+		}
+		a.add(entry.Request.URL, &archivedResponse{status: entry.Response.Status, header: header, body: body})
+	}
+	return nil
+}
+
+// loadWARC reads the response records of a WARC file. Bodies are kept as
+// sent, so a compressed body is decompressed by the crawler as usual.
+func (a *replayArchive) loadWARC(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		// Each record is usually its own gzip member; gzip.Reader reads
+		// them as one stream
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		br = bufio.NewReader(zr)
+	}
+
+	tp := textproto.NewReader(br)
+	for {
+		line, err := tp.ReadLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(line, "WARC/") {
+			// The blank lines between records
+			continue
+		}
+		fields, err := tp.ReadMIMEHeader()
+		if err != nil {
+			return err
+		}
+		length, err := strconv.ParseInt(fields.Get("Content-Length"), 10, 64)
+		if err != nil {
+			return fmt.Errorf("record without a Content-Length")
+		}
+		block := make([]byte, length)
+		if _, err := io.ReadFull(br, block); err != nil {
+			return err
+		}
+
+		if fields.Get("WARC-Type") != "response" || !strings.HasPrefix(fields.Get("Content-Type"), "application/http") {
+			continue
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(block)), nil)
+		if err != nil {
+			continue
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			continue
+		}
+		resp.Header.Del("Transfer-Encoding")
+		resp.Header.Del("Content-Length")
+		target := strings.Trim(fields.Get("WARC-Target-URI"), "<>")
+		a.add(target, &archivedResponse{status: resp.StatusCode, header: resp.Header, body: body})
+	}
+}
+
+// loadMirror reads the URLs saved in a mirror folder from the results CSV
+// mirror mode wrote next to it. Links in the saved pages point at the saved
+// copies, so URLs that aren't in the CSV are looked for where mirror mode
+// would have saved them.
+func (a *replayArchive) loadMirror(dir string) error {
+	a.mirrorDir = dir
+	name := filepath.Base(filepath.Clean(dir))
+	timestamp, ok := strings.CutPrefix(name, "mirror_")
+	if !ok {
+		return nil
+	}
+	f, err := os.Open(filepath.Join(filepath.Dir(filepath.Clean(dir)), resultFileName(ModeMirror, timestamp)))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return err
+	}
+	// URL, LocalPath, Kind, ContentType, SizeKB, Error, Timestamp
+	for _, row := range rows[min(1, len(rows)):] {
+		if len(row) < 6 || row[1] == "" || row[5] != "" {
+			continue
+		}
+		header := http.Header{}
+		header.Set("Content-Type", row[3])
+		a.add(row[0], &archivedResponse{status: http.StatusOK, header: header, file: filepath.Join(dir, filepath.FromSlash(row[1]))})
+	}
+	return nil
+}
+
+// lookup returns the archived response for u, or nil
+func (a *replayArchive) lookup(u *url.URL) *archivedResponse {
+	if resp := a.responses[archiveKey(u)]; resp != nil {
+		return resp
+	}
+	if a.mirrorDir == "" {
+		return nil
+	}
+	for _, contentType := range []string{"text/html", ""} {
+		file := filepath.Join(a.mirrorDir, filepath.FromSlash(a.c.mirrorPath(u, contentType)))
+		if info, err := os.Stat(file); err == nil && !info.IsDir() {
+			header := http.Header{}
+			header.Set("Content-Type", mime.TypeByExtension(filepath.Ext(file)))
+			return &archivedResponse{status: http.StatusOK, header: header, file: file}
+		}
+	}
+	return nil
+}
+
+// RoundTrip implements http.RoundTripper
+func (a *replayArchive) RoundTrip(req *http.Request) (*http.Response, error) {
+	archived := a.lookup(req.URL)
+	if archived == nil {
+		return nil, errNotArchived
+	}
+	body := archived.body
+	if archived.file != "" {
+		var err error
+		if body, err = os.ReadFile(archived.file); err != nil {
+			return nil, errNotArchived
+		}
+	}
+	if req.Method == http.MethodHead {
+		body = nil
+	}
+
+	header := archived.header.Clone()
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", archived.status, http.StatusText(archived.status)),
+		StatusCode:    archived.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// startReplay points the crawler's requests at Config.Replay. Nothing is
+// sent to the site: robots.txt, sitemaps, pages and the link and image
+// checks are all answered from the archive, with no rate limit or cache,
+// and nothing is retried since the archive would give the same answer.
+func (c *Crawler) startReplay() error {
+	if !CanReplay(c.config.Mode) {
+		return fmt.Errorf("%s can't run on an archive, since it loads pages in Chrome", c.config.Mode)
+	}
+	archive, err := c.openReplay(c.config.Replay)
+	if err != nil {
+		return err
+	}
+	c.httpClient.Transport = archive
+	c.checkTransport = archive
+	c.limiter = newHostLimiter(0, 0)
+	c.cache = nil
+	c.config.MaxRetries = 0
+	c.config.RetryBlockedPages = false
+	if c.config.RenderJS {
+		c.log.Warn("⚠️  Not rendering JavaScript - Chrome would load the pages from the site")
+		c.config.RenderJS = false
+	}
+	c.log.Info(fmt.Sprintf("📼 Replaying %d archived responses from %s (%s)", len(archive.responses), c.config.Replay, archive.kind),
+		"path", c.config.Replay, "format", archive.kind, "responses", len(archive.responses))
+	return nil
+}
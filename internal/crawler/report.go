@@ -0,0 +1,205 @@
+package crawler
+
+import (
+	"encoding/csv"
+	"html/template"
+	"os"
+	"strings"
+	"time"
+)
+
+// reportBar is one bar in a report chart
+type reportBar struct {
+	Label   string
+	Count   int64
+	Percent float64
+	Class   string
+}
+
+// reportData is everything rendered into the HTML report
+type reportData struct {
+	Title       string
+	Target      string
+	Generated   string
+	Duration    string
+	Summary     []reportBar
+	StatusCodes []reportBar
+	Errors      []reportBar
+	TableTitle  string
+	Header      []string
+	Rows        [][]string
+}
+
+// reportTitles names the results table for each crawl mode
+var reportTitles = map[SearchMode]string{
+	ModeSearchLink:      "Pages Containing the Link",
+	ModeSearchWord:      "Pages Containing the Word",
+	ModeBrokenLinks:     "Broken Links",
+	ModeOversizedImages: "Oversized Images",
+	ModeRedirectChains:  "Redirect Chains",
+	ModePageWeight:      "Page Weight",
+	ModeExtract:         "Extracted Fields",
+	ModeContentDiff:     "Changes Since the Baseline",
+	ModeDiscover:        "Discovered URLs",
+	ModeOrphans:         "Orphan and Unlisted Pages",
+}
+
+// writeHTMLReport renders a self-contained HTML report next to a mode's
+// results CSV, with summary stats, status-code charts and the results table,
+// so the findings can be shared with people who don't work in spreadsheets.
+// It returns the report's path, or "" if it couldn't be written.
+func (c *Crawler) writeHTMLReport(mode SearchMode) string {
+	resultFile := c.resultFileFor(mode)
+	header, rows := readResultsCSV(resultFile)
+
+	data := reportData{
+		Title:      mode.String() + " Report",
+		Target:     c.config.StartURL,
+		Generated:  time.Now().Format("January 2, 2006 3:04 PM"),
+		Duration:   formatDuration(time.Since(c.startTime)),
+		TableTitle: reportTitles[mode],
+		Header:     header,
+		Rows:       rows,
+	}
+
+	data.Summary = []reportBar{
+		{Label: "Pages Checked", Count: c.stats.PagesChecked},
+		{Label: "Findings", Count: c.stats.MatchesFound},
+		{Label: "Links Checked", Count: c.stats.LinksChecked},
+		{Label: "Images Checked", Count: c.stats.ImagesChecked},
+		{Label: "Errors", Count: c.stats.ErrorCount},
+		{Label: "Blocked", Count: c.stats.BlockedCount},
+	}
+	if c.config.MaxDuration > 0 {
+		data.Summary = append(data.Summary, reportBar{Label: "Skipped (Time Limit)", Count: c.stats.SkippedTimeLimit})
+	}
+	data.StatusCodes = reportBars([]reportBar{
+		{Label: "2xx Success", Count: c.stats.Status2xx, Class: "ok"},
+		{Label: "3xx Redirect", Count: c.stats.Status3xx, Class: "info"},
+		{Label: "4xx Client Error", Count: c.stats.Status4xx, Class: "warn"},
+		{Label: "5xx Server Error", Count: c.stats.Status5xx, Class: "bad"},
+	})
+	data.Errors = reportBars([]reportBar{
+		{Label: "Timeouts", Count: c.stats.Timeouts, Class: "warn"},
+		{Label: "DNS Errors", Count: c.stats.DNSErrors, Class: "bad"},
+		{Label: "SSL/TLS Errors", Count: c.stats.SSLErrors, Class: "bad"},
+		{Label: "Connection Refused", Count: c.stats.ConnectionRefused, Class: "bad"},
+	})
+
+	path := strings.TrimSuffix(resultFile, ".csv") + ".html"
+	f, err := os.Create(path)
+	if err != nil {
+		c.log.Error("❌ Could not write HTML report: "+err.Error(), "file", path, "error", err)
+		return ""
+	}
+	defer f.Close()
+
+	if err := reportTemplate.Execute(f, data); err != nil {
+		c.log.Error("❌ Could not write HTML report: "+err.Error(), "file", path, "error", err)
+		return ""
+	}
+	return path
+}
+
+// reportBars fills in each bar's width relative to the largest count
+func reportBars(bars []reportBar) []reportBar {
+	var max int64
+	for _, b := range bars {
+		if b.Count > max {
+			max = b.Count
+		}
+	}
+	if max == 0 {
+		return nil
+	}
+	for i := range bars {
+		bars[i].Percent = float64(bars[i].Count) / float64(max) * 100
+	}
+	return bars
+}
+
+// readResultsCSV loads the results CSV written during the crawl
+func readResultsCSV(path string) (header []string, rows [][]string) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil || len(records) == 0 {
+		return nil, nil
+	}
+	return records[0], records[1:]
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"isURL": func(s string) bool { return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://") },
+	"split": func(s string) []string { return strings.Split(s, " | ") },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}} - {{.Target}}</title>
+<style>
+  body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif; margin: 0; background: #f5f6f8; color: #1f2933; }
+  header { background: #1f2933; color: #fff; padding: 24px 32px; }
+  header h1 { margin: 0 0 4px; font-size: 24px; }
+  header p { margin: 0; color: #cbd2d9; }
+  main { padding: 24px 32px; max-width: 1200px; }
+  section { background: #fff; border-radius: 8px; padding: 20px 24px; margin-bottom: 24px; box-shadow: 0 1px 3px rgba(0,0,0,.08); }
+  h2 { margin-top: 0; font-size: 18px; }
+  .cards { display: grid; grid-template-columns: repeat(auto-fit, minmax(150px, 1fr)); gap: 12px; }
+  .card { background: #f5f6f8; border-radius: 6px; padding: 12px 16px; }
+  .card .n { font-size: 26px; font-weight: 600; }
+  .card .l { color: #616e7c; font-size: 13px; }
+  .bar { display: flex; align-items: center; margin: 6px 0; }
+  .bar .l { width: 170px; font-size: 14px; }
+  .bar .track { flex: 1; background: #eef0f3; border-radius: 4px; height: 18px; }
+  .bar .fill { height: 18px; border-radius: 4px; background: #3e7bfa; }
+  .bar .fill.ok { background: #2f9e44; } .bar .fill.info { background: #3e7bfa; }
+  .bar .fill.warn { background: #f08c00; } .bar .fill.bad { background: #e03131; }
+  .bar .n { width: 70px; text-align: right; font-variant-numeric: tabular-nums; }
+  table { border-collapse: collapse; width: 100%; font-size: 13px; }
+  th, td { text-align: left; padding: 8px; border-bottom: 1px solid #e4e7eb; vertical-align: top; word-break: break-all; }
+  th { background: #f5f6f8; position: sticky; top: 0; }
+  td ul { margin: 0; padding-left: 16px; }
+  .empty { color: #616e7c; }
+</style>
+</head>
+<body>
+<header>
+  <h1>{{.Title}}</h1>
+  <p>{{.Target}} &middot; {{.Generated}} &middot; took {{.Duration}}</p>
+</header>
+<main>
+<section>
+  <h2>Summary</h2>
+  <div class="cards">
+  {{range .Summary}}<div class="card"><div class="n">{{.Count}}</div><div class="l">{{.Label}}</div></div>
+  {{end}}</div>
+</section>
+{{if .StatusCodes}}<section>
+  <h2>HTTP Status Codes</h2>
+  {{range .StatusCodes}}<div class="bar"><span class="l">{{.Label}}</span><span class="track"><div class="fill {{.Class}}" style="width: {{printf "%.1f" .Percent}}%"></div></span><span class="n">{{.Count}}</span></div>
+  {{end}}
+</section>{{end}}
+{{if .Errors}}<section>
+  <h2>Network Errors</h2>
+  {{range .Errors}}<div class="bar"><span class="l">{{.Label}}</span><span class="track"><div class="fill {{.Class}}" style="width: {{printf "%.1f" .Percent}}%"></div></span><span class="n">{{.Count}}</span></div>
+  {{end}}
+</section>{{end}}
+<section>
+  <h2>{{.TableTitle}} ({{len .Rows}})</h2>
+  {{if .Rows}}<table>
+    <thead><tr>{{range .Header}}<th>{{.}}</th>{{end}}</tr></thead>
+    <tbody>
+    {{range .Rows}}<tr>{{range .}}<td>{{if isURL .}}{{$parts := split .}}{{if gt (len $parts) 1}}<ul>{{range $parts}}<li><a href="{{.}}">{{.}}</a></li>{{end}}</ul>{{else}}<a href="{{.}}">{{.}}</a>{{end}}{{else}}{{.}}{{end}}</td>{{end}}</tr>
+    {{end}}</tbody>
+  </table>{{else}}<p class="empty">Nothing found.</p>{{end}}
+</section>
+</main>
+</body>
+</html>
+`))
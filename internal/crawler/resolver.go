@@ -0,0 +1,285 @@
+package crawler
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dnsCacheTTL is how long a cached lookup is used before the host name is
+// looked up again
+const dnsCacheTTL = 5 * time.Minute
+
+// hostResolver dials the crawler's connections with Config.HostOverrides and
+// Config.DNSServer applied, so a site can be crawled on a server its public
+// DNS doesn't point at (e.g. production host names on a staging IP). With
+// Config.WarmUp it also caches lookups for every client that dials through
+// it, and hands out the connections the warm-up opened. A nil resolver
+// leaves dialing to the transport's defaults.
+type hostResolver struct {
+	overrides map[string]string // lower-case host name → IP
+	dialer    *net.Dialer
+
+	cache bool // look each host name up once per dnsCacheTTL
+	mu    sync.Mutex
+	hosts map[string]*dnsEntry  // lower-case host name → cached lookup
+	warm  map[string][]warmConn // host:port → connections opened by the warm-up
+}
+
+// dnsEntry is a cached lookup. ready is closed once addrs and err are set,
+// so concurrent dials of a host wait for one lookup instead of each making
+// their own.
+type dnsEntry struct {
+	ready   chan struct{}
+	addrs   []string
+	err     error
+	expires time.Time
+}
+
+// newHostResolver validates Config.DNSServer and Config.HostOverrides. It
+// returns nil when neither is set and cache is false.
+func newHostResolver(dnsServer string, overrides map[string]string, cache bool) (*hostResolver, error) {
+	dnsServer = strings.TrimSpace(dnsServer)
+	if dnsServer == "" && len(overrides) == 0 && !cache {
+		return nil, nil
+	}
+
+	r := &hostResolver{
+		overrides: make(map[string]string, len(overrides)),
+		// The same timeouts as http.DefaultTransport
+		dialer: &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second},
+		cache:  cache,
+		hosts:  make(map[string]*dnsEntry),
+		warm:   make(map[string][]warmConn),
+	}
+	for host, ip := range overrides {
+		host = strings.ToLower(strings.TrimSpace(host))
+		ip = strings.TrimSpace(ip)
+		if host == "" || net.ParseIP(ip) == nil {
+			return nil, fmt.Errorf("invalid host override %q → %q (expected a host name and an IP address)", host, ip)
+		}
+		r.overrides[host] = ip
+	}
+
+	if dnsServer != "" {
+		if _, _, err := net.SplitHostPort(dnsServer); err != nil {
+			dnsServer = net.JoinHostPort(strings.Trim(dnsServer, "[]"), "53")
+		}
+		if host, _, _ := net.SplitHostPort(dnsServer); net.ParseIP(host) == nil {
+			return nil, fmt.Errorf("invalid DNS server %q (expected an IP address, optionally with a port)", dnsServer)
+		}
+		r.dialer.Resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, dnsServer)
+			},
+		}
+	}
+	return r, nil
+}
+
+// dialContext implements http.Transport.DialContext
+func (r *hostResolver) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if conn := r.takeWarmConn(addr); conn != nil {
+		return conn, nil
+	}
+	return r.dial(ctx, network, addr)
+}
+
+// dial opens a new connection to addr
+func (r *hostResolver) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return r.dialer.DialContext(ctx, network, addr)
+	}
+	if ip, ok := r.overrides[strings.ToLower(host)]; ok {
+		return r.dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+	}
+	if !r.cache || net.ParseIP(host) != nil {
+		return r.dialer.DialContext(ctx, network, addr)
+	}
+
+	addrs, err := r.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	// Each address in turn, as net.Dialer does with a host name
+	for _, ip := range addrs {
+		var conn net.Conn
+		if conn, err = r.dialer.DialContext(ctx, network, net.JoinHostPort(ip, port)); err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
+
+// lookup returns host's addresses, from the cache when it was looked up in
+// the last dnsCacheTTL. Failed lookups aren't cached, so a host that was
+// briefly unreachable is tried again on the next dial.
+func (r *hostResolver) lookup(ctx context.Context, host string) ([]string, error) {
+	if ip, ok := r.overrides[strings.ToLower(host)]; ok {
+		return []string{ip}, nil
+	}
+	key := strings.ToLower(host)
+
+	r.mu.Lock()
+	entry, ok := r.hosts[key]
+	if ok {
+		select {
+		case <-entry.ready:
+			if entry.err != nil || time.Now().After(entry.expires) {
+				ok = false
+			}
+		default:
+		}
+	}
+	if !ok {
+		entry = &dnsEntry{ready: make(chan struct{})}
+		r.hosts[key] = entry
+		r.mu.Unlock()
+
+		resolver := r.dialer.Resolver
+		if resolver == nil {
+			resolver = net.DefaultResolver
+		}
+		entry.addrs, entry.err = resolver.LookupHost(ctx, host)
+		entry.expires = time.Now().Add(dnsCacheTTL)
+		close(entry.ready)
+		return entry.addrs, entry.err
+	}
+	r.mu.Unlock()
+
+	select {
+	case <-entry.ready:
+		return entry.addrs, entry.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// apply makes t dial through the resolver
+func (r *hostResolver) apply(t *http.Transport) {
+	if r != nil {
+		t.DialContext = r.dialContext
+	}
+}
+
+// chromeRules returns a --host-resolver-rules value for headless Chrome,
+// which can't be given a DNS server: the overrides, plus hosts looked up on
+// Config.DNSServer ahead of time
+func (r *hostResolver) chromeRules(ctx context.Context, hosts []string) string {
+	if r == nil {
+		return ""
+	}
+	mapped := make(map[string]string, len(r.overrides)+len(hosts))
+	for host, ip := range r.overrides {
+		mapped[host] = ip
+	}
+	if r.dialer.Resolver != nil {
+		for _, host := range hosts {
+			host = strings.ToLower(host)
+			if _, ok := mapped[host]; ok || net.ParseIP(host) != nil {
+				continue
+			}
+			if addrs, err := r.dialer.Resolver.LookupHost(ctx, host); err == nil && len(addrs) > 0 {
+				mapped[host] = addrs[0]
+			}
+		}
+	}
+
+	rules := make([]string, 0, len(mapped))
+	for host, ip := range mapped {
+		if strings.Contains(ip, ":") {
+			ip = "[" + ip + "]"
+		}
+		rules = append(rules, fmt.Sprintf("MAP %s %s", host, ip))
+	}
+	sort.Strings(rules)
+	return strings.Join(rules, ", ")
+}
+
+// browserHosts returns the hosts a capture run starts on, for chromeRules
+func (c *Crawler) browserHosts() []string {
+	links := append([]string{c.config.StartURL, c.config.VisualDiffOpts.CompareHost, c.config.JSONFeedOpts.FeedURL, c.config.ListingOpts.URLTemplate}, c.config.StartURLs...)
+	var hosts []string
+	for _, link := range links {
+		if u, err := url.Parse(link); err == nil && u.Hostname() != "" {
+			hosts = append(hosts, u.Hostname())
+		}
+	}
+	return hosts
+}
+
+// HostDialer returns a DialContext func that applies a DNS server and host
+// overrides, for connections made outside a crawl (such as the wizard's
+// connection test). It returns nil when neither is set.
+func HostDialer(dnsServer string, overrides map[string]string) (func(ctx context.Context, network, addr string) (net.Conn, error), error) {
+	r, err := newHostResolver(dnsServer, overrides, false)
+	if err != nil || r == nil {
+		return nil, err
+	}
+	return r.dialContext, nil
+}
+
+// ParseHostOverrides parses host overrides given as "host=IP", or as
+// /etc/hosts lines ("IP host [host...]"), into a Config.HostOverrides map
+func ParseHostOverrides(raw []string) (map[string]string, error) {
+	overrides := make(map[string]string)
+	for _, entry := range raw {
+		if i := strings.Index(entry, "#"); i >= 0 {
+			entry = entry[:i]
+		}
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if host, ip, ok := strings.Cut(entry, "="); ok {
+			host, ip = strings.TrimSpace(host), strings.TrimSpace(ip)
+			if host == "" || net.ParseIP(ip) == nil {
+				return nil, fmt.Errorf("invalid host override %q (expected host=IP)", entry)
+			}
+			overrides[strings.ToLower(host)] = ip
+			continue
+		}
+		fields := strings.Fields(entry)
+		if len(fields) < 2 || net.ParseIP(fields[0]) == nil {
+			return nil, fmt.Errorf("invalid host override %q (expected host=IP or \"IP host\")", entry)
+		}
+		for _, host := range fields[1:] {
+			overrides[strings.ToLower(host)] = fields[0]
+		}
+	}
+	return overrides, nil
+}
+
+// LoadHostsFile reads host overrides from a file in /etc/hosts format
+func LoadHostsFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading hosts file: %v", err)
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading hosts file: %v", err)
+	}
+	overrides, err := ParseHostOverrides(lines)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return overrides, nil
+}
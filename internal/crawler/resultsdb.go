@@ -0,0 +1,273 @@
+package crawler
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// resultsDBBatch is how many inserts go into each transaction
+const resultsDBBatch = 500
+
+// resultsDB writes a crawl's results, a record of every page fetch, and the
+// final stats into a SQLite database, one file per crawl. Statements are
+// piped to the sqlite3 shell as the crawl goes, so there's no cgo driver to
+// build and the rows are in the file even if the crawl is stopped early.
+//
+// The database has four tables:
+//
+//	crawl    one row: mode, start URL, start and finish times, results file
+//	fetches  url, attempt, depth, status, content_type, bytes, duration_ms, error, fetched_at,
+//	         redirected_to, dns_ms, connect_ms, tls_ms, ttfb_ms, download_ms, found_on
+//	results  the results CSV's columns, one row per CSV row
+//	stats    name, value - the final statistics
+//
+// Each of Config.AlsoModes adds a table of its own results, named after the
+// mode, e.g. results_broken_links.
+type resultsDB struct {
+	mu      sync.Mutex
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	stderr  bytes.Buffer
+	columns map[string]int // columns of each results table, once created
+	pending int            // statements since the last COMMIT
+}
+
+// checkSQLite reports whether the sqlite3 shell is available
+func checkSQLite() error {
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		return fmt.Errorf("sqlite3 not found in PATH - the results database needs it; install with: sudo apt install sqlite3")
+	}
+	return nil
+}
+
+// openResultsDB creates the database at path, replacing any file there, and
+// creates the crawl and fetches tables
+func openResultsDB(path string) (*resultsDB, error) {
+	if err := checkSQLite(); err != nil {
+		return nil, err
+	}
+	os.Remove(path)
+
+	db := &resultsDB{}
+	db.cmd = exec.Command("sqlite3", "-bail", path)
+	db.cmd.Stderr = &db.stderr
+	stdin, err := db.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	db.stdin = stdin
+	if err := db.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("could not start sqlite3: %v", err)
+	}
+
+	db.exec(`CREATE TABLE crawl (mode TEXT, start_url TEXT, started TEXT, finished TEXT, duration_seconds REAL, timed_out INTEGER, results_file TEXT);
+CREATE TABLE fetches (url TEXT, attempt INTEGER, depth INTEGER, status INTEGER, content_type TEXT, bytes INTEGER, duration_ms INTEGER, error TEXT, fetched_at TEXT, redirected_to TEXT, dns_ms INTEGER, connect_ms INTEGER, tls_ms INTEGER, ttfb_ms INTEGER, download_ms INTEGER, found_on TEXT);
+CREATE TABLE stats (name TEXT PRIMARY KEY, value INTEGER);
+BEGIN;`)
+	return db, nil
+}
+
+// exec sends SQL to the shell. Write errors are picked up by close, which
+// reports what sqlite3 printed.
+func (db *resultsDB) exec(sql string) {
+	io.WriteString(db.stdin, sql+"\n")
+}
+
+// insert adds a row to table, batching inserts into transactions. The
+// caller holds db.mu.
+func (db *resultsDB) insert(table string, values []any) {
+	literals := make([]string, len(values))
+	for i, v := range values {
+		literals[i] = sqlLiteral(v)
+	}
+	db.exec(fmt.Sprintf("INSERT INTO %s VALUES (%s);", table, strings.Join(literals, ", ")))
+	db.pending++
+	if db.pending >= resultsDBBatch {
+		db.exec("COMMIT; BEGIN;")
+		db.pending = 0
+	}
+}
+
+// createResults creates a results table with the results CSV's header as its
+// columns
+func (db *resultsDB) createResults(table string, header []string) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	seen := make(map[string]int)
+	cols := make([]string, len(header))
+	for i, name := range header {
+		key := strings.ToLower(name)
+		seen[key]++
+		if seen[key] > 1 {
+			name = fmt.Sprintf("%s_%d", name, seen[key])
+		}
+		cols[i] = sqlIdent(name)
+	}
+	db.exec(fmt.Sprintf("CREATE TABLE %s (%s);", table, strings.Join(cols, ", ")))
+	if db.columns == nil {
+		db.columns = make(map[string]int)
+	}
+	db.columns[table] = len(cols)
+}
+
+// addResult adds a results CSV row to a results table
+func (db *resultsDB) addResult(table string, row []string) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	columns := db.columns[table]
+	if columns == 0 {
+		return
+	}
+	values := make([]any, columns)
+	for i := range values {
+		if i < len(row) {
+			values[i] = row[i]
+		}
+	}
+	db.insert(table, values)
+}
+
+// fetchRecord is one request for a page, successful or not
+type fetchRecord struct {
+	URL          string
+	FoundOn      string // page the URL was first found on, "" for a start URL
+	Attempt      int
+	Depth        int
+	Status       int    // 0 when no response was received
+	RedirectedTo string // where the page's redirects ended, if it had any
+	ContentType  string
+	Bytes        int
+	Duration     time.Duration
+	Err          error
+	Fetched      time.Time
+
+	// Phases of the request, from its trace (see fetchLogHeader)
+	DNS, Connect, TLS, TTFB, Download time.Duration
+}
+
+// addFetch adds a page request to the fetches table
+func (db *resultsDB) addFetch(f fetchRecord) {
+	var status, errMsg, redirectedTo any
+	if f.Status != 0 {
+		status = f.Status
+	}
+	if f.Err != nil {
+		errMsg = f.Err.Error()
+	}
+	if f.RedirectedTo != "" {
+		redirectedTo = f.RedirectedTo
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.insert("fetches", []any{f.URL, f.Attempt, f.Depth, status, f.ContentType, f.Bytes, f.Duration.Milliseconds(), errMsg, f.Fetched.Format(time.RFC3339),
+		redirectedTo, f.DNS.Milliseconds(), f.Connect.Milliseconds(), f.TLS.Milliseconds(), f.TTFB.Milliseconds(), f.Download.Milliseconds(), f.FoundOn})
+}
+
+// close writes the crawl row and stats, commits, and waits for sqlite3 to
+// finish writing the file
+func (db *resultsDB) close(c *Crawler) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	finished := time.Now()
+	db.insert("crawl", []any{
+		c.config.Mode.String(), c.config.StartURL,
+		c.startTime.Format(time.RFC3339), finished.Format(time.RFC3339),
+		finished.Sub(c.startTime).Seconds(), atomic.LoadInt32(&c.timedOut) == 1, c.resultFile,
+	})
+	stats := reflect.ValueOf(&c.stats).Elem()
+	for i := 0; i < stats.NumField(); i++ {
+		if stats.Field(i).Kind() != reflect.Int64 {
+			// The breakdowns can be had from the fetches table
+			continue
+		}
+		db.insert("stats", []any{stats.Type().Field(i).Name, stats.Field(i).Int()})
+	}
+	db.exec("COMMIT;\n.quit")
+	db.stdin.Close()
+
+	if err := db.cmd.Wait(); err != nil {
+		if msg := strings.TrimSpace(db.stderr.String()); msg != "" {
+			return fmt.Errorf("sqlite3: %s", msg)
+		}
+		return fmt.Errorf("sqlite3: %v", err)
+	}
+	return nil
+}
+
+// sqlLiteral formats a value for an INSERT. Strings that are plain integers
+// are stored as integers, so numeric CSV columns can be compared and summed.
+func sqlLiteral(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case string:
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && strconv.FormatInt(n, 10) == v {
+			return v
+		}
+		return "'" + strings.ReplaceAll(strings.ReplaceAll(v, "\x00", ""), "'", "''") + "'"
+	default:
+		return sqlLiteral(fmt.Sprint(v))
+	}
+}
+
+// sqlIdent quotes a column name
+func sqlIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// writeResult writes a row to a mode's results CSV, and to the results
+// database when Config.ResultsDB is set
+func (c *Crawler) writeResult(mode SearchMode, row []string) {
+	c.out.write(mode, encodeCSVRow(row))
+	if c.db != nil {
+		c.db.addResult(c.resultsTable(mode), row)
+	}
+}
+
+// recordFetch adds a page request to the results database and the fetch
+// log, when the crawl keeps them, and to discover mode's listing
+func (c *Crawler) recordFetch(f fetchRecord) {
+	c.graph.noteFetch(c.getVisitedKey(f.URL), f.Status)
+	if c.db != nil {
+		c.db.addFetch(f)
+	}
+	if c.fetchLog != nil {
+		c.fetchLog.add(f)
+	}
+	c.noteFetch(f)
+	c.noteTraffic(f)
+}
+
+// closeResultsDB finishes the results database once the crawl is over
+func (c *Crawler) closeResultsDB() {
+	if c.db == nil {
+		return
+	}
+	if err := c.db.close(c); err != nil {
+		c.log.Error(fmt.Sprintf("❌ Results database: %v", err), "path", c.dbFile, "error", err)
+		c.dbFile = ""
+	}
+	c.db = nil
+}
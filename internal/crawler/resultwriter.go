@@ -0,0 +1,133 @@
+package crawler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// resultFlushInterval is how often buffered rows are written to the results
+// files, so a file is never more than this far behind the crawl
+const resultFlushInterval = 2 * time.Second
+
+// resultRow is an encoded row (or JSON line) for a mode's results file
+type resultRow struct {
+	mode SearchMode
+	data []byte
+}
+
+// resultOutput is an open results file and its buffer
+type resultOutput struct {
+	file *os.File
+	buf  *bufio.Writer
+}
+
+// resultWriter appends rows to the results files from a single goroutine,
+// so workers never open a file or wait on each other's writes. Each file is
+// opened once for the whole crawl and written through a buffer that's
+// flushed every resultFlushInterval and when the writer is closed.
+type resultWriter struct {
+	mu      sync.RWMutex // held for reading to send rows, for writing to close
+	closed  bool
+	rows    chan resultRow
+	done    chan struct{}
+	outputs map[SearchMode]*resultOutput
+	err     error // first write error, reported when the writer is closed
+}
+
+// newResultWriter starts writing to the results files in outputs, which
+// already hold their headers
+func newResultWriter(outputs map[SearchMode]*resultOutput) *resultWriter {
+	w := &resultWriter{
+		rows:    make(chan resultRow, 256),
+		done:    make(chan struct{}),
+		outputs: outputs,
+	}
+	go w.run()
+	return w
+}
+
+func (w *resultWriter) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(resultFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case row, ok := <-w.rows:
+			if !ok {
+				w.flush()
+				return
+			}
+			if out := w.outputs[row.mode]; out != nil {
+				if _, err := out.buf.Write(row.data); err != nil && w.err == nil {
+					w.err = err
+				}
+			}
+		case <-ticker.C:
+			w.flush()
+		}
+	}
+}
+
+func (w *resultWriter) flush() {
+	for _, out := range w.outputs {
+		if err := out.buf.Flush(); err != nil && w.err == nil {
+			w.err = err
+		}
+	}
+}
+
+// write queues a row for mode's results file. Rows written after the writer
+// is closed are dropped.
+func (w *resultWriter) write(mode SearchMode, data []byte) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return
+	}
+	w.rows <- resultRow{mode: mode, data: data}
+}
+
+// close writes out the queued rows and closes the files
+func (w *resultWriter) close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.rows)
+	w.mu.Unlock()
+
+	<-w.done
+	for _, out := range w.outputs {
+		if err := out.file.Close(); err != nil && w.err == nil {
+			w.err = err
+		}
+	}
+	return w.err
+}
+
+// encodeCSVRow returns row as a line of CSV
+func encodeCSVRow(row []string) []byte {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(row)
+	w.Flush()
+	return buf.Bytes()
+}
+
+// closeResults writes out the rows still buffered and closes the results
+// files once every row is written, before the HTML reports read them
+func (c *Crawler) closeResults() {
+	if c.out == nil {
+		return
+	}
+	if err := c.out.close(); err != nil {
+		c.log.Error(fmt.Sprintf("❌ Results file: %v", err), "path", c.resultFile, "error", err)
+	}
+}
@@ -0,0 +1,264 @@
+package crawler
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// robotsAgent is the product token matched against User-agent lines in robots.txt
+const robotsAgent = "webcrawler"
+
+// robotsRule is a single Allow/Disallow line from robots.txt
+type robotsRule struct {
+	pattern string
+	allow   bool
+}
+
+// robotsRules holds the rules that apply to this crawler for one host
+type robotsRules struct {
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+// robotsEntry caches the robots.txt for a single scheme+host
+type robotsEntry struct {
+	once  sync.Once
+	rules *robotsRules
+}
+
+// robotsChecker enforces robots.txt for one crawl, caching rules per host
+type robotsChecker struct {
+	c       *Crawler
+	cache   sync.Map // scheme://host -> *robotsEntry
+	delayMu sync.Mutex
+	nextHit map[string]time.Time // host -> earliest time of next request
+}
+
+func newRobotsChecker(c *Crawler) *robotsChecker {
+	return &robotsChecker{c: c, nextHit: map[string]time.Time{}}
+}
+
+// allowed reports whether robots.txt permits crawling the given URL.
+// It always returns true when robots checks are disabled.
+func (r *robotsChecker) allowed(ctx context.Context, link string) bool {
+	if r.c.config.IgnoreRobots {
+		return true
+	}
+
+	u, err := url.Parse(link)
+	if err != nil || u.Host == "" {
+		return true
+	}
+
+	rules := r.rules(ctx, u)
+	if rules == nil {
+		return true
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+
+	return rules.allowed(path)
+}
+
+// wait blocks until the host's politeness delay - the longer of its
+// robots.txt Crawl-delay and Config.HostDelay - has elapsed since the
+// previous request to that host. It returns false if ctx was cancelled first.
+func (r *robotsChecker) wait(ctx context.Context, link string) bool {
+	u, err := url.Parse(link)
+	if err != nil || u.Host == "" {
+		return true
+	}
+
+	delay := r.c.config.HostDelay
+	if !r.c.config.IgnoreRobots {
+		if rules := r.rules(ctx, u); rules != nil && rules.crawlDelay > delay {
+			delay = rules.crawlDelay
+		}
+	}
+	if delay <= 0 {
+		return true
+	}
+
+	r.delayMu.Lock()
+	now := time.Now()
+	next := r.nextHit[u.Host]
+	if next.Before(now) {
+		next = now
+	}
+	r.nextHit[u.Host] = next.Add(delay)
+	r.delayMu.Unlock()
+
+	return sleepCtx(ctx, time.Until(next))
+}
+
+// rules returns the cached rules for a host, fetching robots.txt on first use
+func (r *robotsChecker) rules(ctx context.Context, u *url.URL) *robotsRules {
+	key := u.Scheme + "://" + u.Host
+	value, _ := r.cache.LoadOrStore(key, &robotsEntry{})
+	entry := value.(*robotsEntry)
+
+	entry.once.Do(func() {
+		entry.rules = r.fetch(ctx, key+"/robots.txt")
+	})
+
+	return entry.rules
+}
+
+func (r *robotsChecker) fetch(ctx context.Context, robotsURL string) *robotsRules {
+	req, err := http.NewRequestWithContext(ctx, "GET", robotsURL, nil)
+	if err != nil {
+		return nil
+	}
+	req.Header.Set("User-Agent", r.c.fingerprint.userAgent)
+	req.Header.Set("Accept", "text/plain,*/*;q=0.8")
+
+	resp, err := r.c.httpClient.Do(req)
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+
+	// A missing robots.txt (or any other failure) means everything is allowed
+	if resp.StatusCode != 200 {
+		return nil
+	}
+
+	return parseRobots(io.LimitReader(resp.Body, 512*1024), robotsAgent)
+}
+
+// parseRobots parses robots.txt and returns the group that best matches agent,
+// falling back to the "*" group
+func parseRobots(r io.Reader, agent string) *robotsRules {
+	agent = strings.ToLower(agent)
+
+	type group struct {
+		agents []string
+		rules  robotsRules
+	}
+
+	var groups []*group
+	var current *group
+	lastWasAgent := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// Consecutive User-agent lines share a single group
+			if current == nil || !lastWasAgent {
+				current = &group{}
+				groups = append(groups, current)
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+			lastWasAgent = true
+			continue
+		case "allow", "disallow":
+			if current != nil && value != "" {
+				current.rules.rules = append(current.rules.rules, robotsRule{pattern: value, allow: key == "allow"})
+			}
+		case "crawl-delay":
+			if current != nil {
+				if secs, err := strconv.ParseFloat(value, 64); err == nil && secs > 0 {
+					current.rules.crawlDelay = time.Duration(secs * float64(time.Second))
+				}
+			}
+		}
+		lastWasAgent = false
+	}
+
+	var wildcard *robotsRules
+	for _, g := range groups {
+		for _, a := range g.agents {
+			if a == "*" {
+				if wildcard == nil {
+					wildcard = &g.rules
+				}
+				continue
+			}
+			if strings.Contains(agent, a) {
+				return &g.rules
+			}
+		}
+	}
+
+	return wildcard
+}
+
+// allowed applies the longest-match rule; Allow wins ties
+func (r *robotsRules) allowed(path string) bool {
+	bestLen := -1
+	allow := true
+
+	for _, rule := range r.rules {
+		if !robotsMatch(rule.pattern, path) {
+			continue
+		}
+		n := len(rule.pattern)
+		if n > bestLen || (n == bestLen && rule.allow) {
+			bestLen = n
+			allow = rule.allow
+		}
+	}
+
+	return allow
+}
+
+// robotsMatch matches a robots.txt path pattern supporting '*' and a trailing '$'
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = strings.TrimSuffix(pattern, "$")
+	}
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	pos := len(parts[0])
+
+	for i := 1; i < len(parts); i++ {
+		part := parts[i]
+		if i == len(parts)-1 && anchored {
+			return strings.HasSuffix(path[pos:], part)
+		}
+		idx := strings.Index(path[pos:], part)
+		if idx < 0 {
+			return false
+		}
+		pos += idx + len(part)
+	}
+
+	if anchored {
+		return pos == len(path)
+	}
+	return true
+}
@@ -0,0 +1,500 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// unscheduledModes are the modes of modeNames a scheduled job can't run:
+// the feed, listing, extraction and visual diff modes need options only the
+// wizard asks for
+var unscheduledModes = map[SearchMode]bool{
+	ModeJSONFeed:   true,
+	ModeListing:    true,
+	ModeExtract:    true,
+	ModeVisualDiff: true,
+}
+
+// scheduleMode returns the mode a job's mode name stands for, and whether a
+// job can run it
+func scheduleMode(name string) (SearchMode, bool) {
+	mode, ok := modeNames[name]
+	return mode, ok && !unscheduledModes[mode]
+}
+
+// ScheduleFile is a JSON file of recurring crawls, e.g.
+//
+//	{
+//	  "output_dir": "crawl-history",
+//	  "jobs": [
+//	    {"name": "weekly-links", "schedule": "0 6 * * mon", "mode": "broken-links", "start_url": "https://example.com"}
+//	  ]
+//	}
+type ScheduleFile struct {
+	OutputDir string         `json:"output_dir"` // Where each run's results and history.json go (default: "crawl-history" next to the file)
+	Timezone  string         `json:"timezone"`   // IANA zone the schedules are in, e.g. "Europe/London" (default: local time)
+	Jobs      []ScheduledJob `json:"jobs"`
+}
+
+// ScheduledJob is one recurring crawl in a ScheduleFile
+type ScheduledJob struct {
+	Name         string   `json:"name"`                    // Names the job's results folder; letters, digits, "-" and "_"
+	Schedule     string   `json:"schedule"`                // Cron expression, e.g. "0 6 * * mon" or "@daily"
+	Mode         string   `json:"mode"`                    // A mode name from modeNames, e.g. "broken-links"
+	StartURL     string   `json:"start_url"`               // Page to start crawling from
+	SearchTarget string   `json:"search_target,omitempty"` // Link or text to find (link-search and word-search)
+	MaxPages     int      `json:"max_pages,omitempty"`     // 0 = unlimited
+	MaxDepth     int      `json:"max_depth,omitempty"`     // 0 = unlimited
+	Concurrency  int      `json:"concurrency,omitempty"`   // Pages fetched at once (default 5)
+	MaxDuration  string   `json:"max_duration,omitempty"`  // Time limit, e.g. "2h" (default: none)
+	HostDelay    string   `json:"host_delay,omitempty"`    // Minimum time between requests to a host, e.g. "2s"
+	CrawlWindow  string   `json:"crawl_window,omitempty"`  // Only start pages between these local times, e.g. "18:00-08:00"
+	PathFilter   string   `json:"path_filter,omitempty"`   // Only crawl URLs under this path, e.g. "/blog/"
+	Include      []string `json:"include,omitempty"`       // Only crawl URLs matching one of these patterns
+	Exclude      []string `json:"exclude,omitempty"`       // Skip URLs matching any of these patterns
+	Priority     []string `json:"priority,omitempty"`      // Crawl URLs under these paths first, e.g. "/products/"
+	URLList      string   `json:"url_list,omitempty"`      // Fetch only the URLs in this file, relative to the schedule file
+	IgnoreFile   string   `json:"ignore_file,omitempty"`   // Findings not to report, relative to the schedule file (default: --ignore-file)
+	IgnoreRobots bool     `json:"ignore_robots,omitempty"`
+	Fragments    bool     `json:"check_fragments,omitempty"`       // Broken-links: also check #anchor links
+	RenderJS     bool     `json:"render_js,omitempty"`             // Load pages in Chrome so links added by JavaScript are followed
+	Soft404      bool     `json:"detect_soft404,omitempty"`        // Broken-links: also flag pages that say "not found" with a 200
+	BrokenBase   string   `json:"broken_links_baseline,omitempty"` // Broken-links: earlier results CSV; only report what's changed since, relative to the schedule file
+	ImageSize    string   `json:"image_size_threshold,omitempty"`  // Oversized-images: flag images larger than this, e.g. "300KB" (default 500KB)
+	HTMLReport   bool     `json:"html_report,omitempty"`
+	ExcelReport  bool     `json:"excel_report,omitempty"`
+	ResultsDB    bool     `json:"results_db,omitempty"`
+	FetchLog     bool     `json:"fetch_log,omitempty"`
+	LinkGraph    string   `json:"link_graph,omitempty"` // "dot", "gexf" or "json"
+	CertAudit    bool     `json:"cert_audit,omitempty"`
+	SkipVerify   bool     `json:"skip_tls_verify,omitempty"` // Don't check the site's TLS certificate
+	Cache        bool     `json:"cache,omitempty"`           // Reuse pages unchanged since the last run (see Config.HTTPCache)
+	WarmUp       bool     `json:"warm_up,omitempty"`         // Look up and connect to the site's hosts before crawling
+
+	cron *cronSchedule
+}
+
+var jobNameRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// LoadScheduleFile reads and checks a schedule file
+func LoadScheduleFile(path string) (*ScheduleFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file ScheduleFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s is not a valid schedule file: %v", path, err)
+	}
+	if len(file.Jobs) == 0 {
+		return nil, fmt.Errorf("%s has no jobs", path)
+	}
+
+	loc := time.Local
+	if file.Timezone != "" {
+		if loc, err = time.LoadLocation(file.Timezone); err != nil {
+			return nil, fmt.Errorf("unknown timezone %q", file.Timezone)
+		}
+	}
+
+	// Paths in the file are relative to it, not to wherever the crawler runs
+	dir := filepath.Dir(path)
+	if file.OutputDir == "" {
+		file.OutputDir = "crawl-history"
+	}
+	if !filepath.IsAbs(file.OutputDir) {
+		file.OutputDir = filepath.Join(dir, file.OutputDir)
+	}
+	if file.OutputDir, err = filepath.Abs(file.OutputDir); err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool)
+	for i := range file.Jobs {
+		job := &file.Jobs[i]
+		if !jobNameRe.MatchString(job.Name) {
+			return nil, fmt.Errorf("job %d: name %q must be letters, digits, - and _", i+1, job.Name)
+		}
+		if names[job.Name] {
+			return nil, fmt.Errorf("job %q is listed twice", job.Name)
+		}
+		names[job.Name] = true
+
+		if job.cron, err = parseCron(job.Schedule, loc); err != nil {
+			return nil, fmt.Errorf("job %q: %v", job.Name, err)
+		}
+		if _, ok := scheduleMode(job.Mode); !ok {
+			var modes []string
+			for name, mode := range modeNames {
+				if !unscheduledModes[mode] {
+					modes = append(modes, name)
+				}
+			}
+			sort.Strings(modes)
+			return nil, fmt.Errorf("job %q: mode %q can't be scheduled; use one of %s", job.Name, job.Mode, strings.Join(modes, ", "))
+		}
+		if !strings.HasPrefix(job.StartURL, "http://") && !strings.HasPrefix(job.StartURL, "https://") {
+			return nil, fmt.Errorf("job %q: start_url must be a full http(s) URL", job.Name)
+		}
+		if (job.Mode == "link-search" || job.Mode == "word-search") && job.SearchTarget == "" {
+			return nil, fmt.Errorf("job %q: %s needs a search_target", job.Name, job.Mode)
+		}
+		if job.MaxDuration != "" {
+			if _, err := time.ParseDuration(job.MaxDuration); err != nil {
+				return nil, fmt.Errorf("job %q: max_duration %q isn't a duration like 90m or 2h", job.Name, job.MaxDuration)
+			}
+		}
+		if job.PathFilter != "" && !strings.HasPrefix(job.PathFilter, "/") {
+			return nil, fmt.Errorf("job %q: path_filter %q must start with /", job.Name, job.PathFilter)
+		}
+		if job.HostDelay != "" {
+			if _, err := time.ParseDuration(job.HostDelay); err != nil {
+				return nil, fmt.Errorf("job %q: host_delay %q isn't a duration like 2s or 500ms", job.Name, job.HostDelay)
+			}
+		}
+		if job.ImageSize != "" {
+			if _, err := ParseByteSize(job.ImageSize); err != nil {
+				return nil, fmt.Errorf("job %q: image_size_threshold %q isn't a size like 500KB or 2MB", job.Name, job.ImageSize)
+			}
+		}
+		if job.CrawlWindow != "" {
+			if err := ValidateCrawlWindow(job.CrawlWindow); err != nil {
+				return nil, fmt.Errorf("job %q: %v", job.Name, err)
+			}
+		}
+		if job.URLList != "" && !filepath.IsAbs(job.URLList) {
+			if job.URLList, err = filepath.Abs(filepath.Join(dir, job.URLList)); err != nil {
+				return nil, err
+			}
+		}
+		if job.IgnoreFile != "" && !filepath.IsAbs(job.IgnoreFile) {
+			if job.IgnoreFile, err = filepath.Abs(filepath.Join(dir, job.IgnoreFile)); err != nil {
+				return nil, err
+			}
+		}
+		if job.BrokenBase != "" && !filepath.IsAbs(job.BrokenBase) {
+			if job.BrokenBase, err = filepath.Abs(filepath.Join(dir, job.BrokenBase)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &file, nil
+}
+
+// config builds the crawl Config for a run of the job on top of base, which
+// carries the settings every job shares (logger, headers, upload, ...)
+func (job *ScheduledJob) config(base Config, baseline string) Config {
+	cfg := base
+	cfg.Mode, _ = scheduleMode(job.Mode)
+	cfg.StartURL = job.StartURL
+	cfg.SearchTarget = job.SearchTarget
+	cfg.MaxPages = job.MaxPages
+	cfg.MaxDepth = job.MaxDepth
+	cfg.MaxConcurrency = job.Concurrency
+	if cfg.MaxConcurrency <= 0 {
+		cfg.MaxConcurrency = 5
+	}
+	cfg.MaxDuration, _ = time.ParseDuration(job.MaxDuration)
+	if job.HostDelay != "" {
+		cfg.HostDelay, _ = time.ParseDuration(job.HostDelay)
+	}
+	if job.CrawlWindow != "" {
+		cfg.CrawlWindow = job.CrawlWindow
+	}
+	cfg.PathFilter = job.PathFilter
+	cfg.IncludePatterns = job.Include
+	cfg.ExcludePatterns = job.Exclude
+	cfg.PriorityPaths = job.Priority
+	cfg.URLListFile = job.URLList
+	if job.IgnoreFile != "" {
+		cfg.IgnoreFile = job.IgnoreFile
+	}
+	cfg.IgnoreRobots = job.IgnoreRobots
+	cfg.CheckFragments = job.Fragments
+	cfg.RenderJS = job.RenderJS
+	cfg.DetectSoft404 = job.Soft404
+	cfg.BrokenLinksBaseline = job.BrokenBase
+	cfg.HTMLReport = job.HTMLReport
+	cfg.ExcelReport = base.ExcelReport || job.ExcelReport
+	cfg.ResultsDB = base.ResultsDB || job.ResultsDB
+	cfg.FetchLog = base.FetchLog || job.FetchLog
+	cfg.LinkGraph = job.LinkGraph
+	if cfg.LinkGraph == "" {
+		cfg.LinkGraph = base.LinkGraph
+	}
+	cfg.CertAudit = base.CertAudit || job.CertAudit
+	cfg.SkipTLSVerify = base.SkipTLSVerify || job.SkipVerify
+	cfg.WarmUp = base.WarmUp || job.WarmUp
+	if job.Cache && cfg.HTTPCache == "" {
+		cfg.HTTPCache, _ = DefaultCacheDir()
+	}
+	cfg.DiffBaseline = baseline
+	if cfg.Mode == ModeSitemap && cfg.SitemapOpts.Filename == "" {
+		cfg.SitemapOpts = SitemapOptions{Filename: "sitemap.xml", IncludeLastMod: true}
+	}
+
+	// The wizard's defaults
+	if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = 3
+	}
+	if cfg.RetryDelay == 0 {
+		cfg.RetryDelay = 2 * time.Second
+	}
+	cfg.RetryBlockedPages = true
+	cfg.BlockedRetryPasses = 3
+	cfg.AdaptiveThrottle = true
+	if cfg.MaxBodySize == 0 {
+		cfg.MaxBodySize = 50 * 1024 * 1024
+	}
+	cfg.ImageSizeThreshold, _ = ParseByteSize(job.ImageSize)
+	if cfg.ImageSizeThreshold == 0 {
+		cfg.ImageSizeThreshold = 500 * 1024
+	}
+	cfg.Quiet = true
+	return cfg
+}
+
+// HistoryEntry is one run in the schedule's history.json
+type HistoryEntry struct {
+	Job      string         `json:"job"`
+	Mode     string         `json:"mode"`
+	StartURL string         `json:"start_url"`
+	Started  string         `json:"started"`
+	Duration float64        `json:"duration_seconds"`
+	Status   string         `json:"status"` // "finished", "timed out", "over budget" or "cancelled"
+	Dir      string         `json:"dir"`    // the run's folder, relative to the output directory
+	Files    []string       `json:"files"`  // what the run wrote, relative to the output directory
+	Snapshot string         `json:"snapshot,omitempty"`
+	Summary  map[string]any `json:"summary"`
+}
+
+// scheduler runs a ScheduleFile's jobs as they come due. Due jobs go on a
+// queue and run one at a time, so two crawls never compete for bandwidth or
+// the working directory; a job that comes due again while it's still queued
+// or running skips that turn.
+type scheduler struct {
+	file    *ScheduleFile
+	base    Config
+	queue   chan *ScheduledJob
+	mu      sync.Mutex
+	waiting map[string]bool // jobs queued or running
+}
+
+// RunSchedule runs the jobs in the schedule file at path until ctx is
+// cancelled. base holds the settings shared by every job.
+func RunSchedule(ctx context.Context, path string, base Config) error {
+	file, err := LoadScheduleFile(path)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(file.OutputDir, 0755); err != nil {
+		return err
+	}
+	if base.Logger == nil {
+		base.Logger = NewLogger(slog.LevelInfo, nil)
+	}
+
+	s := &scheduler{
+		file:    file,
+		base:    base,
+		queue:   make(chan *ScheduledJob, len(file.Jobs)),
+		waiting: make(map[string]bool),
+	}
+	log := base.Logger
+
+	next := make([]time.Time, len(file.Jobs))
+	now := time.Now()
+	for i := range file.Jobs {
+		job := &file.Jobs[i]
+		next[i] = job.cron.next(now)
+		log.Info(fmt.Sprintf("🗓️  %s (%s): next run %s", job.Name, job.Mode, next[i].Format("Mon 2 Jan 15:04 MST")), "job", job.Name, "next_run", next[i])
+	}
+	log.Info(fmt.Sprintf("📂 Results and history go in %s", file.OutputDir), "path", file.OutputDir)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for job := range s.queue {
+			s.run(ctx, job)
+		}
+	}()
+
+	for {
+		soonest := next[0]
+		for _, t := range next[1:] {
+			if t.Before(soonest) {
+				soonest = t
+			}
+		}
+
+		timer := time.NewTimer(time.Until(soonest))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			close(s.queue)
+			wg.Wait()
+			return nil
+		case <-timer.C:
+		}
+
+		now := time.Now()
+		for i := range file.Jobs {
+			if next[i].After(now) {
+				continue
+			}
+			job := &file.Jobs[i]
+			s.enqueue(job)
+			next[i] = job.cron.next(now)
+		}
+	}
+}
+
+// enqueue adds a due job to the queue unless it's already waiting there
+func (s *scheduler) enqueue(job *ScheduledJob) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.waiting[job.Name] {
+		s.base.Logger.Warn(fmt.Sprintf("⏭️  %s is due but its last run hasn't finished - skipping this turn", job.Name), "job", job.Name)
+		return
+	}
+	s.waiting[job.Name] = true
+	s.queue <- job
+}
+
+// run crawls a job into a new timestamped folder under the job's folder and
+// adds the run to the history
+func (s *scheduler) run(ctx context.Context, job *ScheduledJob) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.waiting, job.Name)
+		s.mu.Unlock()
+	}()
+	if ctx.Err() != nil {
+		return
+	}
+	log := s.base.Logger
+
+	started := time.Now()
+	rel := filepath.Join(job.Name, started.Format("2006-01-02_15-04-05"))
+	dir := filepath.Join(s.file.OutputDir, rel)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Error(fmt.Sprintf("❌ %s: %v", job.Name, err), "job", job.Name, "error", err)
+		return
+	}
+
+	history, err := loadHistory(s.historyPath())
+	if err != nil {
+		log.Error(fmt.Sprintf("❌ %v", err), "path", s.historyPath(), "error", err)
+		return
+	}
+
+	// Content diff compares against the snapshot of the job's last run
+	baseline := ""
+	if job.Mode == "content-diff" {
+		for i := len(history) - 1; i >= 0; i-- {
+			if history[i].Job == job.Name && history[i].Snapshot != "" {
+				baseline = filepath.Join(s.file.OutputDir, history[i].Snapshot)
+				break
+			}
+		}
+	}
+
+	log.Info(fmt.Sprintf("▶️  %s: %s of %s", job.Name, modeNames[job.Mode], job.StartURL), "job", job.Name, "url", job.StartURL, "dir", dir)
+	cfg := job.config(s.base, baseline)
+	cfg.OutputDir = dir
+	c := New(cfg)
+	c.Run(ctx)
+
+	r := c.Results()
+	entry := HistoryEntry{
+		Job:      job.Name,
+		Mode:     job.Mode,
+		StartURL: job.StartURL,
+		Started:  started.Format(time.RFC3339),
+		Duration: r.Duration.Seconds(),
+		Status:   "finished",
+		Dir:      rel,
+		Summary:  make(map[string]any),
+	}
+	if r.Cancelled {
+		entry.Status = "cancelled"
+	} else if r.TimedOut {
+		entry.Status = "timed out"
+	} else if r.OverBudget {
+		entry.Status = "over budget"
+	}
+	// History paths are relative to the output directory, so it can be moved
+	for _, p := range r.outputPaths() {
+		if _, err := os.Stat(p); err == nil {
+			entry.Files = append(entry.Files, runPath(dir, rel, p))
+		}
+	}
+	if r.SnapshotPath != "" && entry.Status == "finished" {
+		entry.Snapshot = runPath(dir, rel, r.SnapshotPath)
+	}
+	for _, f := range runSummary(r) {
+		if f.Name != "Output" {
+			entry.Summary[f.key()] = f.Value
+		}
+	}
+
+	if err := saveHistory(s.historyPath(), append(history, entry)); err != nil {
+		log.Error(fmt.Sprintf("❌ Could not update the history: %v", err), "path", s.historyPath(), "error", err)
+	}
+	log.Info(fmt.Sprintf("✅ %s %s in %s - %s", job.Name, entry.Status, formatDuration(r.Duration), rel), "job", job.Name, "status", entry.Status, "dir", dir)
+	if next := job.cron.next(time.Now()); !next.IsZero() {
+		log.Info(fmt.Sprintf("🗓️  %s: next run %s", job.Name, next.Format("Mon 2 Jan 15:04 MST")), "job", job.Name, "next_run", next)
+	}
+}
+
+// runPath gives a file a run wrote in dir as a path relative to the output
+// directory, rel being dir relative to it. A file written elsewhere (an
+// absolute summary path, say) keeps its own path.
+func runPath(dir, rel, p string) string {
+	within, err := filepath.Rel(dir, p)
+	if err != nil || within == ".." || strings.HasPrefix(within, ".."+string(filepath.Separator)) {
+		return p
+	}
+	return filepath.Join(rel, within)
+}
+
+// historyPath is the history index in the output directory
+func (s *scheduler) historyPath() string {
+	return filepath.Join(s.file.OutputDir, "history.json")
+}
+
+// loadHistory reads the history index, or returns an empty one if it
+// doesn't exist yet
+func loadHistory(path string) ([]HistoryEntry, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't read the history: %w", err)
+	}
+	var history []HistoryEntry
+	if err := json.Unmarshal(data, &history); err != nil {
+		return nil, fmt.Errorf("%s is not a history file: %v", path, err)
+	}
+	return history, nil
+}
+
+// saveHistory writes the history index, oldest run first
+func saveHistory(path string, history []HistoryEntry) error {
+	data, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
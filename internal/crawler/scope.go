@@ -0,0 +1,117 @@
+package crawler
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// ScopePolicy controls which hosts count as part of the site being crawled
+type ScopePolicy int
+
+const (
+	// ScopeExactHost follows only the start URLs' hosts (www and the bare
+	// domain count as the same host) plus any AllowedDomains
+	ScopeExactHost ScopePolicy = iota
+	// ScopeSubdomains also follows every subdomain of the start URLs'
+	// registrable domains, e.g. blog.example.com and shop.example.com
+	ScopeSubdomains
+	// ScopeAllowlist follows the start URLs' hosts plus exactly the hosts in
+	// AllowedDomains; an entry like *.example.com matches its subdomains too
+	ScopeAllowlist
+)
+
+func (p ScopePolicy) String() string {
+	switch p {
+	case ScopeExactHost:
+		return "Exact host"
+	case ScopeSubdomains:
+		return "Include subdomains"
+	case ScopeAllowlist:
+		return "Custom allowlist"
+	default:
+		return "Unknown"
+	}
+}
+
+// crawlScope decides which hosts belong to a crawl
+type crawlScope struct {
+	hosts   map[string]bool // exact hostnames, without port
+	domains []string        // domains whose subdomains are also in scope
+}
+
+func newCrawlScope(cfg Config) *crawlScope {
+	s := &crawlScope{hosts: make(map[string]bool)}
+
+	// The host a visual diff compares against gets the same headers and
+	// cookies as the baseline
+	for _, start := range append([]string{cfg.StartURL, cfg.VisualDiffOpts.CompareHost}, cfg.StartURLs...) {
+		u, err := url.Parse(start)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+		host := strings.ToLower(u.Hostname())
+		s.hosts[host] = true
+		if apex, ok := strings.CutPrefix(host, "www."); ok {
+			s.hosts[apex] = true
+		} else {
+			s.hosts["www."+host] = true
+		}
+
+		if cfg.ScopePolicy == ScopeSubdomains {
+			if domain, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil {
+				s.domains = append(s.domains, domain)
+			} else {
+				s.domains = append(s.domains, strings.TrimPrefix(host, "www."))
+			}
+		}
+	}
+
+	for _, d := range cfg.AllowedDomains {
+		d = strings.ToLower(strings.TrimSpace(d))
+		d = strings.TrimPrefix(strings.TrimPrefix(d, "https://"), "http://")
+		d = strings.TrimSuffix(d, "/")
+		if d == "" {
+			continue
+		}
+
+		if wildcard, ok := strings.CutPrefix(d, "*."); ok {
+			s.domains = append(s.domains, wildcard)
+		} else if cfg.ScopePolicy == ScopeAllowlist {
+			s.hosts[d] = true
+		} else {
+			s.domains = append(s.domains, d)
+		}
+	}
+
+	return s
+}
+
+// contains reports whether u is on a host the crawl may follow
+func (s *crawlScope) contains(u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return false
+	}
+	if s.hosts[host] {
+		return true
+	}
+	for _, d := range s.domains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// startURLs returns the primary start URL followed by any extra seeds
+func (c *Crawler) startURLs() []string {
+	urls := []string{c.config.StartURL}
+	for _, u := range c.config.StartURLs {
+		if u = strings.TrimSpace(u); u != "" && u != c.config.StartURL {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
@@ -0,0 +1,122 @@
+package crawler
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	snippetRadius = 100 // characters of context kept on each side of a match
+	maxSnippets   = 3   // snippets written per page; the rest are only counted
+)
+
+// searchDocumentText searches text extracted from a PDF or Word document.
+// Link search reports only the count, since there's no anchor text to show.
+func (c *Crawler) searchDocumentText(text string) (count int, details []string) {
+	count, snippets := c.searchOccurrences(text)
+	if c.runs(ModeSearchLink) {
+		return count, nil
+	}
+	return count, snippets
+}
+
+// searchOccurrences counts the matches of the search pattern in text and
+// returns the surrounding text of the first few
+func (c *Crawler) searchOccurrences(text string) (count int, snippets []string) {
+	for _, loc := range c.searchPattern.FindAllStringIndex(text, -1) {
+		if loc[0] == loc[1] {
+			// Empty matches (e.g. a regex like "x*") aren't occurrences
+			continue
+		}
+		count++
+		if len(snippets) < maxSnippets {
+			snippets = append(snippets, snippetAround(text, loc[0], loc[1]))
+		}
+	}
+	return count, snippets
+}
+
+// snippetAround returns text[start:end] with up to snippetRadius characters
+// on either side, whitespace collapsed and … marking where it was cut
+func snippetAround(text string, start, end int) string {
+	from := start
+	for i := 0; i < snippetRadius && from > 0; i++ {
+		_, size := utf8.DecodeLastRuneInString(text[:from])
+		from -= size
+	}
+	to := end
+	for i := 0; i < snippetRadius && to < len(text); i++ {
+		_, size := utf8.DecodeRuneInString(text[to:])
+		to += size
+	}
+
+	snippet := strings.Join(strings.Fields(text[from:to]), " ")
+	if from > 0 {
+		snippet = "…" + snippet
+	}
+	if to < len(text) {
+		snippet += "…"
+	}
+	return snippet
+}
+
+// hiddenElements hold text that never renders on the page
+var hiddenElements = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Template: true,
+}
+
+// blockElements start a new line, so words either side of them don't run
+// together once the tags are gone
+var blockElements = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Aside: true, atom.Blockquote: true,
+	atom.Br: true, atom.Dd: true, atom.Div: true, atom.Dl: true, atom.Dt: true,
+	atom.Figcaption: true, atom.Footer: true, atom.Form: true, atom.H1: true,
+	atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Header: true, atom.Hr: true, atom.Li: true, atom.Main: true, atom.Nav: true,
+	atom.Ol: true, atom.Option: true, atom.P: true, atom.Pre: true, atom.Section: true,
+	atom.Table: true, atom.Td: true, atom.Th: true, atom.Title: true, atom.Tr: true,
+	atom.Ul: true,
+}
+
+// visibleText returns the text a reader would see on the page: tags,
+// attributes, comments, scripts and styles are dropped and entities decoded
+func visibleText(body []byte) string {
+	return newHTMLPage(body).text()
+}
+
+// text returns the page's visibleText from its parsed tree
+func (p *htmlPage) text() string {
+	var text strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			text.WriteString(n.Data)
+			return
+		case html.ElementNode:
+			if hiddenElements[n.DataAtom] {
+				return
+			}
+		}
+		block := n.Type == html.ElementNode && blockElements[n.DataAtom]
+		if block {
+			text.WriteByte('\n')
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+		if block {
+			text.WriteByte('\n')
+		}
+	}
+	if root := p.root(); root != nil {
+		walk(root)
+	}
+	return text.String()
+}
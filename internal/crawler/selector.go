@@ -0,0 +1,401 @@
+package crawler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// cssSelector is a compiled CSS selector list. It supports type, universal,
+// #id, .class and [attribute] selectors (=, ~=, |=, ^=, $=, *=), the
+// :first-child, :last-child and :nth-child(n|odd|even) pseudo-classes, and
+// the descendant, >, + and ~ combinators - enough to point at the parts of
+// a page worth scraping without pulling in a browser.
+type cssSelector []cssComplex
+
+// cssComplex is one selector of a list: compounds joined by combinators,
+// where combinators[i] sits between compounds[i] and compounds[i+1]
+type cssComplex struct {
+	compounds   []cssCompound
+	combinators []byte // ' ', '>', '+' or '~'
+}
+
+type cssCompound struct {
+	tag     string // "" matches any element
+	id      string
+	classes []string
+	attrs   []cssAttr
+	pseudos []cssPseudo
+}
+
+type cssAttr struct {
+	name, op, value string // op is "" when only the attribute's presence is checked
+}
+
+type cssPseudo struct {
+	name string
+	a, b int // :nth-child(an+b)
+}
+
+// compileCSS parses a selector list such as "article h1, .post-title"
+func compileCSS(sel string) (cssSelector, error) {
+	p := &selectorParser{s: strings.TrimSpace(sel)}
+	if p.s == "" {
+		return nil, fmt.Errorf("empty selector")
+	}
+
+	var list cssSelector
+	for {
+		complex, err := p.complex()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, complex)
+		p.skipSpace()
+		if p.eof() {
+			return list, nil
+		}
+		if p.peek() != ',' {
+			return nil, fmt.Errorf("unexpected %q in selector %q", p.peek(), sel)
+		}
+		p.pos++
+	}
+}
+
+// selectorParser reads CSS selectors and XPath expressions
+type selectorParser struct {
+	s   string
+	pos int
+}
+
+func (p *selectorParser) eof() bool  { return p.pos >= len(p.s) }
+func (p *selectorParser) peek() byte { return p.s[p.pos] }
+
+func (p *selectorParser) skipSpace() bool {
+	start := p.pos
+	for !p.eof() && strings.IndexByte(" \t\r\n", p.peek()) >= 0 {
+		p.pos++
+	}
+	return p.pos > start
+}
+
+func (p *selectorParser) complex() (cssComplex, error) {
+	var c cssComplex
+	p.skipSpace()
+	for {
+		compound, err := p.compound()
+		if err != nil {
+			return c, err
+		}
+		c.compounds = append(c.compounds, compound)
+
+		sawSpace := p.skipSpace()
+		if p.eof() || p.peek() == ',' {
+			return c, nil
+		}
+		comb := byte(' ')
+		if strings.IndexByte(">+~", p.peek()) >= 0 {
+			comb = p.peek()
+			p.pos++
+			p.skipSpace()
+		} else if !sawSpace {
+			return c, fmt.Errorf("unexpected %q in selector %q", p.peek(), p.s)
+		}
+		c.combinators = append(c.combinators, comb)
+	}
+}
+
+func (p *selectorParser) compound() (cssCompound, error) {
+	var c cssCompound
+	start := p.pos
+	if !p.eof() && p.peek() == '*' {
+		p.pos++
+	} else if name := p.ident(); name != "" {
+		c.tag = strings.ToLower(name)
+	}
+
+	for !p.eof() {
+		switch p.peek() {
+		case '#':
+			p.pos++
+			if c.id = p.ident(); c.id == "" {
+				return c, fmt.Errorf("missing id after # in selector %q", p.s)
+			}
+		case '.':
+			p.pos++
+			class := p.ident()
+			if class == "" {
+				return c, fmt.Errorf("missing class name after . in selector %q", p.s)
+			}
+			c.classes = append(c.classes, class)
+		case '[':
+			attr, err := p.attribute()
+			if err != nil {
+				return c, err
+			}
+			c.attrs = append(c.attrs, attr)
+		case ':':
+			pseudo, err := p.pseudo()
+			if err != nil {
+				return c, err
+			}
+			c.pseudos = append(c.pseudos, pseudo)
+		default:
+			if p.pos == start {
+				return c, fmt.Errorf("unexpected %q in selector %q", p.peek(), p.s)
+			}
+			return c, nil
+		}
+	}
+	if p.pos == start {
+		return c, fmt.Errorf("selector %q ends unexpectedly", p.s)
+	}
+	return c, nil
+}
+
+func (p *selectorParser) ident() string {
+	start := p.pos
+	for !p.eof() {
+		ch := p.peek()
+		if ch == '-' || ch == '_' || ch >= 0x80 || (ch >= '0' && ch <= '9') || isASCIILetterByte(ch) {
+			p.pos++
+			continue
+		}
+		break
+	}
+	return p.s[start:p.pos]
+}
+
+func isASCIILetterByte(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func (p *selectorParser) attribute() (cssAttr, error) {
+	p.pos++ // [
+	p.skipSpace()
+	attr := cssAttr{name: strings.ToLower(p.ident())}
+	if attr.name == "" {
+		return attr, fmt.Errorf("missing attribute name in selector %q", p.s)
+	}
+	p.skipSpace()
+	if p.eof() {
+		return attr, fmt.Errorf("unclosed [ in selector %q", p.s)
+	}
+	if p.peek() == ']' {
+		p.pos++
+		return attr, nil
+	}
+
+	for _, op := range []string{"~=", "|=", "^=", "$=", "*=", "="} {
+		if strings.HasPrefix(p.s[p.pos:], op) {
+			attr.op = op
+			p.pos += len(op)
+			break
+		}
+	}
+	if attr.op == "" {
+		return attr, fmt.Errorf("unknown attribute operator in selector %q", p.s)
+	}
+	p.skipSpace()
+
+	if !p.eof() && (p.peek() == '"' || p.peek() == '\'') {
+		quote := p.peek()
+		end := strings.IndexByte(p.s[p.pos+1:], quote)
+		if end < 0 {
+			return attr, fmt.Errorf("unclosed quote in selector %q", p.s)
+		}
+		attr.value = p.s[p.pos+1 : p.pos+1+end]
+		p.pos += end + 2
+	} else {
+		attr.value = p.ident()
+	}
+
+	p.skipSpace()
+	if p.eof() || p.peek() != ']' {
+		return attr, fmt.Errorf("unclosed [ in selector %q", p.s)
+	}
+	p.pos++
+	return attr, nil
+}
+
+func (p *selectorParser) pseudo() (cssPseudo, error) {
+	p.pos++ // :
+	ps := cssPseudo{name: strings.ToLower(p.ident())}
+	switch ps.name {
+	case "first-child":
+		ps.name, ps.b = "nth-child", 1
+	case "last-child":
+	case "nth-child":
+		if p.eof() || p.peek() != '(' {
+			return ps, fmt.Errorf(":nth-child needs an argument in selector %q", p.s)
+		}
+		end := strings.IndexByte(p.s[p.pos:], ')')
+		if end < 0 {
+			return ps, fmt.Errorf("unclosed ( in selector %q", p.s)
+		}
+		arg := strings.ToLower(strings.TrimSpace(p.s[p.pos+1 : p.pos+end]))
+		p.pos += end + 1
+		switch arg {
+		case "odd":
+			ps.a, ps.b = 2, 1
+		case "even":
+			ps.a, ps.b = 2, 0
+		default:
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 1 {
+				return ps, fmt.Errorf("unsupported :nth-child(%s) in selector %q (use a number, odd or even)", arg, p.s)
+			}
+			ps.b = n
+		}
+	default:
+		return ps, fmt.Errorf("unsupported pseudo-class :%s in selector %q", ps.name, p.s)
+	}
+	return ps, nil
+}
+
+// matchAll returns the elements under root that match the selector, in
+// document order
+func (sel cssSelector) matchAll(root *html.Node) []*html.Node {
+	var matches []*html.Node
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			for _, c := range sel {
+				if c.match(n, len(c.compounds)-1) {
+					matches = append(matches, n)
+					break
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(root)
+	return matches
+}
+
+// match checks n against compounds[i] and, through the combinators, the
+// compounds before it
+func (c cssComplex) match(n *html.Node, i int) bool {
+	if !c.compounds[i].match(n) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+
+	switch c.combinators[i-1] {
+	case '>':
+		parent := n.Parent
+		return parent != nil && parent.Type == html.ElementNode && c.match(parent, i-1)
+	case '+':
+		prev := prevElement(n)
+		return prev != nil && c.match(prev, i-1)
+	case '~':
+		for prev := prevElement(n); prev != nil; prev = prevElement(prev) {
+			if c.match(prev, i-1) {
+				return true
+			}
+		}
+	default:
+		for parent := n.Parent; parent != nil; parent = parent.Parent {
+			if parent.Type == html.ElementNode && c.match(parent, i-1) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c cssCompound) match(n *html.Node) bool {
+	if c.tag != "" && n.Data != c.tag {
+		return false
+	}
+	if c.id != "" && attrValue(n, "id") != c.id {
+		return false
+	}
+	if len(c.classes) > 0 {
+		classes := strings.Fields(attrValue(n, "class"))
+		for _, want := range c.classes {
+			if !containsString(classes, want) {
+				return false
+			}
+		}
+	}
+	for _, a := range c.attrs {
+		if !a.match(n) {
+			return false
+		}
+	}
+	for _, ps := range c.pseudos {
+		if !ps.match(n) {
+			return false
+		}
+	}
+	return true
+}
+
+func (a cssAttr) match(n *html.Node) bool {
+	v, ok := attrLookup(n, a.name)
+	if !ok {
+		return false
+	}
+	switch a.op {
+	case "":
+		return true
+	case "=":
+		return v == a.value
+	case "~=":
+		return containsString(strings.Fields(v), a.value)
+	case "|=":
+		return v == a.value || strings.HasPrefix(v, a.value+"-")
+	case "^=":
+		return a.value != "" && strings.HasPrefix(v, a.value)
+	case "$=":
+		return a.value != "" && strings.HasSuffix(v, a.value)
+	case "*=":
+		return a.value != "" && strings.Contains(v, a.value)
+	}
+	return false
+}
+
+func (ps cssPseudo) match(n *html.Node) bool {
+	if ps.name == "last-child" {
+		for next := n.NextSibling; next != nil; next = next.NextSibling {
+			if next.Type == html.ElementNode {
+				return false
+			}
+		}
+		return true
+	}
+
+	pos := 1
+	for prev := prevElement(n); prev != nil; prev = prevElement(prev) {
+		pos++
+	}
+	if ps.a == 0 {
+		return pos == ps.b
+	}
+	return pos >= ps.b && (pos-ps.b)%ps.a == 0
+}
+
+func prevElement(n *html.Node) *html.Node {
+	for prev := n.PrevSibling; prev != nil; prev = prev.PrevSibling {
+		if prev.Type == html.ElementNode {
+			return prev
+		}
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
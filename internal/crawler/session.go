@@ -0,0 +1,249 @@
+package crawler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BrowserSession is a session copied from a real browser through
+// Config.SessionFile, so a crawl can carry on past a login or a bot challenge
+// the browser already got through: its cookies, each with the URL it belongs
+// to, and for a HAR file the headers the browser sent to each host
+type BrowserSession struct {
+	cookies []sessionCookie
+	headers map[string]map[string]string // host -> headers of its last page request
+	expired int                          // cookies left out because they'd expired
+}
+
+// sessionCookie is an imported cookie and the URL it was set for
+type sessionCookie struct {
+	url    *url.URL
+	cookie *http.Cookie
+}
+
+// sessionSkipHeaders are request headers a HAR records that describe one
+// particular request rather than the session, or that the crawler manages
+var sessionSkipHeaders = map[string]bool{
+	"Host": true, "Cookie": true, "Connection": true, "Content-Length": true, "Content-Type": true,
+	"Accept-Encoding": true, "Referer": true, "Origin": true, "If-None-Match": true, "If-Modified-Since": true,
+}
+
+// LoadBrowserSession reads a Netscape cookies.txt file, as cookie export
+// extensions and curl write, or a HAR file saved from the browser's developer
+// tools. A .har file, or one starting with {, is read as HAR.
+func LoadBrowserSession(path string) (*BrowserSession, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read session file: %v", err)
+	}
+	s := &BrowserSession{headers: make(map[string]map[string]string)}
+	if strings.EqualFold(filepath.Ext(path), ".har") || bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		err = s.loadHAR(data)
+	} else {
+		err = s.loadCookiesTxt(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid session file %s: %v", path, err)
+	}
+	if len(s.cookies) == 0 && len(s.headers) == 0 {
+		if s.expired > 0 {
+			return nil, fmt.Errorf("every cookie in %s has expired - export the session again", path)
+		}
+		return nil, fmt.Errorf("no cookies or headers found in %s", path)
+	}
+	return s, nil
+}
+
+// loadCookiesTxt reads the tab-separated lines of a cookies.txt file:
+// domain, whether subdomains share it, path, secure, expiry, name and value.
+// Lines starting with #HttpOnly_ are HttpOnly cookies; other # lines are
+// comments.
+func (s *BrowserSession) loadCookiesTxt(data []byte) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		httpOnly := false
+		if rest, ok := strings.CutPrefix(line, "#HttpOnly_"); ok {
+			line, httpOnly = rest, true
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return fmt.Errorf("line %d: expected 7 tab-separated fields, found %d", n, len(fields))
+		}
+
+		host := strings.TrimPrefix(fields[0], ".")
+		secure := strings.EqualFold(fields[3], "TRUE")
+		cookie := &http.Cookie{Name: fields[5], Value: fields[6], Path: fields[2], Secure: secure, HttpOnly: httpOnly}
+		if strings.EqualFold(fields[1], "TRUE") {
+			cookie.Domain = host
+		}
+		if expires, _ := strconv.ParseInt(fields[4], 10, 64); expires > 0 {
+			// 0 is a session cookie
+			cookie.Expires = time.Unix(expires, 0)
+		}
+		scheme := "http"
+		if secure {
+			scheme = "https"
+		}
+		s.add(&url.URL{Scheme: scheme, Host: host, Path: fields[2]}, cookie)
+	}
+	return scanner.Err()
+}
+
+// loadHAR reads the cookies each request sent and each response set, in the
+// order the browser made them, so the latest value of a cookie wins. The
+// headers of the last page each host served are kept as that host's headers.
+func (s *BrowserSession) loadHAR(data []byte) error {
+	type harCookie struct {
+		Name     string `json:"name"`
+		Value    string `json:"value"`
+		Path     string `json:"path"`
+		Domain   string `json:"domain"`
+		Expires  string `json:"expires"`
+		HTTPOnly bool   `json:"httpOnly"`
+		Secure   bool   `json:"secure"`
+	}
+	var har struct {
+		Log struct {
+			Entries []struct {
+				Request struct {
+					URL     string `json:"url"`
+					Headers []struct {
+						Name  string `json:"name"`
+						Value string `json:"value"`
+					} `json:"headers"`
+					Cookies []harCookie `json:"cookies"`
+				} `json:"request"`
+				Response struct {
+					Cookies []harCookie `json:"cookies"`
+					Content struct {
+						MimeType string `json:"mimeType"`
+					} `json:"content"`
+				} `json:"response"`
+			} `json:"entries"`
+		} `json:"log"`
+	}
+	if err := json.Unmarshal(data, &har); err != nil {
+		return err
+	}
+
+	for _, entry := range har.Log.Entries {
+		u, err := url.Parse(entry.Request.URL)
+		if err != nil || u.Host == "" {
+			continue
+		}
+		// What the browser sent applies to the whole host
+		for _, c := range entry.Request.Cookies {
+			s.add(u, &http.Cookie{Name: c.Name, Value: c.Value, Path: "/"})
+		}
+		for _, c := range entry.Response.Cookies {
+			cookie := &http.Cookie{Name: c.Name, Value: c.Value, Path: c.Path, Domain: strings.TrimPrefix(c.Domain, "."), Secure: c.Secure, HttpOnly: c.HTTPOnly}
+			if expires, err := time.Parse(time.RFC3339, c.Expires); err == nil {
+				cookie.Expires = expires
+			}
+			s.add(u, cookie)
+		}
+
+		if !strings.Contains(entry.Response.Content.MimeType, "text/html") {
+			continue
+		}
+		headers := make(map[string]string)
+		for _, h := range entry.Request.Headers {
+			name := http.CanonicalHeaderKey(h.Name)
+			// HTTP/2 pseudo-headers such as :authority start with a colon
+			if strings.HasPrefix(name, ":") || sessionSkipHeaders[name] {
+				continue
+			}
+			headers[name] = h.Value
+		}
+		s.headers[strings.ToLower(u.Hostname())] = headers
+	}
+	return nil
+}
+
+// add keeps a cookie unless it has already expired
+func (s *BrowserSession) add(u *url.URL, cookie *http.Cookie) {
+	if !cookie.Expires.IsZero() && cookie.Expires.Before(time.Now()) {
+		s.expired++
+		return
+	}
+	s.cookies = append(s.cookies, sessionCookie{url: u, cookie: cookie})
+}
+
+// Cookies returns how many cookies the session holds
+func (s *BrowserSession) Cookies() int {
+	return len(s.cookies)
+}
+
+// Headers returns the headers the browser sent to host, or nil if the
+// session has none for it
+func (s *BrowserSession) Headers(host string) map[string]string {
+	return s.headers[strings.ToLower(host)]
+}
+
+// setCookies puts the session's cookies in jar, each for its own URL, so
+// they're only sent where the browser would send them
+func (s *BrowserSession) setCookies(jar http.CookieJar) {
+	for _, c := range s.cookies {
+		jar.SetCookies(c.url, []*http.Cookie{c.cookie})
+	}
+}
+
+// AddTo adds the session's cookies and headers to a request made outside a
+// crawl, such as the wizard's connection test
+func (s *BrowserSession) AddTo(req *http.Request) {
+	jar, _ := cookiejar.New(nil)
+	s.setCookies(jar)
+	for _, cookie := range jar.Cookies(req.URL) {
+		req.AddCookie(cookie)
+	}
+	for name, value := range s.Headers(req.URL.Hostname()) {
+		req.Header.Set(name, value)
+	}
+}
+
+// importSession loads Config.SessionFile into the crawler's cookie jar, and
+// adds the headers the browser sent to the start URL's host to
+// Config.Headers. Headers set in the config take precedence.
+func (c *Crawler) importSession() {
+	s, err := LoadBrowserSession(c.config.SessionFile)
+	if err != nil {
+		c.log.Warn(fmt.Sprintf("⚠️  %v - crawling without the browser session", err), "path", c.config.SessionFile, "error", err)
+		return
+	}
+	s.setCookies(c.httpClient.Jar)
+
+	var host string
+	if u, err := url.Parse(c.config.StartURL); err == nil {
+		host = u.Hostname()
+	}
+	if imported := s.Headers(host); len(imported) > 0 {
+		headers := make(map[string]string, len(imported)+len(c.config.Headers))
+		for name, value := range imported {
+			headers[name] = value
+		}
+		for name, value := range c.config.Headers {
+			headers[name] = value
+		}
+		c.config.Headers = headers
+	}
+
+	c.log.Info(fmt.Sprintf("🍪 %d cookies and %d headers imported from %s", s.Cookies(), len(s.Headers(host)), c.config.SessionFile),
+		"path", c.config.SessionFile, "cookies", s.Cookies(), "headers", len(s.Headers(host)))
+	if s.expired > 0 {
+		c.log.Warn(fmt.Sprintf("⚠️  %d cookies in %s have expired and were left out", s.expired, c.config.SessionFile), "path", c.config.SessionFile, "expired", s.expired)
+	}
+}
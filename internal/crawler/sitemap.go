@@ -3,9 +3,10 @@ package crawler
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
 	"os"
@@ -36,24 +37,63 @@ type URLSet struct {
 	URLs    []SitemapURL `xml:"url"`
 }
 
-// Sitemap-specific variables
-var (
-	sitemapURLs    sync.Map // stores URLs to include in sitemap
-	sitemapVisited sync.Map // tracks all visited URLs to avoid duplicates
-	sitemapConfig  Config
-	sitemapWG      sync.WaitGroup
-	sitemapSema    chan struct{}
-	sitemapBase    *url.URL
-	sitemapStats   struct {
-		PagesFound   int64
-		PagesChecked int64
-		ErrorCount   int64
-		BlockedCount int64
-		SkippedCount int64
-	}
-	sitemapStart time.Time
+// SitemapIndex is the root element of a sitemap index file
+type SitemapIndex struct {
+	XMLName  xml.Name            `xml:"sitemapindex"`
+	XMLNS    string              `xml:"xmlns,attr"`
+	Sitemaps []SitemapIndexEntry `xml:"sitemap"`
+}
+
+// SitemapIndexEntry points to one child sitemap file
+type SitemapIndexEntry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// Limits for a single sitemap file from the sitemaps.org protocol
+const (
+	maxSitemapURLs  = 50000
+	maxSitemapBytes = 50 * 1024 * 1024
 )
 
+const sitemapXMLNS = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// SitemapStats tracks sitemap crawl progress
+type SitemapStats struct {
+	PagesFound       int64
+	PagesChecked     int64
+	ErrorCount       int64
+	BlockedCount     int64
+	SkippedCount     int64
+	NoIndexCount     int64 // pages excluded by a noindex robots directive
+	CanonicalAliases int64 // pages replaced by their canonical URL
+	SkippedTimeLimit int64 // pages not crawled because Config.MaxDuration ran out
+	SkippedBudget    int64 // pages not crawled because Config.MaxTotalBytes was used up
+}
+
+// sitemapGenerator holds the state of a sitemap crawl
+type sitemapGenerator struct {
+	c         *Crawler
+	config    Config
+	urls      sync.Map    // stores URLs to include in sitemap
+	visited   *visitedSet // tracks all visited URLs to avoid duplicates
+	wg        sync.WaitGroup
+	sema      chan struct{}
+	base      *url.URL
+	stats     SitemapStats
+	startTime time.Time
+	files     []string // sitemap files written, index first when split
+}
+
+func newSitemapGenerator(c *Crawler) *sitemapGenerator {
+	return &sitemapGenerator{
+		c:       c,
+		config:  c.config,
+		sema:    make(chan struct{}, c.config.MaxConcurrency),
+		visited: newVisitedSet(c.config),
+	}
+}
+
 // SitemapEntry holds URL info for sitemap generation
 type SitemapEntry struct {
 	URL     string
@@ -62,24 +102,18 @@ type SitemapEntry struct {
 
 // StartSitemapGeneration initiates the sitemap crawl and generation
 func StartSitemapGeneration(cfg Config) {
-	sitemapURLs = sync.Map{}
-	sitemapVisited = sync.Map{}
-	sitemapConfig = cfg
-	sitemapStart = time.Now()
-	sitemapStats = struct {
-		PagesFound   int64
-		PagesChecked int64
-		ErrorCount   int64
-		BlockedCount int64
-		SkippedCount int64
-	}{}
-
-	sitemapSema = make(chan struct{}, cfg.MaxConcurrency)
+	cfg.Mode = ModeSitemap
+	New(cfg).Run(context.Background())
+}
+
+func (s *sitemapGenerator) run(ctx context.Context) {
+	cfg := s.config
+	s.startTime = time.Now()
 
 	var err error
-	sitemapBase, err = url.Parse(cfg.StartURL)
+	s.base, err = url.Parse(cfg.StartURL)
 	if err != nil {
-		fmt.Printf("❌ Invalid start URL: %v\n", err)
+		s.c.log.Error(fmt.Sprintf("❌ Invalid start URL: %v", err), "error", err)
 		return
 	}
 
@@ -88,28 +122,43 @@ func StartSitemapGeneration(cfg Config) {
 	fmt.Printf("│  📄 Output: %-44s │\n", cfg.SitemapOpts.Filename)
 	fmt.Printf("│  📅 Freq:   %-44s │\n", cfg.SitemapOpts.ChangeFreq)
 	fmt.Printf("│  ⭐ Priority: %-42.1f │\n", cfg.SitemapOpts.Priority)
+	if s.visited.bloom != nil {
+		fmt.Printf("│  🧮 Seen:   %-44s │\n", truncateString(s.visited.describe(), 44))
+	}
 	fmt.Println("└───────────────────────────────────────────────────────────┘")
 	fmt.Println()
 
 	// Start live stats
 	stopStats := make(chan bool)
-	go printSitemapLiveStats(stopStats)
+	go s.printSitemapLiveStats(stopStats)
+
+	// Begin crawling, or just check the listed pages
+	seeds := s.c.startURLs()
+	if len(s.c.urlList) > 0 {
+		seeds = s.c.urlList
+	}
+	for _, start := range seeds {
+		s.crawlForSitemap(ctx, start, 0)
+	}
+	s.wg.Wait()
 
-	// Begin crawling
-	crawlForSitemap(cfg.StartURL)
-	sitemapWG.Wait()
+	if s.c.interrupted(ctx) {
+		s.c.log.Warn("🛑 Crawl cancelled - writing partial sitemap...")
+	} else if atomic.LoadInt32(&s.c.timedOut) == 1 {
+		s.c.log.Warn("⌛ Time limit reached - writing partial sitemap...")
+	}
 
 	// Stop live stats
 	stopStats <- true
 
 	// Generate the sitemap file
-	generateSitemapFile(cfg)
+	s.generateSitemapFile(cfg)
 
 	// Print final stats
-	printSitemapFinalStats(cfg)
+	s.printSitemapFinalStats(cfg)
 }
 
-func printSitemapLiveStats(stop chan bool) {
+func (s *sitemapGenerator) printSitemapLiveStats(stop chan bool) {
 	ticker := time.NewTicker(2 * time.Second)
 	defer ticker.Stop()
 
@@ -118,11 +167,14 @@ func printSitemapLiveStats(stop chan bool) {
 		case <-stop:
 			return
 		case <-ticker.C:
-			elapsed := time.Since(sitemapStart)
-			found := atomic.LoadInt64(&sitemapStats.PagesFound)
-			checked := atomic.LoadInt64(&sitemapStats.PagesChecked)
-			errors := atomic.LoadInt64(&sitemapStats.ErrorCount)
-			blocked := atomic.LoadInt64(&sitemapStats.BlockedCount)
+			if s.config.Quiet {
+				continue
+			}
+			elapsed := time.Since(s.startTime)
+			found := atomic.LoadInt64(&s.stats.PagesFound)
+			checked := atomic.LoadInt64(&s.stats.PagesChecked)
+			errors := atomic.LoadInt64(&s.stats.ErrorCount)
+			blocked := atomic.LoadInt64(&s.stats.BlockedCount)
 
 			pagesPerSec := float64(checked) / elapsed.Seconds()
 
@@ -138,7 +190,15 @@ func printSitemapLiveStats(stop chan bool) {
 	}
 }
 
-func crawlForSitemap(link string) {
+func (s *sitemapGenerator) crawlForSitemap(ctx context.Context, link string, depth int) {
+	if s.c.interrupted(ctx) {
+		return
+	}
+
+	if s.config.MaxDepth > 0 && depth > s.config.MaxDepth {
+		return
+	}
+
 	// Normalize URL
 	parsedURL, err := url.Parse(link)
 	if err != nil {
@@ -150,87 +210,123 @@ func crawlForSitemap(link string) {
 	normalizedURL := parsedURL.String()
 
 	// Check if already visited using separate visited map
-	if _, loaded := sitemapVisited.LoadOrStore(normalizedURL, true); loaded {
+	if s.visited.add(normalizedURL) {
+		return
+	}
+
+	// Respect robots.txt - disallowed pages are neither crawled nor listed
+	if !s.c.robots.allowed(ctx, normalizedURL) {
+		atomic.AddInt64(&s.stats.SkippedCount, 1)
+		return
+	}
+
+	// Pages found after the time limit are left out rather than listed unchecked
+	if s.c.outOfTime() {
+		atomic.AddInt64(&s.stats.SkippedTimeLimit, 1)
+		return
+	}
+	if s.c.outOfBudget() {
+		atomic.AddInt64(&s.stats.SkippedBudget, 1)
 		return
 	}
 
 	// Check path filter to determine if URL should be in sitemap
 	includeInSitemap := true
-	if sitemapConfig.PathFilter != "" {
-		pathWithSlash := parsedURL.Path
-		if !strings.HasSuffix(pathWithSlash, "/") {
-			pathWithSlash = pathWithSlash + "/"
-		}
-		filterPath := sitemapConfig.PathFilter
-		if !strings.HasSuffix(filterPath, "/") {
-			filterPath = filterPath + "/"
-		}
-
-		// Check if the URL path starts with the filter path
-		if !strings.HasPrefix(pathWithSlash, filterPath) && pathWithSlash != filterPath {
-			includeInSitemap = false
-			atomic.AddInt64(&sitemapStats.SkippedCount, 1)
-		}
+	if !underPath(parsedURL, s.config.PathFilter) {
+		includeInSitemap = false
+		atomic.AddInt64(&s.stats.SkippedCount, 1)
 	}
 
 	// Only add to sitemap URLs if it matches the filter
 	if includeInSitemap {
-		sitemapURLs.Store(normalizedURL, &SitemapEntry{URL: normalizedURL})
+		s.urls.Store(normalizedURL, &SitemapEntry{URL: normalizedURL})
 	}
 
-	atomic.AddInt64(&sitemapStats.PagesFound, 1)
+	if found := atomic.AddInt64(&s.stats.PagesFound, 1); s.config.MaxPages > 0 && found > int64(s.config.MaxPages) {
+		atomic.AddInt64(&s.stats.PagesFound, -1)
+		s.urls.Delete(normalizedURL)
+		return
+	}
 
-	sitemapWG.Add(1)
+	s.wg.Add(1)
 	go func(shouldInclude bool) {
-		defer sitemapWG.Done()
-		sitemapSema <- struct{}{}
-		defer func() { <-sitemapSema }()
+		defer s.wg.Done()
+		select {
+		case s.sema <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		defer func() { <-s.sema }()
+		if !s.c.throttle.acquire(ctx) {
+			return
+		}
+		defer s.c.throttle.release()
 
-		fetchForSitemap(normalizedURL, shouldInclude)
+		if !s.c.proceed(ctx) {
+			atomic.AddInt64(&s.stats.PagesFound, -1)
+			s.urls.Delete(normalizedURL)
+			return
+		}
+		if s.c.outOfTime() {
+			atomic.AddInt64(&s.stats.PagesFound, -1)
+			atomic.AddInt64(&s.stats.SkippedTimeLimit, 1)
+			s.urls.Delete(normalizedURL)
+			return
+		}
+		if s.c.outOfBudget() {
+			atomic.AddInt64(&s.stats.PagesFound, -1)
+			atomic.AddInt64(&s.stats.SkippedBudget, 1)
+			s.urls.Delete(normalizedURL)
+			return
+		}
+
+		s.fetchForSitemap(ctx, normalizedURL, shouldInclude, depth)
 	}(includeInSitemap)
 }
 
-func fetchForSitemap(link string, includeInSitemap bool) {
-	atomic.AddInt64(&sitemapStats.PagesChecked, 1)
+func (s *sitemapGenerator) fetchForSitemap(ctx context.Context, link string, includeInSitemap bool, depth int) {
+	atomic.AddInt64(&s.stats.PagesChecked, 1)
 
-	req, err := http.NewRequest("GET", link, nil)
+	if !s.c.robots.wait(ctx, link) || !s.c.limiter.wait(ctx, link) {
+		return
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", link, nil)
 	if err != nil {
-		atomic.AddInt64(&sitemapStats.ErrorCount, 1)
+		atomic.AddInt64(&s.stats.ErrorCount, 1)
 		if includeInSitemap {
-			sitemapURLs.Delete(link)
+			s.urls.Delete(link)
 		}
 		return
 	}
 
-	req.Header.Set("User-Agent", userAgents[0])
-	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
-	req.Header.Set("Accept-Language", "en-US,en;q=0.5")
-	req.Header.Set("Accept-Encoding", "gzip, deflate, br")
-	req.Header.Set("Connection", "keep-alive")
+	s.c.fingerprint.apply(req, "none")
 
-	resp, err := httpClient.Do(req)
+	resp, err := s.c.conditionalRequest(req, 0)
 	if err != nil {
-		atomic.AddInt64(&sitemapStats.ErrorCount, 1)
+		atomic.AddInt64(&s.stats.ErrorCount, 1)
 		if includeInSitemap {
-			sitemapURLs.Delete(link)
+			s.urls.Delete(link)
 		}
 		return
 	}
 	defer resp.Body.Close()
 
 	// Handle blocked/error responses
-	if resp.StatusCode == 403 || resp.StatusCode == 503 || resp.StatusCode == 429 {
-		atomic.AddInt64(&sitemapStats.BlockedCount, 1)
+	blocked := resp.StatusCode == 403 || resp.StatusCode == 503 || resp.StatusCode == 429
+	s.c.noteResponse(resp, blocked)
+	if blocked {
+		atomic.AddInt64(&s.stats.BlockedCount, 1)
 		if includeInSitemap {
-			sitemapURLs.Delete(link)
+			s.urls.Delete(link)
 		}
 		return
 	}
 
 	if resp.StatusCode >= 400 {
-		atomic.AddInt64(&sitemapStats.ErrorCount, 1)
+		atomic.AddInt64(&s.stats.ErrorCount, 1)
 		if includeInSitemap {
-			sitemapURLs.Delete(link)
+			s.urls.Delete(link)
 		}
 		return
 	}
@@ -239,16 +335,16 @@ func fetchForSitemap(link string, includeInSitemap bool) {
 	contentType := resp.Header.Get("Content-Type")
 	if !strings.Contains(contentType, "text/html") {
 		if includeInSitemap {
-			sitemapURLs.Delete(link)
+			s.urls.Delete(link)
 		}
 		return
 	}
 
 	// Get Last-Modified header if requested
-	if includeInSitemap && sitemapConfig.SitemapOpts.IncludeLastMod {
+	if includeInSitemap && s.config.SitemapOpts.IncludeLastMod {
 		if lm := resp.Header.Get("Last-Modified"); lm != "" {
 			if t, err := time.Parse(time.RFC1123, lm); err == nil {
-				if entry, ok := sitemapURLs.Load(link); ok {
+				if entry, ok := s.urls.Load(link); ok {
 					e := entry.(*SitemapEntry)
 					e.LastMod = t.Format("2006-01-02")
 				}
@@ -257,32 +353,76 @@ func fetchForSitemap(link string, includeInSitemap bool) {
 	}
 
 	// Read body
-	var reader io.Reader = resp.Body
-	if resp.Header.Get("Content-Encoding") == "gzip" {
-		gzReader, err := gzip.NewReader(resp.Body)
-		if err != nil {
-			return
-		}
-		defer gzReader.Close()
-		reader = gzReader
-	}
-
-	bodyBytes, err := io.ReadAll(reader)
+	bodyBytes, err := s.c.readBody(resp)
 	if err != nil {
+		if errors.Is(err, errBodyTooLarge) {
+			atomic.AddInt64(&s.stats.SkippedCount, 1)
+			if includeInSitemap {
+				s.urls.Delete(link)
+			}
+		}
 		return
 	}
 
 	// Check for bot protection - use sitemap-specific detection that's less aggressive
 	if detectSitemapBotProtection(string(bodyBytes)) {
-		atomic.AddInt64(&sitemapStats.BlockedCount, 1)
+		atomic.AddInt64(&s.stats.BlockedCount, 1)
 		if includeInSitemap {
-			sitemapURLs.Delete(link)
+			s.urls.Delete(link)
+		}
+		return
+	}
+	s.c.cachePage(resp, bodyBytes)
+	page := newHTMLPage(bodyBytes)
+
+	// Fall back to dates in the page itself when the server sent no Last-Modified
+	if includeInSitemap && s.config.SitemapOpts.IncludeLastMod {
+		if entry, ok := s.urls.Load(link); ok {
+			e := entry.(*SitemapEntry)
+			if e.LastMod == "" {
+				e.LastMod = pageLastModified(page.root())
+			}
 		}
+	}
+
+	if includeInSitemap {
+		s.applyPageIndexing(link, parsePageIndexing(page.root(), link, resp.Header.Get("X-Robots-Tag")))
+	}
+
+	// Extract and follow internal links (noindex pages can still link elsewhere)
+	if s.c.config.URLListFile == "" {
+		s.extractLinksForSitemap(ctx, page.root(), link, depth)
+	}
+}
+
+// applyPageIndexing drops noindex pages from the sitemap and replaces pages
+// that declare a different canonical URL with that canonical, so aliases
+// (tracking params, trailing slashes, print views) appear only once
+func (s *sitemapGenerator) applyPageIndexing(link string, info pageIndexing) {
+	if info.NoIndex {
+		s.urls.Delete(link)
+		atomic.AddInt64(&s.stats.NoIndexCount, 1)
 		return
 	}
 
-	// Extract and follow internal links
-	extractLinksForSitemap(bodyBytes, link)
+	if info.Canonical == "" || info.Canonical == link {
+		return
+	}
+
+	value, ok := s.urls.LoadAndDelete(link)
+	if !ok {
+		return
+	}
+	atomic.AddInt64(&s.stats.CanonicalAliases, 1)
+
+	canonical, err := url.Parse(info.Canonical)
+	if err != nil || !s.c.scope.contains(canonical) {
+		// Canonical points off-site; this page isn't the one to list
+		return
+	}
+
+	entry := value.(*SitemapEntry)
+	s.urls.LoadOrStore(info.Canonical, &SitemapEntry{URL: info.Canonical, LastMod: entry.LastMod})
 }
 
 // detectSitemapBotProtection is less aggressive than the main crawler's detection
@@ -328,71 +468,84 @@ func detectSitemapBotProtection(body string) bool {
 	return false
 }
 
-func extractLinksForSitemap(body []byte, sourceURL string) {
-	doc, err := html.Parse(bytes.NewReader(body))
-	if err != nil {
+func (s *sitemapGenerator) extractLinksForSitemap(ctx context.Context, doc *html.Node, sourceURL string, depth int) {
+	if doc == nil {
 		return
 	}
 
+	pageBase, err := url.Parse(sourceURL)
+	if err != nil {
+		pageBase = s.base
+	}
+
 	var extractedLinks []string
 
+	// addLink keeps a link if it's a page on the site the sitemap should list
+	addLink := func(href string) {
+		href = strings.TrimSpace(href)
+
+		// Skip empty, anchors, mailto, tel, javascript
+		if href == "" ||
+			strings.HasPrefix(href, "#") ||
+			strings.HasPrefix(href, "mailto:") ||
+			strings.HasPrefix(href, "tel:") ||
+			strings.HasPrefix(href, "javascript:") {
+			return
+		}
+
+		// Parse and resolve the URL
+		u, err := url.Parse(href)
+		if err != nil {
+			return
+		}
+
+		// Skip non-http(s) schemes
+		if u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https" {
+			return
+		}
+
+		// Resolve relative URLs against the page they appear on
+		resolved := pageBase.ResolveReference(u)
+
+		// Only follow links within the crawl's domains
+		if !s.c.scope.contains(resolved) {
+			return
+		}
+
+		// Apply include/exclude patterns
+		if !s.c.filter.allowed(resolved) {
+			atomic.AddInt64(&s.stats.SkippedCount, 1)
+			return
+		}
+
+		// Skip common non-page extensions
+		path := strings.ToLower(resolved.Path)
+		skipExtensions := []string{
+			".pdf", ".doc", ".docx", ".xls", ".xlsx", ".ppt", ".pptx",
+			".zip", ".rar", ".tar", ".gz", ".7z",
+			".jpg", ".jpeg", ".png", ".gif", ".webp", ".svg", ".ico",
+			".mp3", ".mp4", ".avi", ".mov", ".wmv", ".flv",
+			".css", ".js", ".json", ".xml", ".rss", ".atom",
+		}
+		skip := false
+		for _, ext := range skipExtensions {
+			if strings.HasSuffix(path, ext) {
+				skip = true
+				break
+			}
+		}
+		if skip {
+			return
+		}
+
+		extractedLinks = append(extractedLinks, resolved.String())
+	}
+
 	var f func(*html.Node)
 	f = func(n *html.Node) {
-		if n.Type == html.ElementNode && n.Data == "a" {
-			for _, a := range n.Attr {
-				if a.Key == "href" {
-					href := strings.TrimSpace(a.Val)
-
-					// Skip empty, anchors, mailto, tel, javascript
-					if href == "" ||
-						strings.HasPrefix(href, "#") ||
-						strings.HasPrefix(href, "mailto:") ||
-						strings.HasPrefix(href, "tel:") ||
-						strings.HasPrefix(href, "javascript:") {
-						continue
-					}
-
-					// Parse and resolve the URL
-					u, err := url.Parse(href)
-					if err != nil {
-						continue
-					}
-
-					// Skip non-http(s) schemes
-					if u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https" {
-						continue
-					}
-
-					// Resolve relative URLs
-					resolved := sitemapBase.ResolveReference(u)
-
-					// Only follow same-host links
-					if resolved.Host != sitemapBase.Host {
-						continue
-					}
-
-					// Skip common non-page extensions
-					path := strings.ToLower(resolved.Path)
-					skipExtensions := []string{
-						".pdf", ".doc", ".docx", ".xls", ".xlsx", ".ppt", ".pptx",
-						".zip", ".rar", ".tar", ".gz", ".7z",
-						".jpg", ".jpeg", ".png", ".gif", ".webp", ".svg", ".ico",
-						".mp3", ".mp4", ".avi", ".mov", ".wmv", ".flv",
-						".css", ".js", ".json", ".xml", ".rss", ".atom",
-					}
-					skip := false
-					for _, ext := range skipExtensions {
-						if strings.HasSuffix(path, ext) {
-							skip = true
-							break
-						}
-					}
-					if skip {
-						continue
-					}
-
-					extractedLinks = append(extractedLinks, resolved.String())
-				}
+		if n.Type == html.ElementNode {
+			if href := linkTarget(n); href != "" {
+				addLink(href)
 			}
 		}
 		for c := n.FirstChild; c != nil; c = c.NextSibling {
@@ -414,8 +567,10 @@ func extractLinksForSitemap(body []byte, sourceURL string) {
 
 	// Crawl all extracted links
 	for _, link := range extractedLinks {
-		time.Sleep(30 * time.Millisecond)
-		crawlForSitemap(link)
+		if !sleepCtx(ctx, 30*time.Millisecond) {
+			return
+		}
+		s.crawlForSitemap(ctx, link, depth+1)
 	}
 }
 
@@ -540,20 +695,17 @@ func generatePaginationURLs(parsedURL *url.URL) []string {
 	return urls
 }
 
-func generateSitemapFile(cfg Config) {
+func (s *sitemapGenerator) generateSitemapFile(cfg Config) {
 	fmt.Println()
 	fmt.Println()
 	fmt.Println("📝 Generating sitemap XML...")
 
 	// Collect all URLs
 	var urls []SitemapURL
-	sitemapURLs.Range(func(key, value interface{}) bool {
+	s.urls.Range(func(key, value interface{}) bool {
 		entry := value.(*SitemapEntry)
-		sitemapURL := SitemapURL{
-			Loc:        entry.URL,
-			ChangeFreq: cfg.SitemapOpts.ChangeFreq,
-			Priority:   cfg.SitemapOpts.Priority,
-		}
+		sitemapURL := SitemapURL{Loc: entry.URL}
+		applySitemapRules(&sitemapURL, cfg.SitemapOpts)
 		if entry.LastMod != "" {
 			sitemapURL.LastMod = entry.LastMod
 		}
@@ -566,44 +718,143 @@ func generateSitemapFile(cfg Config) {
 		return urls[i].Loc < urls[j].Loc
 	})
 
-	// Create the URLSet
-	urlSet := URLSet{
-		XMLNS: "http://www.sitemaps.org/schemas/sitemap/0.9",
-		URLs:  urls,
+	filename := cfg.SitemapOpts.Filename
+	if filename == "" {
+		filename = "sitemap.xml"
 	}
+	filename = s.c.outputPath(filename)
 
-	// Marshal to XML
-	output, err := xml.MarshalIndent(urlSet, "", "  ")
+	// Split into several files when over the protocol limits
+	chunks, err := splitSitemapURLs(urls)
 	if err != nil {
-		fmt.Printf("❌ Error generating XML: %v\n", err)
+		s.c.log.Error(fmt.Sprintf("❌ Error generating XML: %v", err), "error", err)
 		return
 	}
 
-	// Add XML header
-	xmlContent := []byte(xml.Header + string(output))
+	if len(chunks) == 1 {
+		size, err := s.writeSitemapXML(filename, chunks[0], cfg.SitemapOpts.Gzip)
+		if err != nil {
+			return
+		}
+		fmt.Printf("✅ Sitemap written to: %s\n", s.files[0])
+		fmt.Printf("   📊 Total URLs: %d\n", len(urls))
+		fmt.Printf("   📦 File size: %s\n", formatBytes(size))
+		return
+	}
 
-	// Write to file
-	filename := cfg.SitemapOpts.Filename
-	if filename == "" {
-		filename = "sitemap.xml"
+	// Child sitemaps are named after the index: sitemap-1.xml, sitemap-2.xml, ...
+	ext := filepath.Ext(filename)
+	stem := strings.TrimSuffix(filename, ext)
+	now := time.Now().Format("2006-01-02")
+
+	index := SitemapIndex{XMLNS: sitemapXMLNS}
+	var children []string
+	var totalSize int64
+	for i, chunk := range chunks {
+		childName := fmt.Sprintf("%s-%d%s", stem, i+1, ext)
+		size, err := s.writeSitemapXML(childName, chunk, cfg.SitemapOpts.Gzip)
+		if err != nil {
+			return
+		}
+		totalSize += size
+		written := s.files[len(s.files)-1]
+		children = append(children, written)
+		index.Sitemaps = append(index.Sitemaps, SitemapIndexEntry{
+			Loc:     s.base.Scheme + "://" + s.base.Host + "/" + filepath.ToSlash(filepath.Base(written)),
+			LastMod: now,
+		})
+	}
+
+	// The index goes first so it's what callers report as the output
+	s.files = nil
+	if _, err := s.writeSitemapXML(filename, index, cfg.SitemapOpts.Gzip); err != nil {
+		return
 	}
+	s.files = append(s.files, children...)
 
-	err = os.WriteFile(filename, xmlContent, 0644)
+	fmt.Printf("✅ Sitemap index written to: %s\n", s.files[0])
+	fmt.Printf("   📊 Total URLs: %d across %d sitemap files\n", len(urls), len(chunks))
+	fmt.Printf("   📦 Total size: %s\n", formatBytes(totalSize))
+	fmt.Println("   💡 Upload all files to the site root so the index links resolve")
+}
+
+// splitSitemapURLs breaks urls into chunks that each fit in one sitemap file
+// (at most 50,000 URLs and 50MB uncompressed)
+func splitSitemapURLs(urls []SitemapURL) ([][]SitemapURL, error) {
+	if len(urls) == 0 {
+		return [][]SitemapURL{nil}, nil
+	}
+
+	var chunks [][]SitemapURL
+	for start := 0; start < len(urls); start += maxSitemapURLs {
+		end := min(start+maxSitemapURLs, len(urls))
+		split, err := splitBySize(urls[start:end])
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, split...)
+	}
+	return chunks, nil
+}
+
+// splitBySize halves a chunk until each part marshals to under maxSitemapBytes
+func splitBySize(urls []SitemapURL) ([][]SitemapURL, error) {
+	output, err := xml.MarshalIndent(URLSet{XMLNS: sitemapXMLNS, URLs: urls}, "", "  ")
 	if err != nil {
-		fmt.Printf("❌ Error writing sitemap file: %v\n", err)
-		return
+		return nil, err
+	}
+	if len(xml.Header)+len(output) <= maxSitemapBytes || len(urls) == 1 {
+		return [][]SitemapURL{urls}, nil
 	}
 
-	fmt.Printf("✅ Sitemap written to: %s\n", filename)
-	fmt.Printf("   📊 Total URLs: %d\n", len(urls))
-	fmt.Printf("   📦 File size: %s\n", formatBytes(int64(len(xmlContent))))
+	mid := len(urls) / 2
+	left, err := splitBySize(urls[:mid])
+	if err != nil {
+		return nil, err
+	}
+	right, err := splitBySize(urls[mid:])
+	if err != nil {
+		return nil, err
+	}
+	return append(left, right...), nil
+}
+
+// writeSitemapXML writes a urlset chunk or SitemapIndex to filename, adding
+// .gz and compressing when gz is set. It returns the size written.
+func (s *sitemapGenerator) writeSitemapXML(filename string, v interface{}, gz bool) (int64, error) {
+	if urls, ok := v.([]SitemapURL); ok {
+		v = URLSet{XMLNS: sitemapXMLNS, URLs: urls}
+	}
+
+	output, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		s.c.log.Error(fmt.Sprintf("❌ Error generating XML: %v", err), "error", err)
+		return 0, err
+	}
+	content := []byte(xml.Header + string(output))
+
+	if gz {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(content)
+		zw.Close()
+		content = buf.Bytes()
+		filename += ".gz"
+	}
+
+	if err := os.WriteFile(filename, content, 0644); err != nil {
+		s.c.log.Error(fmt.Sprintf("❌ Error writing sitemap file: %v", err), "file", filename, "error", err)
+		return 0, err
+	}
+	s.files = append(s.files, filename)
+	return int64(len(content)), nil
 }
 
-func printSitemapFinalStats(cfg Config) {
-	elapsed := time.Since(sitemapStart)
+func (s *sitemapGenerator) printSitemapFinalStats(cfg Config) {
+	elapsed := time.Since(s.startTime)
 
 	urlCount := 0
-	sitemapURLs.Range(func(key, value interface{}) bool {
+	s.urls.Range(func(key, value interface{}) bool {
 		urlCount++
 		return true
 	})
@@ -615,15 +866,30 @@ func printSitemapFinalStats(cfg Config) {
 	fmt.Println("║                                                                   ║")
 	fmt.Printf("║  ⏱️  Total Time:           %-40s ║\n", formatDuration(elapsed))
 	fmt.Printf("║  📄 URLs in Sitemap:       %-40d ║\n", urlCount)
-	fmt.Printf("║  🔍 Pages Checked:         %-40d ║\n", sitemapStats.PagesChecked)
-	fmt.Printf("║  ❌ Errors:                %-40d ║\n", sitemapStats.ErrorCount)
-	fmt.Printf("║  🛡️  Blocked:               %-40d ║\n", sitemapStats.BlockedCount)
-	fmt.Printf("║  ⏭️  Skipped (filtered):    %-40d ║\n", sitemapStats.SkippedCount)
+	fmt.Printf("║  🔍 Pages Checked:         %-40d ║\n", s.stats.PagesChecked)
+	fmt.Printf("║  ❌ Errors:                %-40d ║\n", s.stats.ErrorCount)
+	fmt.Printf("║  🛡️  Blocked:               %-40d ║\n", s.stats.BlockedCount)
+	fmt.Printf("║  ⏭️  Skipped (filtered):    %-40d ║\n", s.stats.SkippedCount)
+	fmt.Printf("║  🙈 Excluded (noindex):    %-40d ║\n", s.stats.NoIndexCount)
+	fmt.Printf("║  🔗 Canonical Aliases:     %-40d ║\n", s.stats.CanonicalAliases)
+	if s.config.MaxDuration > 0 {
+		fmt.Printf("║  ⌛ Skipped (Time Limit):  %-40d ║\n", s.stats.SkippedTimeLimit)
+	}
+	if s.c.config.MaxTotalBytes > 0 {
+		fmt.Printf("║  💸 Skipped (Budget):      %-40d ║\n", s.stats.SkippedBudget)
+	}
 	fmt.Println("║                                                                   ║")
 	fmt.Println("╠═══════════════════════════════════════════════════════════════════╣")
 	fmt.Println("║                      📁 OUTPUT FILE                               ║")
 	fmt.Println("╠═══════════════════════════════════════════════════════════════════╣")
-	fmt.Printf("║  📄 Filename:              %-40s ║\n", cfg.SitemapOpts.Filename)
+	outputFile := cfg.SitemapOpts.Filename
+	if len(s.files) > 0 {
+		outputFile = s.files[0]
+	}
+	fmt.Printf("║  📄 Filename:              %-40s ║\n", truncateString(outputFile, 40))
+	if len(s.files) > 1 {
+		fmt.Printf("║  🗂️  Sitemap Files:         %-40d ║\n", len(s.files)-1)
+	}
 	fmt.Printf("║  📅 Change Frequency:      %-40s ║\n", cfg.SitemapOpts.ChangeFreq)
 	fmt.Printf("║  ⭐ Priority:              %-40.1f ║\n", cfg.SitemapOpts.Priority)
 	includeLastMod := "No"
@@ -643,7 +909,20 @@ func printSitemapFinalStats(cfg Config) {
 		fmt.Println("      - Try with a different path filter")
 	}
 
-	pagesPerSec := float64(sitemapStats.PagesChecked) / elapsed.Seconds()
+	if atomic.LoadInt32(&s.c.timedOut) == 1 {
+		fmt.Println()
+		fmt.Printf("⚠️  WARNING: Time limit of %s reached - checked %d of %d discovered pages (%.1f%%)\n",
+			formatDuration(s.config.MaxDuration), s.stats.PagesChecked, s.stats.PagesChecked+s.stats.SkippedTimeLimit,
+			coverage(s.stats.PagesChecked, s.stats.SkippedTimeLimit))
+		fmt.Println("   💡 Raise the time limit or add a path filter to cover the rest of the site")
+	}
+	if s.c.outOfBudget() {
+		fmt.Println()
+		fmt.Printf("⚠️  WARNING: Download budget of %s used up - %d discovered pages were not checked\n", formatBytes(s.c.config.MaxTotalBytes), s.stats.SkippedBudget)
+		fmt.Println("   💡 Raise the budget or add a path filter to cover the rest of the site")
+	}
+
+	pagesPerSec := float64(s.stats.PagesChecked) / elapsed.Seconds()
 	fmt.Println()
 	fmt.Printf("⚡ Performance: %.2f pages/second\n", pagesPerSec)
 }
\ No newline at end of file
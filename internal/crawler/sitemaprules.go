@@ -0,0 +1,91 @@
+package crawler
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// SitemapRule overrides priority and/or change frequency for URLs whose path
+// matches Pattern. Patterns use robots.txt syntax: a path prefix where '*'
+// matches anything and a trailing '$' anchors the end ("/$" is the homepage).
+type SitemapRule struct {
+	Pattern    string
+	Priority   float64 // -1 keeps the default priority
+	ChangeFreq string  // "" keeps the default change frequency
+}
+
+var validChangeFreqs = map[string]bool{
+	"always": true, "hourly": true, "daily": true, "weekly": true,
+	"monthly": true, "yearly": true, "never": true,
+}
+
+// ParseSitemapRules parses a comma-separated rule list such as
+// "/$=1.0:daily, /blog/=0.8:weekly, /legal/=0.2:yearly". Either the priority
+// or the change frequency may be omitted ("/news/=:hourly", "/about=0.7").
+func ParseSitemapRules(s string) ([]SitemapRule, error) {
+	var rules []SitemapRule
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		pattern, settings, ok := strings.Cut(part, "=")
+		pattern = strings.TrimSpace(pattern)
+		if !ok || !strings.HasPrefix(pattern, "/") {
+			return nil, fmt.Errorf("rule %q must look like /path/=priority:changefreq", part)
+		}
+
+		rule := SitemapRule{Pattern: pattern, Priority: -1}
+		priority, freq, _ := strings.Cut(settings, ":")
+
+		if priority = strings.TrimSpace(priority); priority != "" {
+			p, err := strconv.ParseFloat(priority, 64)
+			if err != nil || p < 0 || p > 1 {
+				return nil, fmt.Errorf("rule %q: priority must be between 0.0 and 1.0", part)
+			}
+			rule.Priority = p
+		}
+
+		if freq = strings.ToLower(strings.TrimSpace(freq)); freq != "" {
+			if !validChangeFreqs[freq] {
+				return nil, fmt.Errorf("rule %q: unknown change frequency %q", part, freq)
+			}
+			rule.ChangeFreq = freq
+		}
+
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// applySitemapRules sets the priority and change frequency for one URL from
+// the first matching rule, falling back to the global defaults
+func applySitemapRules(entry *SitemapURL, opts SitemapOptions) {
+	entry.Priority = opts.Priority
+	entry.ChangeFreq = opts.ChangeFreq
+
+	u, err := url.Parse(entry.Loc)
+	if err != nil {
+		return
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	for _, rule := range opts.Rules {
+		if !robotsMatch(rule.Pattern, path) {
+			continue
+		}
+		if rule.Priority >= 0 {
+			entry.Priority = rule.Priority
+		}
+		if rule.ChangeFreq != "" {
+			entry.ChangeFreq = rule.ChangeFreq
+		}
+		return
+	}
+}
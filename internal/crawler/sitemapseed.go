@@ -0,0 +1,181 @@
+package crawler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+)
+
+// sitemapDocument matches both <urlset> and <sitemapindex> documents
+type sitemapDocument struct {
+	XMLName  xml.Name
+	URLs     []sitemapLoc `xml:"url"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+type sitemapLoc struct {
+	Loc string `xml:"loc"`
+}
+
+// maxSitemapFiles caps how many sitemap files are read from nested indexes
+const maxSitemapFiles = 1000
+
+// loadSitemapSeeds returns every page URL listed in the site's sitemap.
+// If sitemapURL is empty, /sitemap.xml and /sitemap_index.xml on the start
+// URL's host are tried. Sitemap index files are followed recursively and
+// gzipped sitemaps are decompressed transparently.
+func (c *Crawler) loadSitemapSeeds(ctx context.Context, startURL, sitemapURL string) ([]string, error) {
+	candidates := []string{sitemapURL}
+	if sitemapURL == "" {
+		base, err := url.Parse(startURL)
+		if err != nil {
+			return nil, err
+		}
+		root := base.Scheme + "://" + base.Host
+		candidates = []string{root + "/sitemap.xml", root + "/sitemap_index.xml"}
+	}
+
+	var lastErr error
+	for _, candidate := range candidates {
+		seen := make(map[string]bool)
+		urls, err := c.readSitemap(ctx, candidate, seen)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if len(urls) > 0 {
+			return urls, nil
+		}
+	}
+
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return nil, fmt.Errorf("no URLs found in sitemap")
+}
+
+// readSitemap fetches one sitemap file and returns its page URLs, descending
+// into child sitemaps when the file is a sitemap index
+func (c *Crawler) readSitemap(ctx context.Context, sitemapURL string, seen map[string]bool) ([]string, error) {
+	if seen[sitemapURL] || len(seen) >= maxSitemapFiles {
+		return nil, nil
+	}
+	seen[sitemapURL] = true
+
+	body, err := c.fetchSitemapBody(ctx, sitemapURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc sitemapDocument
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("invalid sitemap XML at %s: %v", sitemapURL, err)
+	}
+
+	var urls []string
+	for _, u := range doc.URLs {
+		if loc := strings.TrimSpace(u.Loc); loc != "" {
+			urls = append(urls, loc)
+		}
+	}
+
+	for _, child := range doc.Sitemaps {
+		loc := strings.TrimSpace(child.Loc)
+		if loc == "" {
+			continue
+		}
+		childURLs, err := c.readSitemap(ctx, loc, seen)
+		if err != nil {
+			// A single broken child sitemap shouldn't abort the whole index
+			c.log.Warn(fmt.Sprintf("   ⚠️  Skipping sitemap %s: %v", truncateString(loc, 60), err), "url", loc, "error", err)
+			continue
+		}
+		urls = append(urls, childURLs...)
+	}
+
+	return urls, nil
+}
+
+func (c *Crawler) fetchSitemapBody(ctx context.Context, sitemapURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", sitemapURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", c.fingerprint.userAgent)
+	req.Header.Set("Accept", "application/xml,text/xml,*/*;q=0.8")
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Handle both Content-Encoding: gzip and .xml.gz files served as-is
+	if len(body) >= 2 && body[0] == 0x1f && body[1] == 0x8b {
+		gzReader, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		defer gzReader.Close()
+		return io.ReadAll(gzReader)
+	}
+
+	return body, nil
+}
+
+// sitemapSeedsForCrawl loads the sitemap seeds for the crawl and keeps only URLs
+// within the crawl's domains. It returns nil (after printing why) when the sitemap
+// can't be used, so callers can fall back to link discovery.
+func (c *Crawler) sitemapSeedsForCrawl(ctx context.Context) []string {
+	cfg := c.config
+	c.log.Info("🗺️  Loading URLs from sitemap...")
+
+	urls, err := c.loadSitemapSeeds(ctx, cfg.StartURL, cfg.SitemapSeedURL)
+	if err != nil {
+		c.log.Warn(fmt.Sprintf("   ⚠️  Could not read sitemap (%v) - falling back to link discovery\n", err), "error", err)
+		return nil
+	}
+
+	var seeds []string
+	for _, link := range urls {
+		u, err := url.Parse(link)
+		if err != nil || !c.scope.contains(u) {
+			continue
+		}
+		if !underPath(u, c.config.PathFilter) {
+			atomic.AddInt64(&c.stats.SkippedPath, 1)
+			continue
+		}
+		if !c.filter.allowed(u) {
+			atomic.AddInt64(&c.stats.SkippedPattern, 1)
+			continue
+		}
+		seeds = append(seeds, link)
+	}
+
+	if len(seeds) == 0 {
+		c.log.Warn("   ⚠️  Sitemap has no URLs on the crawled domains - falling back to link discovery\n")
+		return nil
+	}
+
+	c.log.Info(fmt.Sprintf("   ✅ %d URLs loaded from sitemap\n", len(seeds)), "count", len(seeds))
+	return seeds
+}
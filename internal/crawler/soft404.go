@@ -0,0 +1,129 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// defaultSoft404Titles are the words in a <title> that mark a page as a
+// "not found" page even though it was served with a 200
+var defaultSoft404Titles = []string{"not found", "404", "does not exist", "doesn't exist", "no longer available", "page missing", "page unavailable"}
+
+// soft404PathRe matches the path of a generic error page a missing URL
+// redirects to, such as /404, /not-found or /errors/page-not-found.html
+var soft404PathRe = regexp.MustCompile(`(?i)(^|[/_.-])(404|not-?found|page-?not-?found|error)([/_.-]|$)`)
+
+// soft404Checker recognizes pages that say "not found" with a 200 status,
+// for broken-link mode when Config.DetectSoft404 is set
+type soft404Checker struct {
+	title []*regexp.Regexp // matched against the <title>
+	body  []*regexp.Regexp // matched against the page's visible text
+}
+
+// compileSoft404Pattern turns a Config.Soft404Patterns entry into a matcher:
+// text is found anywhere, ignoring case, and re: starts a regular expression
+func compileSoft404Pattern(p string) (*regexp.Regexp, error) {
+	if expr, ok := strings.CutPrefix(p, "re:"); ok {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid soft 404 regex %s: %v", p, err)
+		}
+		return re, nil
+	}
+	return regexp.MustCompile("(?i)" + regexp.QuoteMeta(p)), nil
+}
+
+// newSoft404Checker builds the checker for Config.Soft404Patterns, which are
+// looked for in the title and the text, on top of the default title words
+func newSoft404Checker(patterns []string) (*soft404Checker, error) {
+	s := &soft404Checker{}
+	for _, words := range defaultSoft404Titles {
+		s.title = append(s.title, regexp.MustCompile("(?i)\\b"+regexp.QuoteMeta(words)+"\\b"))
+	}
+	for _, p := range patterns {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		re, err := compileSoft404Pattern(p)
+		if err != nil {
+			return nil, err
+		}
+		s.title = append(s.title, re)
+		s.body = append(s.body, re)
+	}
+	return s, nil
+}
+
+// ValidateSoft404Patterns reports the first invalid entry in a soft 404
+// pattern list
+func ValidateSoft404Patterns(raw []string) error {
+	_, err := newSoft404Checker(raw)
+	return err
+}
+
+// reason returns why a page fetched from link looks like a "not found" page,
+// or "" if it doesn't. final is where redirects ended up.
+func (s *soft404Checker) reason(link string, final *url.URL, page *htmlPage) string {
+	if from, err := url.Parse(link); err == nil && final != nil && final.Path != from.Path && soft404PathRe.MatchString(final.Path) {
+		return fmt.Sprintf("redirects to %s", final.Path)
+	}
+	if title := page.title(); title != "" {
+		for _, re := range s.title {
+			if re.MatchString(title) {
+				return fmt.Sprintf("title %q", title)
+			}
+		}
+	}
+	if len(s.body) > 0 {
+		text := page.text()
+		for _, re := range s.body {
+			if m := re.FindString(text); m != "" {
+				return fmt.Sprintf("page says %q", m)
+			}
+		}
+	}
+	return ""
+}
+
+// noteSoft404 records whether a page the crawl fetched looks like a "not
+// found" page, so links to it needn't be fetched again
+func (c *Crawler) noteSoft404(link string, final *url.URL, page *htmlPage) {
+	c.soft404Pages.Store(c.getVisitedKey(link), c.soft404.reason(link, final, page))
+}
+
+// checkSoft404s looks for "not found" pages among the pages links lead to
+// that answered with a 2xx status. Pages the crawl fetched are judged by
+// what it read; the rest are fetched once here.
+func (c *Crawler) checkSoft404s(ctx context.Context) {
+	var pages []string
+	c.linkResults.Range(func(key, value any) bool {
+		if result := value.(*linkResult); result.kind == resourcePage && result.checked && !result.broken() {
+			pages = append(pages, key.(string))
+		}
+		return true
+	})
+
+	c.forEachLinked(ctx, pages, func(page string) {
+		var reason string
+		if value, ok := c.soft404Pages.Load(c.getVisitedKey(page)); ok {
+			reason = value.(string)
+		} else if _, final, body := c.fetchLinked(ctx, page, "text/html"); body != nil {
+			reason = c.soft404.reason(page, final, newHTMLPage(body))
+		}
+		if reason == "" {
+			return
+		}
+
+		value, _ := c.linkResults.Load(page)
+		result := value.(*linkResult)
+		result.soft404 = reason
+		result.errMsg, result.method = "soft 404: "+reason, "GET"
+		atomic.AddInt64(&c.stats.Soft404s, 1)
+		c.log.Info(fmt.Sprintf("💔 SOFT 404: %s (%s)", page, reason), "url", page, "reason", reason)
+		c.noteBreakage("broken_link", page, fmt.Sprintf("soft 404 (%s), linked from %s", reason, strings.Join(result.referrers(), ", ")))
+	})
+}
@@ -0,0 +1,221 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"sort"
+
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/page"
+)
+
+const (
+	// Chrome can't capture more than 16384 device pixels in one go, so
+	// longer pages are shot in tiles of this height and stitched together
+	screenshotTileHeight = 8192
+	// Pages up to this tall are shot in one go, encoded by Chrome
+	screenshotSingleShot = 16383
+	// Pages longer than this many device pixels are cut off, which keeps
+	// endlessly growing pages from producing unopenable images
+	maxScreenshotHeight = 200000
+
+	defaultImageQuality = 85 // JPEG and WebP
+)
+
+// ImageFormat is the file format of screenshots
+type ImageFormat int
+
+const (
+	ImagePNG ImageFormat = iota + 1
+	ImageJPEG
+	ImageWebP
+)
+
+func (f ImageFormat) String() string {
+	switch f {
+	case ImageJPEG:
+		return "JPEG"
+	case ImageWebP:
+		return "WebP"
+	default:
+		return "PNG"
+	}
+}
+
+// Ext is the file extension for the format, without the dot
+func (f ImageFormat) Ext() string {
+	switch f {
+	case ImageJPEG:
+		return "jpg"
+	case ImageWebP:
+		return "webp"
+	default:
+		return "png"
+	}
+}
+
+// maxHeight is the tallest image the format can hold, in pixels
+func (f ImageFormat) maxHeight() float64 {
+	switch f {
+	case ImageJPEG:
+		return 65535
+	case ImageWebP:
+		return 16383
+	default:
+		return maxScreenshotHeight
+	}
+}
+
+func (f ImageFormat) cdp() page.CaptureScreenshotFormat {
+	switch f {
+	case ImageJPEG:
+		return page.CaptureScreenshotFormatJpeg
+	case ImageWebP:
+		return page.CaptureScreenshotFormatWebp
+	default:
+		return page.CaptureScreenshotFormatPng
+	}
+}
+
+// imageOutput is how a screenshot is encoded: its format, its JPEG/WebP
+// quality, and how much it's scaled from the device's resolution
+type imageOutput struct {
+	format  ImageFormat
+	quality int
+	scale   float64
+}
+
+// captureFullPage resizes the viewport to the whole page and screenshots it.
+// width and height are in CSS pixels and dpr is the device pixel ratio, so
+// the image is width × dpr × out.scale pixels wide. It returns the format the
+// image was actually saved in - JPEG instead of WebP, or PNG instead of JPEG,
+// when the page is too tall for the format - and the number of CSS pixels
+// captured, which is less than height only when the page was longer than
+// maxScreenshotHeight.
+func captureFullPage(ctx context.Context, width, height int64, dpr float64, mobile bool, out imageOutput) ([]byte, ImageFormat, int64, error) {
+	if out.scale <= 0 {
+		out.scale = 1
+	}
+	pixels := dpr * out.scale // image pixels per CSS pixel
+	if limit := int64(maxScreenshotHeight / pixels); height > limit {
+		height = limit
+	}
+	format := out.format
+	if format == ImageWebP && float64(height)*pixels > ImageWebP.maxHeight() {
+		format = ImageJPEG
+	}
+	if format == ImageJPEG && float64(height)*pixels > ImageJPEG.maxHeight() {
+		format = ImagePNG
+	}
+
+	err := emulation.SetDeviceMetricsOverride(width, height, dpr, mobile).
+		WithScreenOrientation(&emulation.ScreenOrientation{
+			Type:  emulation.OrientationTypePortraitPrimary,
+			Angle: 0,
+		}).Do(ctx)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	clip := func(y, h int64) *page.Viewport {
+		return &page.Viewport{X: 0, Y: float64(y), Width: float64(width), Height: float64(h), Scale: out.scale}
+	}
+
+	if float64(height)*pixels <= screenshotSingleShot {
+		shot := page.CaptureScreenshot().
+			WithFormat(format.cdp()).
+			WithClip(clip(0, height)).
+			WithFromSurface(true).
+			WithCaptureBeyondViewport(true)
+		if format != ImagePNG {
+			shot = shot.WithQuality(int64(out.quality))
+		}
+		buf, err := shot.Do(ctx)
+		return buf, format, height, err
+	}
+
+	// Tiles are always PNG, so stitching doesn't compress them twice
+	var tiles [][]byte
+	tileHeight := int64(screenshotTileHeight / pixels)
+	for y := int64(0); y < height; y += tileHeight {
+		tile, err := page.CaptureScreenshot().
+			WithClip(clip(y, min(tileHeight, height-y))).
+			WithFromSurface(true).
+			WithCaptureBeyondViewport(true).
+			Do(ctx)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		tiles = append(tiles, tile)
+	}
+
+	buf, err := stitchTiles(tiles, format, out.quality)
+	return buf, format, height, err
+}
+
+// stitchTiles stacks PNG tiles of the same width into one PNG or JPEG
+func stitchTiles(tiles [][]byte, format ImageFormat, quality int) ([]byte, error) {
+	img := &tiledImage{tiles: tiles, cur: -1}
+	for _, tile := range tiles {
+		cfg, err := png.DecodeConfig(bytes.NewReader(tile))
+		if err != nil {
+			return nil, err
+		}
+		img.tops = append(img.tops, img.height)
+		img.height += cfg.Height
+		img.width = max(img.width, cfg.Width)
+	}
+
+	var buf bytes.Buffer
+	var err error
+	if format == ImageJPEG {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if img.err != nil {
+		return nil, img.err
+	}
+	return buf.Bytes(), nil
+}
+
+// tiledImage is PNG tiles stacked top to bottom. Tiles are decoded one at a
+// time as the encoder works down the rows, so a very long page never has to
+// be held in memory decoded all at once.
+type tiledImage struct {
+	tiles  [][]byte
+	tops   []int // first row of each tile
+	width  int
+	height int
+	cur    int // index of the decoded tile
+	img    image.Image
+	err    error
+}
+
+func (t *tiledImage) ColorModel() color.Model { return color.RGBAModel }
+
+func (t *tiledImage) Bounds() image.Rectangle { return image.Rect(0, 0, t.width, t.height) }
+
+func (t *tiledImage) At(x, y int) color.Color {
+	if t.err != nil {
+		return color.RGBA{}
+	}
+	if t.cur < 0 || y < t.tops[t.cur] || (t.cur+1 < len(t.tops) && y >= t.tops[t.cur+1]) {
+		i := sort.SearchInts(t.tops, y+1) - 1
+		img, err := png.Decode(bytes.NewReader(t.tiles[i]))
+		if err != nil {
+			t.err = err
+			return color.RGBA{}
+		}
+		t.cur, t.img = i, img
+	}
+	b := t.img.Bounds()
+	return t.img.At(b.Min.X+x, b.Min.Y+y-t.tops[t.cur])
+}
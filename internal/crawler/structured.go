@@ -0,0 +1,333 @@
+package crawler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// StructuredItem is one schema.org entity found on a page, written as a line
+// of the structured data JSONL file
+type StructuredItem struct {
+	URL       string         `json:"url"`
+	FoundOn   string         `json:"found_on,omitempty"` // page the crawl found URL on
+	Format    string         `json:"format"`             // "json-ld" or "microdata"
+	Type      string         `json:"type,omitempty"`
+	Missing   []string       `json:"missing,omitempty"` // required properties that are absent or empty
+	Error     string         `json:"error,omitempty"`   // why a JSON-LD block couldn't be read
+	Data      map[string]any `json:"data,omitempty"`
+	Timestamp string         `json:"timestamp"`
+}
+
+// structuredDataColumns are the results database columns for StructuredItem,
+// with Missing joined by commas and Data as JSON
+var structuredDataColumns = []string{"URL", "FoundOn", "Format", "Type", "Missing", "Error", "Data", "Timestamp"}
+
+// requiredProperties lists the properties checked for each supported type.
+// A "|" separates alternatives, any one of which is enough.
+var requiredProperties = map[string][]string{
+	"Article":      {"headline", "author", "datePublished", "image"},
+	"Product":      {"name", "offers|review|aggregateRating"},
+	"Organization": {"name", "url"},
+}
+
+// structuredBaseTypes maps common subtypes to the type whose required
+// properties apply to them
+var structuredBaseTypes = map[string]string{
+	"Article": "Article", "NewsArticle": "Article", "BlogPosting": "Article",
+	"TechArticle": "Article", "ScholarlyArticle": "Article", "Report": "Article",
+	"Product": "Product", "ProductGroup": "Product",
+	"Organization": "Organization", "Corporation": "Organization", "NGO": "Organization",
+	"EducationalOrganization": "Organization", "GovernmentOrganization": "Organization",
+	"NewsMediaOrganization": "Organization", "OnlineStore": "Organization",
+}
+
+// extractStructuredData returns the JSON-LD and microdata entities on a page
+func extractStructuredData(doc *html.Node, pageURL string) []StructuredItem {
+	if doc == nil {
+		return nil
+	}
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil
+	}
+
+	var items []StructuredItem
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch {
+			case n.Data == "script" && strings.EqualFold(strings.TrimSpace(attrValue(n, "type")), "application/ld+json"):
+				if n.FirstChild != nil {
+					items = append(items, parseJSONLD(n.FirstChild.Data, pageURL)...)
+				}
+			case hasAttr(n, "itemscope") && !hasAttr(n, "itemprop"):
+				// Top-level microdata item; items with itemprop belong to
+				// the item around them
+				data := microdataItem(n, base)
+				items = append(items, StructuredItem{URL: pageURL, Format: "microdata", Type: schemaTypeName(data["@type"]), Data: data})
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(doc)
+
+	for i := range items {
+		if items[i].Error == "" {
+			items[i].Missing = missingProperties(items[i].Type, items[i].Data)
+		}
+	}
+	return items
+}
+
+// parseJSONLD reads a JSON-LD block, which may hold one entity, an array of
+// them, or an @graph
+func parseJSONLD(raw, pageURL string) []StructuredItem {
+	var parsed any
+	if err := json.Unmarshal([]byte(strings.TrimSpace(raw)), &parsed); err != nil {
+		return []StructuredItem{{URL: pageURL, Format: "json-ld", Error: fmt.Sprintf("invalid JSON: %v", err)}}
+	}
+
+	var entities []map[string]any
+	var collect func(any)
+	collect = func(v any) {
+		switch v := v.(type) {
+		case []any:
+			for _, e := range v {
+				collect(e)
+			}
+		case map[string]any:
+			if graph, ok := v["@graph"]; ok {
+				collect(graph)
+				return
+			}
+			entities = append(entities, v)
+		}
+	}
+	collect(parsed)
+
+	items := make([]StructuredItem, 0, len(entities))
+	for _, e := range entities {
+		items = append(items, StructuredItem{URL: pageURL, Format: "json-ld", Type: schemaTypeName(e["@type"]), Data: e})
+	}
+	return items
+}
+
+// microdataItem converts an itemscope element and its itemprop descendants
+// into the same shape as JSON-LD. URL properties are resolved against base.
+func microdataItem(scope *html.Node, base *url.URL) map[string]any {
+	data := map[string]any{}
+	if itemType := strings.Fields(attrValue(scope, "itemtype")); len(itemType) > 0 {
+		data["@type"] = itemType[0]
+	}
+	if id := attrValue(scope, "itemid"); id != "" {
+		data["@id"] = id
+	}
+
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			props := strings.Fields(attrValue(c, "itemprop"))
+			var value any
+			if len(props) > 0 {
+				if hasAttr(c, "itemscope") {
+					value = microdataItem(c, base)
+				} else {
+					value = microdataValue(c, base)
+				}
+			}
+			for _, prop := range props {
+				switch existing := data[prop].(type) {
+				case nil:
+					data[prop] = value
+				case []any:
+					data[prop] = append(existing, value)
+				default:
+					data[prop] = []any{existing, value}
+				}
+			}
+			// A nested item's properties belong to it, not to this item
+			if !hasAttr(c, "itemscope") {
+				f(c)
+			}
+		}
+	}
+	f(scope)
+	return data
+}
+
+// microdataValue returns an itemprop's value, which comes from an attribute
+// for some elements and from the text for the rest
+func microdataValue(n *html.Node, base *url.URL) string {
+	if v, ok := attrLookup(n, "content"); ok {
+		return strings.TrimSpace(v)
+	}
+	resolve := func(ref string) string {
+		if u, err := url.Parse(strings.TrimSpace(ref)); err == nil && ref != "" {
+			return base.ResolveReference(u).String()
+		}
+		return ref
+	}
+	switch n.Data {
+	case "a", "area", "link":
+		return resolve(attrValue(n, "href"))
+	case "img", "audio", "embed", "iframe", "source", "track", "video":
+		return resolve(attrValue(n, "src"))
+	case "object":
+		return resolve(attrValue(n, "data"))
+	case "data", "meter":
+		return attrValue(n, "value")
+	case "time":
+		if v := attrValue(n, "datetime"); v != "" {
+			return v
+		}
+	}
+	return strings.Join(strings.Fields(nodeText(n)), " ")
+}
+
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+			b.WriteByte(' ')
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(n)
+	return b.String()
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	_, ok := attrLookup(n, key)
+	return ok
+}
+
+// schemaTypeName reduces an @type or itemtype to its schema.org name, so
+// "https://schema.org/NewsArticle" and "schema:NewsArticle" are "NewsArticle".
+// Entities with several types are named by all of them.
+func schemaTypeName(t any) string {
+	switch t := t.(type) {
+	case string:
+		t = strings.TrimSuffix(strings.TrimSpace(t), "/")
+		if i := strings.LastIndexAny(t, "/:#"); i >= 0 {
+			t = t[i+1:]
+		}
+		return t
+	case []any:
+		names := make([]string, 0, len(t))
+		for _, v := range t {
+			if name := schemaTypeName(v); name != "" {
+				names = append(names, name)
+			}
+		}
+		return strings.Join(names, ",")
+	}
+	return ""
+}
+
+// missingProperties returns the required properties of the entity's type
+// that are absent or empty
+func missingProperties(typeName string, data map[string]any) []string {
+	var missing []string
+	checked := map[string]bool{}
+	for _, t := range strings.Split(typeName, ",") {
+		base := structuredBaseTypes[t]
+		if base == "" || checked[base] {
+			continue
+		}
+		checked[base] = true
+		for _, required := range requiredProperties[base] {
+			present := false
+			for _, prop := range strings.Split(required, "|") {
+				if !emptyValue(data[prop]) {
+					present = true
+					break
+				}
+			}
+			if !present {
+				missing = append(missing, strings.ReplaceAll(required, "|", " or "))
+			}
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+func emptyValue(v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(v) == ""
+	case []any:
+		for _, e := range v {
+			if !emptyValue(e) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		return len(v) == 0
+	}
+	return false
+}
+
+// processStructuredData extracts a page's structured data and appends each
+// entity to the JSONL results file
+func (c *Crawler) processStructuredData(pageURL, foundOn string, doc *html.Node) {
+	items := extractStructuredData(doc, pageURL)
+	if len(items) == 0 || c.skipIgnored(ModeStructuredData, pageURL) {
+		return
+	}
+	atomic.AddInt64(&c.stats.PagesWithStructuredData, 1)
+
+	for _, item := range items {
+		atomic.AddInt64(&c.stats.MatchesFound, 1)
+		switch {
+		case item.Error != "":
+			atomic.AddInt64(&c.stats.StructuredDataInvalid, 1)
+			c.log.Info(fmt.Sprintf("🧩 INVALID JSON-LD: %s", pageURL), "url", pageURL, "error", item.Error)
+			c.notifyFinding("invalid_structured_data", pageURL, item.Error)
+		case len(item.Missing) > 0:
+			atomic.AddInt64(&c.stats.StructuredDataInvalid, 1)
+			c.log.Info(fmt.Sprintf("🧩 %s MISSING %s: %s", item.Type, strings.Join(item.Missing, ", "), pageURL), "url", pageURL, "type", item.Type, "missing", strings.Join(item.Missing, ", "))
+			c.notifyFinding("invalid_structured_data", pageURL, fmt.Sprintf("%s missing %s", item.Type, strings.Join(item.Missing, ", ")))
+		default:
+			c.log.Debug(fmt.Sprintf("   🧩 %s (%s): %s", item.Type, item.Format, pageURL), "url", pageURL, "type", item.Type, "format", item.Format)
+		}
+	}
+
+	var lines bytes.Buffer
+	enc := json.NewEncoder(&lines)
+	enc.SetEscapeHTML(false)
+	now := time.Now().Format(time.RFC3339)
+	for _, item := range items {
+		item.FoundOn, item.Timestamp = foundOn, now
+		enc.Encode(item)
+		if c.db != nil {
+			data := ""
+			if item.Data != nil {
+				b, _ := json.Marshal(item.Data)
+				data = string(b)
+			}
+			c.db.addResult(c.resultsTable(ModeStructuredData), []string{item.URL, item.FoundOn, item.Format, item.Type, strings.Join(item.Missing, ", "), item.Error, data, item.Timestamp})
+		}
+	}
+	c.out.write(ModeStructuredData, lines.Bytes())
+}
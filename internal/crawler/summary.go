@@ -0,0 +1,97 @@
+package crawler
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// summaryJSON is the JSON written to Config.SummaryFile. Stats holds the
+// mode's own statistics, with the same field names as the Go structs.
+type summaryJSON struct {
+	Mode            string   `json:"mode"`
+	StartURL        string   `json:"start_url"`
+	Started         string   `json:"started"`
+	DurationSeconds float64  `json:"duration_seconds"`
+	Complete        bool     `json:"complete"` // neither stopped early nor cut short by the time limit or download budget
+	TimedOut        bool     `json:"timed_out"`
+	OverBudget      bool     `json:"over_budget"`
+	Cancelled       bool     `json:"cancelled"`
+	PagesAnswered   int64    `json:"pages_answered"`
+	Findings        int64    `json:"findings"`
+	Outputs         []string `json:"outputs"`
+	Stats           any      `json:"stats"`
+}
+
+// Findings returns what the run found that needs looking into: matches,
+// broken links, oversized images and the other crawl modes' findings, or
+// pages that differ in visual diff mode. The capture, sitemap and feed
+// modes don't have findings.
+func (r Results) Findings() int64 {
+	switch r.Mode {
+	case ModePDFCapture, ModeListing, ModeSitemap, ModeJSONFeed:
+		return 0
+	case ModeVisualDiff:
+		return r.VisualDiffStats.PagesDifferent
+	}
+	return r.Stats.MatchesFound
+}
+
+// Answered returns how many pages (or feed items) the run got a response
+// for, whatever its status, which is 0 when the site couldn't be reached
+func (r Results) Answered() int64 {
+	switch r.Mode {
+	case ModePDFCapture, ModeListing:
+		return r.PDFStats.PagesVisited
+	case ModeSitemap:
+		return r.SitemapStats.PagesChecked
+	case ModeJSONFeed:
+		return r.JSONFeedStats.ItemsFetched
+	case ModeVisualDiff:
+		return r.VisualDiffStats.PagesCompared
+	}
+	s := r.Stats
+	return s.Status2xx + s.Status3xx + s.Status4xx + s.Status5xx
+}
+
+// writeSummary writes the run's results and final statistics as JSON to
+// Config.SummaryFile, so a CI job can read them without parsing the console
+func (c *Crawler) writeSummary() {
+	r := c.results
+	s := summaryJSON{
+		Mode:            r.Mode.String(),
+		StartURL:        c.config.StartURL,
+		Started:         c.startTime.Format(time.RFC3339),
+		DurationSeconds: r.Duration.Seconds(),
+		Complete:        !r.TimedOut && !r.Cancelled && !r.OverBudget,
+		TimedOut:        r.TimedOut,
+		OverBudget:      r.OverBudget,
+		Cancelled:       r.Cancelled,
+		PagesAnswered:   r.Answered(),
+		Findings:        r.Findings(),
+		Outputs:         r.outputPaths(),
+		Stats:           r.Stats,
+	}
+	switch r.Mode {
+	case ModePDFCapture, ModeListing:
+		s.Stats = r.PDFStats
+	case ModeSitemap:
+		s.Stats = r.SitemapStats
+	case ModeJSONFeed:
+		s.Stats = r.JSONFeedStats
+	case ModeVisualDiff:
+		s.Stats = r.VisualDiffStats
+	}
+	if s.Outputs == nil {
+		s.Outputs = []string{}
+	}
+
+	path := c.outputPath(c.config.SummaryFile)
+	data, _ := json.MarshalIndent(s, "", "  ")
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		c.log.Error(fmt.Sprintf("❌ Summary: %v", err), "path", path, "error", err)
+		return
+	}
+	c.results.SummaryPath = path
+}
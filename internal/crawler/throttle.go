@@ -0,0 +1,198 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// maxRetryAfter caps the wait a Retry-After header can ask for, so a
+	// server asking for an hour doesn't stall the crawl
+	maxRetryAfter = 5 * time.Minute
+	// maxBackoff caps the delay between retries of a page
+	maxBackoff = time.Minute
+	// throttleWindow is how many responses the adaptive throttle looks at
+	// before deciding whether to slow down or speed up
+	throttleWindow = 20
+	// defaultBlockRate is the share of blocked responses in a window that
+	// slows the crawl down when Config.BlockRateThreshold isn't set
+	defaultBlockRate = 0.2
+)
+
+// retryAfter returns how long a 429 or 503 response asks the client to wait,
+// from a Retry-After header in seconds or as an HTTP date, capped at
+// maxRetryAfter. It's 0 when there's no usable header.
+func retryAfter(resp *http.Response) time.Duration {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return 0
+	}
+	value := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	if value == "" {
+		return 0
+	}
+	var d time.Duration
+	if secs, err := strconv.Atoi(value); err == nil {
+		d = time.Duration(secs) * time.Second
+	} else if t, err := http.ParseTime(value); err == nil {
+		d = time.Until(t)
+	}
+	if d <= 0 {
+		return 0
+	}
+	return min(d, maxRetryAfter)
+}
+
+// backoffDelay is how long to wait before retry attempt n of a page: base
+// doubled for each earlier attempt, up to maxBackoff, give or take a quarter
+// so that pages which failed together don't all retry together
+func backoffDelay(base time.Duration, attempt int) time.Duration {
+	if base <= 0 || attempt <= 0 {
+		return 0
+	}
+	d := base
+	for i := 1; i < attempt && d < maxBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, maxBackoff)
+	return d - d/4 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// adaptiveThrottle lowers the number of pages fetched at once while the site
+// is blocking or rate-limiting the crawl, and raises it again once responses
+// come back clean: halved after a window of responses with too many blocks,
+// one more after each window without any. A nil throttle doesn't limit
+// anything.
+type adaptiveThrottle struct {
+	mu        sync.Mutex
+	max       int // Config.MaxConcurrency
+	limit     int // pages allowed at once right now
+	active    int
+	changed   chan struct{} // closed when active or limit changes
+	threshold float64
+	seen      int // responses in the current window
+	blocked   int
+}
+
+func newAdaptiveThrottle(maxConcurrency int, threshold float64) *adaptiveThrottle {
+	if threshold <= 0 {
+		threshold = defaultBlockRate
+	}
+	maxConcurrency = max(maxConcurrency, 1)
+	return &adaptiveThrottle{
+		max:       maxConcurrency,
+		limit:     maxConcurrency,
+		changed:   make(chan struct{}),
+		threshold: threshold,
+	}
+}
+
+// acquire waits until another page may be fetched. It returns false if ctx
+// was cancelled first.
+func (t *adaptiveThrottle) acquire(ctx context.Context) bool {
+	if t == nil {
+		return ctx.Err() == nil
+	}
+	for {
+		t.mu.Lock()
+		if t.active < t.limit {
+			t.active++
+			t.mu.Unlock()
+			return true
+		}
+		changed := t.changed
+		t.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// release gives back a slot taken by acquire
+func (t *adaptiveThrottle) release() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.active--
+	t.broadcast()
+}
+
+// broadcast wakes the pages waiting in acquire. The caller holds t.mu.
+func (t *adaptiveThrottle) broadcast() {
+	close(t.changed)
+	t.changed = make(chan struct{})
+}
+
+// record counts a response, and at the end of each window adjusts the
+// limit. It returns the limits before and after when they differ, and the
+// window's block rate.
+func (t *adaptiveThrottle) record(blocked bool) (from, to int, rate float64) {
+	if t == nil {
+		return 0, 0, 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.seen++
+	if blocked {
+		t.blocked++
+	}
+	if t.seen < throttleWindow {
+		return 0, 0, 0
+	}
+
+	rate = float64(t.blocked) / float64(t.seen)
+	from = t.limit
+	switch {
+	case rate > t.threshold:
+		t.limit = max(t.limit/2, 1)
+	case t.blocked == 0:
+		t.limit = min(t.limit+1, t.max)
+	}
+	t.seen, t.blocked = 0, 0
+	if t.limit == from {
+		return 0, 0, 0
+	}
+	t.broadcast()
+	return from, t.limit, rate
+}
+
+// noteResponse feeds a page response to the adaptive throttle and honors
+// its Retry-After header, holding back every request to the host until the
+// wait is over. It returns the wait, or 0 if the response didn't ask for one.
+func (c *Crawler) noteResponse(resp *http.Response, blocked bool) time.Duration {
+	if from, to, rate := c.throttle.record(blocked); from != to {
+		if to < from {
+			atomic.AddInt64(&c.stats.ThrottleSlowdowns, 1)
+			c.log.Warn(fmt.Sprintf("🐢 Slowing down: %d → %d pages at a time (%.0f%% of recent responses blocked or rate limited)", from, to, rate*100), "from", from, "to", to, "block_rate", rate)
+		} else {
+			c.log.Info(fmt.Sprintf("🐇 Speeding up: %d → %d pages at a time", from, to), "from", from, "to", to)
+		}
+	}
+	return c.honorRetryAfter(resp)
+}
+
+// honorRetryAfter holds back requests to a 429 or 503 response's host for
+// as long as its Retry-After header asks
+func (c *Crawler) honorRetryAfter(resp *http.Response) time.Duration {
+	wait := retryAfter(resp)
+	if wait == 0 {
+		return 0
+	}
+	host := resp.Request.URL.Host
+	if c.limiter.hold(host, time.Now().Add(wait)) {
+		atomic.AddInt64(&c.stats.RetryAfterWaits, 1)
+		c.log.Warn(fmt.Sprintf("⏳ %s asked for a pause of %s (Retry-After) - holding requests to it", host, formatDuration(wait)), "host", host, "wait", wait.String())
+	}
+	return wait
+}
@@ -0,0 +1,112 @@
+package crawler
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// TrafficStats totals the page fetches of one content type or section of
+// the site, for Stats.ByContentType and Stats.ByPath
+type TrafficStats struct {
+	Requests int64 // fetches, retries included
+	Bytes    int64 // bodies downloaded
+	Errors   int64 // fetches that failed or answered 4xx/5xx
+}
+
+// heaviestRows is how many sections and content types the final statistics
+// list
+const heaviestRows = 8
+
+// noteTraffic adds a page fetch to Stats.ByContentType and Stats.ByPath
+func (c *Crawler) noteTraffic(f fetchRecord) {
+	contentType := "none"
+	if f.Status != 0 {
+		contentType = "unknown"
+	}
+	if mediaType, _, _ := strings.Cut(f.ContentType, ";"); strings.TrimSpace(mediaType) != "" {
+		contentType = strings.ToLower(strings.TrimSpace(mediaType))
+	}
+	failed := f.Err != nil || f.Status >= 400
+
+	c.trafficMu.Lock()
+	defer c.trafficMu.Unlock()
+	if c.stats.ByContentType == nil {
+		c.stats.ByContentType = make(map[string]TrafficStats)
+		c.stats.ByPath = make(map[string]TrafficStats)
+	}
+	add := func(m map[string]TrafficStats, key string) {
+		t := m[key]
+		t.Requests++
+		t.Bytes += int64(f.Bytes)
+		if failed {
+			t.Errors++
+		}
+		m[key] = t
+	}
+	add(c.stats.ByContentType, contentType)
+	add(c.stats.ByPath, c.siteSection(f.URL))
+}
+
+// siteSection returns the top-level section a page is in: "/blog/" for
+// /blog/2024/post, and "/" for the home page and other pages at the top
+// level. Pages on hosts other than the start URL's are prefixed with their
+// host.
+func (c *Crawler) siteSection(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "/"
+	}
+	section := "/"
+	if first, _, nested := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/"); nested && first != "" {
+		section = "/" + first + "/"
+	}
+	if start, err := url.Parse(c.config.StartURL); err == nil && !strings.EqualFold(u.Host, start.Host) {
+		section = strings.ToLower(u.Host) + section
+	}
+	return section
+}
+
+// heaviest returns the keys of m, largest download first
+func heaviest(m map[string]TrafficStats) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if m[keys[i]].Bytes != m[keys[j]].Bytes {
+			return m[keys[i]].Bytes > m[keys[j]].Bytes
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// printTrafficTable prints the heaviest entries of a breakdown as rows of
+// the final statistics, with each one's share of the bytes downloaded
+func printTrafficTable(column string, m map[string]TrafficStats) {
+	var total int64
+	for _, t := range m {
+		total += t.Bytes
+	}
+
+	fmt.Printf("║  %-26s %7s %10s %6s %7s     ║\n", column, "Fetches", "Size", "Share", "Errors")
+	keys := heaviest(m)
+	for i, key := range keys {
+		if i == heaviestRows {
+			fmt.Printf("║  %-64s ║\n", fmt.Sprintf("... and %d more", len(keys)-heaviestRows))
+			break
+		}
+		t := m[key]
+		share := 0.0
+		if total > 0 {
+			share = float64(t.Bytes) / float64(total) * 100
+		}
+		if len(key) > 26 {
+			key = key[:23] + "..."
+		}
+		fmt.Printf("║  %-26s %7d %10s %5.1f%% %7d     ║\n", key, t.Requests, formatBytes(t.Bytes), share, t.Errors)
+	}
+	fmt.Println("║                                                                   ║")
+}
@@ -0,0 +1,381 @@
+package crawler
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// UploadOptions sends a run's output - capture folders, results CSVs,
+// sitemaps, reports and snapshots - to an S3-compatible or Google Cloud
+// Storage bucket when the run finishes, for crawls on ephemeral CI machines.
+// Files are written to the working directory first, then uploaded.
+//
+// S3 credentials come from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and, for
+// temporary credentials, AWS_SESSION_TOKEN. Google Cloud Storage uses the
+// access token in GOOGLE_OAUTH_ACCESS_TOKEN, or else asks gcloud for one.
+type UploadOptions struct {
+	URL         string // Bucket and optional folder, e.g. "s3://my-bucket/crawls" or "gs://my-bucket/crawls"
+	Endpoint    string // S3-compatible endpoint for MinIO, Cloudflare R2, DigitalOcean Spaces... (default: AWS_ENDPOINT_URL_S3, AWS_ENDPOINT_URL or AWS)
+	Region      string // S3 region (default: AWS_REGION, AWS_DEFAULT_REGION or us-east-1)
+	RemoveLocal bool   // Delete the local copies once everything is uploaded
+}
+
+// uploadConcurrency is how many files are uploaded at once
+const uploadConcurrency = 4
+
+// objectStore puts files in a bucket
+type objectStore interface {
+	put(ctx context.Context, key, localPath string) error
+	url(key string) string
+}
+
+// ValidateUploadURL checks an upload destination is an s3:// or gs:// URL
+// with a bucket
+func ValidateUploadURL(dest string) error {
+	_, _, _, err := parseUploadURL(dest)
+	return err
+}
+
+// parseUploadURL splits an upload destination into its scheme, bucket and
+// folder prefix (without slashes at either end)
+func parseUploadURL(dest string) (scheme, bucket, prefix string, err error) {
+	u, err := url.Parse(strings.TrimSpace(dest))
+	if err != nil || (u.Scheme != "s3" && u.Scheme != "gs") || u.Host == "" {
+		return "", "", "", fmt.Errorf("upload destination must look like s3://bucket/folder or gs://bucket/folder")
+	}
+	return u.Scheme, u.Host, strings.Trim(u.Path, "/"), nil
+}
+
+// newObjectStore sets up the bucket in opts, checking its credentials are
+// available before the crawl starts
+func newObjectStore(opts UploadOptions) (objectStore, string, error) {
+	scheme, bucket, prefix, err := parseUploadURL(opts.URL)
+	if err != nil {
+		return nil, "", err
+	}
+	client := &http.Client{Timeout: 10 * time.Minute}
+
+	if scheme == "gs" {
+		token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN")
+		if token == "" {
+			out, err := exec.Command("gcloud", "auth", "print-access-token").Output()
+			if err != nil {
+				return nil, "", fmt.Errorf("no Google Cloud credentials - set GOOGLE_OAUTH_ACCESS_TOKEN or sign in with gcloud auth login")
+			}
+			token = strings.TrimSpace(string(out))
+		}
+		return &gcsStore{client: client, bucket: bucket, token: token}, prefix, nil
+	}
+
+	s := &s3Store{
+		client:       client,
+		bucket:       bucket,
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		region:       firstNonEmpty(opts.Region, os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"), "us-east-1"),
+	}
+	if s.accessKey == "" || s.secretKey == "" {
+		return nil, "", fmt.Errorf("no S3 credentials - set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	if endpoint := firstNonEmpty(opts.Endpoint, os.Getenv("AWS_ENDPOINT_URL_S3"), os.Getenv("AWS_ENDPOINT_URL")); endpoint != "" {
+		if !strings.Contains(endpoint, "://") {
+			endpoint = "https://" + endpoint
+		}
+		if s.endpoint, err = url.Parse(strings.TrimRight(endpoint, "/")); err != nil || s.endpoint.Host == "" {
+			return nil, "", fmt.Errorf("invalid S3 endpoint %q", endpoint)
+		}
+	}
+	return s, prefix, nil
+}
+
+// s3Store uploads to S3 or an S3-compatible service with Signature V4
+type s3Store struct {
+	client       *http.Client
+	bucket       string
+	region       string
+	endpoint     *url.URL // nil for AWS; custom endpoints are addressed path-style
+	accessKey    string
+	secretKey    string
+	sessionToken string
+}
+
+// objectURL is where the object is PUT: virtual-hosted on AWS, path-style
+// on other endpoints, which don't all support bucket subdomains
+func (s *s3Store) objectURL(key string) *url.URL {
+	if s.endpoint == nil {
+		return &url.URL{Scheme: "https", Host: s.bucket + ".s3." + s.region + ".amazonaws.com", Path: "/" + key}
+	}
+	u := *s.endpoint
+	u.Path = strings.TrimRight(u.Path, "/") + "/" + s.bucket + "/" + key
+	return &u
+}
+
+func (s *s3Store) url(key string) string {
+	return "s3://" + s.bucket + "/" + key
+}
+
+func (s *s3Store) put(ctx context.Context, key, localPath string) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Signature V4 signs the payload's hash, so the file is read twice
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	payloadHash := hex.EncodeToString(h.Sum(nil))
+
+	u := s.objectURL(key)
+	req, err := http.NewRequestWithContext(ctx, "PUT", u.String(), f)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", uploadContentType(localPath))
+	s.sign(req, u, payloadHash, time.Now().UTC())
+
+	return doUpload(s.client, req)
+}
+
+// sign adds the AWS Signature V4 headers to req
+func (s *s3Store) sign(req *http.Request, u *url.URL, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+
+	headers := map[string]string{"host": u.Host}
+	for name, values := range req.Header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-amz-") || lower == "content-type" {
+			headers[lower] = strings.TrimSpace(values[0])
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s3EscapePath(u.Path),
+		"",
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + s.region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), day)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature))
+	// Send the path exactly as it was signed
+	req.URL.RawPath = s3EscapePath(u.Path)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3EscapePath percent-encodes an object path the way Signature V4 expects:
+// everything but unreserved characters and the slashes between segments
+func s3EscapePath(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		ch := p[i]
+		if ch == '/' || ch == '-' || ch == '_' || ch == '.' || ch == '~' ||
+			(ch >= 'A' && ch <= 'Z') || (ch >= 'a' && ch <= 'z') || (ch >= '0' && ch <= '9') {
+			b.WriteByte(ch)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+// gcsStore uploads to Google Cloud Storage through its JSON API
+type gcsStore struct {
+	client *http.Client
+	bucket string
+	token  string
+}
+
+func (g *gcsStore) url(key string) string {
+	return "gs://" + g.bucket + "/" + key
+}
+
+func (g *gcsStore) put(ctx context.Context, key, localPath string) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	endpoint := "https://storage.googleapis.com/upload/storage/v1/b/" + url.PathEscape(g.bucket) +
+		"/o?uploadType=media&name=" + url.QueryEscape(key)
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, f)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("Content-Type", uploadContentType(localPath))
+	req.Header.Set("Authorization", "Bearer "+g.token)
+
+	return doUpload(g.client, req)
+}
+
+// doUpload sends an upload request, returning the service's error message
+// when it's refused
+func doUpload(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("HTTP %d - %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// uploadContentType guesses a file's MIME type from its extension
+func uploadContentType(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".mhtml":
+		return "multipart/related"
+	case ".jsonl":
+		return "application/x-ndjson"
+	case ".gz":
+		return "application/gzip"
+	}
+	if t := mime.TypeByExtension(filepath.Ext(name)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// outputPaths lists the files and folders the run wrote
+func (r Results) outputPaths() []string {
+	var paths []string
+	seen := make(map[string]bool)
+	for _, p := range append([]string{r.OutputPath, r.SummaryPath, r.ReportPath, r.WorkbookPath, r.DatabasePath, r.FetchLogPath, r.CertAuditPath, r.LinkGraphPath, r.SnapshotPath, r.MirrorPath}, r.ExtraOutputs...) {
+		if p != "" && !seen[p] {
+			seen[p] = true
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// uploadResults uploads the run's output to Config.Upload's bucket, keeping
+// folder structure under the bucket's prefix
+func (c *Crawler) uploadResults(ctx context.Context) {
+	opts := c.config.Upload
+	store, prefix := c.store, c.storePrefix
+
+	type upload struct{ local, key string }
+	var uploads []upload
+	var roots []string
+	for _, root := range c.results.outputPaths() {
+		info, err := os.Stat(root)
+		if err != nil {
+			continue
+		}
+		roots = append(roots, root)
+		if !info.IsDir() {
+			uploads = append(uploads, upload{root, path.Join(prefix, filepath.ToSlash(filepath.Base(root)))})
+			continue
+		}
+		filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				rel, _ := filepath.Rel(filepath.Dir(root), p)
+				uploads = append(uploads, upload{p, path.Join(prefix, filepath.ToSlash(rel))})
+			}
+			return nil
+		})
+	}
+	if len(uploads) == 0 {
+		return
+	}
+
+	c.log.Info(fmt.Sprintf("☁️  Uploading %d files to %s...", len(uploads), opts.URL), "destination", opts.URL, "files", len(uploads))
+	var uploaded, failed int64
+	var wg sync.WaitGroup
+	sema := make(chan struct{}, uploadConcurrency)
+	for _, u := range uploads {
+		wg.Add(1)
+		sema <- struct{}{}
+		go func(u upload) {
+			defer wg.Done()
+			defer func() { <-sema }()
+			if err := store.put(ctx, u.key, u.local); err != nil {
+				atomic.AddInt64(&failed, 1)
+				c.log.Error(fmt.Sprintf("❌ Upload failed: %s - %v", u.local, err), "path", u.local, "destination", store.url(u.key), "error", err)
+				return
+			}
+			atomic.AddInt64(&uploaded, 1)
+			c.log.Debug("Uploaded", "path", u.local, "destination", store.url(u.key))
+		}(u)
+	}
+	wg.Wait()
+
+	c.results.UploadedFiles = int(uploaded)
+	if failed > 0 {
+		c.log.Warn(fmt.Sprintf("⚠️  Uploaded %d of %d files - local copies kept", uploaded, len(uploads)), "uploaded", uploaded, "failed", failed)
+		return
+	}
+	c.log.Info(fmt.Sprintf("☁️  Uploaded %d files to %s", uploaded, opts.URL), "destination", opts.URL, "files", uploaded)
+
+	if opts.RemoveLocal {
+		for _, root := range roots {
+			os.RemoveAll(root)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package crawler
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// urlFilter applies Config.IncludePatterns and ExcludePatterns to discovered
+// links. A pattern prefixed with re: (re:/page/\d+/?$) is a regular expression
+// searched anywhere in the URL; anything else is a glob matched against the
+// whole path and query, where * matches any run of characters (including /)
+// and ? matches one. Globs containing :// are matched against the full URL.
+type urlFilter struct {
+	include []urlPattern
+	exclude []urlPattern
+}
+
+type urlPattern struct {
+	re      *regexp.Regexp
+	fullURL bool
+}
+
+// compileURLPattern turns one include/exclude pattern into a matcher
+func compileURLPattern(p string) (urlPattern, error) {
+	if expr, ok := strings.CutPrefix(p, "re:"); ok {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return urlPattern{}, fmt.Errorf("invalid regex %s: %v", p, err)
+		}
+		return urlPattern{re: re, fullURL: true}, nil
+	}
+
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range p {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return urlPattern{re: regexp.MustCompile(b.String()), fullURL: strings.Contains(p, "://")}, nil
+}
+
+func compileURLPatterns(raw []string) ([]urlPattern, error) {
+	var patterns []urlPattern
+	for _, p := range raw {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		compiled, err := compileURLPattern(p)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, compiled)
+	}
+	return patterns, nil
+}
+
+// ValidateURLPatterns reports the first invalid entry in an include or
+// exclude pattern list
+func ValidateURLPatterns(raw []string) error {
+	_, err := compileURLPatterns(raw)
+	return err
+}
+
+func newURLFilter(include, exclude []string) (*urlFilter, error) {
+	inc, err := compileURLPatterns(include)
+	if err != nil {
+		return nil, err
+	}
+	exc, err := compileURLPatterns(exclude)
+	if err != nil {
+		return nil, err
+	}
+	return &urlFilter{include: inc, exclude: exc}, nil
+}
+
+// allowed reports whether u passes the filter: it must match an include
+// pattern (if any are set) and no exclude pattern
+func (f *urlFilter) allowed(u *url.URL) bool {
+	if f == nil {
+		return true
+	}
+	if len(f.include) > 0 && !matchAnyPattern(f.include, u) {
+		return false
+	}
+	return !matchAnyPattern(f.exclude, u)
+}
+
+// underPath reports whether u is in the section of the site Config.PathFilter
+// limits a crawl to: its path is prefix or starts with it. "/blog" and
+// "/blog/" both match /blog and everything under /blog/, but not /blogroll.
+// An empty prefix matches everything.
+func underPath(u *url.URL, prefix string) bool {
+	if prefix == "" {
+		return true
+	}
+	prefix = strings.TrimSuffix(prefix, "/") + "/"
+	path := u.Path
+	if !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+	return strings.HasPrefix(path, prefix)
+}
+
+func matchAnyPattern(patterns []urlPattern, u *url.URL) bool {
+	for _, p := range patterns {
+		target := u.RequestURI()
+		if p.fullURL {
+			target = u.String()
+		}
+		if p.re.MatchString(target) {
+			return true
+		}
+	}
+	return false
+}
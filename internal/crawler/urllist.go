@@ -0,0 +1,99 @@
+package crawler
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// loadURLList reads the pages to fetch from a plain text file (one URL per
+// line) or a CSV export, where the first cell in each row that looks like a
+// URL is used - so crawl results, analytics exports and spreadsheets work
+// as they are. Paths starting with / are resolved against base. Blank lines,
+// # comments, header rows and duplicates are skipped.
+func loadURLList(path string, base *url.URL) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rows [][]string
+	if strings.HasSuffix(strings.ToLower(path), ".csv") {
+		r := csv.NewReader(f)
+		r.FieldsPerRecord = -1
+		r.LazyQuotes = true
+		for {
+			row, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", path, err)
+			}
+			rows = append(rows, row)
+		}
+	} else {
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			rows = append(rows, []string{scanner.Text()})
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+
+	var urls []string
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		for _, cell := range row {
+			link, ok := listedURL(cell, base)
+			if !ok {
+				continue
+			}
+			if !seen[link] {
+				seen[link] = true
+				urls = append(urls, link)
+			}
+			break
+		}
+	}
+
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("no URLs found in %s", path)
+	}
+	return urls, nil
+}
+
+// listedURL returns the absolute http(s) URL in a line or cell of a URL list
+func listedURL(cell string, base *url.URL) (string, bool) {
+	cell = strings.TrimSpace(strings.TrimPrefix(cell, "\ufeff"))
+	if cell == "" || strings.HasPrefix(cell, "#") {
+		return "", false
+	}
+	if !strings.HasPrefix(cell, "/") && !strings.Contains(cell, "://") {
+		return "", false
+	}
+
+	u, err := url.Parse(cell)
+	if err != nil {
+		return "", false
+	}
+	u = base.ResolveReference(u)
+	u.Fragment = ""
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", false
+	}
+	return u.String(), true
+}
+
+// followLinks reports whether pages' links are crawled. When the pages come
+// from a URL list, the sitemap or listing pages, only those pages are fetched.
+func (c *Crawler) followLinks() bool {
+	return c.config.URLListFile == "" && !c.config.SeedFromSitemap && c.config.Mode != ModeListing
+}
@@ -0,0 +1,116 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"golang.org/x/net/html"
+)
+
+// videoSources returns the files a <video> element can play: its src and the
+// src of each <source> inside it
+func videoSources(n *html.Node) []string {
+	var sources []string
+	if src := attrValue(n, "src"); src != "" {
+		sources = append(sources, src)
+	} else if src := attrValue(n, "data-src"); src != "" {
+		sources = append(sources, src)
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != html.ElementNode || child.Data != "source" {
+			continue
+		}
+		if src := attrValue(child, "src"); src != "" {
+			sources = append(sources, src)
+		} else if src := attrValue(child, "data-src"); src != "" {
+			sources = append(sources, src)
+		}
+	}
+	return sources
+}
+
+// checkVideo audits one of a <video>'s files in oversized-image mode. Its
+// size comes from the response headers, so the video isn't downloaded. It's
+// flagged when it's over Config.VideoSizeThreshold, or when the <video> has
+// no width or height attribute, so the page jumps when it loads.
+func (c *Crawler) checkVideo(ctx context.Context, src, pageURL string, displayW, displayH int) {
+	u, err := url.Parse(src)
+	if err != nil {
+		return
+	}
+	pageBase, err := url.Parse(pageURL)
+	if err != nil {
+		return
+	}
+	resolved := pageBase.ResolveReference(u).String()
+	atomic.AddInt64(&c.stats.VideosChecked, 1)
+
+	sizeBytes, contentType, ok := c.mediaSize(ctx, resolved)
+	if !ok {
+		return
+	}
+
+	audit := imageAudit{DisplayWidth: displayW, DisplayHeight: displayH}
+	if limit := c.imageLimits.video; sizeBytes > limit {
+		audit.Issues = append(audit.Issues, fmt.Sprintf("video over %dKB", limit/1024))
+	}
+	if displayW == 0 && displayH == 0 {
+		audit.Issues = append(audit.Issues, "no dimensions")
+	}
+	if !audit.flagged() || c.skipIgnored(ModeOversizedImages, resolved) {
+		return
+	}
+	atomic.AddInt64(&c.stats.VideosFlagged, 1)
+
+	sizeKB := sizeBytes / 1024
+	c.writeOversizedImage(resolved, pageURL, sizeKB, contentType, audit)
+	c.log.Info(fmt.Sprintf("🎬 VIDEO (%dKB, %s): %s", sizeKB, strings.Join(audit.Issues, ", "), resolved), "url", resolved, "size_kb", sizeKB, "issues", strings.Join(audit.Issues, "; "), "page", pageURL)
+	c.notifyFinding("oversized_video", resolved, fmt.Sprintf("%s, on %s", strings.Join(audit.Issues, ", "), pageURL))
+}
+
+// mediaSize finds a media file's size without downloading it: from the
+// Content-Length of a HEAD request, or if the server doesn't give one, from
+// the Content-Range of a one-byte ranged GET. ok is false when the file
+// couldn't be fetched.
+func (c *Crawler) mediaSize(ctx context.Context, link string) (size int64, contentType string, ok bool) {
+	client := &http.Client{Timeout: c.requestTimeout(), Transport: c.checkTransport, Jar: c.httpClient.Jar}
+	for _, method := range []string{"HEAD", "GET"} {
+		if !c.limiter.wait(ctx, link) {
+			return 0, "", false
+		}
+		req, err := http.NewRequestWithContext(ctx, method, link, nil)
+		if err != nil {
+			return 0, "", false
+		}
+		req.Header.Set("User-Agent", c.fingerprint.userAgent)
+		if method == "GET" {
+			req.Header.Set("Range", "bytes=0-0")
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return 0, "", false
+		}
+		resp.Body.Close()
+
+		contentType = resp.Header.Get("Content-Type")
+		switch {
+		case resp.StatusCode == http.StatusPartialContent:
+			// Content-Range: bytes 0-0/12345
+			if _, total, found := strings.Cut(resp.Header.Get("Content-Range"), "/"); found {
+				if n, err := strconv.ParseInt(total, 10, 64); err == nil {
+					return n, contentType, true
+				}
+			}
+		case resp.StatusCode == http.StatusOK && resp.ContentLength >= 0:
+			return resp.ContentLength, contentType, true
+		case resp.StatusCode >= 400 && resp.StatusCode != http.StatusMethodNotAllowed && resp.StatusCode != http.StatusNotImplemented:
+			return 0, "", false
+		}
+	}
+	return 0, "", false
+}
@@ -0,0 +1,149 @@
+package crawler
+
+import (
+	"fmt"
+	"hash/maphash"
+	"math"
+	"sync"
+)
+
+// VisitedStore controls how a crawl remembers the URLs it has already seen
+type VisitedStore int
+
+const (
+	// VisitedExact keeps every URL in memory, so memory grows with the site
+	VisitedExact VisitedStore = iota
+	// VisitedBloom keeps a Bloom filter sized by Config.BloomCapacity, so
+	// memory stays flat; about 1 in 100 new URLs is taken for one already
+	// seen and skipped once the filter is full
+	VisitedBloom
+)
+
+func (s VisitedStore) String() string {
+	switch s {
+	case VisitedExact:
+		return "Exact (every URL in memory)"
+	case VisitedBloom:
+		return "Bloom filter (fixed memory)"
+	default:
+		return "Unknown"
+	}
+}
+
+// defaultBloomCapacity is how many URLs the Bloom filter is sized for when
+// Config.BloomCapacity isn't set: about 12 MB
+const defaultBloomCapacity = 10_000_000
+
+// bloomFalsePositives is the share of new URLs mistaken for seen ones once
+// the filter holds its capacity
+const bloomFalsePositives = 0.01
+
+// visitedSet records the URLs a crawl has queued, exactly or in a Bloom filter
+type visitedSet struct {
+	exact sync.Map
+	bloom *bloomFilter // nil unless Config.VisitedStore is VisitedBloom
+}
+
+func newVisitedSet(cfg Config) *visitedSet {
+	v := &visitedSet{}
+	if cfg.VisitedStore == VisitedBloom {
+		capacity := cfg.BloomCapacity
+		if capacity <= 0 {
+			capacity = defaultBloomCapacity
+		}
+		v.bloom = newBloomFilter(capacity, bloomFalsePositives)
+	}
+	return v
+}
+
+// add records key, and reports whether it was already there
+func (v *visitedSet) add(key string) (seen bool) {
+	if v.bloom != nil {
+		return v.bloom.add(key)
+	}
+	_, seen = v.exact.LoadOrStore(key, true)
+	return seen
+}
+
+// remove forgets key. A Bloom filter can't forget, so the key stays seen.
+func (v *visitedSet) remove(key string) {
+	if v.bloom == nil {
+		v.exact.Delete(key)
+	}
+}
+
+// overflowed reports, the first time it's true, that the Bloom filter holds
+// more URLs than it was sized for, so more than bloomFalsePositives of new
+// URLs are being skipped
+func (v *visitedSet) overflowed() bool {
+	return v.bloom != nil && v.bloom.overflowed()
+}
+
+// describe returns the Bloom filter's size, for the crawl summary
+func (v *visitedSet) describe() string {
+	return fmt.Sprintf("Bloom filter (%s, %d URLs)", formatBytes(int64(len(v.bloom.bits))*8), v.bloom.capacity)
+}
+
+// bloomFilter is a fixed-size set that never misses a key it was given, and
+// mistakes a new key for a known one at about the rate it was sized for
+type bloomFilter struct {
+	mu       sync.Mutex
+	bits     []uint64
+	m        uint64 // bits
+	k        int    // bits set per key
+	count    int
+	capacity int
+	warned   bool
+	seed1    maphash.Seed
+	seed2    maphash.Seed
+}
+
+// newBloomFilter sizes a filter to hold capacity keys at a false-positive
+// rate of p
+func newBloomFilter(capacity int, p float64) *bloomFilter {
+	m := uint64(math.Ceil(-float64(capacity) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	m = max((m+63)/64*64, 64)
+	k := max(int(math.Round(float64(m)/float64(capacity)*math.Ln2)), 1)
+	return &bloomFilter{
+		bits:     make([]uint64, m/64),
+		m:        m,
+		k:        k,
+		capacity: capacity,
+		seed1:    maphash.MakeSeed(),
+		seed2:    maphash.MakeSeed(),
+	}
+}
+
+// add sets key's bits, and reports whether they were all set already. The
+// k positions come from two hashes (h1 + i*h2), which is as good as k
+// independent ones.
+func (b *bloomFilter) add(key string) (seen bool) {
+	h1 := maphash.String(b.seed1, key)
+	h2 := maphash.String(b.seed2, key) | 1
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	seen = true
+	for i := 0; i < b.k; i++ {
+		bit := (h1 + uint64(i)*h2) % b.m
+		word, mask := bit/64, uint64(1)<<(bit%64)
+		if b.bits[word]&mask == 0 {
+			seen = false
+			b.bits[word] |= mask
+		}
+	}
+	if !seen {
+		b.count++
+	}
+	return seen
+}
+
+func (b *bloomFilter) overflowed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.warned || b.count <= b.capacity {
+		return false
+	}
+	b.warned = true
+	return true
+}
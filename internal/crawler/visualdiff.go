@@ -0,0 +1,369 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+const (
+	defaultVisualDiffThreshold = 0.1 // percent of pixels
+	defaultVisualDiffTolerance = 16  // per color channel, out of 255
+)
+
+// VisualDiffOptions configures a visual regression run. Config.StartURL is
+// the baseline host (usually production) and CompareHost the one checked
+// against it (usually staging).
+type VisualDiffOptions struct {
+	CompareHost string   // e.g. "https://staging.example.com"
+	Paths       []string // pages to compare: paths, or full URLs whose path is used on both hosts
+	Threshold   float64  // flag pages with more than this percentage of pixels different (default 0.1)
+	Tolerance   int      // ignore per-channel color differences up to this, such as anti-aliasing (default 16)
+}
+
+type VisualDiffStats struct {
+	PagesCompared    int64
+	PagesDifferent   int64 // over the mismatch threshold
+	Errors           int64
+	SkippedTimeLimit int64 // pages not compared because Config.MaxDuration ran out
+	PagesIgnored     int64 // pages not compared because they match Config.IgnoreFile
+}
+
+// visualDiff holds the state of a visual regression run
+type visualDiff struct {
+	c         *Crawler
+	opts      VisualDiffOptions
+	stats     VisualDiffStats
+	startTime time.Time
+	baseHost  *url.URL
+	cmpHost   *url.URL
+	outputDir string
+	csvFile   string
+	csvMu     sync.Mutex
+	wg        sync.WaitGroup
+	sema      chan struct{}
+	browsers  *browserPool
+}
+
+func newVisualDiff(c *Crawler) *visualDiff {
+	opts := c.config.VisualDiffOpts
+	if opts.Threshold <= 0 {
+		opts.Threshold = defaultVisualDiffThreshold
+	}
+	if opts.Tolerance <= 0 {
+		opts.Tolerance = defaultVisualDiffTolerance
+	}
+	return &visualDiff{
+		c:    c,
+		opts: opts,
+		sema: make(chan struct{}, c.config.MaxConcurrency),
+	}
+}
+
+func (v *visualDiff) run(ctx context.Context) {
+	cfg := v.c.config
+	v.startTime = time.Now()
+	v.opts.Paths = append(v.opts.Paths, v.c.urlList...)
+
+	var err error
+	if v.baseHost, err = url.Parse(cfg.StartURL); err != nil || v.baseHost.Host == "" {
+		v.c.log.Error(fmt.Sprintf("❌ Invalid baseline URL: %s", cfg.StartURL), "url", cfg.StartURL)
+		return
+	}
+	if v.cmpHost, err = url.Parse(v.opts.CompareHost); err != nil || v.cmpHost.Host == "" {
+		v.c.log.Error(fmt.Sprintf("❌ Invalid compare URL: %s", v.opts.CompareHost), "url", v.opts.CompareHost)
+		return
+	}
+
+	timestamp := time.Now().Format("2006-01-02_15-04-05")
+	v.outputDir = v.c.outputPath(fmt.Sprintf("visual_diff_%s", timestamp))
+	os.MkdirAll(v.outputDir, 0755)
+	v.csvFile = filepath.Join(v.outputDir, "visual_diff.csv")
+	v.createVisualDiffCSV()
+
+	v.browsers = newBrowserPool(v.c, chromedp.Flag("hide-scrollbars", true))
+	defer v.browsers.close()
+
+	fmt.Println("┌─────────────────── VISUAL DIFF STARTING ──────────────────┐")
+	fmt.Printf("│  🅰️  Baseline: %-43s │\n", truncateString(v.baseHost.Scheme+"://"+v.baseHost.Host, 43))
+	fmt.Printf("│  🅱️  Compare:  %-43s │\n", truncateString(v.cmpHost.Scheme+"://"+v.cmpHost.Host, 43))
+	fmt.Printf("│  📄 Pages:    %-43d │\n", len(v.opts.Paths))
+	fmt.Printf("│  📁 Output:   %-43s │\n", v.outputDir)
+	fmt.Println("└───────────────────────────────────────────────────────────┘")
+	fmt.Println()
+
+	for _, entry := range v.opts.Paths {
+		if v.c.interrupted(ctx) {
+			break
+		}
+		if v.c.outOfTime() {
+			atomic.AddInt64(&v.stats.SkippedTimeLimit, 1)
+			continue
+		}
+		baseURL, cmpURL, err := v.pageURLs(entry)
+		if err != nil {
+			atomic.AddInt64(&v.stats.Errors, 1)
+			v.c.log.Error(fmt.Sprintf("❌ %v", err), "path", entry)
+			continue
+		}
+
+		v.wg.Add(1)
+		go func(baseURL, cmpURL string) {
+			defer v.wg.Done()
+			select {
+			case v.sema <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-v.sema }()
+
+			if !v.c.proceed(ctx) {
+				return
+			}
+			if v.c.outOfTime() {
+				atomic.AddInt64(&v.stats.SkippedTimeLimit, 1)
+				return
+			}
+			v.comparePage(ctx, baseURL, cmpURL)
+		}(baseURL, cmpURL)
+	}
+	v.wg.Wait()
+
+	if v.c.interrupted(ctx) {
+		v.c.log.Warn("🛑 Visual diff cancelled - partial results saved")
+	}
+	v.printVisualDiffFinalStats()
+}
+
+// pageURLs returns an entry's URL on the baseline and compare hosts
+func (v *visualDiff) pageURLs(entry string) (string, string, error) {
+	entry = strings.TrimSpace(entry)
+	ref, err := url.Parse(entry)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid page %q: %v", entry, err)
+	}
+	if ref.IsAbs() {
+		ref = &url.URL{Path: ref.Path, RawPath: ref.RawPath, RawQuery: ref.RawQuery}
+	} else if !strings.HasPrefix(ref.Path, "/") {
+		ref.Path = "/" + ref.Path
+	}
+	ref.Fragment = ""
+	return v.baseHost.ResolveReference(ref).String(), v.cmpHost.ResolveReference(ref).String(), nil
+}
+
+func (v *visualDiff) createVisualDiffCSV() {
+	f, _ := os.Create(v.csvFile)
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	defer w.Flush()
+	w.Write([]string{"Path", "BaselineURL", "CompareURL", "MismatchPercent", "DiffPixels", "BaselineSize", "CompareSize", "Status", "DiffImage", "Timestamp"})
+}
+
+func (v *visualDiff) writeVisualDiffCSV(row []string) {
+	v.csvMu.Lock()
+	defer v.csvMu.Unlock()
+
+	f, _ := os.OpenFile(v.csvFile, os.O_APPEND|os.O_WRONLY, 0644)
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	defer w.Flush()
+	w.Write(append(row, time.Now().Format(time.RFC3339)))
+}
+
+// comparePage screenshots a page on both hosts, saves both screenshots and a
+// diff image with the changed pixels in red, and records the mismatch
+func (v *visualDiff) comparePage(ctx context.Context, baseURL, cmpURL string) {
+	if v.c.ignores(ModeVisualDiff, baseURL) || v.c.ignores(ModeVisualDiff, cmpURL) {
+		atomic.AddInt64(&v.stats.PagesIgnored, 1)
+		v.c.log.Debug(fmt.Sprintf("   🙈 Ignored: %s", cmpURL), "url", cmpURL)
+		return
+	}
+	u, _ := url.Parse(baseURL)
+	path := u.RequestURI()
+	name := sanitizeFilename(baseURL, v.c.config.IgnoreQueryParams)
+
+	shots := make([]image.Image, 2)
+	for i, pageURL := range []string{baseURL, cmpURL} {
+		if !v.c.limiter.wait(ctx, pageURL) {
+			return
+		}
+		buf, err := v.screenshot(ctx, pageURL)
+		if err == nil {
+			shots[i], err = png.Decode(bytes.NewReader(buf))
+		}
+		if err != nil {
+			atomic.AddInt64(&v.stats.Errors, 1)
+			v.c.log.Error(fmt.Sprintf("❌ Error: %s - %v", truncateString(pageURL, 40), err), "url", pageURL, "error", err)
+			v.writeVisualDiffCSV([]string{path, baseURL, cmpURL, "", "", "", "", "error: " + err.Error(), ""})
+			return
+		}
+		suffix := "_baseline.png"
+		if i == 1 {
+			suffix = "_compare.png"
+		}
+		os.WriteFile(filepath.Join(v.outputDir, name+suffix), buf, 0644)
+	}
+
+	diff, diffPixels, totalPixels := diffImages(shots[0], shots[1], v.opts.Tolerance)
+	mismatch := 0.0
+	if totalPixels > 0 {
+		mismatch = float64(diffPixels) / float64(totalPixels) * 100
+	}
+
+	diffPath := filepath.Join(v.outputDir, name+"_diff.png")
+	var diffBuf bytes.Buffer
+	if err := png.Encode(&diffBuf, diff); err == nil {
+		os.WriteFile(diffPath, diffBuf.Bytes(), 0644)
+	}
+
+	atomic.AddInt64(&v.stats.PagesCompared, 1)
+	status := "match"
+	if mismatch > v.opts.Threshold {
+		status = "different"
+		atomic.AddInt64(&v.stats.PagesDifferent, 1)
+		v.c.log.Info(fmt.Sprintf("🔍 VISUAL CHANGE (%.2f%%): %s", mismatch, path), "path", path, "mismatch_percent", mismatch, "diff_pixels", diffPixels)
+		v.c.notifyFinding("visual_change", cmpURL, fmt.Sprintf("%.2f%% of pixels differ", mismatch))
+	} else {
+		v.c.log.Debug(fmt.Sprintf("   ✅ Matches (%.2f%%): %s", mismatch, path), "path", path, "mismatch_percent", mismatch)
+	}
+
+	size := func(img image.Image) string {
+		b := img.Bounds()
+		return fmt.Sprintf("%dx%d", b.Dx(), b.Dy())
+	}
+	v.writeVisualDiffCSV([]string{
+		path, baseURL, cmpURL, strconv.FormatFloat(mismatch, 'f', 2, 64), strconv.FormatInt(diffPixels, 10),
+		size(shots[0]), size(shots[1]), status, filepath.Base(diffPath),
+	})
+}
+
+// screenshot renders a page in headless Chrome and returns a full-page PNG.
+// Animations and transitions are switched off and the text caret hidden, so
+// two loads of an unchanged page produce the same pixels.
+func (v *visualDiff) screenshot(parent context.Context, pageURL string) ([]byte, error) {
+	ctx, cancel, err := v.browsers.tab(parent)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+
+	ctx, cancel = context.WithTimeout(ctx, 180*time.Second)
+	defer cancel()
+
+	var pngBuf []byte
+	err = chromedp.Run(ctx,
+		v.c.browserRequestHeaders(pageURL),
+		chromedp.Navigate(pageURL),
+		chromedp.WaitReady("body", chromedp.ByQuery),
+		chromedp.Sleep(2*time.Second),
+		// Scroll to trigger lazy loading
+		chromedp.Evaluate(`window.scrollTo(0, document.body.scrollHeight)`, nil),
+		chromedp.Sleep(1*time.Second),
+		chromedp.Evaluate(`window.scrollTo(0, 0)`, nil),
+		chromedp.Evaluate(`(() => {
+			const style = document.createElement('style');
+			style.textContent = '*, *::before, *::after { animation: none !important; transition: none !important; caret-color: transparent !important; }';
+			document.head.appendChild(style);
+		})()`, nil),
+		chromedp.Sleep(1*time.Second),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			_, _, contentSize, _, _, _, err := page.GetLayoutMetrics().Do(ctx)
+			if err != nil {
+				return err
+			}
+
+			// Both hosts are shot at the same width so the images line up
+			pngBuf, _, _, err = captureFullPage(ctx, 1920, int64(contentSize.Height), 1, false, imageOutput{format: ImagePNG})
+			return err
+		}),
+	)
+	return pngBuf, err
+}
+
+// diffImages compares two screenshots pixel by pixel. The diff image is the
+// baseline faded to light grey with the mismatched pixels in red. Where one
+// screenshot is taller or wider than the other, the extra area counts as
+// mismatched.
+func diffImages(a, b image.Image, tolerance int) (diff *image.RGBA, diffPixels, totalPixels int64) {
+	ab, bb := a.Bounds(), b.Bounds()
+	width, height := max(ab.Dx(), bb.Dx()), max(ab.Dy(), bb.Dy())
+	diff = image.NewRGBA(image.Rect(0, 0, width, height))
+	red := color.RGBA{R: 255, A: 255}
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			pa := image.Pt(ab.Min.X+x, ab.Min.Y+y)
+			pb := image.Pt(bb.Min.X+x, bb.Min.Y+y)
+			if !pa.In(ab) || !pb.In(bb) {
+				diff.SetRGBA(x, y, red)
+				diffPixels++
+				continue
+			}
+
+			r1, g1, b1, _ := a.At(pa.X, pa.Y).RGBA()
+			r2, g2, b2, _ := b.At(pb.X, pb.Y).RGBA()
+			if channelDiff(r1, r2) > tolerance || channelDiff(g1, g2) > tolerance || channelDiff(b1, b2) > tolerance {
+				diff.SetRGBA(x, y, red)
+				diffPixels++
+				continue
+			}
+
+			// Faded luminance of the baseline, for context
+			lum := (299*r1 + 587*g1 + 114*b1) / 1000 >> 8
+			faded := uint8(200 + lum*55/255)
+			diff.SetRGBA(x, y, color.RGBA{R: faded, G: faded, B: faded, A: 255})
+		}
+	}
+	return diff, diffPixels, int64(width) * int64(height)
+}
+
+// channelDiff returns the difference between two 16-bit color channels on
+// the 0-255 scale
+func channelDiff(a, b uint32) int {
+	d := int(a>>8) - int(b>>8)
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+func (v *visualDiff) printVisualDiffFinalStats() {
+	elapsed := time.Since(v.startTime)
+
+	fmt.Println()
+	fmt.Println("╔═══════════════════════════════════════════════════════════════════╗")
+	fmt.Println("║                   📊 VISUAL DIFF COMPLETE 📊                      ║")
+	fmt.Println("╠═══════════════════════════════════════════════════════════════════╣")
+	fmt.Println("║                                                                   ║")
+	fmt.Printf("║  ⏱️  Total Time:           %-40s ║\n", formatDuration(elapsed))
+	fmt.Printf("║  📄 Pages Compared:        %-40d ║\n", v.stats.PagesCompared)
+	fmt.Printf("║  🔍 Visually Different:    %-40d ║\n", v.stats.PagesDifferent)
+	fmt.Printf("║  🎚️  Threshold:             %-40s ║\n", fmt.Sprintf("%.2f%% of pixels", v.opts.Threshold))
+	fmt.Printf("║  ❌ Errors:                %-40d ║\n", v.stats.Errors)
+	if v.c.config.MaxDuration > 0 {
+		fmt.Printf("║  ⌛ Skipped (Time Limit):  %-40d ║\n", v.stats.SkippedTimeLimit)
+	}
+	if v.c.ignore != nil {
+		fmt.Printf("║  🙈 Ignored:               %-40d ║\n", v.stats.PagesIgnored)
+	}
+	fmt.Printf("║  📁 Output Directory:      %-40s ║\n", v.outputDir)
+	fmt.Println("║                                                                   ║")
+	fmt.Println("╚═══════════════════════════════════════════════════════════════════╝")
+}
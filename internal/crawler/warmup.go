@@ -0,0 +1,176 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// assetSubdomains are the subdomains sites commonly serve images, scripts
+// and styles from, warmed up alongside the start hosts in the modes that
+// fetch them
+var assetSubdomains = []string{"www", "cdn", "static", "assets", "img", "images", "media"}
+
+const (
+	warmUpTimeout  = 5 * time.Second  // the longest the warm-up holds up the crawl
+	warmConnMaxAge = 10 * time.Second // servers drop connections that haven't sent a request
+	warmMaxConns   = 32               // most connections opened to one host
+	warmAssetConns = 2                // connections opened to each asset host
+)
+
+// warmConn is a connection the warm-up opened, waiting for the crawl's
+// first requests to the host
+type warmConn struct {
+	conn   net.Conn
+	opened time.Time
+}
+
+// warmTarget is a host the warm-up looks up and connects to
+type warmTarget struct {
+	addr  string // host:port
+	conns int
+}
+
+// warmUp is Config.WarmUp: before the crawl starts, it looks up the start
+// hosts and their common asset subdomains in parallel, filling the shared
+// DNS cache, and opens connections to the ones that resolve, so the first
+// pages and images don't each wait on a lookup and a TCP handshake. With
+// proxies there's nothing to warm up: the proxy connects to the site.
+func (c *Crawler) warmUp(ctx context.Context) {
+	if c.resolver == nil {
+		return
+	}
+	if c.proxies != nil {
+		c.log.Info("🔥 Warm-up skipped: the proxies connect to the site themselves")
+		return
+	}
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, warmUpTimeout)
+	defer cancel()
+
+	var hosts, conns int64
+	var wg sync.WaitGroup
+	for _, target := range c.warmTargets() {
+		wg.Add(1)
+		go func(target warmTarget) {
+			defer wg.Done()
+			host, _, _ := net.SplitHostPort(target.addr)
+			if _, err := c.resolver.lookup(ctx, host); err != nil {
+				c.log.Debug(fmt.Sprintf("   🔥 %s doesn't resolve: %v", host, err), "host", host, "error", err)
+				return
+			}
+			atomic.AddInt64(&hosts, 1)
+
+			var opened sync.WaitGroup
+			for i := 0; i < target.conns; i++ {
+				opened.Add(1)
+				go func() {
+					defer opened.Done()
+					conn, err := c.resolver.dial(ctx, "tcp", target.addr)
+					if err != nil {
+						c.log.Debug(fmt.Sprintf("   🔥 Could not connect to %s: %v", target.addr, err), "addr", target.addr, "error", err)
+						return
+					}
+					c.resolver.addWarmConn(target.addr, conn)
+					atomic.AddInt64(&conns, 1)
+				}()
+			}
+			opened.Wait()
+		}(target)
+	}
+	wg.Wait()
+
+	elapsed := time.Since(start).Round(time.Millisecond)
+	c.log.Info(fmt.Sprintf("🔥 Warmed up %d hosts (%d connections) in %s", hosts, conns, elapsed), "hosts", hosts, "connections", conns, "duration_ms", elapsed.Milliseconds())
+}
+
+// warmTargets returns the start hosts, with as many connections as the
+// crawl will use at once, and in the modes that fetch images and other
+// assets, the common asset subdomains of each
+func (c *Crawler) warmTargets() []warmTarget {
+	perHost := min(c.config.MaxConcurrency, warmMaxConns)
+	if c.config.RequestsPerSecond > 0 || c.config.HostDelay > 0 {
+		// A rate-limited crawl sends one request at a time
+		perHost = 1
+	}
+	assets := c.runs(ModeOversizedImages) || c.runs(ModePageWeight) || c.runs(ModeBrokenLinks)
+
+	seen := make(map[string]bool)
+	var targets []warmTarget
+	add := func(host, port string, conns int) {
+		addr := net.JoinHostPort(strings.ToLower(host), port)
+		if !seen[addr] {
+			seen[addr] = true
+			targets = append(targets, warmTarget{addr: addr, conns: conns})
+		}
+	}
+	var bases []warmTarget
+	for _, link := range append([]string{c.config.StartURL}, c.config.StartURLs...) {
+		u, err := url.Parse(link)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+		port := u.Port()
+		if port == "" {
+			port = "80"
+			if u.Scheme == "https" {
+				port = "443"
+			}
+		}
+		add(u.Hostname(), port, perHost)
+		if assets && net.ParseIP(u.Hostname()) == nil && strings.Contains(u.Hostname(), ".") {
+			bases = append(bases, warmTarget{addr: net.JoinHostPort(strings.TrimPrefix(u.Hostname(), "www."), port)})
+		}
+	}
+	for _, base := range bases {
+		domain, port, _ := net.SplitHostPort(base.addr)
+		for _, sub := range assetSubdomains {
+			add(sub+"."+domain, port, warmAssetConns)
+		}
+	}
+	return targets
+}
+
+// addWarmConn keeps a connection the warm-up opened to addr for the next
+// dial of it
+func (r *hostResolver) addWarmConn(addr string, conn net.Conn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.warm[addr] = append(r.warm[addr], warmConn{conn: conn, opened: time.Now()})
+}
+
+// takeWarmConn returns a connection the warm-up opened to addr, or nil when
+// there's none left that's recent enough to still be open
+func (r *hostResolver) takeWarmConn(addr string) net.Conn {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for len(r.warm[addr]) > 0 {
+		w := r.warm[addr][0]
+		r.warm[addr] = r.warm[addr][1:]
+		if time.Since(w.opened) < warmConnMaxAge {
+			return w.conn
+		}
+		w.conn.Close()
+	}
+	return nil
+}
+
+// closeWarmConns closes the warm-up's connections the crawl didn't use
+func (r *hostResolver) closeWarmConns() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for addr, conns := range r.warm {
+		for _, w := range conns {
+			w.conn.Close()
+		}
+		delete(r.warm, addr)
+	}
+}
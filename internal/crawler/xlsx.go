@@ -0,0 +1,310 @@
+package crawler
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxCellLength is the most characters Excel keeps in a cell
+const maxCellLength = 32767
+
+// workbookNumber matches the values written as numbers rather than text, so
+// status codes, sizes and counts sort and sum in Excel. Longer digit strings,
+// and ones with leading zeros, stay text.
+var workbookNumber = regexp.MustCompile(`^-?(0|[1-9][0-9]{0,14})(\.[0-9]+)?$`)
+
+// workbookSheet is a worksheet of the Excel workbook
+type workbookSheet struct {
+	name   string
+	header []string
+	rows   [][]string
+	filter bool // the header row gets filter buttons and stays in view
+}
+
+// writeWorkbook writes every check's results to an Excel workbook next to
+// the results file, one worksheet per check after a summary sheet, so the
+// audit opens in Excel without the encoding and formatting a CSV loses. It
+// returns the workbook's path, or "" if it couldn't be written.
+func (c *Crawler) writeWorkbook() string {
+	var sheets []workbookSheet
+	used := make(map[string]bool)
+	for _, mode := range c.crawlModes() {
+		var header []string
+		var rows [][]string
+		if mode == ModeStructuredData {
+			header, rows = readStructuredData(c.resultFileFor(mode))
+		} else {
+			header, rows = readResultsCSV(c.resultFileFor(mode))
+		}
+		if header == nil {
+			continue
+		}
+		sheets = append(sheets, workbookSheet{name: sheetName(mode.String(), used), header: header, rows: rows, filter: true})
+	}
+	sheets = append([]workbookSheet{c.workbookSummary(sheets)}, sheets...)
+
+	path := strings.TrimSuffix(c.resultFile, filepath.Ext(c.resultFile)) + ".xlsx"
+	if err := saveWorkbook(path, sheets); err != nil {
+		c.log.Error("❌ Could not write Excel workbook: "+err.Error(), "file", path, "error", err)
+		return ""
+	}
+	return path
+}
+
+// workbookSummary is the workbook's first sheet: the crawl's settings and
+// stats, and how many rows each check's sheet holds
+func (c *Crawler) workbookSummary(sheets []workbookSheet) workbookSheet {
+	count := func(n int64) string { return strconv.FormatInt(n, 10) }
+	rows := [][]string{
+		{"Target", c.config.StartURL},
+		{"Started", c.startTime.Format("2006-01-02 15:04:05")},
+		{"Duration", formatDuration(time.Since(c.startTime))},
+		{"Pages Checked", count(c.stats.PagesChecked)},
+		{"Findings", count(c.stats.MatchesFound)},
+		{"Links Checked", count(c.stats.LinksChecked)},
+		{"Images Checked", count(c.stats.ImagesChecked)},
+		{"Errors", count(c.stats.ErrorCount)},
+		{"Blocked", count(c.stats.BlockedCount)},
+		{"2xx Success", count(c.stats.Status2xx)},
+		{"3xx Redirect", count(c.stats.Status3xx)},
+		{"4xx Client Error", count(c.stats.Status4xx)},
+		{"5xx Server Error", count(c.stats.Status5xx)},
+	}
+	if c.config.MaxDuration > 0 {
+		rows = append(rows, []string{"Skipped (Time Limit)", count(c.stats.SkippedTimeLimit)})
+	}
+	if c.config.MaxPages > 0 {
+		rows = append(rows, []string{"Skipped (Page Limit)", count(c.stats.SkippedLimit)})
+	}
+	rows = append(rows, []string{"", ""})
+	for _, sheet := range sheets {
+		rows = append(rows, []string{sheet.name + " (rows)", strconv.Itoa(len(sheet.rows))})
+	}
+	return workbookSheet{name: "Summary", header: []string{"Crawl", "Value"}, rows: rows}
+}
+
+// readStructuredData loads structured-data mode's JSON lines as rows, with
+// Missing joined by commas and Data as JSON as in the results database
+func readStructuredData(path string) (header []string, rows [][]string) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var item StructuredItem
+		if json.Unmarshal(scanner.Bytes(), &item) != nil {
+			continue
+		}
+		data := ""
+		if item.Data != nil {
+			b, _ := json.Marshal(item.Data)
+			data = string(b)
+		}
+		rows = append(rows, []string{item.URL, item.FoundOn, item.Format, item.Type, strings.Join(item.Missing, ", "), item.Error, data, item.Timestamp})
+	}
+	return structuredDataColumns, rows
+}
+
+// sheetName makes name a valid worksheet name that isn't used yet: at most
+// 31 characters, without the characters Excel doesn't allow
+func sheetName(name string, used map[string]bool) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '-'
+		}
+		return r
+	}, name)
+	base := truncateRunes(name, 31)
+	name = base
+	for i := 2; used[strings.ToLower(name)]; i++ {
+		suffix := fmt.Sprintf(" (%d)", i)
+		name = truncateRunes(base, 31-len(suffix)) + suffix
+	}
+	used[strings.ToLower(name)] = true
+	return name
+}
+
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// saveWorkbook writes sheets to an .xlsx file at path
+func saveWorkbook(path string, sheets []workbookSheet) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	z := zip.NewWriter(f)
+
+	var types, sheetList, rels, filters strings.Builder
+	for i, sheet := range sheets {
+		n := i + 1
+		fmt.Fprintf(&types, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, n)
+		fmt.Fprintf(&sheetList, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xmlText(sheet.name), n, n)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, n, n)
+		if sheet.filter {
+			fmt.Fprintf(&filters, `<definedName name="_xlnm._FilterDatabase" localSheetId="%d" hidden="1">'%s'!%s</definedName>`,
+				i, xmlText(strings.ReplaceAll(sheet.name, "'", "''")), filterRange(sheet, true))
+		}
+	}
+	definedNames := ""
+	if filters.Len() > 0 {
+		definedNames = "<definedNames>" + filters.String() + "</definedNames>"
+	}
+
+	parts := []struct{ name, body string }{
+		{"[Content_Types].xml", `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+			types.String() + `</Types>`},
+		{"_rels/.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets>` + sheetList.String() + `</sheets>` + definedNames + `</workbook>`},
+		{"xl/_rels/workbook.xml.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` + rels.String() +
+			fmt.Sprintf(`<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, len(sheets)+1) +
+			`</Relationships>`},
+		{"xl/styles.xml", workbookStyles},
+	}
+	for _, part := range parts {
+		if err := writeZipPart(z, part.name, func(w io.Writer) { io.WriteString(w, part.body) }); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	for i, sheet := range sheets {
+		if err := writeZipPart(z, fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), sheet.write); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := z.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeZipPart adds an XML part to the workbook
+func writeZipPart(z *zip.Writer, name string, write func(io.Writer)) error {
+	w, err := z.Create(name)
+	if err != nil {
+		return err
+	}
+	b := bufio.NewWriter(w)
+	b.WriteString(xml.Header)
+	write(b)
+	return b.Flush()
+}
+
+// workbookStyles has the default style and a bold one (s="1") for headers
+const workbookStyles = `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>` +
+	`<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>` +
+	`</styleSheet>`
+
+// write writes the sheet's XML: a bold header row, columns sized to their
+// contents, and for results sheets a frozen header with filter buttons
+func (s workbookSheet) write(w io.Writer) {
+	io.WriteString(w, `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	if s.filter {
+		io.WriteString(w, `<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	}
+
+	widths := make([]int, len(s.header))
+	for _, row := range append([][]string{s.header}, s.rows...) {
+		for i, value := range row {
+			if i < len(widths) {
+				widths[i] = max(widths[i], utf8.RuneCountInString(value))
+			}
+		}
+	}
+	io.WriteString(w, "<cols>")
+	for i, width := range widths {
+		fmt.Fprintf(w, `<col min="%d" max="%d" width="%d" customWidth="1"/>`, i+1, i+1, min(max(width, 8), 80)+2)
+	}
+	io.WriteString(w, "</cols><sheetData>")
+
+	writeRow := func(r int, row []string, style string) {
+		fmt.Fprintf(w, `<row r="%d">`, r)
+		for i, value := range row {
+			if value == "" {
+				continue
+			}
+			ref := cellRef(i, r)
+			if workbookNumber.MatchString(value) {
+				fmt.Fprintf(w, `<c r="%s"%s><v>%s</v></c>`, ref, style, value)
+				continue
+			}
+			fmt.Fprintf(w, `<c r="%s"%s t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, style, xmlText(truncateRunes(value, maxCellLength)))
+		}
+		io.WriteString(w, "</row>")
+	}
+	writeRow(1, s.header, ` s="1"`)
+	for i, row := range s.rows {
+		writeRow(i+2, row, "")
+	}
+	io.WriteString(w, "</sheetData>")
+
+	if s.filter {
+		fmt.Fprintf(w, `<autoFilter ref="%s"/>`, filterRange(s, false))
+	}
+	io.WriteString(w, "</worksheet>")
+}
+
+// filterRange is the range the sheet's filter covers, e.g. A1:H20, or
+// $A$1:$H$20 as the workbook's defined names write it
+func filterRange(s workbookSheet, absolute bool) string {
+	last, rows := columnName(max(len(s.header), 1)-1), len(s.rows)+1
+	if absolute {
+		return fmt.Sprintf("$A$1:$%s$%d", last, rows)
+	}
+	return fmt.Sprintf("A1:%s%d", last, rows)
+}
+
+// cellRef returns the A1-style reference of a zero-based column and a
+// one-based row
+func cellRef(col, row int) string {
+	return columnName(col) + strconv.Itoa(row)
+}
+
+// columnName returns a zero-based column's letters: A to Z, then AA, AB...
+func columnName(col int) string {
+	name := ""
+	for col++; col > 0; col = (col - 1) / 26 {
+		name = string(rune('A'+(col-1)%26)) + name
+	}
+	return name
+}
+
+// xmlText escapes s for XML, replacing characters XML can't hold
+func xmlText(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,262 @@
+package crawler
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// xmlFeedLink is a <link> in an RSS or Atom feed: Atom puts the URL in
+// href, RSS in the element's text
+type xmlFeedLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Text string `xml:",chardata"`
+}
+
+type rssItem struct {
+	Title string        `xml:"title"`
+	Links []xmlFeedLink `xml:"link"`
+	GUID  struct {
+		ID          string `xml:",chardata"`
+		IsPermaLink string `xml:"isPermaLink,attr"`
+	} `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	DCDate      string   `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Description string   `xml:"description"`
+	Categories  []string `xml:"category"`
+	Subjects    []string `xml:"http://purl.org/dc/elements/1.1/ subject"`
+}
+
+type rssFeed struct {
+	Channel struct {
+		Links []xmlFeedLink `xml:"link"`
+		Items []rssItem     `xml:"item"`
+	} `xml:"channel"`
+	Items []rssItem `xml:"item"` // RSS 1.0 puts the items beside the channel
+}
+
+type atomEntry struct {
+	Title      string        `xml:"title"`
+	Links      []xmlFeedLink `xml:"link"`
+	ID         string        `xml:"id"`
+	Published  string        `xml:"published"`
+	Updated    string        `xml:"updated"`
+	Summary    string        `xml:"summary"`
+	Categories []struct {
+		Term  string `xml:"term,attr"`
+		Label string `xml:"label,attr"`
+	} `xml:"category"`
+}
+
+type atomFeed struct {
+	Links   []xmlFeedLink `xml:"link"`
+	Entries []atomEntry   `xml:"entry"`
+}
+
+// isXMLFeed reports whether a feed response is XML rather than JSON, going
+// by its content since feeds are often served with a generic content type
+func isXMLFeed(body []byte) bool {
+	body = bytes.TrimPrefix(body, []byte("\xef\xbb\xbf"))
+	return bytes.HasPrefix(bytes.TrimSpace(body), []byte("<"))
+}
+
+// decodeXMLFeed parses an RSS 0.9x/1.0/2.0 or Atom feed into the shape of a
+// wrapped JSON feed - {"items": [...], "next": "..."} with each item's
+// headline, link, date, brief and tags - so it goes through the same field
+// mapping and paging as JSON. Relative links are resolved against pageURL.
+// It also returns the feed's type, e.g. "RSS 2.0" or "Atom".
+func decodeXMLFeed(body []byte, pageURL string) (any, string, error) {
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	dec.Strict = false
+	dec.Entity = xml.HTMLEntity
+	dec.CharsetReader = feedCharsetReader
+
+	var root xml.StartElement
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid XML: %v", err)
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			root = start
+			break
+		}
+	}
+
+	base, _ := url.Parse(pageURL)
+	resolve := func(link string) string {
+		link = strings.TrimSpace(link)
+		if link == "" || base == nil {
+			return link
+		}
+		if ref, err := base.Parse(link); err == nil {
+			return ref.String()
+		}
+		return link
+	}
+
+	var items []any
+	var next string
+	var feedType string
+	switch root.Name.Local {
+	case "rss", "RDF":
+		var feed rssFeed
+		if err := dec.DecodeElement(&feed, &root); err != nil {
+			return nil, "", fmt.Errorf("invalid RSS feed: %v", err)
+		}
+		feedType = "RSS 1.0"
+		if root.Name.Local == "rss" {
+			feedType = "RSS " + xmlAttr(root, "version")
+		}
+
+		for _, item := range append(feed.Channel.Items, feed.Items...) {
+			link := ""
+			for _, l := range item.Links {
+				if strings.TrimSpace(l.Text) != "" {
+					link = l.Text
+					break
+				}
+			}
+			if link == "" && item.GUID.IsPermaLink != "false" && strings.HasPrefix(strings.TrimSpace(item.GUID.ID), "http") {
+				link = item.GUID.ID
+			}
+			if link == "" {
+				link = alternateLink(item.Links)
+			}
+			date := item.PubDate
+			if date == "" {
+				date = item.DCDate
+			}
+			items = append(items, map[string]any{
+				"guid":     strings.TrimSpace(item.GUID.ID),
+				"headline": feedText(item.Title),
+				"link":     resolve(link),
+				"date":     strings.TrimSpace(date),
+				"brief":    feedText(item.Description),
+				"tags":     strings.Join(append(item.Categories, item.Subjects...), ", "),
+			})
+		}
+		next = relLink(feed.Channel.Links, "next")
+
+	case "feed":
+		var feed atomFeed
+		if err := dec.DecodeElement(&feed, &root); err != nil {
+			return nil, "", fmt.Errorf("invalid Atom feed: %v", err)
+		}
+		feedType = "Atom"
+
+		for _, entry := range feed.Entries {
+			link := alternateLink(entry.Links)
+			if link == "" && strings.HasPrefix(strings.TrimSpace(entry.ID), "http") {
+				link = entry.ID
+			}
+			date := entry.Published
+			if date == "" {
+				date = entry.Updated
+			}
+			var tags []string
+			for _, cat := range entry.Categories {
+				if cat.Label != "" {
+					tags = append(tags, cat.Label)
+				} else if cat.Term != "" {
+					tags = append(tags, cat.Term)
+				}
+			}
+			items = append(items, map[string]any{
+				"guid":     strings.TrimSpace(entry.ID),
+				"headline": feedText(entry.Title),
+				"link":     resolve(link),
+				"date":     strings.TrimSpace(date),
+				"brief":    feedText(entry.Summary),
+				"tags":     strings.Join(tags, ", "),
+			})
+		}
+		next = relLink(feed.Links, "next")
+
+	default:
+		return nil, "", fmt.Errorf("not an RSS or Atom feed (root element <%s>)", root.Name.Local)
+	}
+
+	doc := map[string]any{"items": items}
+	if items == nil {
+		doc["items"] = []any{}
+	}
+	if next != "" {
+		doc["next"] = resolve(next)
+	}
+	return doc, strings.TrimSpace(feedType), nil
+}
+
+// alternateLink returns the href of the first Atom link to the entry's web
+// page: one with rel="alternate" or no rel at all
+func alternateLink(links []xmlFeedLink) string {
+	if href := relLink(links, "alternate"); href != "" {
+		return href
+	}
+	return relLink(links, "")
+}
+
+// relLink returns the href of the first link with the given rel
+func relLink(links []xmlFeedLink, rel string) string {
+	for _, l := range links {
+		if l.Rel == rel && strings.TrimSpace(l.Href) != "" {
+			return l.Href
+		}
+	}
+	return ""
+}
+
+// xmlAttr returns the value of an element's attribute, or ""
+func xmlAttr(el xml.StartElement, name string) string {
+	for _, attr := range el.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// feedText flattens a feed title or description to one line of plain
+// text. RSS descriptions are usually HTML, escaped or in CDATA.
+func feedText(s string) string {
+	if strings.ContainsAny(s, "<&") {
+		s = visibleText([]byte(s))
+	}
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// cp1252 maps the bytes 0x80-0x9F of Windows-1252 to Unicode; the rest of
+// the code page matches ISO 8859-1
+var cp1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8d, 'Ž', 0x8f,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9d, 'ž', 'Ÿ',
+}
+
+// feedCharsetReader decodes the single-byte encodings older feeds declare in
+// their XML prolog. encoding/xml only reads UTF-8 by itself.
+func feedCharsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "iso8859-1", "latin1", "l1", "windows-1252", "cp1252", "us-ascii", "ascii":
+	default:
+		return nil, fmt.Errorf("unsupported feed encoding %q", charset)
+	}
+
+	raw, err := io.ReadAll(input)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(raw)+len(raw)/8)
+	for _, b := range raw {
+		r := rune(b)
+		if b >= 0x80 && b < 0xa0 {
+			r = cp1252[b-0x80]
+		}
+		out = utf8.AppendRune(out, r)
+	}
+	return bytes.NewReader(out), nil
+}
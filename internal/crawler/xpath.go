@@ -0,0 +1,474 @@
+package crawler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// xpathExpr is a compiled XPath expression. Only location paths are
+// supported: / and // steps with element names or *, ., .., text() and a
+// final @attribute, joined with |. Predicates can be a position ([1],
+// [last()]) or conditions joined with "and": @attr, @attr='v', text()='v',
+// child='v', contains(x, 'v') and starts-with(x, 'v'), with = or !=.
+type xpathExpr []xpathPath
+
+type xpathPath []xpathStep
+
+type xpathStepKind int
+
+const (
+	xpathElement xpathStepKind = iota
+	xpathText
+	xpathAttr
+	xpathSelf
+	xpathParent
+)
+
+type xpathStep struct {
+	kind       xpathStepKind
+	descendant bool   // the step follows // rather than /
+	name       string // element or attribute name, "*" for any
+	predicates []xpathPredicate
+}
+
+// xpathPredicate filters a step's nodes by position, or by conditions that
+// must all hold
+type xpathPredicate struct {
+	position int // 1-based; 0 when the predicate isn't positional
+	last     bool
+	conds    []xpathCond
+}
+
+type xpathCond struct {
+	fn      string // "", "contains" or "starts-with"
+	operand xpathOperand
+	op      string // "", "=" or "!="; "" only checks the operand exists
+	value   string
+}
+
+type xpathOperand struct {
+	kind byte   // '@' attribute, 't' text(), '.' the node itself, 'e' child element
+	name string // attribute or child element name
+}
+
+// compileXPath parses an expression such as "//div[@class='price']/text()"
+func compileXPath(expr string) (xpathExpr, error) {
+	p := &selectorParser{s: strings.TrimSpace(expr)}
+	var x xpathExpr
+	for {
+		path, err := p.xpathPath()
+		if err != nil {
+			return nil, err
+		}
+		x = append(x, path)
+		p.skipSpace()
+		if p.eof() {
+			return x, nil
+		}
+		if p.peek() != '|' {
+			return nil, fmt.Errorf("unexpected %q in XPath %q", p.peek(), p.s)
+		}
+		p.pos++
+		p.skipSpace()
+	}
+}
+
+func (p *selectorParser) xpathPath() (xpathPath, error) {
+	if p.eof() || p.peek() != '/' {
+		return nil, fmt.Errorf("XPath %q must start with / or //", p.s)
+	}
+
+	var path xpathPath
+	for !p.eof() && p.peek() == '/' {
+		if len(path) > 0 && path[len(path)-1].kind == xpathAttr {
+			return nil, fmt.Errorf("@attribute must be the last step in XPath %q", p.s)
+		}
+		descendant := strings.HasPrefix(p.s[p.pos:], "//")
+		if descendant {
+			p.pos += 2
+		} else {
+			p.pos++
+		}
+		step, err := p.xpathStep(descendant)
+		if err != nil {
+			return nil, err
+		}
+		path = append(path, step)
+		p.skipSpace()
+	}
+	return path, nil
+}
+
+func (p *selectorParser) xpathStep(descendant bool) (xpathStep, error) {
+	step := xpathStep{descendant: descendant}
+	rest := p.s[p.pos:]
+	switch {
+	case strings.HasPrefix(rest, ".."):
+		step.kind = xpathParent
+		p.pos += 2
+	case strings.HasPrefix(rest, "."):
+		step.kind = xpathSelf
+		p.pos++
+	case strings.HasPrefix(rest, "text()"):
+		step.kind = xpathText
+		p.pos += len("text()")
+	case strings.HasPrefix(rest, "@"):
+		step.kind = xpathAttr
+		p.pos++
+		if step.name = p.xpathName(); step.name == "" {
+			return step, fmt.Errorf("missing attribute name after @ in XPath %q", p.s)
+		}
+		return step, nil
+	default:
+		if step.name = p.xpathName(); step.name == "" {
+			if p.eof() {
+				return step, fmt.Errorf("XPath %q ends unexpectedly", p.s)
+			}
+			return step, fmt.Errorf("unexpected %q in XPath %q", p.peek(), p.s)
+		}
+	}
+
+	for !p.eof() && p.peek() == '[' {
+		pred, err := p.xpathPredicate()
+		if err != nil {
+			return step, err
+		}
+		step.predicates = append(step.predicates, pred)
+	}
+	return step, nil
+}
+
+// xpathName reads an element or attribute name, or *
+func (p *selectorParser) xpathName() string {
+	if !p.eof() && p.peek() == '*' {
+		p.pos++
+		return "*"
+	}
+	return strings.ToLower(p.ident())
+}
+
+func (p *selectorParser) xpathPredicate() (xpathPredicate, error) {
+	var pred xpathPredicate
+	p.pos++ // [
+	p.skipSpace()
+
+	rest := p.s[p.pos:]
+	switch {
+	case strings.HasPrefix(rest, "last()"):
+		pred.last = true
+		p.pos += len("last()")
+	case rest != "" && rest[0] >= '0' && rest[0] <= '9':
+		start := p.pos
+		for !p.eof() && p.peek() >= '0' && p.peek() <= '9' {
+			p.pos++
+		}
+		pred.position, _ = strconv.Atoi(p.s[start:p.pos])
+		if pred.position < 1 {
+			return pred, fmt.Errorf("positions start at 1 in XPath %q", p.s)
+		}
+	default:
+		for {
+			cond, err := p.xpathCond()
+			if err != nil {
+				return pred, err
+			}
+			pred.conds = append(pred.conds, cond)
+			p.skipSpace()
+			if !strings.HasPrefix(p.s[p.pos:], "and ") {
+				break
+			}
+			p.pos += len("and ")
+			p.skipSpace()
+		}
+	}
+
+	p.skipSpace()
+	if p.eof() || p.peek() != ']' {
+		return pred, fmt.Errorf("unclosed [ in XPath %q", p.s)
+	}
+	p.pos++
+	return pred, nil
+}
+
+func (p *selectorParser) xpathCond() (xpathCond, error) {
+	var cond xpathCond
+	for _, fn := range []string{"contains", "starts-with"} {
+		if strings.HasPrefix(p.s[p.pos:], fn+"(") {
+			cond.fn = fn
+			p.pos += len(fn) + 1
+			p.skipSpace()
+		}
+	}
+
+	operand, err := p.xpathOperand()
+	if err != nil {
+		return cond, err
+	}
+	cond.operand = operand
+	p.skipSpace()
+
+	if cond.fn != "" {
+		if p.eof() || p.peek() != ',' {
+			return cond, fmt.Errorf("%s() needs two arguments in XPath %q", cond.fn, p.s)
+		}
+		p.pos++
+		p.skipSpace()
+		if cond.value, err = p.xpathLiteral(); err != nil {
+			return cond, err
+		}
+		p.skipSpace()
+		if p.eof() || p.peek() != ')' {
+			return cond, fmt.Errorf("unclosed %s( in XPath %q", cond.fn, p.s)
+		}
+		p.pos++
+		return cond, nil
+	}
+
+	switch {
+	case strings.HasPrefix(p.s[p.pos:], "!="):
+		cond.op = "!="
+		p.pos += 2
+	case strings.HasPrefix(p.s[p.pos:], "="):
+		cond.op = "="
+		p.pos++
+	default:
+		return cond, nil
+	}
+	p.skipSpace()
+	cond.value, err = p.xpathLiteral()
+	return cond, err
+}
+
+func (p *selectorParser) xpathOperand() (xpathOperand, error) {
+	rest := p.s[p.pos:]
+	switch {
+	case strings.HasPrefix(rest, "@"):
+		p.pos++
+		name := p.xpathName()
+		if name == "" || name == "*" {
+			return xpathOperand{}, fmt.Errorf("missing attribute name after @ in XPath %q", p.s)
+		}
+		return xpathOperand{kind: '@', name: name}, nil
+	case strings.HasPrefix(rest, "text()"):
+		p.pos += len("text()")
+		return xpathOperand{kind: 't'}, nil
+	case strings.HasPrefix(rest, "normalize-space()"):
+		p.pos += len("normalize-space()")
+		return xpathOperand{kind: '.'}, nil
+	case strings.HasPrefix(rest, "."):
+		p.pos++
+		return xpathOperand{kind: '.'}, nil
+	}
+	if name := p.xpathName(); name != "" && name != "*" {
+		return xpathOperand{kind: 'e', name: name}, nil
+	}
+	if p.eof() {
+		return xpathOperand{}, fmt.Errorf("XPath %q ends unexpectedly", p.s)
+	}
+	return xpathOperand{}, fmt.Errorf("unsupported predicate at %q in XPath %q", p.s[p.pos:], p.s)
+}
+
+// xpathLiteral reads a quoted string or a bare number
+func (p *selectorParser) xpathLiteral() (string, error) {
+	if !p.eof() && (p.peek() == '"' || p.peek() == '\'') {
+		quote := p.peek()
+		end := strings.IndexByte(p.s[p.pos+1:], quote)
+		if end < 0 {
+			return "", fmt.Errorf("unclosed quote in XPath %q", p.s)
+		}
+		value := p.s[p.pos+1 : p.pos+1+end]
+		p.pos += end + 2
+		return value, nil
+	}
+	start := p.pos
+	for !p.eof() && (p.peek() == '.' || p.peek() == '-' || (p.peek() >= '0' && p.peek() <= '9')) {
+		p.pos++
+	}
+	if p.pos == start {
+		return "", fmt.Errorf("expected a quoted value in XPath %q", p.s)
+	}
+	return p.s[start:p.pos], nil
+}
+
+// values evaluates the expression against a parsed page and returns the text
+// of each node it selects, or the attribute values for an @attribute path
+func (x xpathExpr) values(doc *html.Node) []string {
+	var values []string
+	for _, path := range x {
+		nodes := []*html.Node{doc}
+		for _, step := range path {
+			if step.kind == xpathAttr {
+				for _, n := range step.contextNodes(nodes) {
+					for _, a := range n.Attr {
+						if step.name == "*" || strings.EqualFold(a.Key, step.name) {
+							values = append(values, a.Val)
+						}
+					}
+				}
+				nodes = nil
+				break
+			}
+			nodes = step.apply(nodes)
+		}
+		for _, n := range nodes {
+			if n.Type == html.TextNode {
+				values = append(values, n.Data)
+			} else {
+				values = append(values, nodeText(n))
+			}
+		}
+	}
+	return values
+}
+
+// contextNodes returns the nodes a step starts from: the current nodes, or
+// after // the current nodes and everything inside them
+func (s xpathStep) contextNodes(nodes []*html.Node) []*html.Node {
+	if !s.descendant {
+		return nodes
+	}
+	var all []*html.Node
+	seen := make(map[*html.Node]bool)
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if seen[n] {
+			return
+		}
+		seen[n] = true
+		all = append(all, n)
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode {
+				f(c)
+			}
+		}
+	}
+	for _, n := range nodes {
+		f(n)
+	}
+	return all
+}
+
+// apply returns the nodes a step selects from the current nodes. Positions in
+// predicates count among each context node's matches, as in XPath.
+func (s xpathStep) apply(nodes []*html.Node) []*html.Node {
+	var out []*html.Node
+	seen := make(map[*html.Node]bool)
+	for _, ctx := range s.contextNodes(nodes) {
+		var candidates []*html.Node
+		switch s.kind {
+		case xpathSelf:
+			candidates = []*html.Node{ctx}
+		case xpathParent:
+			if ctx.Parent != nil {
+				candidates = []*html.Node{ctx.Parent}
+			}
+		default:
+			for c := ctx.FirstChild; c != nil; c = c.NextSibling {
+				if s.kind == xpathText && c.Type == html.TextNode ||
+					s.kind == xpathElement && c.Type == html.ElementNode && (s.name == "*" || c.Data == s.name) {
+					candidates = append(candidates, c)
+				}
+			}
+		}
+
+		for _, pred := range s.predicates {
+			candidates = pred.filter(candidates)
+		}
+		for _, n := range candidates {
+			if !seen[n] {
+				seen[n] = true
+				out = append(out, n)
+			}
+		}
+	}
+	return out
+}
+
+func (pred xpathPredicate) filter(nodes []*html.Node) []*html.Node {
+	switch {
+	case pred.last:
+		if len(nodes) == 0 {
+			return nil
+		}
+		return nodes[len(nodes)-1:]
+	case pred.position > 0:
+		if pred.position > len(nodes) {
+			return nil
+		}
+		return nodes[pred.position-1 : pred.position]
+	}
+
+	var out []*html.Node
+	for _, n := range nodes {
+		ok := true
+		for _, cond := range pred.conds {
+			if !cond.match(n) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// match reports whether any of the operand's values meets the condition,
+// which is how XPath compares node sets with strings
+func (cond xpathCond) match(n *html.Node) bool {
+	for _, v := range cond.operand.values(n) {
+		switch {
+		case cond.fn == "contains":
+			if strings.Contains(v, cond.value) {
+				return true
+			}
+		case cond.fn == "starts-with":
+			if strings.HasPrefix(v, cond.value) {
+				return true
+			}
+		case cond.op == "=":
+			if v == cond.value {
+				return true
+			}
+		case cond.op == "!=":
+			if v != cond.value {
+				return true
+			}
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+func (o xpathOperand) values(n *html.Node) []string {
+	switch o.kind {
+	case '@':
+		if v, ok := attrLookup(n, o.name); ok {
+			return []string{v}
+		}
+	case 't':
+		var texts []string
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.TextNode {
+				texts = append(texts, c.Data)
+			}
+		}
+		return texts
+	case '.':
+		return []string{strings.Join(strings.Fields(nodeText(n)), " ")}
+	case 'e':
+		var texts []string
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode && c.Data == o.name {
+				texts = append(texts, strings.Join(strings.Fields(nodeText(c)), " "))
+			}
+		}
+		return texts
+	}
+	return nil
+}
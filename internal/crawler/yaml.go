@@ -0,0 +1,485 @@
+package crawler
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The config file reader understands the block-style subset of YAML that
+// hand-written config files use: nested mappings, "- " sequences, flow
+// sequences and mappings of scalars ([a, b], {k: v}), quoted and plain
+// scalars, "|" and ">" block scalars, and # comments. Anchors, tags and
+// multiple documents aren't supported. Scalars are returned as strings and
+// typed by the field they're decoded into, so "1.10" stays a string where a
+// string is wanted.
+
+// yamlLine is a line of YAML with its indentation measured and its comment
+// removed. raw keeps the original for block scalars.
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+	raw    string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// parseYAML parses a YAML document into map[string]any, []any, string and
+// nil values
+func parseYAML(data []byte) (any, error) {
+	p := &yamlParser{}
+	for i, raw := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		text := strings.TrimLeft(raw, " ")
+		if strings.HasPrefix(text, "\t") {
+			return nil, fmt.Errorf("line %d: indent with spaces, not tabs", i+1)
+		}
+		p.lines = append(p.lines, yamlLine{num: i + 1, indent: len(raw) - len(text), text: strings.TrimSpace(stripYAMLComment(text)), raw: raw})
+	}
+
+	p.skipBlank()
+	if p.pos < len(p.lines) && p.lines[p.pos].text == "---" {
+		p.pos++
+		p.skipBlank()
+	}
+	if p.pos == len(p.lines) {
+		return map[string]any{}, nil
+	}
+	v, err := p.parseBlock(p.lines[p.pos].indent)
+	if err != nil {
+		return nil, err
+	}
+	p.skipBlank()
+	if p.pos < len(p.lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", p.lines[p.pos].num)
+	}
+	return v, nil
+}
+
+// skipBlank moves past empty and comment-only lines
+func (p *yamlParser) skipBlank() {
+	for p.pos < len(p.lines) && p.lines[p.pos].text == "" {
+		p.pos++
+	}
+}
+
+// parseBlock parses the sequence or mapping starting at the current line,
+// whose entries are indented by indent
+func (p *yamlParser) parseBlock(indent int) (any, error) {
+	if isYAMLSeqItem(p.lines[p.pos].text) {
+		return p.parseSeq(indent)
+	}
+	if _, _, ok := splitYAMLKey(p.lines[p.pos].text); !ok {
+		// A lone scalar, e.g. a document that's just "hello"
+		line := p.lines[p.pos]
+		p.pos++
+		return parseYAMLScalar(line.text, line.num)
+	}
+	return p.parseMap(indent)
+}
+
+func (p *yamlParser) parseMap(indent int) (any, error) {
+	m := make(map[string]any)
+	for {
+		p.skipBlank()
+		if p.pos == len(p.lines) || p.lines[p.pos].indent < indent {
+			return m, nil
+		}
+		line := p.lines[p.pos]
+		if line.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", line.num)
+		}
+		if isYAMLSeqItem(line.text) {
+			return nil, fmt.Errorf("line %d: list item where a key was expected", line.num)
+		}
+		key, rest, ok := splitYAMLKey(line.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", line.num)
+		}
+		if _, dup := m[key]; dup {
+			return nil, fmt.Errorf("line %d: %q is set twice", line.num, key)
+		}
+		p.pos++
+
+		v, err := p.parseValue(rest, indent, line.num)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = v
+	}
+}
+
+func (p *yamlParser) parseSeq(indent int) (any, error) {
+	list := []any{}
+	for {
+		p.skipBlank()
+		if p.pos == len(p.lines) || p.lines[p.pos].indent < indent {
+			return list, nil
+		}
+		line := p.lines[p.pos]
+		if line.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", line.num)
+		}
+		if !isYAMLSeqItem(line.text) {
+			return list, nil
+		}
+
+		item := strings.TrimSpace(strings.TrimPrefix(line.text, "-"))
+		if _, _, isKey := splitYAMLKey(item); isKey || isYAMLSeqItem(item) {
+			// "- key: value" starts a mapping (or "- - x" a sequence)
+			// indented to where the item's text begins
+			offset := strings.Index(line.raw, item)
+			p.lines[p.pos] = yamlLine{num: line.num, indent: offset, text: item, raw: line.raw}
+			v, err := p.parseBlock(offset)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+			continue
+		}
+
+		p.pos++
+		v, err := p.parseValue(item, indent, line.num)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+}
+
+// parseValue parses what follows a key or "- ": an inline scalar, a block
+// scalar, or a nested block on the following lines
+func (p *yamlParser) parseValue(rest string, indent, num int) (any, error) {
+	switch {
+	case rest == "":
+		p.skipBlank()
+		if p.pos == len(p.lines) {
+			return nil, nil
+		}
+		next := p.lines[p.pos]
+		if next.indent > indent || (next.indent == indent && isYAMLSeqItem(next.text)) {
+			// A list may sit at the same indentation as its key
+			return p.parseBlock(next.indent)
+		}
+		return nil, nil
+	case strings.HasPrefix(rest, "|") || strings.HasPrefix(rest, ">"):
+		return p.parseBlockScalar(rest, indent), nil
+	default:
+		return parseYAMLScalar(rest, num)
+	}
+}
+
+// parseBlockScalar reads the lines of a "|" (literal) or ">" (folded) block
+// scalar indented deeper than indent
+func (p *yamlParser) parseBlockScalar(header string, indent int) string {
+	var lines []string
+	blockIndent := -1
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if strings.TrimSpace(line.raw) == "" {
+			lines = append(lines, "")
+			p.pos++
+			continue
+		}
+		if line.indent <= indent {
+			break
+		}
+		if blockIndent < 0 {
+			blockIndent = line.indent
+		}
+		lines = append(lines, line.raw[min(blockIndent, line.indent):])
+		p.pos++
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	var text string
+	if strings.HasPrefix(header, ">") {
+		var b strings.Builder
+		for i, l := range lines {
+			switch {
+			case i == 0:
+			case l == "" || lines[i-1] == "":
+				b.WriteString("\n")
+			default:
+				b.WriteString(" ")
+			}
+			b.WriteString(l)
+		}
+		text = b.String()
+	} else {
+		text = strings.Join(lines, "\n")
+	}
+	if !strings.Contains(header, "-") && text != "" {
+		text += "\n"
+	}
+	return text
+}
+
+// isYAMLSeqItem reports whether a line is a "- " sequence entry
+func isYAMLSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// splitYAMLKey splits "key: value" at the first colon followed by a space
+// or the end of the line, outside quotes
+func splitYAMLKey(text string) (key, rest string, ok bool) {
+	if strings.HasPrefix(text, "[") || strings.HasPrefix(text, "{") {
+		return "", "", false
+	}
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		ch := text[i]
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case (ch == '"' || ch == '\'') && i == 0:
+			quote = ch
+		case ch == ':' && (i+1 == len(text) || text[i+1] == ' '):
+			key = strings.TrimSpace(text[:i])
+			if k, err := unquoteYAML(key); err == nil {
+				key = k
+			}
+			return key, strings.TrimSpace(text[i+1:]), key != ""
+		}
+	}
+	return "", "", false
+}
+
+// stripYAMLComment removes a # comment: one at the start of the line or
+// after a space, outside quotes
+func stripYAMLComment(text string) string {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		ch := text[i]
+		switch {
+		case quote != 0:
+			if ch == '\\' && quote == '"' {
+				i++
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			if i == 0 || strings.ContainsRune(" :[{,-", rune(text[i-1])) {
+				quote = ch
+			}
+		case ch == '#' && (i == 0 || text[i-1] == ' '):
+			return text[:i]
+		}
+	}
+	return text
+}
+
+// parseYAMLScalar parses an inline value: a quoted or plain scalar, or a
+// flow sequence or mapping of them
+func parseYAMLScalar(text string, num int) (any, error) {
+	switch {
+	case strings.HasPrefix(text, "["):
+		if !strings.HasSuffix(text, "]") {
+			return nil, fmt.Errorf("line %d: unclosed [", num)
+		}
+		list := []any{}
+		for _, part := range splitYAMLFlow(text[1 : len(text)-1]) {
+			v, err := parseYAMLScalar(part, num)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	case strings.HasPrefix(text, "{"):
+		if !strings.HasSuffix(text, "}") {
+			return nil, fmt.Errorf("line %d: unclosed {", num)
+		}
+		m := make(map[string]any)
+		for _, part := range splitYAMLFlow(text[1 : len(text)-1]) {
+			key, rest, ok := splitYAMLKey(part)
+			if !ok {
+				return nil, fmt.Errorf("line %d: expected \"key: value\" in {...}", num)
+			}
+			v, err := parseYAMLScalar(rest, num)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+		}
+		return m, nil
+	case text == "" || text == "~" || text == "null":
+		return nil, nil
+	}
+	s, err := unquoteYAML(text)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %v", num, err)
+	}
+	return s, nil
+}
+
+// splitYAMLFlow splits the inside of [...] or {...} at commas outside
+// quotes and brackets
+func splitYAMLFlow(text string) []string {
+	var parts []string
+	var quote byte
+	depth, start := 0, 0
+	for i := 0; i < len(text); i++ {
+		ch := text[i]
+		switch {
+		case quote != 0:
+			if ch == '\\' && quote == '"' {
+				i++
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == '[' || ch == '{':
+			depth++
+		case ch == ']' || ch == '}':
+			depth--
+		case ch == ',' && depth == 0:
+			parts = append(parts, strings.TrimSpace(text[start:i]))
+			start = i + 1
+		}
+	}
+	if last := strings.TrimSpace(text[start:]); last != "" {
+		parts = append(parts, last)
+	}
+	return parts
+}
+
+// unquoteYAML returns a scalar's value: the text of a '...' or "..." string,
+// or a plain scalar as it is
+func unquoteYAML(text string) (string, error) {
+	switch {
+	case len(text) >= 2 && text[0] == '\'' && text[len(text)-1] == '\'':
+		return strings.ReplaceAll(text[1:len(text)-1], "''", "'"), nil
+	case len(text) >= 2 && text[0] == '"' && text[len(text)-1] == '"':
+		s, err := strconv.Unquote(text)
+		if err != nil {
+			return "", fmt.Errorf("invalid quoted string %s", text)
+		}
+		return s, nil
+	case strings.HasPrefix(text, "'") || strings.HasPrefix(text, `"`):
+		return "", fmt.Errorf("unclosed quote in %s", text)
+	}
+	return text, nil
+}
+
+// marshalYAML writes map[string]any, []any and scalar values as block-style
+// YAML that parseYAML reads back. Map keys are sorted.
+func marshalYAML(v any) []byte {
+	var b strings.Builder
+	switch v := v.(type) {
+	case map[string]any:
+		writeYAMLMap(&b, v, 0)
+	case []any:
+		writeYAMLSeq(&b, v, 0)
+	default:
+		b.WriteString(yamlScalar(v) + "\n")
+	}
+	return []byte(b.String())
+}
+
+func writeYAMLMap(b *strings.Builder, m map[string]any, indent int) {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		b.WriteString(strings.Repeat(" ", indent) + yamlScalar(key) + ":")
+		writeYAMLValue(b, m[key], indent)
+	}
+}
+
+func writeYAMLSeq(b *strings.Builder, list []any, indent int) {
+	for _, item := range list {
+		// Nested blocks are written at the item's indentation, then their
+		// first line is marked as the item
+		var nested strings.Builder
+		switch item := item.(type) {
+		case map[string]any:
+			if len(item) > 0 {
+				writeYAMLMap(&nested, item, indent+2)
+			}
+		case []any:
+			if len(item) > 0 {
+				writeYAMLSeq(&nested, item, indent+2)
+			}
+		}
+		if nested.Len() > 0 {
+			b.WriteString(strings.Repeat(" ", indent) + "- " + nested.String()[indent+2:])
+			continue
+		}
+		b.WriteString(strings.Repeat(" ", indent) + "-")
+		writeYAMLValue(b, item, indent)
+	}
+}
+
+// writeYAMLValue writes what follows "key:" or "-": a scalar on the same
+// line, or a nested block or multi-line string on the lines below
+func writeYAMLValue(b *strings.Builder, v any, indent int) {
+	switch v := v.(type) {
+	case map[string]any:
+		if len(v) == 0 {
+			b.WriteString(" {}\n")
+			return
+		}
+		b.WriteString("\n")
+		writeYAMLMap(b, v, indent+2)
+	case []any:
+		if len(v) == 0 {
+			b.WriteString(" []\n")
+			return
+		}
+		b.WriteString("\n")
+		writeYAMLSeq(b, v, indent+2)
+	case string:
+		if !strings.Contains(strings.TrimRight(v, "\n"), "\n") || strings.HasPrefix(v, " ") {
+			b.WriteString(" " + yamlScalar(v) + "\n")
+			return
+		}
+		header := "|"
+		if !strings.HasSuffix(v, "\n") {
+			header = "|-"
+		}
+		b.WriteString(" " + header + "\n")
+		for _, line := range strings.Split(strings.TrimSuffix(v, "\n"), "\n") {
+			if line == "" {
+				b.WriteString("\n")
+			} else {
+				b.WriteString(strings.Repeat(" ", indent+2) + line + "\n")
+			}
+		}
+	case nil:
+		b.WriteString("\n")
+	default:
+		b.WriteString(" " + yamlScalar(v) + "\n")
+	}
+}
+
+// yamlScalar formats a scalar, quoting strings that would otherwise read
+// back as something else
+func yamlScalar(v any) string {
+	switch v := v.(type) {
+	case string:
+		if v == "" || v != strings.TrimSpace(v) || strings.ContainsAny(v[:1], "-?:,[]{}#&*!|>'\"%@`") ||
+			strings.Contains(v, ": ") || strings.Contains(v, " #") || strings.HasSuffix(v, ":") ||
+			strings.ContainsAny(v, "\n\t") || v == "~" || strings.EqualFold(v, "null") {
+			return strconv.Quote(v)
+		}
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case nil:
+		return "~"
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,375 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+// oleSignature starts every OLE2 compound file (.doc, .xls, .ppt, .msg)
+var oleSignature = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+// IsLegacyWord reports whether data looks like a binary Word document, for
+// servers that send .doc files as application/octet-stream
+func IsLegacyWord(data []byte) bool {
+	return bytes.HasPrefix(data, oleSignature)
+}
+
+// ExtractTextFromDoc returns the text of a Word 97-2003 (.doc) document, or
+// "" if it can't be read. Encrypted files and pre-97 Word formats, which
+// store their text differently, are not supported.
+func ExtractTextFromDoc(r io.Reader) string {
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		return ""
+	}
+
+	cf, err := openCompoundFile(buf)
+	if err != nil {
+		return ""
+	}
+
+	// The File Information Block at the start of the WordDocument stream
+	// says where the piece table lives
+	wordDoc := cf.stream("WordDocument")
+	if len(wordDoc) < 0x1AA || binary.LittleEndian.Uint16(wordDoc) != 0xA5EC {
+		return ""
+	}
+	flags := binary.LittleEndian.Uint16(wordDoc[0x0A:])
+	if flags&0x0100 != 0 {
+		return "" // encrypted
+	}
+	tableName := "0Table"
+	if flags&0x0200 != 0 {
+		tableName = "1Table"
+	}
+	table := cf.stream(tableName)
+
+	fcClx := int(binary.LittleEndian.Uint32(wordDoc[0x1A2:]))
+	lcbClx := int(binary.LittleEndian.Uint32(wordDoc[0x1A6:]))
+	if lcbClx == 0 || fcClx < 0 || fcClx+lcbClx > len(table) {
+		return ""
+	}
+
+	return cleanWordText(readPieceTable(wordDoc, table[fcClx:fcClx+lcbClx]))
+}
+
+// readPieceTable assembles the document text from the pieces listed in the
+// Clx structure. Each piece is either 8-bit cp1252 or UTF-16 text somewhere
+// in the WordDocument stream.
+func readPieceTable(wordDoc, clx []byte) string {
+	// Property modifiers (Prc) can precede the piece table (Pcdt)
+	for len(clx) > 0 && clx[0] == 0x01 {
+		if len(clx) < 3 {
+			return ""
+		}
+		cb := int(binary.LittleEndian.Uint16(clx[1:]))
+		if 3+cb > len(clx) {
+			return ""
+		}
+		clx = clx[3+cb:]
+	}
+	if len(clx) < 5 || clx[0] != 0x02 {
+		return ""
+	}
+
+	lcb := int(binary.LittleEndian.Uint32(clx[1:]))
+	plc := clx[5:]
+	if lcb > len(plc) {
+		return ""
+	}
+	plc = plc[:lcb]
+
+	// n+1 character positions followed by n 8-byte piece descriptors
+	n := (lcb - 4) / 12
+	var text strings.Builder
+	for i := 0; i < n; i++ {
+		cpStart := binary.LittleEndian.Uint32(plc[4*i:])
+		cpEnd := binary.LittleEndian.Uint32(plc[4*(i+1):])
+		if cpEnd <= cpStart {
+			continue
+		}
+		count := int(cpEnd - cpStart)
+		fc := binary.LittleEndian.Uint32(plc[4*(n+1)+8*i+2:])
+
+		if fc&0x40000000 != 0 {
+			offset := int(fc&^0x40000000) / 2
+			if offset+count > len(wordDoc) {
+				continue
+			}
+			for _, b := range wordDoc[offset : offset+count] {
+				text.WriteRune(cp1252Rune(b))
+			}
+		} else {
+			offset := int(fc)
+			if offset+2*count > len(wordDoc) {
+				continue
+			}
+			units := make([]uint16, count)
+			for j := range units {
+				units[j] = binary.LittleEndian.Uint16(wordDoc[offset+2*j:])
+			}
+			text.WriteString(string(utf16.Decode(units)))
+		}
+	}
+	return text.String()
+}
+
+// cleanWordText turns Word's control characters into plain text. Field
+// instructions are dropped except for HYPERLINK targets, so link searches
+// still find URLs behind linked text.
+func cleanWordText(s string) string {
+	var text strings.Builder
+	var fields []*strings.Builder // instruction text of each open field, nil once past its separator
+
+	for _, r := range s {
+		switch r {
+		case 0x13: // field begin
+			fields = append(fields, &strings.Builder{})
+			continue
+		case 0x14, 0x15: // field separator, field end
+			if len(fields) > 0 {
+				if inst := fields[len(fields)-1]; inst != nil {
+					if target := hyperlinkTarget(inst.String()); target != "" {
+						text.WriteString(" " + target + " ")
+					}
+				}
+				if r == 0x14 {
+					fields[len(fields)-1] = nil
+				} else {
+					fields = fields[:len(fields)-1]
+				}
+			}
+			continue
+		}
+
+		if len(fields) > 0 && fields[len(fields)-1] != nil {
+			fields[len(fields)-1].WriteRune(r)
+			continue
+		}
+
+		switch {
+		case r == '\r' || r == 0x0B || r == 0x0C:
+			text.WriteByte('\n')
+		case r == 0x07:
+			text.WriteByte('\t')
+		case r == 0x1E:
+			text.WriteByte('-')
+		case r < 0x20 && r != '\t' && r != '\n':
+			// Pictures, footnote marks and other placeholders
+		default:
+			text.WriteRune(r)
+		}
+	}
+	return text.String()
+}
+
+// hyperlinkTarget returns the URL from a HYPERLINK field instruction such as
+// HYPERLINK "https://example.com" \o "tooltip", or "" for other fields
+func hyperlinkTarget(inst string) string {
+	inst = strings.TrimSpace(inst)
+	if len(inst) < len("HYPERLINK") || !strings.EqualFold(inst[:len("HYPERLINK")], "HYPERLINK") {
+		return ""
+	}
+	rest := strings.TrimSpace(inst[len("HYPERLINK"):])
+	if strings.HasPrefix(rest, `"`) {
+		if end := strings.Index(rest[1:], `"`); end >= 0 {
+			return rest[1 : end+1]
+		}
+		return strings.Trim(rest, `"`)
+	}
+	if fields := strings.Fields(rest); len(fields) > 0 && !strings.HasPrefix(fields[0], `\`) {
+		return fields[0]
+	}
+	return ""
+}
+
+// cp1252High maps Windows-1252 bytes 0x80-0x9F, where it differs from Latin-1
+var cp1252High = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+func cp1252Rune(b byte) rune {
+	if b >= 0x80 && b < 0xA0 {
+		return cp1252High[b-0x80]
+	}
+	return rune(b)
+}
+
+// compoundFile is a read-only view of an OLE2 compound file: a small FAT
+// filesystem whose streams hold the parts of a legacy Office document
+type compoundFile struct {
+	data       []byte
+	sectorSize int
+	fat        []uint32
+	miniFAT    []uint32
+	miniStream []byte
+	miniCutoff uint32
+	entries    []compoundEntry
+}
+
+type compoundEntry struct {
+	name  string
+	kind  byte // 1 = storage, 2 = stream, 5 = root
+	start uint32
+	size  uint32
+}
+
+const (
+	maxRegularSector = 0xFFFFFFFA // sector numbers at or above this are markers
+	miniSectorSize   = 64
+)
+
+var errNotCompoundFile = errors.New("not an OLE2 compound file")
+
+func openCompoundFile(data []byte) (*compoundFile, error) {
+	if len(data) < 512 || !IsLegacyWord(data) {
+		return nil, errNotCompoundFile
+	}
+	shift := binary.LittleEndian.Uint16(data[0x1E:])
+	if shift != 9 && shift != 12 {
+		return nil, errNotCompoundFile
+	}
+
+	cf := &compoundFile{
+		data:       data,
+		sectorSize: 1 << shift,
+		miniCutoff: binary.LittleEndian.Uint32(data[0x38:]),
+	}
+	dirStart := binary.LittleEndian.Uint32(data[0x30:])
+	miniFATStart := binary.LittleEndian.Uint32(data[0x3C:])
+	difatNext := binary.LittleEndian.Uint32(data[0x44:])
+	difatCount := binary.LittleEndian.Uint32(data[0x48:])
+
+	// The header lists the first 109 FAT sectors; the DIFAT chain lists the rest
+	var fatSectors []uint32
+	for i := 0; i < 109; i++ {
+		if s := binary.LittleEndian.Uint32(data[0x4C+4*i:]); s < maxRegularSector {
+			fatSectors = append(fatSectors, s)
+		}
+	}
+	perSector := cf.sectorSize/4 - 1
+	for i := uint32(0); i < difatCount && difatNext < maxRegularSector; i++ {
+		sector := cf.sector(difatNext)
+		if sector == nil {
+			break
+		}
+		for j := 0; j < perSector; j++ {
+			if s := binary.LittleEndian.Uint32(sector[4*j:]); s < maxRegularSector {
+				fatSectors = append(fatSectors, s)
+			}
+		}
+		difatNext = binary.LittleEndian.Uint32(sector[4*perSector:])
+	}
+
+	for _, s := range fatSectors {
+		sector := cf.sector(s)
+		if sector == nil {
+			return nil, errNotCompoundFile
+		}
+		for j := 0; j < cf.sectorSize; j += 4 {
+			cf.fat = append(cf.fat, binary.LittleEndian.Uint32(sector[j:]))
+		}
+	}
+
+	dir := cf.readChain(dirStart, -1)
+	for off := 0; off+128 <= len(dir); off += 128 {
+		entry := dir[off : off+128]
+		nameLen := int(binary.LittleEndian.Uint16(entry[64:]))
+		if nameLen > 64 {
+			nameLen = 64
+		}
+		units := make([]uint16, 0, nameLen/2)
+		for j := 0; j+1 < nameLen; j += 2 {
+			if u := binary.LittleEndian.Uint16(entry[j:]); u != 0 {
+				units = append(units, u)
+			}
+		}
+		cf.entries = append(cf.entries, compoundEntry{
+			name:  string(utf16.Decode(units)),
+			kind:  entry[66],
+			start: binary.LittleEndian.Uint32(entry[116:]),
+			size:  binary.LittleEndian.Uint32(entry[120:]),
+		})
+	}
+	if len(cf.entries) == 0 || cf.entries[0].kind != 5 {
+		return nil, errNotCompoundFile
+	}
+
+	root := cf.entries[0]
+	cf.miniStream = cf.readChain(root.start, int(root.size))
+	miniFAT := cf.readChain(miniFATStart, -1)
+	for j := 0; j+4 <= len(miniFAT); j += 4 {
+		cf.miniFAT = append(cf.miniFAT, binary.LittleEndian.Uint32(miniFAT[j:]))
+	}
+
+	return cf, nil
+}
+
+// sector returns the contents of sector n, or nil if it's out of range
+func (cf *compoundFile) sector(n uint32) []byte {
+	off := (int(n) + 1) * cf.sectorSize
+	if n >= maxRegularSector || off+cf.sectorSize > len(cf.data) {
+		return nil
+	}
+	return cf.data[off : off+cf.sectorSize]
+}
+
+// readChain follows a FAT chain from start and returns up to size bytes
+// (size < 0 reads the whole chain)
+func (cf *compoundFile) readChain(start uint32, size int) []byte {
+	var out []byte
+	for s, hops := start, 0; s < maxRegularSector && hops <= len(cf.fat); hops++ {
+		sector := cf.sector(s)
+		if sector == nil {
+			break
+		}
+		out = append(out, sector...)
+		if (size >= 0 && len(out) >= size) || int(s) >= len(cf.fat) {
+			break
+		}
+		s = cf.fat[s]
+	}
+	if size >= 0 && len(out) > size {
+		out = out[:size]
+	}
+	return out
+}
+
+// readMiniChain is readChain for streams stored in the 64-byte mini stream
+func (cf *compoundFile) readMiniChain(start uint32, size int) []byte {
+	var out []byte
+	for s, hops := start, 0; s < maxRegularSector && hops <= len(cf.miniFAT); hops++ {
+		off := int(s) * miniSectorSize
+		if off+miniSectorSize > len(cf.miniStream) {
+			break
+		}
+		out = append(out, cf.miniStream[off:off+miniSectorSize]...)
+		if len(out) >= size || int(s) >= len(cf.miniFAT) {
+			break
+		}
+		s = cf.miniFAT[s]
+	}
+	if len(out) > size {
+		out = out[:size]
+	}
+	return out
+}
+
+// stream returns the contents of the named stream, or nil if there isn't one
+func (cf *compoundFile) stream(name string) []byte {
+	for _, e := range cf.entries {
+		if e.kind != 2 || e.name != name {
+			continue
+		}
+		if e.size < cf.miniCutoff {
+			return cf.readMiniChain(e.start, int(e.size))
+		}
+		return cf.readChain(e.start, int(e.size))
+	}
+	return nil
+}
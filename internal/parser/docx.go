@@ -9,6 +9,14 @@ import (
 )
 
 func ContainsLinkInDocx(r io.Reader, target string) bool {
+	return ContainsMatchInDocx(r, func(text string) bool {
+		return strings.Contains(text, target)
+	})
+}
+
+// ContainsMatchInDocx reports whether match returns true for any run of text
+// in the Word document
+func ContainsMatchInDocx(r io.Reader, match func(string) bool) bool {
 	buf, err := io.ReadAll(r)
 	if err != nil {
 		return false
@@ -22,10 +30,33 @@ func ContainsLinkInDocx(r io.Reader, target string) bool {
 
 	for _, para := range doc.Paragraphs() {
 		for _, run := range para.Runs() {
-			if strings.Contains(run.Text(), target) {
+			if match(run.Text()) {
 				return true
 			}
 		}
 	}
 	return false
 }
+
+// ExtractTextFromDocx returns the text of the Word document with one line
+// per paragraph, or "" if it can't be read
+func ExtractTextFromDocx(r io.Reader) string {
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		return ""
+	}
+
+	doc, err := document.Read(bytes.NewReader(buf), int64(len(buf)))
+	if err != nil {
+		return ""
+	}
+
+	var text strings.Builder
+	for _, para := range doc.Paragraphs() {
+		for _, run := range para.Runs() {
+			text.WriteString(run.Text())
+		}
+		text.WriteString("\n")
+	}
+	return text.String()
+}
@@ -1,14 +1,26 @@
 package parser
 
 import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
 func ContainsLinkInPDF(r io.Reader, target string) bool {
+	return ContainsMatchInPDF(r, func(text string) bool {
+		return strings.Contains(text, target)
+	})
+}
+
+// ContainsMatchInPDF reports whether match returns true for the text of any
+// page extracted from the PDF
+func ContainsMatchInPDF(r io.Reader, match func(string) bool) bool {
 	buf, err := io.ReadAll(r)
 	if err != nil {
 		return false
@@ -38,7 +50,7 @@ func ContainsLinkInPDF(r io.Reader, target string) bool {
 		}
 		if strings.HasSuffix(path, ".txt") {
 			data, readErr := os.ReadFile(path)
-			if readErr == nil && strings.Contains(string(data), target) {
+			if readErr == nil && match(string(data)) {
 				found = true
 			}
 		}
@@ -51,3 +63,231 @@ func ContainsLinkInPDF(r io.Reader, target string) bool {
 
 	return found
 }
+
+// ExtractTextFromPDF returns the text of every page in the PDF, in page
+// order and separated by newlines, or "" if it can't be extracted
+func ExtractTextFromPDF(r io.Reader) string {
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		return ""
+	}
+
+	os.MkdirAll("assets/tmp", 0755)
+	tmpPDF := "assets/tmp/tmp.pdf"
+	if err := os.WriteFile(tmpPDF, buf, 0644); err != nil {
+		return ""
+	}
+
+	outDir := "assets/tmp/text"
+	os.MkdirAll(outDir, 0755)
+
+	defer func() {
+		os.RemoveAll(outDir)
+		os.Remove(tmpPDF)
+	}()
+
+	cmd := exec.Command("pdfcpu", "extract", "-mode", "text", tmpPDF, outDir)
+	if err := cmd.Run(); err != nil {
+		return ""
+	}
+
+	var pages []string
+	filepath.Walk(outDir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && strings.HasSuffix(path, ".txt") {
+			pages = append(pages, path)
+		}
+		return nil
+	})
+	sort.Strings(pages)
+
+	var text []string
+	for _, page := range pages {
+		if data, err := os.ReadFile(page); err == nil {
+			text = append(text, string(data))
+		}
+	}
+	return strings.Join(text, "\n")
+}
+
+// maxPDFStreamSize caps how much of each compressed stream is inflated when
+// looking for link annotations
+const maxPDFStreamSize = 16 << 20
+
+// ExtractLinksFromPDF returns the URLs of the PDF's link annotations - the
+// clickable areas that often sit on text like "click here" without the URL
+// being printed. Annotations stored in compressed object streams are found
+// too. Encrypted PDFs yield no links.
+func ExtractLinksFromPDF(r io.Reader) []string {
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var links []string
+	collect := func(data []byte) {
+		for _, link := range pdfURIs(data) {
+			if !seen[link] {
+				seen[link] = true
+				links = append(links, link)
+			}
+		}
+	}
+
+	collect(buf)
+	for _, stream := range pdfFlateStreams(buf) {
+		collect(stream)
+	}
+	return links
+}
+
+// pdfURIs finds every /URI entry with a string value in raw PDF object data
+func pdfURIs(data []byte) []string {
+	var uris []string
+	key := []byte("/URI")
+	for i := 0; ; {
+		idx := bytes.Index(data[i:], key)
+		if idx < 0 {
+			return uris
+		}
+		i += idx + len(key)
+
+		j := i
+		for j < len(data) && isPDFSpace(data[j]) {
+			j++
+		}
+		if j >= len(data) {
+			return uris
+		}
+
+		var value []byte
+		switch {
+		case data[j] == '(':
+			value = pdfLiteralString(data[j:])
+		case data[j] == '<' && (j+1 >= len(data) || data[j+1] != '<'):
+			value = pdfHexString(data[j:])
+		}
+		if uri := strings.TrimSpace(string(value)); uri != "" {
+			uris = append(uris, uri)
+		}
+	}
+}
+
+// pdfLiteralString decodes a (string) starting at data[0], handling escapes
+// and balanced parentheses
+func pdfLiteralString(data []byte) []byte {
+	var out []byte
+	depth := 0
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == '(':
+			depth++
+			if depth == 1 {
+				continue
+			}
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return out
+			}
+		case c == '\\' && i+1 < len(data):
+			i++
+			switch e := data[i]; e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r', '\n':
+				continue // line continuation
+			default:
+				if e >= '0' && e <= '7' {
+					n := 0
+					for k := 0; k < 3 && i < len(data) && data[i] >= '0' && data[i] <= '7'; k++ {
+						n = n*8 + int(data[i]-'0')
+						i++
+					}
+					i--
+					c = byte(n)
+				} else {
+					c = e
+				}
+			}
+		}
+		out = append(out, c)
+	}
+	return nil // unterminated
+}
+
+// pdfHexString decodes a <hex string> starting at data[0]
+func pdfHexString(data []byte) []byte {
+	end := bytes.IndexByte(data, '>')
+	if end < 0 {
+		return nil
+	}
+	var digits []byte
+	for _, c := range data[1:end] {
+		if !isPDFSpace(c) {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	if _, err := hex.Decode(out, digits); err != nil {
+		return nil
+	}
+	return out
+}
+
+// pdfFlateStreams inflates every stream that's zlib-compressed, which is
+// where PDF 1.5+ files keep most of their objects
+func pdfFlateStreams(data []byte) [][]byte {
+	var streams [][]byte
+	for i := 0; ; {
+		idx := bytes.Index(data[i:], []byte("stream"))
+		if idx < 0 {
+			return streams
+		}
+		start := i + idx + len("stream")
+		i = start
+
+		// "endstream" also contains "stream"
+		if bytes.HasSuffix(data[:start-len("stream")], []byte("end")) {
+			continue
+		}
+		if start < len(data) && data[start] == '\r' {
+			start++
+		}
+		if start < len(data) && data[start] == '\n' {
+			start++
+		}
+
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end < 0 {
+			return streams
+		}
+		i = start + end
+
+		zr, err := zlib.NewReader(bytes.NewReader(data[start : start+end]))
+		if err != nil {
+			continue
+		}
+		inflated, _ := io.ReadAll(io.LimitReader(zr, maxPDFStreamSize))
+		zr.Close()
+		if len(inflated) > 0 {
+			streams = append(streams, inflated)
+		}
+	}
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
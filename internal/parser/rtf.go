@@ -0,0 +1,174 @@
+package parser
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// IsRTF reports whether data looks like an RTF document, for servers that
+// send .rtf files as application/octet-stream
+func IsRTF(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte(`{\rtf`))
+}
+
+// rtfSkipDestinations are groups holding fonts, styles, metadata or binary
+// data rather than document text
+var rtfSkipDestinations = map[string]bool{
+	"colortbl": true, "datastore": true, "filetbl": true, "fonttbl": true,
+	"generator": true, "info": true, "latentstyles": true, "listoverridetable": true,
+	"listtable": true, "object": true, "pict": true, "revtbl": true, "rsidtbl": true,
+	"stylesheet": true, "themedata": true, "xmlnstbl": true,
+}
+
+// rtfSymbols maps control words that stand for a character
+var rtfSymbols = map[string]string{
+	"par": "\n", "line": "\n", "sect": "\n", "page": "\n", "row": "\n",
+	"tab": "\t", "cell": "\t",
+	"emdash": "—", "endash": "–", "bullet": "•",
+	"lquote": "‘", "rquote": "’", "ldblquote": "“", "rdblquote": "”",
+}
+
+// rtfState is the part of the parser state that's scoped to a {group}
+type rtfState struct {
+	skip    bool // inside a destination that isn't document text
+	fldinst bool // inside a field instruction
+	uc      int  // fallback characters to skip after \u
+}
+
+// ExtractTextFromRTF returns the plain text of an RTF document, or "" if it
+// isn't RTF. Like Word documents, HYPERLINK field targets are kept so link
+// searches find URLs behind linked text.
+func ExtractTextFromRTF(r io.Reader) string {
+	buf, err := io.ReadAll(r)
+	if err != nil || !IsRTF(buf) {
+		return ""
+	}
+
+	var text, inst strings.Builder
+	state := rtfState{uc: 1}
+	var stack []rtfState
+	pendingSkip := 0 // \u fallback characters still to skip
+
+	emit := func(s string) {
+		switch {
+		case state.fldinst:
+			inst.WriteString(s)
+		case !state.skip:
+			text.WriteString(s)
+		}
+	}
+
+	for i := 0; i < len(buf); {
+		ch := buf[i]
+		switch ch {
+		case '{':
+			stack = append(stack, state)
+			i++
+		case '}':
+			if len(stack) > 0 {
+				closingInst := state.fldinst
+				state = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				if closingInst && !state.fldinst {
+					if target := hyperlinkTarget(inst.String()); target != "" {
+						emit(" " + target + " ")
+					}
+					inst.Reset()
+				}
+			}
+			i++
+		case '\r', '\n':
+			i++
+		case '\\':
+			i++
+			if i >= len(buf) {
+				break
+			}
+			c := buf[i]
+			switch {
+			case c == '\\' || c == '{' || c == '}':
+				emit(string(c))
+				i++
+			case c == '\'':
+				if i+2 < len(buf) {
+					if b, err := strconv.ParseUint(string(buf[i+1:i+3]), 16, 8); err == nil {
+						if pendingSkip > 0 {
+							pendingSkip--
+						} else {
+							emit(string(cp1252Rune(byte(b))))
+						}
+					}
+				}
+				i += 3
+			case c == '*':
+				state.skip = true
+				i++
+			case c == '~':
+				emit(" ")
+				i++
+			case c == '_':
+				emit("-")
+				i++
+			case isASCIILetter(c):
+				start := i
+				for i < len(buf) && isASCIILetter(buf[i]) {
+					i++
+				}
+				word := string(buf[start:i])
+
+				paramStart := i
+				if i < len(buf) && buf[i] == '-' {
+					i++
+				}
+				for i < len(buf) && buf[i] >= '0' && buf[i] <= '9' {
+					i++
+				}
+				param, hasParam := 0, i > paramStart
+				if hasParam {
+					param, _ = strconv.Atoi(string(buf[paramStart:i]))
+				}
+				if i < len(buf) && buf[i] == ' ' {
+					i++ // the delimiter space belongs to the control word
+				}
+
+				switch {
+				case word == "fldinst":
+					state.skip = false
+					state.fldinst = true
+				case rtfSkipDestinations[word]:
+					state.skip = true
+				case word == "u" && hasParam:
+					if param < 0 {
+						param += 65536
+					}
+					emit(string(rune(param)))
+					pendingSkip = state.uc
+				case word == "uc" && hasParam:
+					state.uc = param
+				case word == "bin" && hasParam && param > 0:
+					i += param
+				default:
+					if s, ok := rtfSymbols[word]; ok {
+						emit(s)
+					}
+				}
+			default:
+				i++
+			}
+		default:
+			if pendingSkip > 0 {
+				pendingSkip--
+			} else {
+				emit(string(cp1252Rune(ch)))
+			}
+			i++
+		}
+	}
+	return text.String()
+}
+
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}